@@ -2,13 +2,14 @@ package agreement
 
 import (
 	"crypto/subtle"
-	"errors"
 	"math"
 	"math/big"
 
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // SM2KeyExchange implements SM2 key exchange protocol.
@@ -42,19 +43,20 @@ func (ke *SM2KeyExchange) Init(privParam crypto.CipherParameters) error {
 		param := pwid.GetParameters()
 		baseParam, ok = param.(*SM2KeyExchangePrivateParameters)
 		if !ok {
-			return errors.New("expected SM2KeyExchangePrivateParameters")
+			return exceptions.New(exceptions.ErrInvalidKey, "expected SM2KeyExchangePrivateParameters")
 		}
 		ke.userID = pwid.GetID()
 	} else if p, ok := privParam.(*SM2KeyExchangePrivateParameters); ok {
 		baseParam = p
 		ke.userID = []byte{}
 	} else {
-		return errors.New("invalid parameter type")
+		return exceptions.New(exceptions.ErrInvalidParameter, "invalid parameter type")
 	}
 
+	ke.Destroy()
 	ke.initiator = baseParam.IsInitiator()
-	ke.staticKey = baseParam.GetStaticPrivateKey()
-	ke.ephemeralKey = baseParam.GetEphemeralPrivateKey()
+	ke.staticKey = new(big.Int).Set(baseParam.GetStaticPrivateKey())
+	ke.ephemeralKey = new(big.Int).Set(baseParam.GetEphemeralPrivateKey())
 	ke.curve = baseParam.GetCurve()
 	ke.staticPubPoint = baseParam.GetStaticPublicPoint()
 	ke.ephemeralPubPoint = baseParam.GetEphemeralPublicPoint()
@@ -66,10 +68,23 @@ func (ke *SM2KeyExchange) Init(privParam crypto.CipherParameters) error {
 	return nil
 }
 
+// Destroy clears the static and ephemeral private keys. The exchange must
+// be initialized again before further use.
+func (ke *SM2KeyExchange) Destroy() {
+	util.ClearBigInt(ke.staticKey)
+	util.ClearBigInt(ke.ephemeralKey)
+	ke.staticKey = nil
+	ke.ephemeralKey = nil
+	ke.digest.Reset()
+}
+
 // CalculateKey calculates the shared key.
 func (ke *SM2KeyExchange) CalculateKey(kLen int, pubParam crypto.CipherParameters) ([]byte, error) {
 	if kLen <= 0 {
-		return nil, errors.New("key length must be positive")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "key length must be positive")
+	}
+	if ke.staticKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SM2KeyExchange not initialized")
 	}
 
 	var otherPub *SM2KeyExchangePublicParameters
@@ -79,14 +94,14 @@ func (ke *SM2KeyExchange) CalculateKey(kLen int, pubParam crypto.CipherParameter
 		param := pwid.GetParameters()
 		otherPub, ok = param.(*SM2KeyExchangePublicParameters)
 		if !ok {
-			return nil, errors.New("expected SM2KeyExchangePublicParameters")
+			return nil, exceptions.New(exceptions.ErrInvalidKey, "expected SM2KeyExchangePublicParameters")
 		}
 		otherUserID = pwid.GetID()
 	} else if p, ok := pubParam.(*SM2KeyExchangePublicParameters); ok {
 		otherPub = p
 		otherUserID = []byte{}
 	} else {
-		return nil, errors.New("invalid parameter type")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "invalid parameter type")
 	}
 
 	za := ke.getZ(ke.userID, ke.staticPubPoint)
@@ -114,7 +129,10 @@ func (ke *SM2KeyExchange) CalculateKeyWithConfirmation(
 	pubParam crypto.CipherParameters,
 ) ([][]byte, error) {
 	if kLen <= 0 {
-		return nil, errors.New("key length must be positive")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "key length must be positive")
+	}
+	if ke.staticKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SM2KeyExchange not initialized")
 	}
 
 	var otherPub *SM2KeyExchangePublicParameters
@@ -124,18 +142,18 @@ func (ke *SM2KeyExchange) CalculateKeyWithConfirmation(
 		param := pwid.GetParameters()
 		otherPub, ok = param.(*SM2KeyExchangePublicParameters)
 		if !ok {
-			return nil, errors.New("expected SM2KeyExchangePublicParameters")
+			return nil, exceptions.New(exceptions.ErrInvalidKey, "expected SM2KeyExchangePublicParameters")
 		}
 		otherUserID = pwid.GetID()
 	} else if p, ok := pubParam.(*SM2KeyExchangePublicParameters); ok {
 		otherPub = p
 		otherUserID = []byte{}
 	} else {
-		return nil, errors.New("invalid parameter type")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "invalid parameter type")
 	}
 
 	if ke.initiator && confirmationTag == nil {
-		return nil, errors.New("if initiating, confirmationTag must be set")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "if initiating, confirmationTag must be set")
 	}
 
 	za := ke.getZ(ke.userID, ke.staticPubPoint)
@@ -160,7 +178,7 @@ func (ke *SM2KeyExchange) CalculateKeyWithConfirmation(
 		s1 := ke.s1(u, inner)
 
 		if subtle.ConstantTimeCompare(s1, confirmationTag) != 1 {
-			return nil, errors.New("confirmation tag mismatch")
+			return nil, exceptions.New(exceptions.ErrAuthenticationFailed, "confirmation tag mismatch")
 		}
 
 		return [][]byte{rv, ke.s2(u, inner)}, nil
@@ -187,17 +205,19 @@ func (ke *SM2KeyExchange) calculateU(otherPub *SM2KeyExchangePublicParameters) (
 	x1 := ke.reduce(ke.ephemeralPubPoint.X)
 	x2 := ke.reduce(p2.X)
 
-	tA := new(big.Int).Add(ke.staticKey, new(big.Int).Mul(x1, ke.ephemeralKey))
+	tA := new(big.Int).Mul(x1, ke.ephemeralKey)
+	tA.Add(tA, ke.staticKey)
 	k1 := new(big.Int).Mul(big.NewInt(int64(ke.curve.H)), tA)
 	k1.Mod(k1, ke.curve.N)
 
 	k2 := new(big.Int).Mul(k1, x2)
 	k2.Mod(k2, ke.curve.N)
 
-	// U = k1*P1 + k2*P2
-	u1 := ke.curve.ScalarMult(p1, k1.Bytes())
-	u2 := ke.curve.ScalarMult(p2, k2.Bytes())
-	u := ke.curve.Add(u1, u2)
+	// U = k1*P1 + k2*P2; k1 and k2 derive from private keys
+	u := ec.SumOfTwoMultipliesSecret(p1, k1, p2, k2)
+	util.ClearBigInt(tA)
+	util.ClearBigInt(k1)
+	util.ClearBigInt(k2)
 
 	return u, nil
 }
@@ -232,6 +252,7 @@ func (ke *SM2KeyExchange) kdf(u *ec.Point, za, zb []byte, klen int) []byte {
 			copyLen = len(rv) - off
 		}
 		copy(rv[off:], hash[:copyLen])
+		util.Clear(hash)
 		off += copyLen
 	}
 
@@ -331,3 +352,6 @@ func (ke *SM2KeyExchange) addFieldElement(v interface{}) {
 	}
 	ke.digest.BlockUpdate(bytes, 0, len(bytes))
 }
+
+// Ensure SM2KeyExchange implements Destroyable interface
+var _ crypto.Destroyable = (*SM2KeyExchange)(nil)
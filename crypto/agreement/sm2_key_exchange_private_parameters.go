@@ -1,10 +1,12 @@
 package agreement
 
 import (
-	"errors"
 	"math/big"
 
+	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // SM2KeyExchangePrivateParameters contains private parameters for SM2 key exchange.
@@ -25,13 +27,13 @@ func NewSM2KeyExchangePrivateParameters(
 	curve *ec.Curve,
 ) (*SM2KeyExchangePrivateParameters, error) {
 	if staticPrivateKey == nil {
-		return nil, errors.New("staticPrivateKey cannot be nil")
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "staticPrivateKey cannot be nil")
 	}
 	if ephemeralPrivateKey == nil {
-		return nil, errors.New("ephemeralPrivateKey cannot be nil")
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "ephemeralPrivateKey cannot be nil")
 	}
 	if curve == nil {
-		return nil, errors.New("curve cannot be nil")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "curve cannot be nil")
 	}
 
 	// Calculate public points
@@ -82,3 +84,14 @@ func (p *SM2KeyExchangePrivateParameters) GetCurve() *ec.Curve {
 func (p *SM2KeyExchangePrivateParameters) IsCipherParameters() bool {
 	return true
 }
+
+// Destroy clears the static and ephemeral private keys. These are the
+// *big.Int values passed to NewSM2KeyExchangePrivateParameters, so the
+// caller's copies are cleared too; SM2KeyExchange holds its own copies.
+func (p *SM2KeyExchangePrivateParameters) Destroy() {
+	util.ClearBigInt(p.staticPrivateKey)
+	util.ClearBigInt(p.ephemeralPrivateKey)
+}
+
+// Ensure SM2KeyExchangePrivateParameters implements Destroyable interface
+var _ crypto.Destroyable = (*SM2KeyExchangePrivateParameters)(nil)
@@ -0,0 +1,328 @@
+package agreement
+
+import (
+	"crypto/subtle"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// Wire sizes of the key exchange messages on a 256-bit curve such as
+// sm2p256v1. Ephemeral public keys are sent as uncompressed points and
+// confirmation tags are SM3 hashes:
+//
+//	initiator -> responder: R_A         (65 bytes)
+//	responder -> initiator: R_B || S_B  (65 + 32 bytes)
+//	initiator -> responder: S_A         (32 bytes)
+const (
+	SM2KeyExchangePointSize = 65
+	SM2KeyExchangeTagSize   = 32
+)
+
+// Protocol states shared by the initiator and the responder.
+const (
+	kxStateNew = iota
+	kxStateSent
+	kxStateDone
+)
+
+// SM2KeyExchangeInitiator runs the initiator (user A) side of the SM2 key
+// exchange with key confirmation, GM/T 0003.3 section 6.1.
+//
+// An exchange is used once: it fails if its methods are called out of
+// order, and cannot be continued after an error.
+type SM2KeyExchangeInitiator struct {
+	party sm2KeyExchangeParty
+}
+
+// SM2KeyExchangeResponder runs the responder (user B) side of the SM2 key
+// exchange with key confirmation, GM/T 0003.3 section 6.1.
+//
+// An exchange is used once: it fails if its methods are called out of
+// order, and cannot be continued after an error.
+type SM2KeyExchangeResponder struct {
+	party    sm2KeyExchangeParty
+	key      []byte
+	expected []byte
+}
+
+// sm2KeyExchangeParty holds the configuration and state common to both
+// sides.
+type sm2KeyExchangeParty struct {
+	initiator bool
+	keyBits   int
+	domain    *params.ECDomainParameters
+	staticKey *big.Int
+	userID    []byte
+	peerKey   *ec.Point
+	peerID    []byte
+	random    io.Reader
+	exchange  *SM2KeyExchange
+	ephemeral *ec.Point
+	state     int
+}
+
+// NewSM2KeyExchangeInitiator creates the initiator side of a key exchange
+// agreeing on a key of keyBits bits. own holds the initiator's static
+// *params.ECPrivateKeyParameters and peer the responder's static
+// *params.ECPublicKeyParameters on the same curve; either may be wrapped in
+// crypto.ParametersWithID to set the user's ID, as for SM2KeyExchange.
+func NewSM2KeyExchangeInitiator(keyBits int, own, peer crypto.CipherParameters) (*SM2KeyExchangeInitiator, error) {
+	party, err := newSM2KeyExchangeParty(true, keyBits, own, peer)
+	if err != nil {
+		return nil, err
+	}
+	return &SM2KeyExchangeInitiator{party: *party}, nil
+}
+
+// NewSM2KeyExchangeResponder creates the responder side of a key exchange
+// agreeing on a key of keyBits bits. own and peer are as for
+// NewSM2KeyExchangeInitiator, from the responder's point of view.
+func NewSM2KeyExchangeResponder(keyBits int, own, peer crypto.CipherParameters) (*SM2KeyExchangeResponder, error) {
+	party, err := newSM2KeyExchangeParty(false, keyBits, own, peer)
+	if err != nil {
+		return nil, err
+	}
+	return &SM2KeyExchangeResponder{party: *party}, nil
+}
+
+// SetRandom sets the random source for the ephemeral key. If not set,
+// crypto/rand.Reader is used.
+func (a *SM2KeyExchangeInitiator) SetRandom(random io.Reader) {
+	a.party.random = random
+}
+
+// Start generates the ephemeral key and returns the first message R_A.
+func (a *SM2KeyExchangeInitiator) Start() ([]byte, error) {
+	p := &a.party
+	if p.state != kxStateNew {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "key exchange already started")
+	}
+	p.state = kxStateDone
+
+	if err := p.generate(); err != nil {
+		return nil, err
+	}
+	p.state = kxStateSent
+	return p.ephemeral.GetEncoded(false), nil
+}
+
+// Finish processes the responder's message R_B || S_B. It checks S_B and
+// returns the shared key and the final message S_A for the responder.
+func (a *SM2KeyExchangeInitiator) Finish(response []byte) (key, confirmation []byte, err error) {
+	p := &a.party
+	if p.state != kxStateSent {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidState, "key exchange not started or already finished")
+	}
+	p.state = kxStateDone
+
+	pointSize := p.pointSize()
+	if len(response) != pointSize+SM2KeyExchangeTagSize {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid key exchange response length")
+	}
+	rb, err := p.decodeEphemeralKey(response[:pointSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	peer, err := p.peerParameters(rb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// CalculateKeyWithConfirmation compares S_B in constant time
+	result, err := p.exchange.CalculateKeyWithConfirmation(p.keyBits, response[pointSize:], peer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result[0], result[1], nil
+}
+
+// SetRandom sets the random source for the ephemeral key. If not set,
+// crypto/rand.Reader is used.
+func (b *SM2KeyExchangeResponder) SetRandom(random io.Reader) {
+	b.party.random = random
+}
+
+// Respond processes the initiator's message R_A, generates the ephemeral
+// key and returns the message R_B || S_B.
+func (b *SM2KeyExchangeResponder) Respond(request []byte) ([]byte, error) {
+	p := &b.party
+	if p.state != kxStateNew {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "key exchange already started")
+	}
+	p.state = kxStateDone
+
+	ra, err := p.decodeEphemeralKey(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.generate(); err != nil {
+		return nil, err
+	}
+	peer, err := p.peerParameters(ra)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.exchange.CalculateKeyWithConfirmation(p.keyBits, nil, peer)
+	if err != nil {
+		return nil, err
+	}
+	b.key, b.expected = result[0], result[2]
+
+	p.state = kxStateSent
+	return append(p.ephemeral.GetEncoded(false), result[1]...), nil
+}
+
+// Finish checks the initiator's confirmation S_A in constant time and
+// returns the shared key.
+func (b *SM2KeyExchangeResponder) Finish(confirmation []byte) ([]byte, error) {
+	p := &b.party
+	if p.state != kxStateSent {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "key exchange not started or already finished")
+	}
+	p.state = kxStateDone
+
+	key, expected := b.key, b.expected
+	b.key, b.expected = nil, nil
+	if subtle.ConstantTimeCompare(confirmation, expected) != 1 {
+		return nil, exceptions.New(exceptions.ErrAuthenticationFailed, "confirmation tag mismatch")
+	}
+	return key, nil
+}
+
+// Destroy clears the static private key and the ephemeral key. The
+// exchange cannot be used afterwards.
+func (a *SM2KeyExchangeInitiator) Destroy() {
+	a.party.destroy()
+}
+
+// Destroy clears the static private key, the ephemeral key and the agreed
+// key if it has not been returned yet. The exchange cannot be used
+// afterwards.
+func (b *SM2KeyExchangeResponder) Destroy() {
+	b.party.destroy()
+	util.Clear(b.key)
+	util.Clear(b.expected)
+	b.key, b.expected = nil, nil
+}
+
+// newSM2KeyExchangeParty validates the static keys of both users.
+func newSM2KeyExchangeParty(initiator bool, keyBits int, own, peer crypto.CipherParameters) (*sm2KeyExchangeParty, error) {
+	if keyBits <= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "key length must be positive")
+	}
+	p := &sm2KeyExchangeParty{initiator: initiator, keyBits: keyBits}
+
+	if withID, ok := own.(*crypto.ParametersWithID); ok {
+		p.userID = withID.GetID()
+		own = withID.GetParameters()
+	}
+	priv, ok := own.(*params.ECPrivateKeyParameters)
+	if !ok || priv.GetParameters() == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "expected SM2 static private key")
+	}
+	p.domain = priv.GetParameters()
+	d := priv.GetD()
+	if d == nil || d.Sign() <= 0 || d.Cmp(p.domain.GetN()) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid static private key")
+	}
+	p.staticKey = new(big.Int).Set(d)
+
+	if withID, ok := peer.(*crypto.ParametersWithID); ok {
+		p.peerID = withID.GetID()
+		peer = withID.GetParameters()
+	}
+	pub, ok := peer.(*params.ECPublicKeyParameters)
+	if !ok || !p.domain.Equals(pub.GetParameters()) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "expected SM2 static public key of the peer on the same curve")
+	}
+	if pub.GetQ() == nil || !p.validPoint(pub.GetQ()) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid static public key of the peer")
+	}
+	p.peerKey = pub.GetQ()
+
+	return p, nil
+}
+
+// generate creates the ephemeral key and initializes the underlying
+// SM2KeyExchange with it.
+func (p *sm2KeyExchangeParty) generate() error {
+	calculator := signers.NewRandomDSAKCalculator()
+	calculator.Init(p.domain.GetN(), p.random)
+	r, err := calculator.NextK()
+	if err != nil {
+		return err
+	}
+	private, err := NewSM2KeyExchangePrivateParameters(p.initiator, p.staticKey, r, p.domain.GetCurve())
+	if err != nil {
+		util.ClearBigInt(r)
+		return err
+	}
+
+	// The exchange keeps its own copies of the keys
+	p.exchange = NewSM2KeyExchange(nil)
+	err = p.exchange.Init(crypto.NewParametersWithID(private, p.userID))
+	util.ClearBigInt(r)
+	if err != nil {
+		return err
+	}
+	p.ephemeral = private.GetEphemeralPublicPoint()
+	return nil
+}
+
+// destroy clears the private keys and ends the exchange.
+func (p *sm2KeyExchangeParty) destroy() {
+	util.ClearBigInt(p.staticKey)
+	if p.exchange != nil {
+		p.exchange.Destroy()
+	}
+	p.state = kxStateDone
+}
+
+// peerParameters combines the peer's static key with its ephemeral key.
+func (p *sm2KeyExchangeParty) peerParameters(ephemeral *ec.Point) (crypto.CipherParameters, error) {
+	public, err := NewSM2KeyExchangePublicParameters(p.peerKey, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewParametersWithID(public, p.peerID), nil
+}
+
+// pointSize returns the length of an uncompressed point on the curve.
+func (p *sm2KeyExchangeParty) pointSize() int {
+	return 1 + 2*((p.domain.GetCurve().GetFieldSize()+7)/8)
+}
+
+// validPoint reports whether q is a point of order n on the curve.
+func (p *sm2KeyExchangeParty) validPoint(q *ec.Point) bool {
+	if q.IsInfinity() || !q.GetCurve().Equals(p.domain.GetCurve()) || !q.IsValid() {
+		return false
+	}
+	return p.domain.GetH().Cmp(big.NewInt(1)) == 0 || q.Multiply(p.domain.GetN()).IsInfinity()
+}
+
+// decodeEphemeralKey decodes an uncompressed ephemeral public key and
+// checks that it is a valid point of the curve other than infinity.
+func (p *sm2KeyExchangeParty) decodeEphemeralKey(data []byte) (*ec.Point, error) {
+	if len(data) != p.pointSize() || data[0] != 0x04 {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid ephemeral public key encoding")
+	}
+	point, err := p.domain.GetCurve().DecodePoint(data)
+	if err != nil {
+		return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "invalid ephemeral public key: %w", err)
+	}
+	return point, nil
+}
+
+// Ensure the exchange types implement Destroyable interface
+var (
+	_ crypto.Destroyable = (*SM2KeyExchangeInitiator)(nil)
+	_ crypto.Destroyable = (*SM2KeyExchangeResponder)(nil)
+)
@@ -0,0 +1,301 @@
+package agreement
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// newExchange creates both sides of a key exchange between static keys dA
+// and dB with the given user IDs.
+func newExchange(t *testing.T, dA, dB *big.Int, idA, idB []byte) (*SM2KeyExchangeInitiator, *SM2KeyExchangeResponder) {
+	t.Helper()
+	return newExchangeOn(t, sm2.GetECDomainParameters(), dA, dB, idA, idB)
+}
+
+// newExchangeOn is newExchange on the curve of domain.
+func newExchangeOn(t *testing.T, domain *params.ECDomainParameters, dA, dB *big.Int, idA, idB []byte) (*SM2KeyExchangeInitiator, *SM2KeyExchangeResponder) {
+	t.Helper()
+	curve := domain.GetCurve()
+	privA := crypto.NewParametersWithID(params.NewECPrivateKeyParameters(dA, domain), idA)
+	privB := crypto.NewParametersWithID(params.NewECPrivateKeyParameters(dB, domain), idB)
+	pubA := crypto.NewParametersWithID(params.NewECPublicKeyParameters(curve.ScalarBaseMult(dA.Bytes()), domain), idA)
+	pubB := crypto.NewParametersWithID(params.NewECPublicKeyParameters(curve.ScalarBaseMult(dB.Bytes()), domain), idB)
+
+	initiator, err := NewSM2KeyExchangeInitiator(128, privA, pubB)
+	if err != nil {
+		t.Fatalf("NewSM2KeyExchangeInitiator failed: %v", err)
+	}
+	responder, err := NewSM2KeyExchangeResponder(128, privB, pubA)
+	if err != nil {
+		t.Fatalf("NewSM2KeyExchangeResponder failed: %v", err)
+	}
+	return initiator, responder
+}
+
+func TestSM2KeyExchangeProtocol(t *testing.T) {
+	dA, _ := new(big.Int).SetString("6FCBA2EF9AE0AB902BC3BDE3FF915D44BA4CC78F88E2F8E7F8996D3B8CCEEDEE", 16)
+	rA, _ := new(big.Int).SetString("83A2C9C8B96E5AF70BD480B472409A9A327257F1EBB73F5B073354B248668563", 16)
+	dB, _ := new(big.Int).SetString("5E35D7D3F3C54DBAC72E61819E730B019A84208CA3A35E4C2E353DFCCB2A3B53", 16)
+	rB, _ := new(big.Int).SetString("33FE21940342161C55619C4A0C060293D543C80AF19748CE176D83477DE71C80", 16)
+	idA := []byte("ALICE123@YAHOO.COM")
+	idB := []byte("BILL456@YAHOO.COM")
+
+	t.Run("MatchesSM2KeyExchange", func(t *testing.T) {
+		initiator, responder := newExchange(t, dA, dB, idA, idB)
+		initiator.SetRandom(bytes.NewReader(rA.FillBytes(make([]byte, 32))))
+		responder.SetRandom(bytes.NewReader(rB.FillBytes(make([]byte, 32))))
+
+		ra, err := initiator.Start()
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		response, err := responder.Respond(ra)
+		if err != nil {
+			t.Fatalf("Respond failed: %v", err)
+		}
+		keyA, confirmation, err := initiator.Finish(response)
+		if err != nil {
+			t.Fatalf("Initiator Finish failed: %v", err)
+		}
+		keyB, err := responder.Finish(confirmation)
+		if err != nil {
+			t.Fatalf("Responder Finish failed: %v", err)
+		}
+		if !bytes.Equal(keyA, keyB) || len(keyA) != 16 {
+			t.Fatalf("Keys do not match:\nA: %x\nB: %x", keyA, keyB)
+		}
+
+		// The same ephemeral keys through the parameter-based API
+		curve := sm2.GetCurve()
+		privA, _ := NewSM2KeyExchangePrivateParameters(true, dA, rA, curve)
+		privB, _ := NewSM2KeyExchangePrivateParameters(false, dB, rB, curve)
+		pubB, _ := NewSM2KeyExchangePublicParameters(privB.GetStaticPublicPoint(), privB.GetEphemeralPublicPoint())
+		ke := NewSM2KeyExchange(nil)
+		_ = ke.Init(crypto.NewParametersWithID(privA, idA))
+		expected, err := ke.CalculateKey(128, crypto.NewParametersWithID(pubB, idB))
+		if err != nil {
+			t.Fatalf("CalculateKey failed: %v", err)
+		}
+		if !bytes.Equal(keyA, expected) {
+			t.Errorf("Key differs from SM2KeyExchange:\ngot:  %x\nwant: %x", keyA, expected)
+		}
+		if !bytes.Equal(ra, privA.GetEphemeralPublicPoint().GetEncoded(false)) {
+			t.Error("R_A is not the uncompressed ephemeral public key")
+		}
+	})
+
+	t.Run("RandomEphemeralKeys", func(t *testing.T) {
+		initiator, responder := newExchange(t, dA, dB, nil, nil)
+		ra, _ := initiator.Start()
+		response, err := responder.Respond(ra)
+		if err != nil {
+			t.Fatalf("Respond failed: %v", err)
+		}
+		if len(ra) != SM2KeyExchangePointSize || len(response) != SM2KeyExchangePointSize+SM2KeyExchangeTagSize {
+			t.Errorf("Unexpected message sizes %d and %d", len(ra), len(response))
+		}
+		keyA, confirmation, err := initiator.Finish(response)
+		if err != nil {
+			t.Fatalf("Initiator Finish failed: %v", err)
+		}
+		if len(confirmation) != SM2KeyExchangeTagSize {
+			t.Errorf("Unexpected confirmation size %d", len(confirmation))
+		}
+		keyB, err := responder.Finish(confirmation)
+		if err != nil || !bytes.Equal(keyA, keyB) {
+			t.Errorf("Keys do not match: %v", err)
+		}
+	})
+
+	t.Run("TamperedTags", func(t *testing.T) {
+		initiator, responder := newExchange(t, dA, dB, idA, idB)
+		ra, _ := initiator.Start()
+		response, _ := responder.Respond(ra)
+		response[len(response)-1] ^= 0x01
+		if _, _, err := initiator.Finish(response); err == nil {
+			t.Error("Expected error for tampered S_B")
+		}
+
+		initiator, responder = newExchange(t, dA, dB, idA, idB)
+		ra, _ = initiator.Start()
+		response, _ = responder.Respond(ra)
+		_, confirmation, _ := initiator.Finish(response)
+		confirmation[0] ^= 0x01
+		if _, err := responder.Finish(confirmation); err == nil {
+			t.Error("Expected error for tampered S_A")
+		}
+	})
+
+	t.Run("MismatchedIdentities", func(t *testing.T) {
+		initiator, _ := newExchange(t, dA, dB, idA, idB)
+		_, responder := newExchange(t, dA, dB, idA, []byte("MALLORY@YAHOO.COM"))
+		ra, _ := initiator.Start()
+		response, _ := responder.Respond(ra)
+		if _, _, err := initiator.Finish(response); err == nil {
+			t.Error("Expected error for a responder using another identity")
+		}
+	})
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		initiator, responder := newExchange(t, dA, dB, idA, idB)
+		if _, _, err := initiator.Finish(make([]byte, 97)); err == nil {
+			t.Error("Expected error finishing before Start")
+		}
+		if _, err := responder.Finish(make([]byte, 32)); err == nil {
+			t.Error("Expected error finishing before Respond")
+		}
+
+		ra, _ := initiator.Start()
+		if _, err := initiator.Start(); err == nil {
+			t.Error("Expected error starting twice")
+		}
+		response, _ := responder.Respond(ra)
+		if _, err := responder.Respond(ra); err == nil {
+			t.Error("Expected error responding twice")
+		}
+		_, confirmation, _ := initiator.Finish(response)
+		if _, _, err := initiator.Finish(response); err == nil {
+			t.Error("Expected error finishing twice")
+		}
+		if _, err := responder.Finish(confirmation); err != nil {
+			t.Errorf("Responder Finish failed: %v", err)
+		}
+		if _, err := responder.Finish(confirmation); err == nil {
+			t.Error("Expected error finishing twice")
+		}
+	})
+
+	t.Run("InvalidMessages", func(t *testing.T) {
+		initiator, responder := newExchange(t, dA, dB, idA, idB)
+		ra, _ := initiator.Start()
+
+		corrupted := append([]byte{}, ra...)
+		corrupted[len(corrupted)-1] ^= 0x01
+		if _, err := responder.Respond(corrupted); err == nil {
+			t.Error("Expected error for off-curve R_A")
+		}
+		_, responder = newExchange(t, dA, dB, idA, idB)
+		point, err := sm2.GetCurve().DecodePoint(ra)
+		if err != nil {
+			t.Fatalf("DecodePoint failed: %v", err)
+		}
+		compressed := point.GetEncoded(true)
+		if _, err := responder.Respond(compressed); err == nil {
+			t.Error("Expected error for compressed R_A")
+		}
+		if _, _, err := initiator.Finish(ra); err == nil {
+			t.Error("Expected error for short response")
+		}
+		if _, _, err := initiator.Finish(append(ra, make([]byte, SM2KeyExchangeTagSize)...)); err == nil {
+			t.Error("Expected error continuing after a failed step")
+		}
+	})
+
+	t.Run("TestCurveExample", func(t *testing.T) {
+		// GM/T 0003.3-2012 Appendix A.2 on the 256-bit Fp example curve
+		domain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		initiator, responder := newExchangeOn(t, domain, dA, dB, idA, idB)
+		initiator.SetRandom(bytes.NewReader(rA.FillBytes(make([]byte, 32))))
+		responder.SetRandom(bytes.NewReader(rB.FillBytes(make([]byte, 32))))
+
+		ra, err := initiator.Start()
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		response, err := responder.Respond(ra)
+		if err != nil {
+			t.Fatalf("Respond failed: %v", err)
+		}
+		keyA, confirmation, err := initiator.Finish(response)
+		if err != nil {
+			t.Fatalf("Initiator Finish failed: %v", err)
+		}
+		keyB, err := responder.Finish(confirmation)
+		if err != nil {
+			t.Fatalf("Responder Finish failed: %v", err)
+		}
+
+		expected := "55B0AC62A6B927BA23703832C853DED4"
+		if fmt.Sprintf("%X", keyA) != expected || !bytes.Equal(keyA, keyB) {
+			t.Errorf("Shared key mismatch:\ngot:  %X / %X\nwant: %s", keyA, keyB, expected)
+		}
+		if sb := fmt.Sprintf("%X", response[SM2KeyExchangePointSize:]); sb != "284C8F198F141B502E81250F1581C7E9EEB4CA6990F9E02DF388B45471F5BC5C" {
+			t.Errorf("S_B mismatch: %s", sb)
+		}
+		if sa := fmt.Sprintf("%X", confirmation); sa != "23444DAF8ED7534366CB901C84B3BDBB63504F4065C1116C91A4C00697E6CF7A" {
+			t.Errorf("S_A mismatch: %s", sa)
+		}
+
+		// A peer key on sm2p256v1 does not match the test curve
+		privA := params.NewECPrivateKeyParameters(dA, domain)
+		pubB := params.NewECPublicKeyParameters(sm2.GetCurve().ScalarBaseMult(dB.Bytes()), sm2.GetECDomainParameters())
+		if _, err := NewSM2KeyExchangeInitiator(128, privA, pubB); err == nil {
+			t.Error("Expected error for a peer key on another curve")
+		}
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		domain := sm2.GetECDomainParameters()
+		priv := params.NewECPrivateKeyParameters(dA, domain)
+		pub := params.NewECPublicKeyParameters(sm2.GetG(), domain)
+		if _, err := NewSM2KeyExchangeInitiator(0, priv, pub); err == nil {
+			t.Error("Expected error for zero key length")
+		}
+		if _, err := NewSM2KeyExchangeInitiator(128, pub, pub); err == nil {
+			t.Error("Expected error for public key as own key")
+		}
+		if _, err := NewSM2KeyExchangeResponder(128, priv, priv); err == nil {
+			t.Error("Expected error for private key as peer key")
+		}
+		zero := params.NewECPrivateKeyParameters(new(big.Int), domain)
+		if _, err := NewSM2KeyExchangeResponder(128, zero, pub); err == nil {
+			t.Error("Expected error for zero private key")
+		}
+	})
+}
+
+func TestSM2KeyExchangeDestroy(t *testing.T) {
+	dA, _ := new(big.Int).SetString("6FCBA2EF9AE0AB902BC3BDE3FF915D44BA4CC78F88E2F8E7F8996D3B8CCEEDEE", 16)
+	dB, _ := new(big.Int).SetString("5E35D7D3F3C54DBAC72E61819E730B019A84208CA3A35E4C2E353DFCCB2A3B53", 16)
+	wantA, wantB := new(big.Int).Set(dA), new(big.Int).Set(dB)
+	initiator, responder := newExchange(t, dA, dB, nil, nil)
+
+	ra, err := initiator.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	response, err := responder.Respond(ra)
+	if err != nil {
+		t.Fatalf("Respond failed: %v", err)
+	}
+	staticKey := initiator.party.staticKey
+	ephemeralKey := initiator.party.exchange.ephemeralKey
+	key := responder.key
+
+	initiator.Destroy()
+	responder.Destroy()
+	if staticKey.Sign() != 0 || ephemeralKey.Sign() != 0 {
+		t.Error("Initiator keys not cleared")
+	}
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Error("Responder's agreed key not cleared")
+	}
+	if dA.Cmp(wantA) != 0 || dB.Cmp(wantB) != 0 {
+		t.Error("Destroy cleared the caller's static keys")
+	}
+	if _, _, err := initiator.Finish(response); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("Finish after Destroy = %v, want ErrInvalidState", err)
+	}
+}
@@ -1,9 +1,8 @@
 package agreement
 
 import (
-	"errors"
-
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // SM2KeyExchangePublicParameters contains public parameters for SM2 key exchange.
@@ -18,10 +17,10 @@ func NewSM2KeyExchangePublicParameters(
 	ephemeralPublicKey *ec.Point,
 ) (*SM2KeyExchangePublicParameters, error) {
 	if staticPublicKey == nil {
-		return nil, errors.New("staticPublicKey cannot be nil")
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "staticPublicKey cannot be nil")
 	}
 	if ephemeralPublicKey == nil {
-		return nil, errors.New("ephemeralPublicKey cannot be nil")
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "ephemeralPublicKey cannot be nil")
 	}
 
 	return &SM2KeyExchangePublicParameters{
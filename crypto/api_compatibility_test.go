@@ -6,6 +6,7 @@ import (
 	"testing"
 	
 	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
@@ -181,14 +182,14 @@ func TestSM2SignerBasicOperation(t *testing.T) {
 	privateKey, publicKey := generateTestKeyPair()
 	
 	// Initialize for signing
-	err := signer.Init(true, publicKey, privateKey)
+	err := signer.Init(true, params.NewECPrivateKeyParameters(privateKey, sm2.GetECDomainParameters()))
 	if err != nil {
 		t.Fatalf("Failed to initialize signer: %v", err)
 	}
 	
 	// Update with message
 	message := []byte("Test message")
-	signer.Update(message)
+	signer.BlockUpdate(message, 0, len(message))
 	
 	// Generate signature
 	signature, err := signer.GenerateSignature()
@@ -202,12 +203,12 @@ func TestSM2SignerBasicOperation(t *testing.T) {
 	
 	// Reset and verify
 	signer.Reset()
-	err = signer.Init(false, publicKey, nil)
+	err = signer.Init(false, params.NewECPublicKeyParameters(publicKey, sm2.GetECDomainParameters()))
 	if err != nil {
 		t.Fatalf("Failed to initialize for verification: %v", err)
 	}
 	
-	signer.Update(message)
+	signer.BlockUpdate(message, 0, len(message))
 	valid, err := signer.VerifySignature(signature)
 	if err != nil {
 		t.Fatalf("Verification failed: %v", err)
@@ -265,8 +266,9 @@ func TestAPIConsistencyIntegration(t *testing.T) {
 		
 		// Generate signature and verify
 		priv, pub := generateTestKeyPair()
-		signer.Init(true, pub, priv)
-		signer.Update([]byte("message"))
+		message := []byte("message")
+		signer.Init(true, params.NewECPrivateKeyParameters(priv, sm2.GetECDomainParameters()))
+		signer.BlockUpdate(message, 0, len(message))
 		sig, err := signer.GenerateSignature()
 		if err != nil {
 			t.Fatal(err)
@@ -274,8 +276,8 @@ func TestAPIConsistencyIntegration(t *testing.T) {
 		
 		// Reset for verification
 		signer.Reset()
-		signer.Init(false, pub, nil)
-		signer.Update([]byte("message"))
+		signer.Init(false, params.NewECPublicKeyParameters(pub, sm2.GetECDomainParameters()))
+		signer.BlockUpdate(message, 0, len(message))
 		valid, err := signer.VerifySignature(sig)
 		if err != nil {
 			t.Fatal(err)
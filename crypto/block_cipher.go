@@ -0,0 +1,43 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InitBlockCipher initializes c, returning an error for invalid parameters.
+// It calls InitChecked if c is a CheckedBlockCipher; for other ciphers a
+// panic in Init is recovered and returned as the error.
+func InitBlockCipher(c BlockCipher, forEncryption bool, params CipherParameters) (err error) {
+	if checked, ok := c.(CheckedBlockCipher); ok {
+		return checked.InitChecked(forEncryption, params)
+	}
+	defer recoverCipherError(&err)
+	c.Init(forEncryption, params)
+	return nil
+}
+
+// ProcessBlock processes a single block with c, returning an error if c is
+// not initialized or a buffer is too short. It calls ProcessBlockChecked if
+// c is a CheckedBlockCipher; for other ciphers a panic in ProcessBlock is
+// recovered and returned as the error.
+func ProcessBlock(c BlockCipher, in []byte, inOff int, out []byte, outOff int) (n int, err error) {
+	if checked, ok := c.(CheckedBlockCipher); ok {
+		return checked.ProcessBlockChecked(in, inOff, out, outOff)
+	}
+	defer recoverCipherError(&err)
+	return c.ProcessBlock(in, inOff, out, outOff), nil
+}
+
+// recoverCipherError stores a recovered panic value in err.
+func recoverCipherError(err *error) {
+	switch r := recover().(type) {
+	case nil:
+	case error:
+		*err = r
+	case string:
+		*err = errors.New(r)
+	default:
+		*err = fmt.Errorf("%v", r)
+	}
+}
@@ -0,0 +1,41 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+// panickingCipher is a BlockCipher that signals errors by panicking.
+type panickingCipher struct {
+	value interface{}
+}
+
+func (c *panickingCipher) Init(forEncryption bool, params CipherParameters) { panic(c.value) }
+func (c *panickingCipher) GetAlgorithmName() string                         { return "Panicking" }
+func (c *panickingCipher) GetBlockSize() int                                { return 16 }
+func (c *panickingCipher) Reset()                                           {}
+
+func (c *panickingCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
+	if c.value == nil {
+		return 16
+	}
+	panic(c.value)
+}
+
+func TestBlockCipherHelpers(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	block := make([]byte, 16)
+
+	if err := InitBlockCipher(&panickingCipher{value: sentinel}, true, nil); err != sentinel {
+		t.Errorf("InitBlockCipher error = %v, want the panic value", err)
+	}
+	if err := InitBlockCipher(&panickingCipher{value: "bad key"}, true, nil); err == nil || err.Error() != "bad key" {
+		t.Errorf("InitBlockCipher error = %v", err)
+	}
+	if _, err := ProcessBlock(&panickingCipher{value: 42}, block, 0, block, 0); err == nil || err.Error() != "42" {
+		t.Errorf("ProcessBlock error = %v", err)
+	}
+	if n, err := ProcessBlock(&panickingCipher{}, block, 0, block, 0); err != nil || n != 16 {
+		t.Errorf("ProcessBlock = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,9 @@
+package crypto
+
+// Destroy calls x.Destroy if x is Destroyable, so that an object can
+// destroy the ciphers, digests and parameters it was built from.
+func Destroy(x interface{}) {
+	if d, ok := x.(Destroyable); ok {
+		d.Destroy()
+	}
+}
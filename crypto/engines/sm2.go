@@ -0,0 +1,158 @@
+package engines
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// SM2Engine adapts sm2.SM2Engine to crypto.AsymmetricBlockCipher. The
+// curve is taken from the domain parameters of the key, the digest used for
+// the KDF and C3 is configurable, and ciphertexts are laid out as C1C2C3,
+// C1C3C2 or DER (sm2.Mode_*), with C1 optionally compressed.
+// Reference: GM/T 0003-2012 Part 4: Public Key Encryption
+// Based on: org.bouncycastle.crypto.engines.SM2Engine
+type SM2Engine struct {
+	engine           *sm2.SM2Engine
+	digest           crypto.Digest
+	mode             int
+	pointCompression bool
+
+	forEncryption bool
+	domain        *params.ECDomainParameters
+}
+
+// NewSM2Engine creates an SM2 engine using SM3 and the C1C2C3 layout.
+func NewSM2Engine() *SM2Engine {
+	return NewSM2EngineWithDigest(nil, sm2.Mode_C1C2C3)
+}
+
+// NewSM2EngineWithDigest creates an SM2 engine using the given digest, or
+// SM3 if digest is nil, and ciphertext layout.
+func NewSM2EngineWithDigest(digest crypto.Digest, mode int) *SM2Engine {
+	if digest == nil {
+		digest = digests.NewSM3Digest()
+	}
+	engine := sm2.NewSM2Engine()
+	engine.SetDigest(digest)
+	engine.SetMode(mode)
+	return &SM2Engine{
+		engine: engine,
+		digest: digest,
+		mode:   mode,
+	}
+}
+
+// SetPointCompression sets whether C1 is written in compressed form.
+// Compressed, uncompressed and hybrid C1 are all accepted for decryption.
+func (e *SM2Engine) SetPointCompression(compressed bool) {
+	e.pointCompression = compressed
+	e.engine.SetPointCompression(compressed)
+}
+
+// Init initializes the engine. For encryption, parameters must be an
+// *params.ECPublicKeyParameters, optionally wrapped in a
+// *params.ParametersWithRandom supplying the source of k (crypto/rand.Reader
+// otherwise); for decryption, an *params.ECPrivateKeyParameters.
+func (e *SM2Engine) Init(forEncryption bool, parameters crypto.CipherParameters) error {
+	if e.mode != sm2.Mode_C1C2C3 && e.mode != sm2.Mode_C1C3C2 && e.mode != sm2.Mode_DER {
+		return exceptions.New(exceptions.ErrInvalidParameter, "unknown ciphertext mode")
+	}
+
+	var domain *params.ECDomainParameters
+	var err error
+	if forEncryption {
+		random := io.Reader(rand.Reader)
+		if rParam, ok := parameters.(*params.ParametersWithRandom); ok {
+			random = rParam.GetRandom()
+			parameters = rParam.GetParameters()
+		}
+		pubParam, ok := parameters.(*params.ECPublicKeyParameters)
+		if !ok {
+			return exceptions.New(exceptions.ErrInvalidKey, "SM2 encryption requires ECPublicKeyParameters")
+		}
+		domain = pubParam.GetParameters()
+		e.engine.SetRandom(random)
+		err = e.engine.InitWithDomain(true, domain, pubParam.GetQ(), nil)
+	} else {
+		privParam, ok := parameters.(*params.ECPrivateKeyParameters)
+		if !ok {
+			return exceptions.New(exceptions.ErrInvalidKey, "SM2 decryption requires ECPrivateKeyParameters")
+		}
+		domain = privParam.GetParameters()
+		err = e.engine.InitWithDomain(false, domain, nil, privParam.GetD())
+	}
+	if err != nil {
+		e.Destroy()
+		return err
+	}
+
+	e.forEncryption = forEncryption
+	e.domain = domain
+	return nil
+}
+
+// GetInputBlockSize returns 0: SM2 takes a message of any length as a
+// single block, so there is no fixed input size. See GetOutputSize.
+func (e *SM2Engine) GetInputBlockSize() int {
+	return 0
+}
+
+// GetOutputBlockSize returns 0, as the output size depends on the input
+// length. See GetOutputSize.
+func (e *SM2Engine) GetOutputBlockSize() int {
+	return 0
+}
+
+// GetOutputSize returns the ciphertext length for a plaintext of inputLen
+// bytes. For DER the result is an upper bound.
+func (e *SM2Engine) GetOutputSize(inputLen int) int {
+	curveLength := 32
+	if e.domain != nil {
+		curveLength = (e.domain.GetCurve().GetFieldSize() + 7) / 8
+	}
+	c1Len := 1 + 2*curveLength
+	if e.pointCompression {
+		c1Len = 1 + curveLength
+	}
+	size := c1Len + inputLen + e.digest.GetDigestSize()
+	if e.mode == sm2.Mode_DER {
+		// SEQUENCE, two INTEGERs with a possible sign byte and two OCTET
+		// STRINGs, each with a tag and up to five length bytes
+		size = 2*(curveLength+7) + (inputLen + 6) + (e.digest.GetDigestSize() + 6) + 6
+	}
+	return size
+}
+
+// ProcessBlock encrypts or decrypts inLen bytes of in starting at inOff.
+func (e *SM2Engine) ProcessBlock(in []byte, inOff int, inLen int) ([]byte, error) {
+	if e.domain == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SM2 engine not initialised")
+	}
+	if inOff < 0 || inLen < 0 || inOff+inLen > len(in) {
+		return nil, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
+	}
+	if e.forEncryption {
+		return e.engine.Encrypt(in[inOff : inOff+inLen])
+	}
+	return e.engine.Decrypt(in[inOff : inOff+inLen])
+}
+
+// Reset is a no-op: the engine keeps no state between blocks.
+func (e *SM2Engine) Reset() {
+}
+
+// Destroy overwrites the engine's copy of the private key and discards
+// the key. The engine must be initialised again before use.
+func (e *SM2Engine) Destroy() {
+	e.engine.Destroy()
+	e.domain = nil
+}
+
+var _ crypto.AsymmetricBlockCipher = (*SM2Engine)(nil)
+var _ crypto.Destroyable = (*SM2Engine)(nil)
@@ -0,0 +1,318 @@
+package engines
+
+import (
+	"bytes"
+	gosha256 "crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// sha256Digest adapts crypto/sha256 to crypto.Digest.
+type sha256Digest struct {
+	h hash.Hash
+}
+
+func (d *sha256Digest) GetAlgorithmName() string          { return "SHA-256" }
+func (d *sha256Digest) GetDigestSize() int                { return gosha256.Size }
+func (d *sha256Digest) Update(in byte)                    { d.h.Write([]byte{in}) }
+func (d *sha256Digest) BlockUpdate(in []byte, off, n int) { d.h.Write(in[off : off+n]) }
+func (d *sha256Digest) Reset()                            { d.h.Reset() }
+func (d *sha256Digest) DoFinal(out []byte, off int) int {
+	copy(out[off:], d.h.Sum(nil))
+	d.h.Reset()
+	return gosha256.Size
+}
+
+func sm2KeyParams(t *testing.T) (*params.ECPrivateKeyParameters, *params.ECPublicKeyParameters) {
+	t.Helper()
+	keyPair, err := sm2.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	domain := sm2.GetECDomainParameters()
+	return params.NewECPrivateKeyParameters(keyPair.PrivateKey, domain),
+		params.NewECPublicKeyParameters(keyPair.PublicKey, domain)
+}
+
+// TestSM2EngineGMT0003Example reproduces the sm2p256v1 encryption example
+// of GM/T 0003.5-2012 through the AsymmetricBlockCipher interface.
+func TestSM2EngineGMT0003Example(t *testing.T) {
+	d, _ := new(big.Int).SetString("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8", 16)
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	domain := sm2.GetECDomainParameters()
+	priv := params.NewECPrivateKeyParameters(d, domain)
+	pub := params.NewECPublicKeyParameters(sm2.GetG().Multiply(d), domain)
+	plaintext := []byte("encryption standard")
+
+	c1, _ := hex.DecodeString("04" +
+		"04EBFC718E8D1798620432268E77FEB6415E2EDE0E073C0F4F640ECD2E149A73" +
+		"E858F9D81E5430A57B36DAAB8F950A3C64E6EE6A63094D99283AFF767E124DF0")
+	c3, _ := hex.DecodeString("59983C18F809E262923C53AEC295D30383B54E39D609D160AFCB1908D0BD8766")
+	c2, _ := hex.DecodeString("21886CA989CA9C7D58087307CA93092D651EFA")
+
+	for _, tc := range []struct {
+		name     string
+		mode     int
+		expected []byte
+	}{
+		{"C1C2C3", sm2.Mode_C1C2C3, bytes.Join([][]byte{c1, c2, c3}, nil)},
+		{"C1C3C2", sm2.Mode_C1C3C2, bytes.Join([][]byte{c1, c3, c2}, nil)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cipher crypto.AsymmetricBlockCipher = NewSM2EngineWithDigest(nil, tc.mode)
+			if err := cipher.Init(true, params.NewParametersWithRandom(pub, bytes.NewReader(k))); err != nil {
+				t.Fatalf("Failed to init for encryption: %v", err)
+			}
+			ciphertext, err := cipher.ProcessBlock(plaintext, 0, len(plaintext))
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			if !bytes.Equal(ciphertext, tc.expected) {
+				t.Errorf("Ciphertext mismatch\nExpected: %X\nGot:      %X", tc.expected, ciphertext)
+			}
+
+			if err := cipher.Init(false, priv); err != nil {
+				t.Fatalf("Failed to init for decryption: %v", err)
+			}
+			decrypted, err := cipher.ProcessBlock(tc.expected, 0, len(tc.expected))
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("Decrypted text mismatch: %q", decrypted)
+			}
+		})
+	}
+}
+
+// TestSM2EngineTestCurveExample reproduces the encryption example of
+// GM/T 0003.4-2012 Appendix A on the 256-bit Fp test curve.
+func TestSM2EngineTestCurveExample(t *testing.T) {
+	domain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := new(big.Int).SetString("1649AB77A00637BD5E2EFE283FBF353534AA7F7CB89463F208DDBC2920BB0DA0", 16)
+	k, _ := hex.DecodeString("4C62EEFD6ECFC2B95B92FD6C3D9575148AFA17425546D49018E5388D49DD7B4F")
+	priv := params.NewECPrivateKeyParameters(d, domain)
+	pub := params.NewECPublicKeyParameters(domain.GetG().Multiply(d), domain)
+	plaintext := []byte("encryption standard")
+
+	expected, _ := hex.DecodeString("04" +
+		"245C26FB68B1DDDDB12C4B6BF9F2B6D5FE60A383B0D18D1C4144ABF17F6252E7" +
+		"76CB9264C2A7E88E52B19903FDC47378F605E36811F5C07423A24B84400F01B8" +
+		"650053A89B41C418B0C3AAD00D886C00286467" +
+		"9C3D7360C30156FAB7C80A0276712DA9D8094A634B766D3A285E07480653426D")
+
+	engine := NewSM2Engine()
+	if err := engine.Init(true, params.NewParametersWithRandom(pub, bytes.NewReader(k))); err != nil {
+		t.Fatalf("Failed to init for encryption: %v", err)
+	}
+	ciphertext, err := engine.ProcessBlock(plaintext, 0, len(plaintext))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext mismatch\nExpected: %X\nGot:      %X", expected, ciphertext)
+	}
+
+	if err := engine.Init(false, priv); err != nil {
+		t.Fatalf("Failed to init for decryption: %v", err)
+	}
+	decrypted, err := engine.ProcessBlock(expected, 0, len(expected))
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decryption failed: %q, %v", decrypted, err)
+	}
+}
+
+// TestSM2EngineInterop checks ciphertexts against sm2.SM2Engine in every
+// layout, with and without point compression.
+func TestSM2EngineInterop(t *testing.T) {
+	priv, pub := sm2KeyParams(t)
+	plaintext := []byte("AsymmetricBlockCipher interop")
+
+	for _, mode := range []int{sm2.Mode_C1C2C3, sm2.Mode_C1C3C2, sm2.Mode_DER} {
+		for _, compressed := range []bool{false, true} {
+			engine := NewSM2EngineWithDigest(nil, mode)
+			engine.SetPointCompression(compressed)
+			_ = engine.Init(true, pub)
+			ciphertext, err := engine.ProcessBlock(plaintext, 0, len(plaintext))
+			if err != nil {
+				t.Fatalf("mode %d: encryption failed: %v", mode, err)
+			}
+			if len(ciphertext) > engine.GetOutputSize(len(plaintext)) {
+				t.Errorf("mode %d: ciphertext longer than GetOutputSize", mode)
+			}
+			if mode != sm2.Mode_DER && compressed && ciphertext[0] != 0x02 && ciphertext[0] != 0x03 {
+				t.Errorf("mode %d: C1 not compressed", mode)
+			}
+
+			reference := sm2.NewSM2Engine()
+			reference.SetMode(mode)
+			_ = reference.Init(false, nil, priv.GetD())
+			decrypted, err := reference.Decrypt(ciphertext)
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("mode %d, compressed %v: sm2.SM2Engine failed to decrypt: %v", mode, compressed, err)
+			}
+		}
+
+		reference := sm2.NewSM2Engine()
+		reference.SetMode(mode)
+		_ = reference.Init(true, pub.GetQ(), nil)
+		ciphertext, _ := reference.Encrypt(plaintext)
+
+		engine := NewSM2EngineWithDigest(nil, mode)
+		_ = engine.Init(false, priv)
+		decrypted, err := engine.ProcessBlock(ciphertext, 0, len(ciphertext))
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("mode %d: failed to decrypt sm2.SM2Engine output: %v", mode, err)
+		}
+	}
+}
+
+func TestSM2EngineDigestOption(t *testing.T) {
+	priv, pub := sm2KeyParams(t)
+	plaintext := bytes.Repeat([]byte("SHA-256 "), 10)
+
+	engine := NewSM2EngineWithDigest(&sha256Digest{h: gosha256.New()}, sm2.Mode_C1C3C2)
+	_ = engine.Init(true, pub)
+	ciphertext, err := engine.ProcessBlock(plaintext, 0, len(plaintext))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	_ = engine.Init(false, priv)
+	decrypted, err := engine.ProcessBlock(ciphertext, 0, len(ciphertext))
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Round trip failed: %v", err)
+	}
+
+	sm3Engine := NewSM2EngineWithDigest(nil, sm2.Mode_C1C3C2)
+	_ = sm3Engine.Init(false, priv)
+	if _, err := sm3Engine.ProcessBlock(ciphertext, 0, len(ciphertext)); err == nil {
+		t.Error("SHA-256 ciphertext decrypted with SM3")
+	}
+}
+
+func TestSM2EngineEmptyAndOffset(t *testing.T) {
+	priv, pub := sm2KeyParams(t)
+	buf := []byte("xxpayloadxx")
+
+	engine := NewSM2Engine()
+	_ = engine.Init(true, pub)
+	ciphertext, err := engine.ProcessBlock(buf, 2, 7)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	empty, err := engine.ProcessBlock(nil, 0, 0)
+	if err != nil {
+		t.Fatalf("Encryption of empty message failed: %v", err)
+	}
+	if _, err := engine.ProcessBlock(buf, 5, 7); err == nil {
+		t.Error("Expected error for out-of-range input")
+	}
+
+	_ = engine.Init(false, priv)
+	decrypted, err := engine.ProcessBlock(ciphertext, 0, len(ciphertext))
+	if err != nil || string(decrypted) != "payload" {
+		t.Errorf("Decryption failed: %q, %v", decrypted, err)
+	}
+	decrypted, err = engine.ProcessBlock(empty, 0, len(empty))
+	if err != nil || len(decrypted) != 0 {
+		t.Errorf("Decryption of empty message failed: %v", err)
+	}
+}
+
+func TestSM2EngineInvalid(t *testing.T) {
+	priv, pub := sm2KeyParams(t)
+
+	engine := NewSM2Engine()
+	if _, err := engine.ProcessBlock([]byte("x"), 0, 1); err == nil {
+		t.Error("Expected error before Init")
+	}
+	if err := engine.Init(true, priv); err == nil {
+		t.Error("Expected error encrypting with a private key")
+	}
+	if err := engine.Init(false, pub); err == nil {
+		t.Error("Expected error decrypting with a public key")
+	}
+	if err := engine.Init(true, params.NewECPublicKeyParameters(sm2.GetCurve().GetInfinity(), sm2.GetECDomainParameters())); err == nil {
+		t.Error("Expected error for the point at infinity")
+	}
+	if err := NewSM2EngineWithDigest(nil, 7).Init(true, pub); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+
+	// A point of the test curve is valid on its own curve but not a key
+	// for sm2p256v1
+	testDomain, _ := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	foreign := params.NewECPublicKeyParameters(testDomain.GetG(), sm2.GetECDomainParameters())
+	if err := engine.Init(true, foreign); !errors.Is(err, ec.ErrPointNotOnCurve) {
+		t.Errorf("Point on another curve: got %v, want ec.ErrPointNotOnCurve", err)
+	}
+
+	plaintext := []byte("tamper")
+	_ = engine.Init(true, pub)
+	ciphertext, _ := engine.ProcessBlock(plaintext, 0, len(plaintext))
+	_ = engine.Init(false, priv)
+	for _, pos := range []int{1, 65, len(ciphertext) - 1} {
+		tampered := append([]byte{}, ciphertext...)
+		tampered[pos] ^= 0x01
+		if _, err := engine.ProcessBlock(tampered, 0, len(tampered)); err == nil {
+			t.Errorf("Tampered byte %d not detected", pos)
+		}
+	}
+	if _, err := engine.ProcessBlock(ciphertext[:96], 0, 96); err == nil {
+		t.Error("Expected error for truncated ciphertext")
+	}
+
+	// C1 off the curve is rejected before it is multiplied by d
+	offCurve := append([]byte{}, ciphertext...)
+	offCurve[64] ^= 0x01
+	if _, err := engine.ProcessBlock(offCurve, 0, len(offCurve)); !errors.Is(err, ec.ErrPointNotOnCurve) {
+		t.Errorf("Off-curve C1: got %v, want ec.ErrPointNotOnCurve", err)
+	}
+
+	// A hybrid C1 is accepted
+	hybrid := append([]byte{}, ciphertext...)
+	hybrid[0] = 0x06 | ciphertext[64]&1
+	if out, err := engine.ProcessBlock(hybrid, 0, len(hybrid)); err != nil || !bytes.Equal(out, plaintext) {
+		t.Errorf("Hybrid C1 not decrypted: %v", err)
+	}
+	hybrid[0] ^= 0x01
+	if _, err := engine.ProcessBlock(hybrid, 0, len(hybrid)); !errors.Is(err, ec.ErrInvalidPointEncoding) {
+		t.Errorf("Hybrid C1 with wrong parity: got %v, want ec.ErrInvalidPointEncoding", err)
+	}
+}
+
+func TestSM2EngineDestroy(t *testing.T) {
+	priv, pub := sm2KeyParams(t)
+	d := new(big.Int).Set(priv.GetD())
+
+	engine := NewSM2Engine()
+	_ = engine.Init(true, pub)
+	ciphertext, err := engine.ProcessBlock([]byte("destroy"), 0, 7)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	if err := engine.Init(false, priv); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	engine.Destroy()
+	if priv.GetD().Cmp(d) != 0 {
+		t.Error("Destroy cleared the caller's private key")
+	}
+	if _, err := engine.ProcessBlock(ciphertext, 0, len(ciphertext)); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("ProcessBlock after Destroy = %v, want ErrInvalidState", err)
+	}
+}
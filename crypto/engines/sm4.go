@@ -2,9 +2,9 @@
 package engines
 
 import (
-	"fmt"
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
@@ -63,18 +63,29 @@ func NewSM4Engine() *SM4Engine {
 }
 
 // Init initializes the cipher for encryption or decryption.
+// It panics if the parameters are invalid; see InitChecked.
 func (e *SM4Engine) Init(forEncryption bool, parameters crypto.CipherParameters) {
+	if err := e.InitChecked(forEncryption, parameters); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher for encryption or decryption,
+// returning an error unless parameters is a 128 bit KeyParameter.
+func (e *SM4Engine) InitChecked(forEncryption bool, parameters crypto.CipherParameters) error {
 	keyParam, ok := parameters.(*params.KeyParameter)
-	if !ok {
-		panic(fmt.Sprintf("invalid parameter passed to SM4 init - %T", parameters))
+	if !ok || keyParam == nil {
+		return exceptions.Newf(exceptions.ErrInvalidKey, "invalid parameter passed to SM4 init - %T", parameters)
 	}
 	
 	key := keyParam.GetKey()
 	if len(key) != 16 {
-		panic("SM4 requires a 128 bit key")
+		return exceptions.New(exceptions.ErrInvalidKey, "SM4 requires a 128 bit key")
 	}
 	
+	util.ClearUint32(e.rk)
 	e.rk = e.expandKey(forEncryption, key)
+	return nil
 }
 
 // GetAlgorithmName returns the algorithm name.
@@ -88,17 +99,29 @@ func (e *SM4Engine) GetBlockSize() int {
 }
 
 // ProcessBlock processes one block of data.
+// It panics if the engine is not initialised or a buffer is too short; see
+// ProcessBlockChecked.
 func (e *SM4Engine) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
+	n, err := e.ProcessBlockChecked(in, inOff, out, outOff)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ProcessBlockChecked processes one block of data, returning an error if
+// the engine is not initialised or a buffer is too short.
+func (e *SM4Engine) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
 	if e.rk == nil {
-		panic("SM4 not initialised")
+		return 0, exceptions.New(exceptions.ErrInvalidState, "SM4 not initialised")
 	}
 	
-	if inOff+sm4BlockSize > len(in) {
-		panic("input buffer too short")
+	if inOff < 0 || inOff+sm4BlockSize > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 	
-	if outOff+sm4BlockSize > len(out) {
-		panic("output buffer too short")
+	if outOff < 0 || outOff+sm4BlockSize > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 	
 	// Read input (big-endian)
@@ -121,7 +144,7 @@ func (e *SM4Engine) ProcessBlock(in []byte, inOff int, out []byte, outOff int) i
 	util.Uint32ToBigEndian(e.X[1], out, outOff+8)
 	util.Uint32ToBigEndian(e.X[0], out, outOff+12)
 	
-	return sm4BlockSize
+	return sm4BlockSize, nil
 }
 
 // Reset resets the cipher.
@@ -129,6 +152,14 @@ func (e *SM4Engine) Reset() {
 	// No internal state to reset beyond rk
 }
 
+// Destroy overwrites the round keys and state registers. The engine must
+// be initialised again before use.
+func (e *SM4Engine) Destroy() {
+	util.ClearUint32(e.rk)
+	e.rk = nil
+	util.ClearUint32(e.X[:])
+}
+
 // rotateLeft performs circular left shift.
 func rotateLeft(x uint32, bits uint) uint32 {
 	return (x << bits) | (x >> (32 - bits))
@@ -210,6 +241,8 @@ func (e *SM4Engine) expandKey(forEncryption bool, key []byte) []uint32 {
 		}
 	}
 	
+	util.ClearUint32(MK)
+	util.ClearUint32(K)
 	return rk
 }
 
@@ -233,5 +266,6 @@ func (e *SM4Engine) f3(x [4]uint32, rk uint32) uint32 {
 	return x[3] ^ t(x[0]^x[1]^x[2]^rk)
 }
 
-// Ensure SM4Engine implements BlockCipher interface
-var _ crypto.BlockCipher = (*SM4Engine)(nil)
+// Ensure SM4Engine implements CheckedBlockCipher and Destroyable interfaces
+var _ crypto.CheckedBlockCipher = (*SM4Engine)(nil)
+var _ crypto.Destroyable = (*SM4Engine)(nil)
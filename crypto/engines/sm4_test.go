@@ -2,9 +2,11 @@ package engines
 
 import (
 	"encoding/hex"
+	"errors"
 	"testing"
 	
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // Test vectors from sm-py-bc and standard SM4 test vectors
@@ -230,3 +232,56 @@ func BenchmarkSM4KeyExpansion(b *testing.B) {
 		engine.Init(true, params.NewKeyParameter(key))
 	}
 }
+
+func TestSM4CheckedErrors(t *testing.T) {
+	engine := NewSM4Engine()
+	block := make([]byte, 16)
+	
+	if _, err := engine.ProcessBlockChecked(block, 0, block, 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	if err := engine.InitChecked(true, params.NewKeyParameter(make([]byte, 15))); err == nil {
+		t.Error("Expected error for wrong key length")
+	}
+	if err := engine.InitChecked(true, params.NewParametersWithIV(params.NewKeyParameter(make([]byte, 16)), block)); err == nil {
+		t.Error("Expected error for parameters with IV")
+	}
+	if err := engine.InitChecked(true, (*params.KeyParameter)(nil)); err == nil {
+		t.Error("Expected error for a nil key")
+	}
+	
+	if err := engine.InitChecked(true, params.NewKeyParameter(make([]byte, 16))); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := engine.ProcessBlockChecked(block[:15], 0, block, 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := engine.ProcessBlockChecked(block, 0, block, 1); err == nil {
+		t.Error("Expected error for short output")
+	}
+	if _, err := engine.ProcessBlockChecked(block, -1, block, 0); err == nil {
+		t.Error("Expected error for negative offset")
+	}
+	if n, err := engine.ProcessBlockChecked(block, 0, block, 0); err != nil || n != 16 {
+		t.Errorf("ProcessBlockChecked = %d, %v", n, err)
+	}
+}
+
+func TestSM4Destroy(t *testing.T) {
+	engine := NewSM4Engine()
+	if err := engine.InitChecked(true, params.NewKeyParameter(make([]byte, 16))); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	rk := engine.rk
+	engine.Destroy()
+
+	for i, k := range rk {
+		if k != 0 {
+			t.Fatalf("round key %d not cleared", i)
+		}
+	}
+	block := make([]byte, 16)
+	if _, err := engine.ProcessBlockChecked(block, 0, block, 0); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("ProcessBlockChecked after Destroy = %v, want ErrInvalidState", err)
+	}
+}
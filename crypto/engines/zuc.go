@@ -2,10 +2,10 @@
 package engines
 
 import (
-	"errors"
-
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // ZUCEngine implements the ZUC-128 stream cipher algorithm.
@@ -96,25 +96,26 @@ func (z *ZUCEngine) Init(forEncryption bool, p crypto.CipherParameters) error {
 	// Extract key and IV from parameters
 	paramsWithIV, ok := p.(*params.ParametersWithIV)
 	if !ok {
-		return errors.New("ZUC init parameters must include an IV (use ParametersWithIV)")
+		return exceptions.New(exceptions.ErrInvalidParameter, "ZUC init parameters must include an IV (use ParametersWithIV)")
 	}
 
 	iv := paramsWithIV.GetIV()
 	keyParam, ok := paramsWithIV.GetParameters().(*params.KeyParameter)
 	if !ok {
-		return errors.New("ZUC init parameters must include a KeyParameter")
+		return exceptions.New(exceptions.ErrInvalidKey, "ZUC init parameters must include a KeyParameter")
 	}
 
 	key := keyParam.GetKey()
 
 	if len(key) != 16 {
-		return errors.New("ZUC requires a 128-bit key")
+		return exceptions.New(exceptions.ErrInvalidKey, "ZUC requires a 128-bit key")
 	}
 
 	if len(iv) != 16 {
-		return errors.New("ZUC requires a 128-bit IV")
+		return exceptions.New(exceptions.ErrInvalidParameter, "ZUC requires a 128-bit IV")
 	}
 
+	z.Destroy()
 	z.workingKey = make([]byte, 16)
 	z.workingIV = make([]byte, 16)
 	copy(z.workingKey, key)
@@ -134,7 +135,7 @@ func (z *ZUCEngine) GetAlgorithmName() string {
 // ReturnByte encrypts/decrypts a single byte.
 func (z *ZUCEngine) ReturnByte(input byte) (byte, error) {
 	if !z.initialized {
-		return 0, errors.New("ZUC not initialized")
+		return 0, exceptions.New(exceptions.ErrInvalidState, "ZUC not initialized")
 	}
 
 	if z.keyStreamIndex == 0 {
@@ -148,15 +149,15 @@ func (z *ZUCEngine) ReturnByte(input byte) (byte, error) {
 // ProcessBytes processes a block of bytes.
 func (z *ZUCEngine) ProcessBytes(input []byte, inOff int, length int, output []byte, outOff int) (int, error) {
 	if !z.initialized {
-		return 0, errors.New("ZUC not initialized")
+		return 0, exceptions.New(exceptions.ErrInvalidState, "ZUC not initialized")
 	}
 
 	if inOff+length > len(input) {
-		return 0, errors.New("input buffer too short")
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 
 	if outOff+length > len(output) {
-		return 0, errors.New("output buffer too short")
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 
 	for i := 0; i < length; i++ {
@@ -178,6 +179,22 @@ func (z *ZUCEngine) Reset() {
 	z.initialized = z.workingKey != nil
 }
 
+// Destroy overwrites the key, IV and cipher state. The engine must be
+// initialized again before use.
+func (z *ZUCEngine) Destroy() {
+	util.Clear(z.workingKey)
+	util.Clear(z.workingIV)
+	z.workingKey = nil
+	z.workingIV = nil
+
+	util.ClearUint32(z.lfsr)
+	util.ClearUint32(z.keyStream)
+	z.r1 = 0
+	z.r2 = 0
+	z.keyStreamIndex = 0
+	z.initialized = false
+}
+
 // setKeyAndIV sets key and IV, initializes LFSR and discards first 32 words.
 func (z *ZUCEngine) setKeyAndIV(key []byte, iv []byte) {
 	// Loading sequence defined in ZUC specification
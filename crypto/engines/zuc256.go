@@ -2,10 +2,9 @@
 package engines
 
 import (
-	"errors"
-
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // Zuc256Engine implements the ZUC-256 stream cipher algorithm.
@@ -46,25 +45,25 @@ func (z *Zuc256Engine) Init(forEncryption bool, p crypto.CipherParameters) error
 	// Extract key and IV from parameters
 	paramsWithIV, ok := p.(*params.ParametersWithIV)
 	if !ok {
-		return errors.New("ZUC-256 init parameters must include an IV (use ParametersWithIV)")
+		return exceptions.New(exceptions.ErrInvalidParameter, "ZUC-256 init parameters must include an IV (use ParametersWithIV)")
 	}
 
 	iv := paramsWithIV.GetIV()
 	keyParam, ok := paramsWithIV.GetParameters().(*params.KeyParameter)
 	if !ok {
-		return errors.New("ZUC-256 init parameters must include a KeyParameter")
+		return exceptions.New(exceptions.ErrInvalidKey, "ZUC-256 init parameters must include a KeyParameter")
 	}
 
 	key := keyParam.GetKey()
 
 	// ZUC-256 supports 256-bit keys
 	if len(key) != 32 {
-		return errors.New("ZUC-256 requires a 256-bit (32-byte) key")
+		return exceptions.New(exceptions.ErrInvalidKey, "ZUC-256 requires a 256-bit (32-byte) key")
 	}
 
 	// ZUC-256 typically uses 184-bit (23-byte) IVs
 	if len(iv) != 23 && len(iv) != 25 {
-		return errors.New("ZUC-256 requires a 184-bit (23-byte) or 200-bit (25-byte) IV")
+		return exceptions.New(exceptions.ErrInvalidParameter, "ZUC-256 requires a 184-bit (23-byte) or 200-bit (25-byte) IV")
 	}
 
 	// Convert 256-bit key to 128-bit format for internal processing
@@ -72,6 +71,8 @@ func (z *Zuc256Engine) Init(forEncryption bool, p crypto.CipherParameters) error
 	derivedKey := z.deriveKey(key, iv)
 	derivedIV := z.deriveIV(key, iv)
 
+	z.ZUCEngine.Destroy()
+
 	z.ZUCEngine.workingKey = derivedKey
 	z.ZUCEngine.workingIV = derivedIV
 
@@ -469,3 +469,37 @@ func TestZUCIncrementalProcessing(t *testing.T) {
 		t.Error("Incremental processing produced different result")
 	}
 }
+
+// TestZUCDestroy tests that a destroyed engine clears its state and must
+// be initialized again.
+func TestZUCDestroy(t *testing.T) {
+	key := make([]byte, 16)
+	iv := make([]byte, 16)
+	input := []byte("zeroization")
+
+	engine := NewZUCEngine()
+	engine.Init(true, params.NewParametersWithIV(params.NewKeyParameter(key), iv))
+	expected := make([]byte, len(input))
+	engine.ProcessBytes(input, 0, len(input), expected, 0)
+
+	workingKey := engine.workingKey
+	engine.Destroy()
+	if !bytes.Equal(workingKey, make([]byte, len(workingKey))) {
+		t.Error("Working key not cleared")
+	}
+	for i, s := range engine.lfsr {
+		if s != 0 {
+			t.Fatalf("LFSR cell %d not cleared", i)
+		}
+	}
+	output := make([]byte, len(input))
+	if _, err := engine.ProcessBytes(input, 0, len(input), output, 0); err == nil {
+		t.Error("Expected error after Destroy")
+	}
+
+	engine.Init(true, params.NewParametersWithIV(params.NewKeyParameter(key), iv))
+	engine.ProcessBytes(input, 0, len(input), output, 0)
+	if !bytes.Equal(output, expected) {
+		t.Error("Re-initialized engine produced a different key stream")
+	}
+}
@@ -45,11 +45,28 @@ type BlockCipher interface {
 	Reset()
 }
 
+// CheckedBlockCipher is a BlockCipher that reports invalid keys, IVs,
+// nonces and buffer sizes as errors. Its Init and ProcessBlock panic with
+// the same errors, as Bouncy Castle throws.
+type CheckedBlockCipher interface {
+	BlockCipher
+
+	// InitChecked initializes the cipher as Init does, returning an error
+	// for invalid parameters
+	InitChecked(forEncryption bool, params CipherParameters) error
+
+	// ProcessBlockChecked processes a single block as ProcessBlock does,
+	// returning an error if the cipher is not initialized or a buffer is
+	// too short
+	ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error)
+}
+
 // Signer defines the interface for digital signature algorithms.
+// Where Bouncy Castle throws, errors are returned instead.
 // Reference: org.bouncycastle.crypto.Signer
 type Signer interface {
 	// Init initializes the signer for signing or verification
-	Init(forSigning bool, params CipherParameters)
+	Init(forSigning bool, params CipherParameters) error
 
 	// Update adds a single byte to the message
 	Update(b byte)
@@ -60,18 +77,21 @@ type Signer interface {
 	// GenerateSignature generates the signature for the message
 	GenerateSignature() ([]byte, error)
 
-	// VerifySignature verifies the signature against the message
-	VerifySignature(signature []byte) bool
+	// VerifySignature verifies the signature against the message. A
+	// signature that does not verify yields false; an error reports a
+	// malformed signature or a signer not initialized for verification.
+	VerifySignature(signature []byte) (bool, error)
 
 	// Reset resets the signer back to its initial state
 	Reset()
 }
 
 // AsymmetricBlockCipher defines the interface for asymmetric encryption engines.
+// Where Bouncy Castle throws, errors are returned instead.
 // Reference: org.bouncycastle.crypto.AsymmetricBlockCipher
 type AsymmetricBlockCipher interface {
 	// Init initializes the cipher for encryption or decryption
-	Init(forEncryption bool, params CipherParameters)
+	Init(forEncryption bool, params CipherParameters) error
 
 	// GetInputBlockSize returns the maximum size of input block
 	GetInputBlockSize() int
@@ -100,6 +120,14 @@ type BlockCipherMode interface {
 	GetUnderlyingCipher() BlockCipher
 }
 
+// CheckedBlockCipherMode is a BlockCipherMode that reports errors as
+// CheckedBlockCipher does.
+type CheckedBlockCipherMode interface {
+	CheckedBlockCipher
+	// GetUnderlyingCipher returns the underlying block cipher
+	GetUnderlyingCipher() BlockCipher
+}
+
 // BufferedBlockCipher defines the interface for buffered block cipher operations.
 // Reference: org.bouncycastle.crypto.BufferedBlockCipher
 type BufferedBlockCipher interface {
@@ -131,6 +159,17 @@ type BufferedBlockCipher interface {
 	Reset()
 }
 
+// CheckedBufferedBlockCipher is a BufferedBlockCipher whose initialization
+// reports invalid parameters as an error. Its Init panics with the same
+// errors.
+type CheckedBufferedBlockCipher interface {
+	BufferedBlockCipher
+
+	// InitChecked initializes the cipher as Init does, returning an error
+	// for invalid parameters
+	InitChecked(forEncryption bool, params CipherParameters) error
+}
+
 // BlockCipherPadding defines the interface for padding schemes.
 // Reference: org.bouncycastle.crypto.paddings.BlockCipherPadding
 type BlockCipherPadding interface {
@@ -157,6 +196,17 @@ type Memoable interface {
 	ResetMemoable(other Memoable)
 }
 
+// Destroyable is implemented by objects that hold key material, such as
+// keys, round keys, MAC pads, secret scalars or buffered plaintext.
+// Destroy overwrites that material; the object must be initialized again
+// before further use. Copies made by the Go runtime or by math/big
+// arithmetic cannot be reached, so destruction is best-effort.
+// Reference: javax.security.auth.Destroyable
+type Destroyable interface {
+	// Destroy overwrites the key material held by the object
+	Destroy()
+}
+
 // KeyGenerator defines the interface for key generation.
 type KeyGenerator interface {
 	// Init initializes the key generator
@@ -0,0 +1,126 @@
+// Package sm2core implements the SM2 signature primitives shared by the
+// sm2 and signers packages: the user hash Z, signing and verification of
+// a digest e = H(Z || M).
+// Reference: GM/T 0003-2012 Part 2: Digital Signature Algorithm
+package sm2core
+
+import (
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// CheckUserID rejects user IDs whose length in bits does not fit the
+// 16-bit ENTL field of Z.
+func CheckUserID(userID []byte) error {
+	if len(userID) >= 8192 {
+		return exceptions.New(exceptions.ErrInvalidParameter, "SM2 user ID must be less than 2^16 bits long")
+	}
+	return nil
+}
+
+// ComputeZ returns Z = H(ENTL || ID || a || b || xG || yG || xA || yA)
+// computed with digest, which is reset first and left reset.
+func ComputeZ(digest crypto.Digest, domain *params.ECDomainParameters, userID []byte, pub *ec.Point) ([]byte, error) {
+	if err := CheckUserID(userID); err != nil {
+		return nil, err
+	}
+	digest.Reset()
+
+	// ENTL: user ID length in bits (2 bytes, big-endian)
+	entl := len(userID) * 8
+	digest.Update(byte(entl >> 8))
+	digest.Update(byte(entl))
+	digest.BlockUpdate(userID, 0, len(userID))
+
+	curve := domain.GetCurve()
+	g := domain.GetG()
+	size := (curve.GetFieldSize() + 7) / 8
+	for _, v := range []*big.Int{
+		curve.GetA().ToBigInt(),
+		curve.GetB().ToBigInt(),
+		g.GetXCoord().ToBigInt(),
+		g.GetYCoord().ToBigInt(),
+		pub.GetXCoord().ToBigInt(),
+		pub.GetYCoord().ToBigInt(),
+	} {
+		b := util.BigIntToBytes(v, size)
+		digest.BlockUpdate(b, 0, len(b))
+	}
+
+	z := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(z, 0)
+	return z, nil
+}
+
+// Sign computes the signature (r, s) of the digest e with the private key
+// d together with the point (x1, y1) = [k]G, drawing each k in [1, n-1]
+// from nextK until r and s are valid. d must be in [1, n-2].
+func Sign(domain *params.ECDomainParameters, d *big.Int, e []byte, nextK func() (*big.Int, error)) (*big.Int, *big.Int, *ec.Point, error) {
+	n := domain.GetN()
+	sf := domain.GetCurve().GetScalarField()
+	eInt := new(big.Int).SetBytes(e)
+
+	// (1 + d)^-1 is computed in the constant-time scalar field, since d
+	// and k are secret
+	dPlus1Inv := sf.Inverse(sf.Add(d, big.NewInt(1)))
+	if dPlus1Inv.Sign() == 0 {
+		return nil, nil, nil, exceptions.New(exceptions.ErrInvalidKey, "invalid private key")
+	}
+	defer util.ClearBigInt(dPlus1Inv)
+
+	for {
+		k, err := nextK()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// r = (e + x1) mod n, where (x1, y1) = [k]G
+		p1 := domain.GetG().MultiplySecret(k)
+		r := new(big.Int).Add(eInt, p1.GetXCoord().ToBigInt())
+		r.Mod(r, n)
+		if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+			util.ClearBigInt(k)
+			continue
+		}
+
+		// s = (1 + d)^-1 * (k - r * d) mod n
+		s := sf.Mul(dPlus1Inv, sf.Sub(k, sf.Mul(r, d)))
+		util.ClearBigInt(k)
+		if s.Sign() == 0 {
+			continue
+		}
+		return r, s, p1, nil
+	}
+}
+
+// Verify reports whether (r, s) is a valid signature of the digest e for
+// the public key pub.
+func Verify(domain *params.ECDomainParameters, pub *ec.Point, e []byte, r, s *big.Int) bool {
+	n := domain.GetN()
+	if r.Sign() <= 0 || r.Cmp(n) >= 0 || s.Sign() <= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+
+	// t = (r + s) mod n
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+
+	// (x1, y1) = [s]G + [t]P
+	p1 := ec.SumOfTwoMultiplies(domain.GetG(), s, pub, t)
+	if p1.IsInfinity() {
+		return false
+	}
+
+	// v = (e + x1) mod n
+	v := new(big.Int).Add(new(big.Int).SetBytes(e), p1.GetXCoord().ToBigInt())
+	v.Mod(v, n)
+	return v.Cmp(r) == 0
+}
@@ -0,0 +1,89 @@
+package sm2core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+func fromHex(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 16)
+	return v
+}
+
+// TestComputeZ checks Z for the key of the GM/T 0003-2012 example on
+// sm2p256v1 and the user ID length limit.
+func TestComputeZ(t *testing.T) {
+	domain, _ := params.NewECDomainParametersByName(ec.SM2P256V1)
+	pub := domain.GetG().Multiply(fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8"))
+
+	z, err := ComputeZ(digests.NewSM3Digest(), domain, []byte("1234567812345678"), pub)
+	if err != nil {
+		t.Fatalf("ComputeZ failed: %v", err)
+	}
+	expected, _ := hex.DecodeString("B2E14C5C79C6DF5B85F4FE7ED8DB7A262B9DA7E07CCB0EA9F4747B8CCDA8A4F3")
+	if !bytes.Equal(z, expected) {
+		t.Errorf("Z mismatch\nExpected: %X\nGot:      %X", expected, z)
+	}
+
+	if _, err := ComputeZ(digests.NewSM3Digest(), domain, make([]byte, 8192), pub); !errors.Is(err, exceptions.ErrInvalidParameter) {
+		t.Errorf("Expected ErrInvalidParameter for a long user ID, got %v", err)
+	}
+}
+
+// TestSignVerify signs with fixed k on the sm2p256v1 example key and
+// checks the signature against the published r and s.
+func TestSignVerify(t *testing.T) {
+	domain, _ := params.NewECDomainParametersByName(ec.SM2P256V1)
+	d := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	pub := domain.GetG().Multiply(d)
+	digest := digests.NewSM3Digest()
+	z, _ := ComputeZ(digest, domain, []byte("1234567812345678"), pub)
+	digest.BlockUpdate(z, 0, len(z))
+	digest.BlockUpdate([]byte("message digest"), 0, 14)
+	e := make([]byte, 32)
+	digest.DoFinal(e, 0)
+
+	k := fromHex("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	r, s, p1, err := Sign(domain, d, e, func() (*big.Int, error) { return new(big.Int).Set(k), nil })
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if r.Cmp(fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")) != 0 ||
+		s.Cmp(fromHex("B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA")) != 0 {
+		t.Errorf("Signature mismatch: r=%X s=%X", r, s)
+	}
+	if !p1.Equals(domain.GetG().Multiply(k)) {
+		t.Error("Sign returned the wrong [k]G")
+	}
+
+	if !Verify(domain, pub, e, r, s) {
+		t.Error("Verify rejected a valid signature")
+	}
+	if Verify(domain, pub, e, s, r) {
+		t.Error("Verify accepted swapped r and s")
+	}
+	if Verify(domain, pub, e, r, new(big.Int).Add(s, domain.GetN())) {
+		t.Error("Verify accepted s >= n")
+	}
+
+	// A random k still gives a valid signature
+	r, s, _, err = Sign(domain, d, e, func() (*big.Int, error) {
+		k, err := rand.Int(rand.Reader, new(big.Int).Sub(domain.GetN(), big.NewInt(1)))
+		if err != nil {
+			return nil, err
+		}
+		return k.Add(k, big.NewInt(1)), nil
+	})
+	if err != nil || !Verify(domain, pub, e, r, s) {
+		t.Errorf("Signature with random k failed: %v", err)
+	}
+}
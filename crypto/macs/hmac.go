@@ -2,10 +2,10 @@
 package macs
 
 import (
-	"errors"
-
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // HMAC constants for padding
@@ -78,7 +78,7 @@ func (h *HMac) Init(p crypto.CipherParameters) error {
 	// Params must be a KeyParameter
 	keyParam, ok := p.(*params.KeyParameter)
 	if !ok {
-		return errors.New("HMac requires KeyParameter")
+		return exceptions.New(exceptions.ErrInvalidKey, "HMac requires KeyParameter")
 	}
 
 	key := keyParam.GetKey()
@@ -152,7 +152,7 @@ func (h *HMac) UpdateArray(in []byte, inOff int, length int) {
 //   - error: nil on success, error if output buffer is too small
 func (h *HMac) DoFinal(out []byte, outOff int) (int, error) {
 	if len(out)-outOff < h.digestSize {
-		return 0, errors.New("output buffer too small")
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too small")
 	}
 
 	// Complete the inner hash: H(K ⊕ ipad || message)
@@ -177,3 +177,11 @@ func (h *HMac) Reset() {
 	// Re-initialize with the input pad (K ⊕ ipad)
 	h.digest.BlockUpdate(h.inputPad, 0, len(h.inputPad))
 }
+
+// Destroy overwrites the padded keys and the inner hash, and resets the
+// underlying digest. The HMAC must be initialized again before use.
+func (h *HMac) Destroy() {
+	util.Clear(h.inputPad)
+	util.Clear(h.outputBuf)
+	h.digest.Reset()
+}
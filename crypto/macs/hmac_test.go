@@ -523,3 +523,19 @@ func TestHMacRFC2104Adaptation(t *testing.T) {
 		})
 	}
 }
+
+// TestHMacDestroy tests that Destroy clears the key pads.
+func TestHMacDestroy(t *testing.T) {
+	hmac := NewHMac(digests.NewSM3Digest())
+	if err := hmac.Init(params.NewKeyParameter([]byte("key"))); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	hmac.Destroy()
+
+	if !bytes.Equal(hmac.inputPad, make([]byte, len(hmac.inputPad))) {
+		t.Error("Input pad not cleared")
+	}
+	if !bytes.Equal(hmac.outputBuf, make([]byte, len(hmac.outputBuf))) {
+		t.Error("Output buffer not cleared")
+	}
+}
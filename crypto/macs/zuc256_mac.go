@@ -2,10 +2,10 @@
 package macs
 
 import (
-	"errors"
-
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/engines"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // Zuc256Mac implements the ZUC-256 MAC algorithm.
@@ -105,12 +105,12 @@ func (z *Zuc256Mac) UpdateArray(in []byte, inOff int, length int) {
 //   - error if any
 func (z *Zuc256Mac) DoFinal(out []byte, outOff int) (int, error) {
 	if !z.initialized {
-		return 0, errors.New("ZUC-256 MAC not initialized")
+		return 0, exceptions.New(exceptions.ErrInvalidState, "ZUC-256 MAC not initialized")
 	}
 
 	macBytes := z.GetMacSize()
 	if len(out)-outOff < macBytes {
-		return 0, errors.New("output buffer too small")
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too small")
 	}
 
 	// Generate keystream for MAC calculation
@@ -171,8 +171,11 @@ func (z *Zuc256Mac) calculateMac() uint64 {
 	return t
 }
 
-// Reset resets the MAC to its initialized state.
+// Reset resets the MAC to its initialized state, clearing the buffered
+// message.
 func (z *Zuc256Mac) Reset() {
+	util.Clear(z.workingData)
+	util.ClearUint32(z.keyStream)
 	z.workingData = make([]byte, 0)
 	z.keyStream = make([]uint32, 0)
 	z.wordCount = 0
@@ -180,3 +183,12 @@ func (z *Zuc256Mac) Reset() {
 		z.engine.Reset()
 	}
 }
+
+// Destroy overwrites the buffered message, the key stream and the key
+// held by the underlying engine. The MAC must be initialized again before
+// use.
+func (z *Zuc256Mac) Destroy() {
+	z.initialized = false
+	z.Reset()
+	z.engine.Destroy()
+}
@@ -2,8 +2,11 @@
 package modes
 
 import (
+
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // CBCBlockCipher implements Cipher Block Chaining (CBC) mode.
@@ -36,38 +39,52 @@ func (c *CBCBlockCipher) GetUnderlyingCipher() crypto.BlockCipher {
 }
 
 // Init initializes the cipher and possibly the IV.
+// It panics if the parameters are invalid; see InitChecked.
 func (c *CBCBlockCipher) Init(forEncryption bool, parameters crypto.CipherParameters) {
-	oldEncrypting := c.encrypting
-	c.encrypting = forEncryption
-	
-	var actualParams crypto.CipherParameters
+	if err := c.InitChecked(forEncryption, parameters); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher and possibly the IV, returning an
+// error if the IV is not one block long or the key is rejected by the
+// underlying cipher.
+func (c *CBCBlockCipher) InitChecked(forEncryption bool, parameters crypto.CipherParameters) error {
+	actualParams := parameters
+	var iv []byte
 	
 	// Check if parameters include an IV
 	if ivParams, ok := parameters.(*params.ParametersWithIV); ok {
-		iv := ivParams.GetIV()
+		iv = ivParams.GetIV()
 		
 		if len(iv) != c.blockSize {
-			panic("initialization vector must be the same length as block size")
+			return exceptions.New(exceptions.ErrInvalidParameter, "initialization vector must be the same length as block size")
 		}
 		
-		copy(c.IV, iv)
 		actualParams = ivParams.GetParameters()
+	}
+	
+	// If actualParams is nil, it's an IV change only (key is to be reused)
+	if actualParams == nil && c.encrypting != forEncryption {
+		return exceptions.New(exceptions.ErrInvalidParameter, "cannot change encrypting state without providing key")
+	}
+	
+	c.encrypting = forEncryption
+	if iv != nil {
+		copy(c.IV, iv)
 	} else {
 		// No IV provided, use all zeros
 		for i := range c.IV {
 			c.IV[i] = 0
 		}
-		actualParams = parameters
 	}
 	
 	c.Reset()
 	
-	// If actualParams is nil, it's an IV change only (key is to be reused)
 	if actualParams != nil {
-		c.cipher.Init(forEncryption, actualParams)
-	} else if oldEncrypting != forEncryption {
-		panic("cannot change encrypting state without providing key")
+		return crypto.InitBlockCipher(c.cipher, forEncryption, actualParams)
 	}
+	return nil
 }
 
 // GetAlgorithmName returns the algorithm name and mode.
@@ -81,7 +98,27 @@ func (c *CBCBlockCipher) GetBlockSize() int {
 }
 
 // ProcessBlock processes one block of input.
+// It panics if the cipher is not initialized or a buffer is too short; see
+// ProcessBlockChecked.
 func (c *CBCBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
+	n, err := c.ProcessBlockChecked(in, inOff, out, outOff)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ProcessBlockChecked processes one block of input, returning an error if
+// the cipher is not initialized or a buffer is too short.
+func (c *CBCBlockCipher) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
+	if inOff < 0 || inOff+c.blockSize > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
+	}
+	
+	if outOff < 0 || outOff+c.blockSize > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
+	}
+	
 	if c.encrypting {
 		return c.encryptBlock(in, inOff, out, outOff)
 	}
@@ -98,34 +135,32 @@ func (c *CBCBlockCipher) Reset() {
 }
 
 // encryptBlock performs CBC encryption on one block.
-func (c *CBCBlockCipher) encryptBlock(in []byte, inOff int, out []byte, outOff int) int {
-	if inOff+c.blockSize > len(in) {
-		panic("input buffer too short")
-	}
-	
+func (c *CBCBlockCipher) encryptBlock(in []byte, inOff int, out []byte, outOff int) (int, error) {
 	// XOR the cbcV and the input, then encrypt the cbcV
 	for i := 0; i < c.blockSize; i++ {
 		c.cbcV[i] ^= in[inOff+i]
 	}
 	
-	length := c.cipher.ProcessBlock(c.cbcV, 0, out, outOff)
+	length, err := crypto.ProcessBlock(c.cipher, c.cbcV, 0, out, outOff)
+	if err != nil {
+		return 0, err
+	}
 	
 	// Copy ciphertext to cbcV
 	copy(c.cbcV, out[outOff:outOff+c.blockSize])
 	
-	return length
+	return length, nil
 }
 
 // decryptBlock performs CBC decryption on one block.
-func (c *CBCBlockCipher) decryptBlock(in []byte, inOff int, out []byte, outOff int) int {
-	if inOff+c.blockSize > len(in) {
-		panic("input buffer too short")
-	}
-	
+func (c *CBCBlockCipher) decryptBlock(in []byte, inOff int, out []byte, outOff int) (int, error) {
 	// Save the ciphertext block for next round
 	copy(c.cbcNextV, in[inOff:inOff+c.blockSize])
 	
-	length := c.cipher.ProcessBlock(in, inOff, out, outOff)
+	length, err := crypto.ProcessBlock(c.cipher, in, inOff, out, outOff)
+	if err != nil {
+		return 0, err
+	}
 	
 	// XOR the cbcV and the output
 	for i := 0; i < c.blockSize; i++ {
@@ -135,8 +170,18 @@ func (c *CBCBlockCipher) decryptBlock(in []byte, inOff int, out []byte, outOff i
 	// Swap the back up buffer into next position
 	c.cbcV, c.cbcNextV = c.cbcNextV, c.cbcV
 	
-	return length
+	return length, nil
+}
+
+// Destroy overwrites the IV and chaining state and destroys the underlying
+// cipher. The mode must be initialized again, with a key, before use.
+func (c *CBCBlockCipher) Destroy() {
+	util.Clear(c.IV)
+	util.Clear(c.cbcV)
+	util.Clear(c.cbcNextV)
+	crypto.Destroy(c.cipher)
 }
 
-// Ensure CBCBlockCipher implements BlockCipher interface
-var _ crypto.BlockCipher = (*CBCBlockCipher)(nil)
+// Ensure CBCBlockCipher implements CheckedBlockCipherMode and Destroyable interfaces
+var _ crypto.CheckedBlockCipherMode = (*CBCBlockCipher)(nil)
+var _ crypto.Destroyable = (*CBCBlockCipher)(nil)
@@ -1,12 +1,15 @@
 package modes
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"testing"
 	
 	"github.com/lihongjie0209/sm-go-bc/crypto/engines"
 	"github.com/lihongjie0209/sm-go-bc/crypto/paddings"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 func TestCBCGetAlgorithmName(t *testing.T) {
@@ -193,3 +196,102 @@ func TestCBCReset(t *testing.T) {
 		t.Errorf("Reset failed: different ciphertexts produced")
 	}
 }
+
+func TestCBCCheckedErrors(t *testing.T) {
+	key := params.NewKeyParameter(make([]byte, 16))
+	block := make([]byte, 16)
+	
+	cbc := NewCBCBlockCipher(engines.NewSM4Engine())
+	if _, err := cbc.ProcessBlockChecked(block, 0, block, 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	if err := cbc.InitChecked(true, params.NewParametersWithIV(key, make([]byte, 8))); err == nil {
+		t.Error("Expected error for short IV")
+	}
+	if err := cbc.InitChecked(true, params.NewKeyParameter(make([]byte, 8))); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+	
+	if err := cbc.InitChecked(true, params.NewParametersWithIV(key, block)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if err := cbc.InitChecked(false, params.NewParametersWithIV(nil, block)); err == nil {
+		t.Error("Expected error when changing direction without a key")
+	}
+	if _, err := cbc.ProcessBlockChecked(block, 8, block, 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := cbc.ProcessBlockChecked(block, 0, make([]byte, 15), 0); err == nil {
+		t.Error("Expected error for short output")
+	}
+	
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Init to panic for short IV")
+		}
+	}()
+	cbc.Init(true, params.NewParametersWithIV(key, make([]byte, 8)))
+}
+
+func TestPaddedBufferedBlockCipherErrors(t *testing.T) {
+	key := params.NewKeyParameter(make([]byte, 16))
+	iv := make([]byte, 16)
+	
+	cipher := NewPaddedBufferedBlockCipher(NewCBCBlockCipher(engines.NewSM4Engine()), paddings.NewPKCS7Padding())
+	if err := cipher.InitChecked(true, params.NewParametersWithIV(key, iv[:4])); err == nil {
+		t.Error("Expected error for short IV")
+	}
+	
+	// Not initialized: the underlying cipher error is returned
+	if _, err := cipher.ProcessBytes(make([]byte, 32), 0, 32, make([]byte, 32), 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	
+	if err := cipher.InitChecked(true, params.NewParametersWithIV(key, iv)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := cipher.ProcessBytes(make([]byte, 8), 0, 16, nil, 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := cipher.ProcessBytes(make([]byte, 10), 0, 10, nil, 0); err != nil {
+		t.Fatalf("ProcessBytes failed: %v", err)
+	}
+	if _, err := cipher.DoFinal(make([]byte, 15), 0); err == nil {
+		t.Error("Expected error for short output in DoFinal")
+	}
+	
+	// Decrypt a 10 byte message into a buffer that is too short
+	ciphertext := make([]byte, 16)
+	if err := cipher.InitChecked(true, params.NewParametersWithIV(key, iv)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	n, _ := cipher.ProcessBytes(make([]byte, 10), 0, 10, ciphertext, 0)
+	if _, err := cipher.DoFinal(ciphertext, n); err != nil {
+		t.Fatalf("DoFinal failed: %v", err)
+	}
+	if err := cipher.InitChecked(false, params.NewParametersWithIV(key, iv)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	n, _ = cipher.ProcessBytes(ciphertext, 0, 16, nil, 0)
+	if _, err := cipher.DoFinal(make([]byte, 9), n); err == nil {
+		t.Error("Expected error for short output in decryption")
+	}
+}
+
+func TestCBCDestroy(t *testing.T) {
+	iv := []byte("0123456789abcdef")
+	block := make([]byte, 16)
+
+	cbc := NewCBCBlockCipher(engines.NewSM4Engine())
+	if err := cbc.InitChecked(true, params.NewParametersWithIV(params.NewKeyParameter(make([]byte, 16)), iv)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	cbc.Destroy()
+
+	if !bytes.Equal(cbc.cbcV, make([]byte, 16)) || !bytes.Equal(cbc.cbcNextV, make([]byte, 16)) {
+		t.Error("Chaining state not cleared")
+	}
+	if _, err := cbc.ProcessBlockChecked(block, 0, block, 0); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("ProcessBlockChecked after Destroy = %v, want ErrInvalidState", err)
+	}
+}
@@ -2,8 +2,11 @@
 package modes
 
 import (
+
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // CFBBlockCipher implements Cipher Feedback (CFB) mode.
@@ -26,11 +29,23 @@ type CFBBlockCipher struct {
 // NewCFBBlockCipher creates a new CFB mode cipher.
 // bitBlockSize is the block size in bits (must be a multiple of 8).
 // Common values are 8 (CFB8), 64 (CFB64), or 128 (CFB128).
+// It panics if bitBlockSize is invalid; see NewCFBBlockCipherChecked.
 func NewCFBBlockCipher(cipher crypto.BlockCipher, bitBlockSize int) *CFBBlockCipher {
+	c, err := NewCFBBlockCipherChecked(cipher, bitBlockSize)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// NewCFBBlockCipherChecked creates a new CFB mode cipher, returning an
+// error if bitBlockSize is not a multiple of 8 between 8 and the cipher
+// block size in bits.
+func NewCFBBlockCipherChecked(cipher crypto.BlockCipher, bitBlockSize int) (*CFBBlockCipher, error) {
 	cipherBlockSize := cipher.GetBlockSize()
 
 	if bitBlockSize > cipherBlockSize*8 || bitBlockSize < 8 || bitBlockSize%8 != 0 {
-		panic("CFB bitBlockSize must be a multiple of 8 and <= cipher block size")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "CFB bitBlockSize must be a multiple of 8 and <= cipher block size")
 	}
 
 	blockSize := bitBlockSize / 8
@@ -43,7 +58,7 @@ func NewCFBBlockCipher(cipher crypto.BlockCipher, bitBlockSize int) *CFBBlockCip
 		cfbV:            make([]byte, cipherBlockSize),
 		cfbOutV:         make([]byte, cipherBlockSize),
 		inBuf:           make([]byte, blockSize),
-	}
+	}, nil
 }
 
 // GetUnderlyingCipher returns the underlying block cipher.
@@ -54,7 +69,16 @@ func (c *CFBBlockCipher) GetUnderlyingCipher() crypto.BlockCipher {
 // Init initializes the cipher and, possibly, the initialization vector (IV).
 // If an IV isn't passed as part of the parameter, the IV will be all zeros.
 // An IV which is too short is handled in FIPS compliant fashion.
+// It panics if the parameters are invalid; see InitChecked.
 func (c *CFBBlockCipher) Init(forEncryption bool, parameters crypto.CipherParameters) {
+	if err := c.InitChecked(forEncryption, parameters); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher as Init does, returning an error if
+// the key is rejected by the underlying cipher.
+func (c *CFBBlockCipher) InitChecked(forEncryption bool, parameters crypto.CipherParameters) error {
 	c.encrypting = forEncryption
 
 	var actualParams crypto.CipherParameters
@@ -87,8 +111,9 @@ func (c *CFBBlockCipher) Init(forEncryption bool, parameters crypto.CipherParame
 	// If actualParams is nil, it's an IV change only (key is to be reused)
 	// Note: CFB always uses encryption mode in the underlying cipher
 	if actualParams != nil {
-		c.cipher.Init(true, actualParams)
+		return crypto.InitBlockCipher(c.cipher, true, actualParams)
 	}
+	return nil
 }
 
 // GetAlgorithmName returns the algorithm name and mode.
@@ -102,21 +127,51 @@ func (c *CFBBlockCipher) GetBlockSize() int {
 }
 
 // ProcessBlock processes one block of input from the array in and writes it to out.
+// It panics if the cipher is not initialized or a buffer is too short; see
+// ProcessBlockChecked.
 func (c *CFBBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
 	return c.ProcessBytes(in, inOff, c.blockSize, out, outOff)
 }
 
+// ProcessBlockChecked processes one block of input, returning an error if
+// the cipher is not initialized or a buffer is too short.
+func (c *CFBBlockCipher) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
+	return c.ProcessBytesChecked(in, inOff, c.blockSize, out, outOff)
+}
+
 // ProcessBytes processes multiple bytes in CFB mode.
+// It panics if the cipher is not initialized or a buffer is too short; see
+// ProcessBytesChecked.
 func (c *CFBBlockCipher) ProcessBytes(in []byte, inOff int, length int, out []byte, outOff int) int {
-	if inOff+length > len(in) {
-		panic("input buffer too short")
+	n, err := c.ProcessBytesChecked(in, inOff, length, out, outOff)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ProcessBytesChecked processes multiple bytes in CFB mode, returning an
+// error if the cipher is not initialized or a buffer is too short.
+func (c *CFBBlockCipher) ProcessBytesChecked(in []byte, inOff int, length int, out []byte, outOff int) (int, error) {
+	if length < 0 {
+		return 0, exceptions.Newf(exceptions.ErrDataLength, "invalid length: %d", length)
 	}
 
-	if outOff+length > len(out) {
-		panic("output buffer too short")
+	if inOff < 0 || inOff+length > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
+	}
+
+	if outOff < 0 || outOff+length > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 
 	for i := 0; i < length; i++ {
+		if c.byteCount == 0 {
+			if _, err := crypto.ProcessBlock(c.cipher, c.cfbV, 0, c.cfbOutV, 0); err != nil {
+				return 0, err
+			}
+		}
+
 		if c.encrypting {
 			out[outOff+i] = c.encryptByte(in[inOff+i])
 		} else {
@@ -124,15 +179,11 @@ func (c *CFBBlockCipher) ProcessBytes(in []byte, inOff int, length int, out []by
 		}
 	}
 
-	return length
+	return length, nil
 }
 
-// encryptByte encrypts a single byte.
+// encryptByte encrypts a single byte with the current keystream block.
 func (c *CFBBlockCipher) encryptByte(inputByte byte) byte {
-	if c.byteCount == 0 {
-		c.cipher.ProcessBlock(c.cfbV, 0, c.cfbOutV, 0)
-	}
-
 	rv := c.cfbOutV[c.byteCount] ^ inputByte
 	c.inBuf[c.byteCount] = rv
 	c.byteCount++
@@ -149,12 +200,8 @@ func (c *CFBBlockCipher) encryptByte(inputByte byte) byte {
 	return rv
 }
 
-// decryptByte decrypts a single byte.
+// decryptByte decrypts a single byte with the current keystream block.
 func (c *CFBBlockCipher) decryptByte(inputByte byte) byte {
-	if c.byteCount == 0 {
-		c.cipher.ProcessBlock(c.cfbV, 0, c.cfbOutV, 0)
-	}
-
 	c.inBuf[c.byteCount] = inputByte
 	rv := c.cfbOutV[c.byteCount] ^ inputByte
 	c.byteCount++
@@ -189,5 +236,18 @@ func (c *CFBBlockCipher) Reset() {
 	c.cipher.Reset()
 }
 
-// Ensure CFBBlockCipher implements BlockCipher interface
-var _ crypto.BlockCipher = (*CFBBlockCipher)(nil)
+// Destroy overwrites the IV, feedback register, key stream and buffered
+// input and destroys the underlying cipher. The mode must be initialized
+// again, with a key, before use.
+func (c *CFBBlockCipher) Destroy() {
+	util.Clear(c.IV)
+	util.Clear(c.cfbV)
+	util.Clear(c.cfbOutV)
+	util.Clear(c.inBuf)
+	c.byteCount = 0
+	crypto.Destroy(c.cipher)
+}
+
+// Ensure CFBBlockCipher implements CheckedBlockCipherMode and Destroyable interfaces
+var _ crypto.CheckedBlockCipherMode = (*CFBBlockCipher)(nil)
+var _ crypto.Destroyable = (*CFBBlockCipher)(nil)
@@ -316,3 +316,37 @@ func BenchmarkCFBBlockCipher_Decrypt(b *testing.B) {
 		}
 	}
 }
+
+// Test that invalid block sizes, keys and buffers are reported as errors
+func TestCFBBlockCipher_CheckedErrors(t *testing.T) {
+	for _, bits := range []int{0, 12, 136} {
+		if _, err := NewCFBBlockCipherChecked(engines.NewSM4Engine(), bits); err == nil {
+			t.Errorf("Expected error for CFB%d", bits)
+		}
+	}
+
+	cfb, err := NewCFBBlockCipherChecked(engines.NewSM4Engine(), 128)
+	if err != nil {
+		t.Fatalf("NewCFBBlockCipherChecked failed: %v", err)
+	}
+	block := make([]byte, 16)
+
+	if _, err := cfb.ProcessBytesChecked(block, 0, 16, block, 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	if err := cfb.InitChecked(true, params.NewParametersWithIV(params.NewKeyParameter(block[:10]), block)); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+	if err := cfb.InitChecked(true, params.NewParametersWithIV(params.NewKeyParameter(block), block)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := cfb.ProcessBytesChecked(block, 0, 17, make([]byte, 17), 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := cfb.ProcessBytesChecked(block, 0, 16, block[:15], 0); err == nil {
+		t.Error("Expected error for short output")
+	}
+	if _, err := cfb.ProcessBlockChecked(block, 0, block, 0); err != nil {
+		t.Errorf("ProcessBlockChecked failed: %v", err)
+	}
+}
@@ -2,8 +2,11 @@
 package modes
 
 import (
+
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // CTRBlockCipher implements Counter (CTR) mode, also known as SIC (Segmented Integer Counter).
@@ -42,16 +45,26 @@ func (c *CTRBlockCipher) GetUnderlyingCipher() crypto.BlockCipher {
 
 // Init initializes the cipher.
 // Note: forEncryption is ignored by CTR mode (always encrypts the counter).
+// It panics if the parameters are invalid; see InitChecked.
 func (c *CTRBlockCipher) Init(forEncryption bool, parameters crypto.CipherParameters) {
+	if err := c.InitChecked(forEncryption, parameters); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher as Init does, returning an error if
+// parameters is not a ParametersWithIV, the IV length is not supported or
+// the key is rejected by the underlying cipher.
+func (c *CTRBlockCipher) InitChecked(forEncryption bool, parameters crypto.CipherParameters) error {
 	ivParams, ok := parameters.(*params.ParametersWithIV)
 	if !ok {
-		panic("CTR/SIC mode requires ParametersWithIV")
+		return exceptions.New(exceptions.ErrInvalidParameter, "CTR/SIC mode requires ParametersWithIV")
 	}
 	
 	iv := ivParams.GetIV()
 	
 	if c.blockSize < len(iv) {
-		panic("CTR/SIC mode requires IV no greater than block size")
+		return exceptions.New(exceptions.ErrInvalidParameter, "CTR/SIC mode requires IV no greater than block size")
 	}
 	
 	maxCounterSize := 8
@@ -60,7 +73,7 @@ func (c *CTRBlockCipher) Init(forEncryption bool, parameters crypto.CipherParame
 	}
 	
 	if c.blockSize-len(iv) > maxCounterSize {
-		panic("CTR/SIC mode requires IV of sufficient length")
+		return exceptions.New(exceptions.ErrInvalidParameter, "CTR/SIC mode requires IV of sufficient length")
 	}
 	
 	copy(c.IV, iv)
@@ -68,10 +81,13 @@ func (c *CTRBlockCipher) Init(forEncryption bool, parameters crypto.CipherParame
 	// Initialize the cipher (always with encryption)
 	underlyingParams := ivParams.GetParameters()
 	if underlyingParams != nil {
-		c.cipher.Init(true, underlyingParams)
+		if err := crypto.InitBlockCipher(c.cipher, true, underlyingParams); err != nil {
+			return err
+		}
 	}
 	
 	c.Reset()
+	return nil
 }
 
 // GetAlgorithmName returns the algorithm name and mode.
@@ -85,24 +101,36 @@ func (c *CTRBlockCipher) GetBlockSize() int {
 }
 
 // ProcessBlock processes one block of input.
+// It panics if the cipher is not initialized, a buffer is too short or the
+// counter is exhausted; see ProcessBlockChecked.
 func (c *CTRBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
+	n, err := c.ProcessBlockChecked(in, inOff, out, outOff)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ProcessBlockChecked processes one block of input, returning an error if
+// the cipher is not initialized, a buffer is too short or the counter is
+// exhausted.
+func (c *CTRBlockCipher) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
 	if c.byteCount != 0 {
 		return c.processBytes(in, inOff, c.blockSize, out, outOff)
 	}
 	
-	if inOff+c.blockSize > len(in) {
-		panic("input buffer too short")
+	if inOff < 0 || inOff+c.blockSize > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 	
-	if outOff+c.blockSize > len(out) {
-		panic("output buffer too short")
+	if outOff < 0 || outOff+c.blockSize > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 	
-	// Check counter before using it
-	c.checkLastIncrement()
-	
-	// Encrypt the counter
-	c.cipher.ProcessBlock(c.counter, 0, c.counterOut, 0)
+	// Check counter before using it, then encrypt it
+	if err := c.nextKeyStream(); err != nil {
+		return 0, err
+	}
 	
 	// XOR with input
 	for i := 0; i < c.blockSize; i++ {
@@ -111,23 +139,24 @@ func (c *CTRBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff i
 	
 	c.incrementCounter()
 	
-	return c.blockSize
+	return c.blockSize, nil
 }
 
 // ProcessBytes processes bytes in stream mode.
-func (c *CTRBlockCipher) processBytes(in []byte, inOff int, length int, out []byte, outOff int) int {
-	if inOff+length > len(in) {
-		panic("input buffer too short")
+func (c *CTRBlockCipher) processBytes(in []byte, inOff int, length int, out []byte, outOff int) (int, error) {
+	if inOff < 0 || inOff+length > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 	
-	if outOff+length > len(out) {
-		panic("output buffer too short")
+	if outOff < 0 || outOff+length > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 	
 	for i := 0; i < length; i++ {
 		if c.byteCount == 0 {
-			c.checkLastIncrement()
-			c.cipher.ProcessBlock(c.counter, 0, c.counterOut, 0)
+			if err := c.nextKeyStream(); err != nil {
+				return 0, err
+			}
 			out[outOff+i] = in[inOff+i] ^ c.counterOut[c.byteCount]
 			c.byteCount++
 		} else {
@@ -140,7 +169,16 @@ func (c *CTRBlockCipher) processBytes(in []byte, inOff int, length int, out []by
 		}
 	}
 	
-	return length
+	return length, nil
+}
+
+// nextKeyStream checks the counter and encrypts it into counterOut.
+func (c *CTRBlockCipher) nextKeyStream() error {
+	if err := c.checkLastIncrement(); err != nil {
+		return err
+	}
+	_, err := crypto.ProcessBlock(c.cipher, c.counter, 0, c.counterOut, 0)
+	return err
 }
 
 // Reset resets the cipher.
@@ -154,13 +192,14 @@ func (c *CTRBlockCipher) Reset() {
 }
 
 // checkLastIncrement checks that counter hasn't wrapped around.
-func (c *CTRBlockCipher) checkLastIncrement() {
+func (c *CTRBlockCipher) checkLastIncrement() error {
 	// If the IV is the same as the blocksize we assume the user knows what they are doing
 	if len(c.IV) < c.blockSize {
 		if c.counter[len(c.IV)-1] != c.IV[len(c.IV)-1] {
-			panic("Counter in CTR/SIC mode out of range")
+			return exceptions.New(exceptions.ErrInvalidState, "Counter in CTR/SIC mode out of range")
 		}
 	}
+	return nil
 }
 
 // incrementCounter increments the counter by 1.
@@ -173,5 +212,17 @@ func (c *CTRBlockCipher) incrementCounter() {
 	}
 }
 
-// Ensure CTRBlockCipher implements BlockCipher interface
-var _ crypto.BlockCipher = (*CTRBlockCipher)(nil)
+// Destroy overwrites the IV, counter and key stream and destroys the
+// underlying cipher. The mode must be initialized again, with a key, before
+// use.
+func (c *CTRBlockCipher) Destroy() {
+	util.Clear(c.IV)
+	util.Clear(c.counter)
+	util.Clear(c.counterOut)
+	c.byteCount = 0
+	crypto.Destroy(c.cipher)
+}
+
+// Ensure CTRBlockCipher implements CheckedBlockCipherMode and Destroyable interfaces
+var _ crypto.CheckedBlockCipherMode = (*CTRBlockCipher)(nil)
+var _ crypto.Destroyable = (*CTRBlockCipher)(nil)
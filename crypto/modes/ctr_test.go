@@ -257,3 +257,35 @@ func BenchmarkCTREncrypt(b *testing.B) {
 		ctr.ProcessBlock(plaintext, 0, output, 0)
 	}
 }
+
+func TestCTRCheckedErrors(t *testing.T) {
+	key := params.NewKeyParameter(make([]byte, 16))
+	ctr := NewCTRBlockCipher(engines.NewSM4Engine())
+	block := make([]byte, 16)
+	
+	if _, err := ctr.ProcessBlockChecked(block, 0, block, 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	if err := ctr.InitChecked(true, key); err == nil {
+		t.Error("Expected error without an IV")
+	}
+	if err := ctr.InitChecked(true, params.NewParametersWithIV(key, make([]byte, 17))); err == nil {
+		t.Error("Expected error for an IV longer than the block size")
+	}
+	if err := ctr.InitChecked(true, params.NewParametersWithIV(key, make([]byte, 4))); err == nil {
+		t.Error("Expected error for an IV that is too short")
+	}
+	if err := ctr.InitChecked(true, params.NewParametersWithIV(params.NewKeyParameter(block[:8]), block)); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+	
+	if err := ctr.InitChecked(true, params.NewParametersWithIV(key, block)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := ctr.ProcessBlockChecked(block, 1, block, 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := ctr.ProcessBlockChecked(block, 0, nil, 0); err == nil {
+		t.Error("Expected error for short output")
+	}
+}
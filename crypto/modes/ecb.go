@@ -3,6 +3,7 @@ package modes
 
 import (
 	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // ECBBlockCipher implements Electronic Codebook (ECB) mode.
@@ -47,8 +48,18 @@ func (e *ECBBlockCipher) GetUnderlyingCipher() crypto.BlockCipher {
 // Parameters:
 //   - forEncryption: true for encryption, false for decryption
 //   - params: cipher parameters (typically KeyParameter)
+//
+// It panics if the parameters are invalid; see InitChecked.
 func (e *ECBBlockCipher) Init(forEncryption bool, params crypto.CipherParameters) {
-	e.cipher.Init(forEncryption, params)
+	if err := e.InitChecked(forEncryption, params); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher for encryption or decryption,
+// returning an error if the key is rejected by the underlying cipher.
+func (e *ECBBlockCipher) InitChecked(forEncryption bool, params crypto.CipherParameters) error {
+	return crypto.InitBlockCipher(e.cipher, forEncryption, params)
 }
 
 // GetAlgorithmName returns the algorithm name and mode.
@@ -65,16 +76,29 @@ func (e *ECBBlockCipher) GetBlockSize() int {
 //
 // ECB mode simply passes through to the underlying cipher with no chaining.
 // Each block is encrypted/decrypted independently.
+//
+// It panics if the cipher is not initialized or a buffer is too short; see
+// ProcessBlockChecked.
 func (e *ECBBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
-	if inOff+e.blockSize > len(in) {
-		panic("input buffer too short")
+	n, err := e.ProcessBlockChecked(in, inOff, out, outOff)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ProcessBlockChecked processes one block of data, returning an error if
+// the cipher is not initialized or a buffer is too short.
+func (e *ECBBlockCipher) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
+	if inOff < 0 || inOff+e.blockSize > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 
-	if outOff+e.blockSize > len(out) {
-		panic("output buffer too short")
+	if outOff < 0 || outOff+e.blockSize > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 
-	return e.cipher.ProcessBlock(in, inOff, out, outOff)
+	return crypto.ProcessBlock(e.cipher, in, inOff, out, outOff)
 }
 
 // Reset resets the cipher to its initial state.
@@ -82,5 +106,12 @@ func (e *ECBBlockCipher) Reset() {
 	e.cipher.Reset()
 }
 
-// Ensure ECBBlockCipher implements BlockCipher interface
-var _ crypto.BlockCipher = (*ECBBlockCipher)(nil)
+// Destroy destroys the underlying cipher. The mode must be initialized
+// again before use.
+func (e *ECBBlockCipher) Destroy() {
+	crypto.Destroy(e.cipher)
+}
+
+// Ensure ECBBlockCipher implements CheckedBlockCipherMode and Destroyable interfaces
+var _ crypto.CheckedBlockCipherMode = (*ECBBlockCipher)(nil)
+var _ crypto.Destroyable = (*ECBBlockCipher)(nil)
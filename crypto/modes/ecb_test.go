@@ -236,3 +236,25 @@ func BenchmarkECBBlockCipher_Decrypt(b *testing.B) {
 		}
 	}
 }
+
+// Test that invalid keys and buffers are reported as errors
+func TestECBBlockCipher_CheckedErrors(t *testing.T) {
+	ecb := NewECBBlockCipher(engines.NewSM4Engine())
+	block := make([]byte, 16)
+
+	if _, err := ecb.ProcessBlockChecked(block, 0, block, 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	if err := ecb.InitChecked(true, params.NewKeyParameter(make([]byte, 32))); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+	if err := ecb.InitChecked(true, params.NewKeyParameter(block)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := ecb.ProcessBlockChecked(block, 1, block, 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := ecb.ProcessBlockChecked(block, 0, block[:8], 0); err == nil {
+		t.Error("Expected error for short output")
+	}
+}
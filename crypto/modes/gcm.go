@@ -2,10 +2,11 @@
 package modes
 
 import (
-	"errors"
+	"crypto/subtle"
 
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
@@ -56,9 +57,20 @@ const gcmBlockSize = 16
 
 // NewGCMBlockCipher creates a new GCM mode cipher.
 // The cipher must have a block size of 16 bytes.
+// It panics otherwise; see NewGCMBlockCipherChecked.
 func NewGCMBlockCipher(cipher crypto.BlockCipher) *GCMBlockCipher {
+	g, err := NewGCMBlockCipherChecked(cipher)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// NewGCMBlockCipherChecked creates a new GCM mode cipher, returning an
+// error if the cipher does not have a block size of 16 bytes.
+func NewGCMBlockCipherChecked(cipher crypto.BlockCipher) (*GCMBlockCipher, error) {
 	if cipher.GetBlockSize() != gcmBlockSize {
-		panic("cipher required with a block size of 16")
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "cipher required with a block size of 16")
 	}
 
 	return &GCMBlockCipher{
@@ -70,7 +82,7 @@ func NewGCMBlockCipher(cipher crypto.BlockCipher) *GCMBlockCipher {
 		S_at:    make([]byte, gcmBlockSize),
 		bufBlock: make([]byte, gcmBlockSize),
 		atBlock:  make([]byte, gcmBlockSize),
-	}
+	}, nil
 }
 
 // GetUnderlyingCipher returns the underlying block cipher.
@@ -83,54 +95,76 @@ func (g *GCMBlockCipher) GetUnderlyingCipher() crypto.BlockCipher {
 // Parameters:
 //   - forEncryption: true for encryption, false for decryption
 //   - parameters: AEADParameters or ParametersWithIV
+//
+// It panics if the parameters are invalid; see InitChecked.
 func (g *GCMBlockCipher) Init(forEncryption bool, parameters crypto.CipherParameters) {
-	g.forEncryption = forEncryption
-	g.macBlock = nil
-	g.initialised = true
+	if err := g.InitChecked(forEncryption, parameters); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher as Init does, returning an error if
+// the MAC size or nonce is invalid, no key is given or the key is rejected
+// by the underlying cipher. The cipher cannot be used after an error.
+func (g *GCMBlockCipher) InitChecked(forEncryption bool, parameters crypto.CipherParameters) error {
+	g.initialised = false
 
 	var keyParam *params.KeyParameter
 	var newNonce []byte
+	var associatedText []byte
+	var macSize int
 
 	// Parse parameters
 	if aeadParams, ok := parameters.(*params.AEADParameters); ok {
 		newNonce = aeadParams.GetNonce()
-		g.associatedText = aeadParams.GetAssociatedText()
+		associatedText = aeadParams.GetAssociatedText()
 
 		macSizeBits := aeadParams.GetMacSize()
 		if macSizeBits < 32 || macSizeBits > 128 || macSizeBits%8 != 0 {
-			panic("Invalid value for MAC size")
+			return exceptions.New(exceptions.ErrInvalidParameter, "Invalid value for MAC size")
 		}
 
-		g.macSize = macSizeBits / 8
+		macSize = macSizeBits / 8
 		keyParam = aeadParams.GetKey()
 	} else if ivParams, ok := parameters.(*params.ParametersWithIV); ok {
 		newNonce = ivParams.GetIV()
-		g.associatedText = nil
-		g.macSize = 16
-		keyParam = ivParams.GetParameters().(*params.KeyParameter)
+		macSize = 16
+		keyParam, _ = ivParams.GetParameters().(*params.KeyParameter)
 	} else {
-		panic("invalid parameters passed to GCM")
+		return exceptions.New(exceptions.ErrInvalidParameter, "invalid parameters passed to GCM")
 	}
 
-	// Adjust buffer size
-	bufLength := gcmBlockSize
-	if !forEncryption {
-		bufLength = gcmBlockSize + g.macSize
-	}
-	g.bufBlock = make([]byte, bufLength)
-
 	if newNonce == nil || len(newNonce) < 1 {
-		panic("IV must be at least 1 byte")
+		return exceptions.New(exceptions.ErrInvalidParameter, "IV must be at least 1 byte")
 	}
 
-	g.nonce = newNonce
+	if keyParam == nil {
+		return exceptions.New(exceptions.ErrInvalidKey, "GCM cipher requires a key")
+	}
 
 	// Initialize cipher and compute H = E(K, 0)
-	g.cipher.Init(true, keyParam)
+	if err := crypto.InitBlockCipher(g.cipher, true, keyParam); err != nil {
+		return err
+	}
 	for i := range g.H {
 		g.H[i] = 0
 	}
-	g.cipher.ProcessBlock(g.H, 0, g.H, 0)
+	if _, err := crypto.ProcessBlock(g.cipher, g.H, 0, g.H, 0); err != nil {
+		return err
+	}
+
+	g.forEncryption = forEncryption
+	g.macBlock = nil
+	g.macSize = macSize
+	g.associatedText = associatedText
+	g.nonce = newNonce
+
+	// Adjust buffer size
+	bufLength := gcmBlockSize
+	if !forEncryption {
+		bufLength = gcmBlockSize + g.macSize
+	}
+	g.bufBlock = make([]byte, bufLength)
 
 	// Compute J0 from nonce
 	for i := range g.J0 {
@@ -172,6 +206,9 @@ func (g *GCMBlockCipher) Init(forEncryption bool, parameters crypto.CipherParame
 	if g.associatedText != nil && len(g.associatedText) > 0 {
 		g.processAADBytes(g.associatedText, 0, len(g.associatedText))
 	}
+
+	g.initialised = true
+	return nil
 }
 
 // GetAlgorithmName returns the algorithm name.
@@ -185,8 +222,16 @@ func (g *GCMBlockCipher) GetBlockSize() int {
 }
 
 // ProcessBlock is not supported for GCM mode (use ProcessBytes and DoFinal).
+// It always panics; see ProcessBlockChecked.
 func (g *GCMBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
-	panic("processBlock not supported for GCM mode (use ProcessBytes and DoFinal)")
+	_, err := g.ProcessBlockChecked(in, inOff, out, outOff)
+	panic(err)
+}
+
+// ProcessBlockChecked is not supported for GCM mode (use ProcessBytes and
+// DoFinal). It always returns an error.
+func (g *GCMBlockCipher) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
+	return 0, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "processBlock not supported for GCM mode (use ProcessBytes and DoFinal)")
 }
 
 // Reset resets the cipher to initial state.
@@ -225,15 +270,19 @@ func (g *GCMBlockCipher) Reset() {
 // For decryption, buffers all data for MAC verification in DoFinal.
 func (g *GCMBlockCipher) ProcessBytes(in []byte, inOff int, length int, out []byte, outOff int) (int, error) {
 	if !g.initialised {
-		return 0, errors.New("GCM cipher not initialised")
+		return 0, exceptions.New(exceptions.ErrInvalidState, "GCM cipher not initialised")
 	}
 
-	if inOff+length > len(in) {
-		return 0, errors.New("input buffer too short")
+	if inOff < 0 || length < 0 || inOff+length > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 
 	if g.forEncryption {
 		// Encryption: process immediately
+		outputLen := (g.bufOff + length) / gcmBlockSize * gcmBlockSize
+		if outOff < 0 || outOff+outputLen > len(out) {
+			return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
+		}
 		return g.encryptBytes(in, inOff, length, out, outOff), nil
 	}
 
@@ -261,7 +310,7 @@ func (g *GCMBlockCipher) ProcessBytes(in []byte, inOff int, length int, out []by
 // DoFinal completes the encryption/decryption and generates/verifies the authentication tag.
 func (g *GCMBlockCipher) DoFinal(out []byte, outOff int) (int, error) {
 	if !g.initialised {
-		return 0, errors.New("GCM cipher not initialised")
+		return 0, exceptions.New(exceptions.ErrInvalidState, "GCM cipher not initialised")
 	}
 
 	if g.forEncryption {
@@ -377,6 +426,10 @@ func (g *GCMBlockCipher) encryptBlock(block []byte, out []byte, outOff int) {
 }
 
 func (g *GCMBlockCipher) encryptDoFinal(out []byte, outOff int) (int, error) {
+	if outOff < 0 || outOff+g.bufOff+g.macSize > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
+	}
+
 	// Initialize cipher state if not done yet
 	if g.totalLength == 0 {
 		g.initCipher()
@@ -434,7 +487,11 @@ func (g *GCMBlockCipher) encryptDoFinal(out []byte, outOff int) (int, error) {
 
 func (g *GCMBlockCipher) decryptDoFinal(out []byte, outOff int) (int, error) {
 	if g.ciphertextBufferLength < g.macSize {
-		return 0, errors.New("data too short")
+		return 0, exceptions.New(exceptions.ErrInvalidCipherText, "data too short")
+	}
+
+	if outOff < 0 || outOff+g.ciphertextBufferLength-g.macSize > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 
 	// Initialize cipher state if not done yet
@@ -477,15 +534,8 @@ func (g *GCMBlockCipher) decryptDoFinal(out []byte, outOff int) (int, error) {
 	gcmXOR(expectedTag, g.S)
 
 	// Verify tag (constant-time comparison)
-	tagMatch := true
-	for i := 0; i < g.macSize; i++ {
-		if expectedTag[i] != receivedTag[i] {
-			tagMatch = false
-		}
-	}
-
-	if !tagMatch {
-		return 0, errors.New("mac check in GCM failed")
+	if subtle.ConstantTimeCompare(expectedTag[:g.macSize], receivedTag) != 1 {
+		return 0, exceptions.New(exceptions.ErrAuthenticationFailed, "mac check in GCM failed")
 	}
 
 	// MAC verified! Now decrypt all data
@@ -554,5 +604,26 @@ func (g *GCMBlockCipher) gHash(Y []byte, data []byte) {
 	}
 }
 
-// Ensure GCMBlockCipher implements BlockCipher interface
-var _ crypto.BlockCipher = (*GCMBlockCipher)(nil)
+// Destroy overwrites the hash subkey, counters, authentication state and
+// buffered data and destroys the underlying cipher. The nonce and
+// associated text belong to the caller and are only released. The mode
+// must be initialized again before use.
+func (g *GCMBlockCipher) Destroy() {
+	g.initialised = false
+	for _, b := range [][]byte{g.H, g.J0, g.counter, g.S, g.S_at, g.bufBlock, g.atBlock, g.macBlock, g.ciphertextBuffer} {
+		util.Clear(b)
+	}
+	g.nonce = nil
+	g.associatedText = nil
+	g.macBlock = nil
+	g.bufOff = 0
+	g.totalLength = 0
+	g.atBlockPos = 0
+	g.atLength = 0
+	g.ciphertextBufferLength = 0
+	crypto.Destroy(g.cipher)
+}
+
+// Ensure GCMBlockCipher implements CheckedBlockCipherMode and Destroyable interfaces
+var _ crypto.CheckedBlockCipherMode = (*GCMBlockCipher)(nil)
+var _ crypto.Destroyable = (*GCMBlockCipher)(nil)
@@ -3,10 +3,13 @@ package modes
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"testing"
 
+	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/engines"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // Test basic GCM encryption and decryption
@@ -254,8 +257,8 @@ func TestGCMBlockCipher_TamperingDetection(t *testing.T) {
 	processed2, _ := gcm2.ProcessBytes(tamperedCiphertext, 0, len(tamperedCiphertext), decrypted, 0)
 	_, err := gcm2.DoFinal(decrypted, processed2)
 
-	if err == nil {
-		t.Error("Expected MAC verification to fail with tampered ciphertext")
+	if !errors.Is(err, exceptions.ErrAuthenticationFailed) {
+		t.Errorf("Expected MAC verification to fail with tampered ciphertext, got %v", err)
 	}
 
 	t.Logf("Tampering correctly detected: %v", err)
@@ -348,3 +351,53 @@ func BenchmarkGCMBlockCipher_Decrypt(b *testing.B) {
 		gcm2.DoFinal(decrypted, processed2)
 	}
 }
+
+// Test that invalid parameters and buffers are reported as errors
+func TestGCMBlockCipher_CheckedErrors(t *testing.T) {
+	if _, err := NewGCMBlockCipherChecked(NewCFBBlockCipher(engines.NewSM4Engine(), 64)); err == nil {
+		t.Error("Expected error for a cipher with a 64 bit block size")
+	}
+
+	key := params.NewKeyParameter(make([]byte, 16))
+	nonce := make([]byte, 12)
+	gcm, err := NewGCMBlockCipherChecked(engines.NewSM4Engine())
+	if err != nil {
+		t.Fatalf("NewGCMBlockCipherChecked failed: %v", err)
+	}
+
+	invalid := map[string]crypto.CipherParameters{
+		"MAC size":    params.NewAEADParameters(key, 100, nonce, nil),
+		"empty nonce": params.NewAEADParameters(key, 128, nil, nil),
+		"no key":      params.NewAEADParameters(nil, 128, nonce, nil),
+		"IV-only":     params.NewParametersWithIV(nil, nonce),
+		"key only":    key,
+		"invalid key": params.NewParametersWithIV(params.NewKeyParameter(make([]byte, 8)), nonce),
+	}
+	for name, p := range invalid {
+		if err := gcm.InitChecked(true, p); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// A cipher whose initialization failed cannot be used
+	if _, err := gcm.ProcessBytes(make([]byte, 16), 0, 16, make([]byte, 16), 0); err == nil {
+		t.Error("Expected error after a failed initialization")
+	}
+
+	if err := gcm.InitChecked(true, params.NewAEADParameters(key, 96, nonce, nil)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := gcm.ProcessBlockChecked(nonce, 0, nonce, 0); err == nil {
+		t.Error("Expected error from ProcessBlockChecked")
+	}
+	if _, err := gcm.ProcessBytes(make([]byte, 20), 0, 20, make([]byte, 15), 0); err == nil {
+		t.Error("Expected error for short output")
+	}
+	n, err := gcm.ProcessBytes(make([]byte, 20), 0, 20, make([]byte, 16), 0)
+	if err != nil || n != 16 {
+		t.Fatalf("ProcessBytes = %d, %v", n, err)
+	}
+	if _, err := gcm.DoFinal(make([]byte, 15), 0); err == nil {
+		t.Error("Expected error for short output in DoFinal")
+	}
+}
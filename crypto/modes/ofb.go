@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // OFBBlockCipher implements Output Feedback (OFB) mode.
@@ -31,11 +33,23 @@ type OFBBlockCipher struct {
 
 // NewOFBBlockCipher creates a new OFB mode cipher.
 // blockSize is the feedback block size in bytes (must be <= cipher block size).
+// It panics if bitBlockSize is invalid; see NewOFBBlockCipherChecked.
 func NewOFBBlockCipher(cipher crypto.BlockCipher, bitBlockSize int) *OFBBlockCipher {
+	o, err := NewOFBBlockCipherChecked(cipher, bitBlockSize)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// NewOFBBlockCipherChecked creates a new OFB mode cipher, returning an
+// error if bitBlockSize is not a multiple of 8 between 8 and the cipher
+// block size in bits.
+func NewOFBBlockCipherChecked(cipher crypto.BlockCipher, bitBlockSize int) (*OFBBlockCipher, error) {
 	cipherBlockSize := cipher.GetBlockSize()
 	
 	if bitBlockSize > cipherBlockSize*8 || bitBlockSize < 8 || bitBlockSize%8 != 0 {
-		panic(fmt.Sprintf("OFB%d not supported", bitBlockSize))
+		return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "OFB%d not supported", bitBlockSize)
 	}
 	
 	blockSize := bitBlockSize / 8
@@ -48,7 +62,7 @@ func NewOFBBlockCipher(cipher crypto.BlockCipher, bitBlockSize int) *OFBBlockCip
 		ofbV:       make([]byte, cipherBlockSize),
 		ofbOutV:    make([]byte, cipherBlockSize),
 		byteCount:  0,
-	}
+	}, nil
 }
 
 // GetUnderlyingCipher returns the underlying block cipher.
@@ -58,7 +72,16 @@ func (o *OFBBlockCipher) GetUnderlyingCipher() crypto.BlockCipher {
 
 // Init initializes the cipher and possibly the IV.
 // Note: forEncryption is ignored for OFB mode since encryption and decryption are identical.
+// It panics if the parameters are invalid; see InitChecked.
 func (o *OFBBlockCipher) Init(forEncryption bool, parameters crypto.CipherParameters) {
+	if err := o.InitChecked(forEncryption, parameters); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher as Init does, returning an error if
+// the key is rejected by the underlying cipher.
+func (o *OFBBlockCipher) InitChecked(forEncryption bool, parameters crypto.CipherParameters) error {
 	ivParams, ok := parameters.(*params.ParametersWithIV)
 	if ok {
 		iv := ivParams.GetIV()
@@ -79,7 +102,7 @@ func (o *OFBBlockCipher) Init(forEncryption bool, parameters crypto.CipherParame
 		underlyingParams := ivParams.GetParameters()
 		if underlyingParams != nil {
 			// OFB always encrypts the feedback register, regardless of mode
-			o.cipher.Init(true, underlyingParams)
+			return crypto.InitBlockCipher(o.cipher, true, underlyingParams)
 		}
 	} else {
 		o.Reset()
@@ -87,9 +110,10 @@ func (o *OFBBlockCipher) Init(forEncryption bool, parameters crypto.CipherParame
 		// If it's not nil, key is to be reused
 		if parameters != nil {
 			// OFB always encrypts the feedback register
-			o.cipher.Init(true, parameters)
+			return crypto.InitBlockCipher(o.cipher, true, parameters)
 		}
 	}
+	return nil
 }
 
 // GetAlgorithmName returns the algorithm name and mode.
@@ -103,26 +127,43 @@ func (o *OFBBlockCipher) GetBlockSize() int {
 }
 
 // ProcessBlock processes a block of input.
+// It panics if the cipher is not initialized or a buffer is too short; see
+// ProcessBlockChecked.
 func (o *OFBBlockCipher) ProcessBlock(in []byte, inOff int, out []byte, outOff int) int {
-	o.processBytes(in, inOff, o.blockSize, out, outOff)
-	return o.blockSize
+	n, err := o.ProcessBlockChecked(in, inOff, out, outOff)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ProcessBlockChecked processes a block of input, returning an error if
+// the cipher is not initialized or a buffer is too short.
+func (o *OFBBlockCipher) ProcessBlockChecked(in []byte, inOff int, out []byte, outOff int) (int, error) {
+	return o.processBytes(in, inOff, o.blockSize, out, outOff)
 }
 
 // processBytes processes a stream of bytes.
-func (o *OFBBlockCipher) processBytes(in []byte, inOff int, length int, out []byte, outOff int) int {
-	if inOff+length > len(in) {
-		panic("input buffer too short")
+func (o *OFBBlockCipher) processBytes(in []byte, inOff int, length int, out []byte, outOff int) (int, error) {
+	if inOff < 0 || inOff+length > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 	
-	if outOff+length > len(out) {
-		panic("output buffer too short")
+	if outOff < 0 || outOff+length > len(out) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 	
 	for i := 0; i < length; i++ {
+		// Generate new keystream block if needed
+		if o.byteCount == 0 {
+			if _, err := crypto.ProcessBlock(o.cipher, o.ofbV, 0, o.ofbOutV, 0); err != nil {
+				return 0, err
+			}
+		}
 		out[outOff+i] = o.calculateByte(in[inOff+i])
 	}
 	
-	return length
+	return length, nil
 }
 
 // Reset resets the feedback register back to the IV and resets the underlying cipher.
@@ -139,13 +180,9 @@ func (o *OFBBlockCipher) GetCurrentIV() []byte {
 	return result
 }
 
-// calculateByte calculates a single output byte.
+// calculateByte calculates a single output byte from the current
+// keystream block.
 func (o *OFBBlockCipher) calculateByte(inByte byte) byte {
-	// Generate new keystream block if needed
-	if o.byteCount == 0 {
-		o.cipher.ProcessBlock(o.ofbV, 0, o.ofbOutV, 0)
-	}
-	
 	// XOR input with keystream
 	outByte := o.ofbOutV[o.byteCount] ^ inByte
 	o.byteCount++
@@ -164,5 +201,17 @@ func (o *OFBBlockCipher) calculateByte(inByte byte) byte {
 	return outByte
 }
 
-// Ensure OFBBlockCipher implements BlockCipher interface
-var _ crypto.BlockCipher = (*OFBBlockCipher)(nil)
+// Destroy overwrites the IV, feedback register and key stream and destroys
+// the underlying cipher. The mode must be initialized again, with a key,
+// before use.
+func (o *OFBBlockCipher) Destroy() {
+	util.Clear(o.IV)
+	util.Clear(o.ofbV)
+	util.Clear(o.ofbOutV)
+	o.byteCount = 0
+	crypto.Destroy(o.cipher)
+}
+
+// Ensure OFBBlockCipher implements CheckedBlockCipherMode and Destroyable interfaces
+var _ crypto.CheckedBlockCipherMode = (*OFBBlockCipher)(nil)
+var _ crypto.Destroyable = (*OFBBlockCipher)(nil)
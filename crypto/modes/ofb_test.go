@@ -314,3 +314,31 @@ func BenchmarkOFBEncrypt(b *testing.B) {
 		ofb.ProcessBlock(plaintext, 0, output, 0)
 	}
 }
+
+func TestOFBCheckedErrors(t *testing.T) {
+	if _, err := NewOFBBlockCipherChecked(engines.NewSM4Engine(), 7); err == nil {
+		t.Error("Expected error for OFB7")
+	}
+	
+	ofb, err := NewOFBBlockCipherChecked(engines.NewSM4Engine(), 128)
+	if err != nil {
+		t.Fatalf("NewOFBBlockCipherChecked failed: %v", err)
+	}
+	block := make([]byte, 16)
+	
+	if _, err := ofb.ProcessBlockChecked(block, 0, block, 0); err == nil {
+		t.Error("Expected error when processing without initialization")
+	}
+	if err := ofb.InitChecked(true, params.NewKeyParameter(block[:4])); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+	if err := ofb.InitChecked(true, params.NewParametersWithIV(params.NewKeyParameter(block), block)); err != nil {
+		t.Fatalf("InitChecked failed: %v", err)
+	}
+	if _, err := ofb.ProcessBlockChecked(block, 4, block, 0); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if _, err := ofb.ProcessBlockChecked(block, 0, block, 4); err == nil {
+		t.Error("Expected error for short output")
+	}
+}
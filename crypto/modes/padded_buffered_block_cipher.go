@@ -2,8 +2,9 @@
 package modes
 
 import (
-	"fmt"
 	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // PaddedBufferedBlockCipher wraps a block cipher with buffering and padding support.
@@ -28,10 +29,19 @@ func NewPaddedBufferedBlockCipher(cipher crypto.BlockCipher, padding crypto.Bloc
 }
 
 // Init initializes the cipher.
+// It panics if the parameters are invalid; see InitChecked.
 func (c *PaddedBufferedBlockCipher) Init(forEncryption bool, params crypto.CipherParameters) {
+	if err := c.InitChecked(forEncryption, params); err != nil {
+		panic(err)
+	}
+}
+
+// InitChecked initializes the cipher, returning an error if the parameters
+// are rejected by the underlying cipher.
+func (c *PaddedBufferedBlockCipher) InitChecked(forEncryption bool, params crypto.CipherParameters) error {
 	c.forEncryption = forEncryption
 	c.Reset()
-	c.cipher.Init(forEncryption, params)
+	return crypto.InitBlockCipher(c.cipher, forEncryption, params)
 }
 
 // GetBlockSize returns the block size for this cipher.
@@ -65,11 +75,18 @@ func (c *PaddedBufferedBlockCipher) GetOutputSize(length int) int {
 
 // ProcessByte processes a single byte.
 func (c *PaddedBufferedBlockCipher) ProcessByte(in byte, out []byte, outOff int) (int, error) {
+	if c.bufOff+1 == len(c.buf) && (outOff < 0 || outOff+len(c.buf) > len(out)) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
+	}
+	
 	c.buf[c.bufOff] = in
 	c.bufOff++
 	
 	if c.bufOff == len(c.buf) {
-		outLen := c.cipher.ProcessBlock(c.buf, 0, out, outOff)
+		outLen, err := crypto.ProcessBlock(c.cipher, c.buf, 0, out, outOff)
+		if err != nil {
+			return 0, err
+		}
 		c.bufOff = 0
 		return outLen, nil
 	}
@@ -80,14 +97,18 @@ func (c *PaddedBufferedBlockCipher) ProcessByte(in byte, out []byte, outOff int)
 // ProcessBytes processes multiple bytes.
 func (c *PaddedBufferedBlockCipher) ProcessBytes(in []byte, inOff int, length int, out []byte, outOff int) (int, error) {
 	if length < 0 {
-		return 0, fmt.Errorf("invalid length: %d", length)
+		return 0, exceptions.Newf(exceptions.ErrDataLength, "invalid length: %d", length)
+	}
+	
+	if inOff < 0 || inOff+length > len(in) {
+		return 0, exceptions.New(exceptions.ErrDataLength, "input buffer too short")
 	}
 	
 	blockSize := c.GetBlockSize()
 	outputLen := c.GetUpdateOutputSize(length)
 	
-	if outputLen > 0 && outOff+outputLen > len(out) {
-		return 0, fmt.Errorf("output buffer too short")
+	if outputLen > 0 && (outOff < 0 || outOff+outputLen > len(out)) {
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
 	}
 	
 	totalLen := 0
@@ -97,14 +118,22 @@ func (c *PaddedBufferedBlockCipher) ProcessBytes(in []byte, inOff int, length in
 		// Fill the buffer
 		copy(c.buf[c.bufOff:], in[inOff:inOff+gapLen])
 		
-		totalLen += c.cipher.ProcessBlock(c.buf, 0, out, outOff)
+		n, err := crypto.ProcessBlock(c.cipher, c.buf, 0, out, outOff)
+		if err != nil {
+			return 0, err
+		}
+		totalLen += n
 		c.bufOff = 0
 		length -= gapLen
 		inOff += gapLen
 		
 		// Process full blocks
 		for length > blockSize {
-			totalLen += c.cipher.ProcessBlock(in, inOff, out, outOff+totalLen)
+			n, err := crypto.ProcessBlock(c.cipher, in, inOff, out, outOff+totalLen)
+			if err != nil {
+				return totalLen, err
+			}
+			totalLen += n
 			length -= blockSize
 			inOff += blockSize
 		}
@@ -123,14 +152,23 @@ func (c *PaddedBufferedBlockCipher) DoFinal(out []byte, outOff int) (int, error)
 	totalLen := 0
 	
 	if c.forEncryption {
+		outputLen := blockSize
+		if c.bufOff == blockSize {
+			outputLen = 2 * blockSize
+		}
+		if outOff < 0 || outOff+outputLen > len(out) {
+			return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
+		}
+		
 		// Add padding
 		if c.bufOff == blockSize {
 			// Buffer is full, process it first
-			if outOff+2*blockSize > len(out) {
-				return 0, fmt.Errorf("output buffer too short")
+			n, err := crypto.ProcessBlock(c.cipher, c.buf, 0, out, outOff)
+			if err != nil {
+				c.Reset()
+				return 0, err
 			}
-			
-			totalLen = c.cipher.ProcessBlock(c.buf, 0, out, outOff)
+			totalLen = n
 			c.bufOff = 0
 		}
 		
@@ -138,29 +176,42 @@ func (c *PaddedBufferedBlockCipher) DoFinal(out []byte, outOff int) (int, error)
 		c.padding.AddPadding(c.buf, c.bufOff)
 		
 		// Process the final padded block
-		totalLen += c.cipher.ProcessBlock(c.buf, 0, out, outOff+totalLen)
+		n, err := crypto.ProcessBlock(c.cipher, c.buf, 0, out, outOff+totalLen)
 		c.Reset()
+		if err != nil {
+			return 0, err
+		}
 		
-		return totalLen, nil
+		return totalLen + n, nil
 	}
 	
 	// Decryption
 	if c.bufOff == blockSize {
-		totalLen = c.cipher.ProcessBlock(c.buf, 0, c.buf, 0)
+		n, err := crypto.ProcessBlock(c.cipher, c.buf, 0, c.buf, 0)
+		if err != nil {
+			c.Reset()
+			return 0, err
+		}
+		totalLen = n
 		c.bufOff = 0
 	} else {
 		c.Reset()
-		return 0, fmt.Errorf("last block incomplete in decryption")
+		return 0, exceptions.New(exceptions.ErrDataLength, "last block incomplete in decryption")
 	}
 	
-	// Remove padding
+	// Remove padding. The error does not say what is wrong with the
+	// padding, so that it cannot be used as a padding oracle.
 	padCount, err := c.padding.PadCount(c.buf)
 	if err != nil {
 		c.Reset()
-		return 0, fmt.Errorf("pad block corrupted: %v", err)
+		return 0, exceptions.New(exceptions.ErrInvalidPadding, "pad block corrupted")
 	}
 	
 	totalLen -= padCount
+	if outOff < 0 || outOff+totalLen > len(out) {
+		c.Reset()
+		return 0, exceptions.New(exceptions.ErrOutputLength, "output buffer too short")
+	}
 	copy(out[outOff:], c.buf[:totalLen])
 	c.Reset()
 	
@@ -180,3 +231,15 @@ func (c *PaddedBufferedBlockCipher) Reset() {
 func (c *PaddedBufferedBlockCipher) GetAlgorithmName() string {
 	return c.cipher.GetAlgorithmName() + "/Padded"
 }
+
+// Destroy overwrites the buffered data and destroys the underlying cipher.
+// The cipher must be initialized again before use.
+func (p *PaddedBufferedBlockCipher) Destroy() {
+	util.Clear(p.buf)
+	p.bufOff = 0
+	crypto.Destroy(p.cipher)
+}
+
+// Ensure PaddedBufferedBlockCipher implements CheckedBufferedBlockCipher and Destroyable interfaces
+var _ crypto.CheckedBufferedBlockCipher = (*PaddedBufferedBlockCipher)(nil)
+var _ crypto.Destroyable = (*PaddedBufferedBlockCipher)(nil)
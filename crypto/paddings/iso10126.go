@@ -2,9 +2,9 @@ package paddings
 
 import (
 	"crypto/rand"
-	"fmt"
 
 	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // ISO10126Padding implements ISO 10126 padding scheme
@@ -53,7 +53,7 @@ func (p *ISO10126Padding) PadCount(input []byte) (int, error) {
 
 	// Validate padding count
 	if count < 1 || count > len(input) {
-		return 0, fmt.Errorf("pad block corrupted: invalid padding length %d", count)
+		return 0, exceptions.New(exceptions.ErrInvalidPadding, "pad block corrupted")
 	}
 
 	return count, nil
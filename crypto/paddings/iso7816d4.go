@@ -1,9 +1,10 @@
 package paddings
 
 import (
-	"fmt"
+	"crypto/subtle"
 
 	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // ISO7816d4Padding implements ISO 7816-4 padding scheme
@@ -45,21 +46,31 @@ func (p *ISO7816d4Padding) AddPadding(input []byte, inOff int) int {
 }
 
 // PadCount returns the number of padding bytes in the input
-// Looks for the 0x80 byte working backwards from the end
+// Looks for the 0x80 byte working backwards from the end. All bytes of the
+// block are checked in constant time, and the error does not tell which
+// check failed.
 func (p *ISO7816d4Padding) PadCount(input []byte) (int, error) {
-	count := len(input) - 1
-
-	// Find the 0x80 byte
-	for count > 0 && input[count] == 0x00 {
-		count--
+	if len(input) == 0 {
+		return 0, exceptions.New(exceptions.ErrDataLength, "empty block")
 	}
 
-	// Verify it's the 0x80 marker
-	if input[count] != 0x80 {
-		return 0, fmt.Errorf("pad block corrupted: expected 0x80, got 0x%02x", input[count])
+	count := 0
+	found := 0
+	bad := 0
+	for i := len(input) - 1; i >= 0; i-- {
+		// Before the 0x80 marker is found, every byte must be 0x00
+		notFound := 1 ^ found
+		isMarker := subtle.ConstantTimeByteEq(input[i], 0x80)
+		isZero := subtle.ConstantTimeByteEq(input[i], 0x00)
+		bad |= notFound & (1 ^ isMarker) & (1 ^ isZero)
+		count += notFound
+		found |= notFound & isMarker
 	}
 
-	return len(input) - count, nil
+	if bad|(1^found) != 0 {
+		return 0, exceptions.New(exceptions.ErrInvalidPadding, "pad block corrupted")
+	}
+	return count, nil
 }
 
 // Ensure ISO7816d4Padding implements BlockCipherPadding
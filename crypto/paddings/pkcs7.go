@@ -2,8 +2,10 @@
 package paddings
 
 import (
-	"errors"
+	"crypto/subtle"
+
 	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // PKCS7Padding implements PKCS#7 padding scheme.
@@ -41,26 +43,28 @@ func (p *PKCS7Padding) AddPadding(in []byte, inOff int) int {
 }
 
 // PadCount returns the number of pad bytes in the block.
+// All bytes of the block are checked in constant time, and the error does
+// not tell which check failed.
 func (p *PKCS7Padding) PadCount(in []byte) (int, error) {
 	blockLen := len(in)
 	if blockLen == 0 {
-		return 0, errors.New("empty block")
+		return 0, exceptions.New(exceptions.ErrDataLength, "empty block")
 	}
 	
-	paddingLen := int(in[blockLen-1])
+	countByte := in[blockLen-1]
+	paddingLen := int(countByte)
 	
-	// Validate padding length
-	if paddingLen < 1 || paddingLen > blockLen {
-		return 0, errors.New("invalid padding length")
+	// The padding length must be in [1, blockLen] and the last paddingLen
+	// bytes must all equal it
+	bad := subtle.ConstantTimeEq(int32(paddingLen), 0) | subtle.ConstantTimeLessOrEq(blockLen+1, paddingLen)
+	for i := 0; i < blockLen; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(blockLen-i, paddingLen)
+		bad |= inPadding &^ subtle.ConstantTimeByteEq(in[i], countByte)
 	}
 	
-	// Verify all padding bytes are correct
-	for i := blockLen - paddingLen; i < blockLen; i++ {
-		if in[i] != byte(paddingLen) {
-			return 0, errors.New("invalid padding bytes")
-		}
+	if bad != 0 {
+		return 0, exceptions.New(exceptions.ErrInvalidPadding, "pad block corrupted")
 	}
-	
 	return paddingLen, nil
 }
 
@@ -1,7 +1,10 @@
 package paddings
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 func TestPKCS7GetPaddingName(t *testing.T) {
@@ -104,8 +107,8 @@ func TestPKCS7PadCount(t *testing.T) {
 			padCount, err := padding.PadCount(tc.block)
 			
 			if tc.shouldError {
-				if err == nil {
-					t.Errorf("Expected error but got none")
+				if !errors.Is(err, exceptions.ErrInvalidPadding) {
+					t.Errorf("Expected exceptions.ErrInvalidPadding, got %v", err)
 				}
 			} else {
 				if err != nil {
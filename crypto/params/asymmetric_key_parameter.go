@@ -26,3 +26,10 @@ func NewBaseAsymmetricKeyParameter(privateKey bool) *BaseAsymmetricKeyParameter
 func (p *BaseAsymmetricKeyParameter) IsPrivate() bool {
 	return p.privateKey
 }
+
+// IsCipherParameters implements the CipherParameters marker interface.
+func (p *BaseAsymmetricKeyParameter) IsCipherParameters() bool {
+	return true
+}
+
+var _ AsymmetricKeyParameter = (*BaseAsymmetricKeyParameter)(nil)
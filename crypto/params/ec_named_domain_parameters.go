@@ -0,0 +1,37 @@
+package params
+
+import (
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// NewECDomainParametersFromCurve returns the domain parameters of a curve
+// whose base point, order and cofactor are set, such as a curve from the
+// ec registry.
+func NewECDomainParametersFromCurve(curve *ec.Curve) *ECDomainParameters {
+	return NewECDomainParameters(curve, curve.GetG(), curve.GetOrder(), big.NewInt(int64(curve.GetCofactor())), nil)
+}
+
+// NewECDomainParametersByName returns the domain parameters of a named
+// curve from the ec registry, e.g. ec.SM2P256V1 or ec.SM2TestFp256.
+// Based on: org.bouncycastle.crypto.params.ECNamedDomainParameters
+func NewECDomainParametersByName(name string) (*ECDomainParameters, error) {
+	curve := ec.GetNamedCurve(name)
+	if curve == nil {
+		return nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "unknown curve: "+name)
+	}
+	return NewECDomainParametersFromCurve(curve), nil
+}
+
+// NewECDomainParametersByOID returns the domain parameters of the curve
+// registered under oid.
+func NewECDomainParametersByOID(oid asn1.ObjectIdentifier) (*ECDomainParameters, error) {
+	curve := ec.GetNamedCurveByOID(oid)
+	if curve == nil {
+		return nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "unknown curve OID: "+oid.String())
+	}
+	return NewECDomainParametersFromCurve(curve), nil
+}
@@ -3,6 +3,8 @@ package params
 
 import (
 	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // ECPrivateKeyParameters represents EC private key parameters.
@@ -24,3 +26,10 @@ func NewECPrivateKeyParameters(d *big.Int, parameters *ECDomainParameters) *ECPr
 func (p *ECPrivateKeyParameters) GetD() *big.Int {
 	return p.d
 }
+
+// Destroy overwrites d, which is the *big.Int passed to
+// NewECPrivateKeyParameters. Signers and engines initialized with the
+// parameters hold their own copy and are not affected.
+func (p *ECPrivateKeyParameters) Destroy() {
+	util.ClearBigInt(p.d)
+}
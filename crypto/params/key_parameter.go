@@ -1,7 +1,10 @@
 // Package params provides cryptographic parameter types.
 package params
 
-import "github.com/lihongjie0209/sm-go-bc/crypto"
+import (
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
 
 // KeyParameter holds a symmetric key.
 // Reference: org.bouncycastle.crypto.params.KeyParameter
@@ -27,5 +30,11 @@ func (kp *KeyParameter) IsCipherParameters() bool {
 	return true
 }
 
-// Ensure KeyParameter implements CipherParameters
+// Destroy overwrites the key bytes.
+func (kp *KeyParameter) Destroy() {
+	util.Clear(kp.key)
+}
+
+// Ensure KeyParameter implements CipherParameters and Destroyable
 var _ crypto.CipherParameters = (*KeyParameter)(nil)
+var _ crypto.Destroyable = (*KeyParameter)(nil)
@@ -38,4 +38,6 @@ func (p *ParametersWithRandom) GetRandom() io.Reader {
 }
 
 // IsCipherParameters is a marker method to indicate this type implements CipherParameters.
-func (p *ParametersWithRandom) IsCipherParameters() {}
+func (p *ParametersWithRandom) IsCipherParameters() bool {
+	return true
+}
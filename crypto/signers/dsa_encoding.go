@@ -0,0 +1,16 @@
+package signers
+
+import (
+	"math/big"
+)
+
+// DSAEncoding converts a DSA-style signature (r, s) to and from bytes.
+// Based on: org.bouncycastle.crypto.signers.DSAEncoding
+type DSAEncoding interface {
+	// Decode parses a signature for the group order n. Range checks on r
+	// and s against n are left to the signer.
+	Decode(n *big.Int, encoding []byte) (r, s *big.Int, err error)
+
+	// Encode returns the encoding of (r, s) for the group order n.
+	Encode(n, r, s *big.Int) ([]byte, error)
+}
@@ -0,0 +1,75 @@
+package signers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+)
+
+func TestStandardDSAEncoding(t *testing.T) {
+	n := sm2.GetN()
+	r := fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")
+	s := fromHex("1234")
+
+	encoding := StandardDSAEncoding{}
+	der, err := encoding.Encode(n, r, s)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected, _ := hex.DecodeString("3027022100F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B302021234")
+	if !bytes.Equal(der, expected) {
+		t.Errorf("Encoding mismatch\nExpected: %X\nGot:      %X", expected, der)
+	}
+
+	r2, s2, err := encoding.Decode(n, der)
+	if err != nil || r2.Cmp(r) != 0 || s2.Cmp(s) != 0 {
+		t.Errorf("Round trip failed: %v", err)
+	}
+
+	// Non-canonical and truncated encodings are rejected
+	for name, bad := range map[string]string{
+		"leading zero":  "302802220000F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B302021234",
+		"negative r":    "30260220F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B302021234",
+		"trailing data": "3027022100F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B30202123400",
+		"missing s":     "3023022100F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3",
+	} {
+		b, _ := hex.DecodeString(bad)
+		if _, _, err := encoding.Decode(n, b); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := encoding.Encode(n, n, s); err == nil {
+		t.Error("Expected error encoding r = n")
+	}
+}
+
+func TestPlainDSAEncoding(t *testing.T) {
+	n := sm2.GetN()
+	r := fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")
+	s := big.NewInt(0x1234)
+
+	encoding := PlainDSAEncoding{}
+	plain, err := encoding.Encode(n, r, s)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(plain) != 64 || !bytes.Equal(plain[:32], r.Bytes()) || plain[62] != 0x12 || plain[63] != 0x34 {
+		t.Errorf("Unexpected encoding: %X", plain)
+	}
+
+	r2, s2, err := encoding.Decode(n, plain)
+	if err != nil || r2.Cmp(r) != 0 || s2.Cmp(s) != 0 {
+		t.Errorf("Round trip failed: %v", err)
+	}
+
+	if _, _, err := encoding.Decode(n, plain[:63]); err == nil {
+		t.Error("Expected error for short encoding")
+	}
+	if _, err := encoding.Encode(n, r, n); err == nil {
+		t.Error("Expected error encoding s = n")
+	}
+}
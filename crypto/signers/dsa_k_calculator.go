@@ -0,0 +1,24 @@
+package signers
+
+import (
+	"io"
+	"math/big"
+)
+
+// DSAKCalculator produces the per-signature nonce k for DSA-style signers.
+// Based on: org.bouncycastle.crypto.signers.DSAKCalculator
+type DSAKCalculator interface {
+	// IsDeterministic reports whether k is derived from the key and message
+	// (InitDeterministic) rather than drawn from a random source (Init).
+	IsDeterministic() bool
+
+	// Init prepares a non-deterministic calculator for the order n.
+	Init(n *big.Int, random io.Reader)
+
+	// InitDeterministic prepares a deterministic calculator for the order n,
+	// private key d and message hash.
+	InitDeterministic(n, d *big.Int, message []byte)
+
+	// NextK returns the next candidate for k in [1, n-1].
+	NextK() (*big.Int, error)
+}
@@ -0,0 +1,159 @@
+package signers
+
+import (
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/macs"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// HMacDSAKCalculator derives k deterministically from the private key and
+// message hash as described in RFC 6979 section 3.2, so that signing never
+// depends on the quality of a random number generator. With SM2 the HMAC is
+// normally built on SM3.
+//
+// A hedged calculator additionally mixes fresh random bytes into the seed
+// (RFC 6979 section 3.6). Its signatures are no longer reproducible, but k
+// stays secret even if the random source is weak.
+// Based on: org.bouncycastle.crypto.signers.HMacDSAKCalculator
+type HMacDSAKCalculator struct {
+	hMac   *macs.HMac
+	k      []byte
+	v      []byte
+	n      *big.Int
+	random io.Reader
+	err    error
+}
+
+// NewHMacDSAKCalculator creates a deterministic k calculator using HMAC
+// over the given digest, e.g. digests.NewSM3Digest().
+func NewHMacDSAKCalculator(digest crypto.Digest) *HMacDSAKCalculator {
+	hMac := macs.NewHMac(digest)
+	return &HMacDSAKCalculator{
+		hMac: hMac,
+		k:    make([]byte, hMac.GetMacSize()),
+		v:    make([]byte, hMac.GetMacSize()),
+	}
+}
+
+// NewHedgedHMacDSAKCalculator creates a k calculator that mixes bytes read
+// from random into the deterministic seed for every signature.
+func NewHedgedHMacDSAKCalculator(digest crypto.Digest, random io.Reader) *HMacDSAKCalculator {
+	c := NewHMacDSAKCalculator(digest)
+	c.random = random
+	return c
+}
+
+// IsDeterministic returns true: the signer initialises the calculator with
+// the private key and message.
+func (c *HMacDSAKCalculator) IsDeterministic() bool {
+	return true
+}
+
+// Init is not supported and panics.
+func (c *HMacDSAKCalculator) Init(n *big.Int, random io.Reader) {
+	panic("HMacDSAKCalculator: random initialisation not supported")
+}
+
+// InitDeterministic seeds the calculator from the order n, private key d and
+// message hash.
+func (c *HMacDSAKCalculator) InitDeterministic(n, d *big.Int, message []byte) {
+	c.n = n
+	c.err = nil
+	for i := range c.v {
+		c.v[i] = 0x01
+		c.k[i] = 0x00
+	}
+
+	size := (n.BitLen() + 7) / 8
+	x := d.FillBytes(make([]byte, size))
+
+	mInt := c.bitsToInt(message)
+	if mInt.Cmp(n) >= 0 {
+		mInt.Sub(mInt, n)
+	}
+	m := mInt.FillBytes(make([]byte, size))
+
+	var extra []byte
+	if c.random != nil {
+		extra = make([]byte, size)
+		if _, err := io.ReadFull(c.random, extra); err != nil {
+			util.Clear(x)
+			c.err = err
+			return
+		}
+	}
+
+	// K = HMAC_K(V || 0x00 || x || m || extra), V = HMAC_K(V)
+	c.updateKey(0x00, x, m, extra)
+	// K = HMAC_K(V || 0x01 || x || m || extra), V = HMAC_K(V)
+	c.updateKey(0x01, x, m, extra)
+
+	util.Clear(x)
+	util.Clear(extra)
+}
+
+// NextK returns the next candidate for k in [1, n-1].
+func (c *HMacDSAKCalculator) NextK() (*big.Int, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	t := make([]byte, (c.n.BitLen()+7)/8)
+	for {
+		for off := 0; off < len(t); off += len(c.v) {
+			c.hMac.UpdateArray(c.v, 0, len(c.v))
+			c.hMac.DoFinal(c.v, 0)
+			copy(t[off:], c.v)
+		}
+
+		k := c.bitsToInt(t)
+		if k.Sign() > 0 && k.Cmp(c.n) < 0 {
+			util.Clear(t)
+			return k, nil
+		}
+
+		// K = HMAC_K(V || 0x00), V = HMAC_K(V)
+		c.updateKey(0x00)
+	}
+}
+
+// updateKey sets K = HMAC_K(V || sep || data...) and then V = HMAC_K(V).
+func (c *HMacDSAKCalculator) updateKey(sep byte, data ...[]byte) {
+	key := params.NewKeyParameter(c.k)
+	c.hMac.Init(key)
+	key.Destroy()
+	c.hMac.UpdateArray(c.v, 0, len(c.v))
+	c.hMac.Update(sep)
+	for _, b := range data {
+		c.hMac.UpdateArray(b, 0, len(b))
+	}
+	c.hMac.DoFinal(c.k, 0)
+
+	key = params.NewKeyParameter(c.k)
+	c.hMac.Init(key)
+	key.Destroy()
+	c.hMac.UpdateArray(c.v, 0, len(c.v))
+	c.hMac.DoFinal(c.v, 0)
+}
+
+// Destroy overwrites K, V and the HMAC state. The calculator must be
+// initialised again before use.
+func (c *HMacDSAKCalculator) Destroy() {
+	util.Clear(c.k)
+	util.Clear(c.v)
+	c.hMac.Destroy()
+}
+
+// bitsToInt interprets t as a big-endian integer and keeps its leftmost
+// qlen bits, where qlen is the bit length of n.
+func (c *HMacDSAKCalculator) bitsToInt(t []byte) *big.Int {
+	v := new(big.Int).SetBytes(t)
+	if excess := len(t)*8 - c.n.BitLen(); excess > 0 {
+		v.Rsh(v, uint(excess))
+	}
+	return v
+}
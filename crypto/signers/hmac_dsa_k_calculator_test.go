@@ -0,0 +1,165 @@
+package signers
+
+import (
+	"bytes"
+	gosha256 "crypto/sha256"
+	"encoding"
+	"hash"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+)
+
+// sha256Digest adapts crypto/sha256 to crypto.Digest and crypto.Memoable so
+// the calculator can be checked against the RFC 6979 test vectors.
+type sha256Digest struct {
+	h hash.Hash
+}
+
+func newSHA256Digest() *sha256Digest                      { return &sha256Digest{h: gosha256.New()} }
+func (d *sha256Digest) GetAlgorithmName() string          { return "SHA-256" }
+func (d *sha256Digest) GetDigestSize() int                { return gosha256.Size }
+func (d *sha256Digest) GetByteLength() int                { return gosha256.BlockSize }
+func (d *sha256Digest) Update(in byte)                    { d.h.Write([]byte{in}) }
+func (d *sha256Digest) BlockUpdate(in []byte, off, n int) { d.h.Write(in[off : off+n]) }
+func (d *sha256Digest) Reset()                            { d.h.Reset() }
+func (d *sha256Digest) DoFinal(out []byte, off int) int {
+	copy(out[off:], d.h.Sum(nil))
+	d.h.Reset()
+	return gosha256.Size
+}
+
+func (d *sha256Digest) Copy() crypto.Memoable {
+	c := newSHA256Digest()
+	c.ResetMemoable(d)
+	return c
+}
+
+func (d *sha256Digest) ResetMemoable(other crypto.Memoable) {
+	state, _ := other.(*sha256Digest).h.(encoding.BinaryMarshaler).MarshalBinary()
+	d.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+}
+
+// TestHMacDSAKCalculatorRFC6979 checks the k values of RFC 6979 appendix
+// A.2.5 (ECDSA, P-256, SHA-256).
+func TestHMacDSAKCalculatorRFC6979(t *testing.T) {
+	q := fromHex("FFFFFFFF00000000FFFFFFFFFFFFFFFFBCE6FAADA7179E84F3B9CAC2FC632551")
+	x := fromHex("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+
+	for _, tc := range []struct {
+		message  string
+		expected string
+	}{
+		{"sample", "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60"},
+		{"test", "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0"},
+	} {
+		h := gosha256.Sum256([]byte(tc.message))
+
+		calc := NewHMacDSAKCalculator(newSHA256Digest())
+		calc.InitDeterministic(q, x, h[:])
+		k, err := calc.NextK()
+		if err != nil {
+			t.Fatalf("NextK failed: %v", err)
+		}
+		if k.Cmp(fromHex(tc.expected)) != 0 {
+			t.Errorf("%s: expected k=%s, got %X", tc.message, tc.expected, k)
+		}
+	}
+}
+
+func TestSM2SignerDeterministic(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	pubKey := sm2.GetG().Multiply(privKey)
+
+	sign := func(message []byte) []byte {
+		signer := NewSM2SignerWithKCalculator(NewHMacDSAKCalculator(digests.NewSM3Digest()))
+		if err := signer.Init(true, privateKeyParams(privKey)); err != nil {
+			t.Fatalf("Failed to init signer: %v", err)
+		}
+		signer.BlockUpdate(message, 0, len(message))
+		signature, err := signer.GenerateSignature()
+		if err != nil {
+			t.Fatalf("Failed to generate signature: %v", err)
+		}
+		return signature
+	}
+
+	sig1 := sign([]byte("message digest"))
+	sig2 := sign([]byte("message digest"))
+	sig3 := sign([]byte("another message"))
+
+	if !bytes.Equal(sig1, sig2) {
+		t.Error("Deterministic signatures of the same message differ")
+	}
+	if bytes.Equal(sig1, sig3) {
+		t.Error("Deterministic signatures of different messages are equal")
+	}
+
+	verifier := NewSM2Signer()
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	message := []byte("message digest")
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(sig1); err != nil || !valid {
+		t.Errorf("Deterministic signature failed verification: %v", err)
+	}
+}
+
+func TestSM2SignerHedged(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	pubKey := sm2.GetG().Multiply(privKey)
+	message := []byte("message digest")
+
+	sign := func(entropy []byte) []byte {
+		calc := NewHedgedHMacDSAKCalculator(digests.NewSM3Digest(), bytes.NewReader(entropy))
+		signer := NewSM2SignerWithKCalculator(calc)
+		_ = signer.Init(true, privateKeyParams(privKey))
+		signer.BlockUpdate(message, 0, len(message))
+		signature, err := signer.GenerateSignature()
+		if err != nil {
+			t.Fatalf("Failed to generate signature: %v", err)
+		}
+		return signature
+	}
+
+	sigA := sign(bytes.Repeat([]byte{0xA5}, 32))
+	sigB := sign(bytes.Repeat([]byte{0x5A}, 32))
+	if bytes.Equal(sigA, sigB) {
+		t.Error("Hedged signatures with different entropy are equal")
+	}
+	if !bytes.Equal(sigA, sign(bytes.Repeat([]byte{0xA5}, 32))) {
+		t.Error("Hedged signatures with the same entropy differ")
+	}
+
+	verifier := NewSM2Signer()
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(sigA); err != nil || !valid {
+		t.Errorf("Hedged signature failed verification: %v", err)
+	}
+
+	// Entropy read failures are reported rather than silently ignored
+	calc := NewHedgedHMacDSAKCalculator(digests.NewSM3Digest(), bytes.NewReader(nil))
+	calc.InitDeterministic(sm2.GetN(), privKey, make([]byte, 32))
+	if _, err := calc.NextK(); err == nil {
+		t.Error("Expected error from exhausted entropy source")
+	}
+}
+
+func TestHMacDSAKCalculatorRange(t *testing.T) {
+	// A tiny order forces rejection of candidates outside [1, n-1]
+	n := big.NewInt(7)
+	calc := NewHMacDSAKCalculator(digests.NewSM3Digest())
+	calc.InitDeterministic(n, big.NewInt(3), []byte{0x42})
+	for i := 0; i < 20; i++ {
+		k, err := calc.NextK()
+		if err != nil {
+			t.Fatalf("NextK failed: %v", err)
+		}
+		if k.Sign() <= 0 || k.Cmp(n) >= 0 {
+			t.Fatalf("k out of range: %v", k)
+		}
+	}
+}
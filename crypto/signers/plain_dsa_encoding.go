@@ -0,0 +1,36 @@
+package signers
+
+import (
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// PlainDSAEncoding encodes a signature as r || s, each as an unsigned
+// big-endian integer padded to the byte length of the group order; for
+// SM2 this is 64 bytes.
+// Based on: org.bouncycastle.crypto.signers.PlainDSAEncoding
+type PlainDSAEncoding struct{}
+
+// Decode splits a fixed-length r || s encoding.
+func (PlainDSAEncoding) Decode(n *big.Int, encoding []byte) (*big.Int, *big.Int, error) {
+	valueLength := (n.BitLen() + 7) / 8
+	if len(encoding) != 2*valueLength {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid plain signature length")
+	}
+	r := new(big.Int).SetBytes(encoding[:valueLength])
+	s := new(big.Int).SetBytes(encoding[valueLength:])
+	return r, s, nil
+}
+
+// Encode writes r and s as fixed-length big-endian integers.
+func (PlainDSAEncoding) Encode(n, r, s *big.Int) ([]byte, error) {
+	valueLength := (n.BitLen() + 7) / 8
+	if r.Sign() < 0 || r.Cmp(n) >= 0 || s.Sign() < 0 || s.Cmp(n) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "value out of range")
+	}
+	encoding := make([]byte, 2*valueLength)
+	r.FillBytes(encoding[:valueLength])
+	s.FillBytes(encoding[valueLength:])
+	return encoding, nil
+}
@@ -0,0 +1,60 @@
+package signers
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+)
+
+// RandomDSAKCalculator draws k uniformly from [1, n-1] by rejection sampling
+// on bytes read from a random source. A deterministic reader therefore
+// reproduces a chosen k exactly, which is how the GM/T 0003 worked examples
+// are checked.
+// Based on: org.bouncycastle.crypto.signers.RandomDSAKCalculator
+type RandomDSAKCalculator struct {
+	n      *big.Int
+	random io.Reader
+}
+
+// NewRandomDSAKCalculator creates a random k calculator.
+func NewRandomDSAKCalculator() *RandomDSAKCalculator {
+	return &RandomDSAKCalculator{}
+}
+
+// IsDeterministic returns false.
+func (c *RandomDSAKCalculator) IsDeterministic() bool {
+	return false
+}
+
+// Init sets the order and random source. If random is nil,
+// crypto/rand.Reader is used.
+func (c *RandomDSAKCalculator) Init(n *big.Int, random io.Reader) {
+	if random == nil {
+		random = rand.Reader
+	}
+	c.n = n
+	c.random = random
+}
+
+// InitDeterministic is not supported and panics.
+func (c *RandomDSAKCalculator) InitDeterministic(n, d *big.Int, message []byte) {
+	panic("RandomDSAKCalculator: deterministic initialisation not supported")
+}
+
+// NextK returns a random k in [1, n-1].
+func (c *RandomDSAKCalculator) NextK() (*big.Int, error) {
+	buf := make([]byte, (c.n.BitLen()+7)/8)
+	excess := uint(len(buf)*8 - c.n.BitLen())
+
+	for {
+		if _, err := io.ReadFull(c.random, buf); err != nil {
+			return nil, err
+		}
+		buf[0] &= byte(0xFF >> excess)
+
+		k := new(big.Int).SetBytes(buf)
+		if k.Sign() > 0 && k.Cmp(c.n) < 0 {
+			return k, nil
+		}
+	}
+}
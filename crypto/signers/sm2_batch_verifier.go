@@ -0,0 +1,403 @@
+package signers
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+)
+
+// sm2BatchSize is the largest number of recoverable signatures combined
+// into one multi-scalar multiplication, which bounds the memory of its
+// tables.
+const sm2BatchSize = 128
+
+// sm2BatchGroupSize is the number of DER or plain signatures combined into
+// one multi-scalar multiplication. Such a signature only fixes the x
+// coordinate of R = [s]G + [t]P, so each group is checked against all
+// 2^(size-1) sign patterns of its R points; small groups keep that search
+// cheap.
+const sm2BatchGroupSize = 4
+
+// sm2BatchRandomizerBits is the size of the random coefficients of the
+// linear combination. A batch containing an invalid signature passes with
+// probability at most 2^-sm2BatchRandomizerBits, or
+// 2^(sm2BatchGroupSize-1-sm2BatchRandomizerBits) for a group.
+const sm2BatchRandomizerBits = 128
+
+// SM2BatchEntry is one signature to be checked by SM2BatchVerifier.
+type SM2BatchEntry struct {
+	PublicKey *ec.Point
+	// UserID is the signer's ID; nil means sm2.DefaultUserID.
+	UserID  []byte
+	Message []byte
+	// Signature is encoded with the verifier's DSAEncoding, or is the
+	// recoverable form r || s || v returned by SM2Signer.SignRecoverable
+	// if Recoverable is set.
+	Signature   []byte
+	Recoverable bool
+}
+
+// SM2BatchVerifier verifies many SM2 signatures together. The verification
+// equations R_i = [s_i]G + [t_i]P_i are combined with random coefficients
+// a_i into [Σ a_i*s_i]G + Σ[a_i*t_i]P_i = Σ[a_i]R_i, which is evaluated
+// with a single multi-scalar multiplication.
+//
+// A DER or plain signature only fixes the x coordinate of R, so these are
+// checked in groups of sm2BatchGroupSize against every choice of signs of
+// the R_i, and a group that fails is verified one signature at a time. The
+// recovery id v of a recoverable signature fixes R, so these are checked
+// in batches of up to sm2BatchSize, and a batch that fails is split.
+type SM2BatchVerifier struct {
+	domain   *params.ECDomainParameters
+	digest   crypto.Digest
+	encoding DSAEncoding
+	random   io.Reader
+}
+
+// sm2BatchItem is a decoded signature awaiting the batch check.
+type sm2BatchItem struct {
+	index int
+	eHash []byte
+	pub   *ec.Point
+	s, t  *big.Int
+	// r is R rebuilt from r, e and v for a recoverable signature, and the
+	// candidate for R with even y otherwise; nil if x1 of R is ambiguous.
+	r *ec.Point
+}
+
+// NewSM2BatchVerifier creates a batch verifier for DER encoded signatures
+// over SM3 on the SM2 curve, matching NewSM2Signer.
+func NewSM2BatchVerifier() *SM2BatchVerifier {
+	return NewSM2BatchVerifierWithDomain(nil, nil, nil)
+}
+
+// NewSM2BatchVerifierWithEncoding creates a batch verifier for the SM2
+// curve with the given signature encoding and digest. A nil encoding means
+// StandardDSAEncoding and a nil digest means SM3.
+func NewSM2BatchVerifierWithEncoding(encoding DSAEncoding, digest crypto.Digest) *SM2BatchVerifier {
+	return NewSM2BatchVerifierWithDomain(nil, encoding, digest)
+}
+
+// NewSM2BatchVerifierWithDomain creates a batch verifier for the curve of
+// domain, e.g. params.NewECDomainParametersByName(ec.SM2TestFp256), with
+// the given signature encoding and digest. A nil domain means
+// sm2.GetECDomainParameters().
+func NewSM2BatchVerifierWithDomain(domain *params.ECDomainParameters, encoding DSAEncoding, digest crypto.Digest) *SM2BatchVerifier {
+	if domain == nil {
+		domain = sm2.GetECDomainParameters()
+	}
+	return &SM2BatchVerifier{
+		domain:   domain,
+		digest:   digest,
+		encoding: encoding,
+		random:   rand.Reader,
+	}
+}
+
+// SetRandom sets the source of the random coefficients. If random is nil,
+// crypto/rand.Reader is used. The coefficients must be unpredictable to
+// whoever produced the signatures.
+func (v *SM2BatchVerifier) SetRandom(random io.Reader) {
+	if random == nil {
+		random = rand.Reader
+	}
+	v.random = random
+}
+
+// Verify checks every entry and returns the indices of the invalid ones in
+// increasing order; an empty result means all signatures are valid. An
+// entry is reported exactly when SM2Signer.VerifySignature would reject it
+// or fail with an error; a recoverable entry is also reported if v is not
+// its recovery id. An error is returned only if the random source fails.
+func (v *SM2BatchVerifier) Verify(entries []SM2BatchEntry) ([]int, error) {
+	signer := NewSM2SignerWithEncoding(v.encoding, v.digest)
+
+	var invalid []int
+	var batch, group []*sm2BatchItem
+	for i := range entries {
+		item := v.prepare(signer, i, &entries[i])
+		switch {
+		case item == nil:
+			invalid = append(invalid, i)
+		case item.r == nil:
+			if !v.verifyOne(signer, entries, item) {
+				invalid = append(invalid, i)
+			}
+		case entries[i].Recoverable:
+			batch = append(batch, item)
+			if len(batch) == sm2BatchSize {
+				bad, err := v.verifyBatch(batch)
+				if err != nil {
+					return nil, err
+				}
+				invalid = append(invalid, bad...)
+				batch = nil
+			}
+		default:
+			group = append(group, item)
+			if len(group) == sm2BatchGroupSize {
+				bad, err := v.verifyGroup(signer, entries, group)
+				if err != nil {
+					return nil, err
+				}
+				invalid = append(invalid, bad...)
+				group = nil
+			}
+		}
+	}
+	if len(batch) > 0 {
+		bad, err := v.verifyBatch(batch)
+		if err != nil {
+			return nil, err
+		}
+		invalid = append(invalid, bad...)
+	}
+	if len(group) > 0 {
+		bad, err := v.verifyGroup(signer, entries, group)
+		if err != nil {
+			return nil, err
+		}
+		invalid = append(invalid, bad...)
+	}
+
+	sort.Ints(invalid)
+	return invalid, nil
+}
+
+// prepare decodes an entry and rebuilds its point R, returning nil if the
+// entry is certainly invalid.
+func (v *SM2BatchVerifier) prepare(signer *SM2Signer, index int, entry *SM2BatchEntry) *sm2BatchItem {
+	if !v.initSigner(signer, entry) {
+		return nil
+	}
+	signer.BlockUpdate(entry.Message, 0, len(entry.Message))
+	eHash := signer.digestDoFinal()
+
+	n := v.domain.GetN()
+	var r, s *big.Int
+	var err error
+	size := recoverableScalarSize(v.domain)
+	if entry.Recoverable {
+		if len(entry.Signature) != 2*size+1 {
+			return nil
+		}
+		r = new(big.Int).SetBytes(entry.Signature[:size])
+		s = new(big.Int).SetBytes(entry.Signature[size : 2*size])
+	} else {
+		r, s, err = signer.encoding.Decode(n, entry.Signature)
+	}
+	if err != nil {
+		return nil
+	}
+	if r.Sign() <= 0 || r.Cmp(n) >= 0 || s.Sign() <= 0 || s.Cmp(n) >= 0 {
+		return nil
+	}
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return nil
+	}
+	item := &sm2BatchItem{index: index, eHash: eHash, pub: entry.PublicKey, s: s, t: t}
+
+	if entry.Recoverable {
+		item.r, err = recoverR(v.domain, r, eHash, entry.Signature[2*size])
+		if err != nil {
+			return nil
+		}
+		return item
+	}
+
+	// x1 = (r - e) mod n, unless x1 + n is a field element as well
+	x1 := new(big.Int).Sub(r, new(big.Int).SetBytes(eHash))
+	x1.Mod(x1, n)
+	curve := v.domain.GetCurve()
+	if new(big.Int).Add(x1, n).Cmp(curve.GetP()) < 0 {
+		return item
+	}
+	encoded := make([]byte, 1+signer.curveLength)
+	encoded[0] = 0x02
+	x1.FillBytes(encoded[1:])
+	item.r, err = curve.DecodePoint(encoded)
+	if err != nil {
+		// No point has x coordinate x1
+		return nil
+	}
+	return item
+}
+
+// initSigner initializes signer to verify an entry, returning false if the
+// public key or user ID is rejected.
+func (v *SM2BatchVerifier) initSigner(signer *SM2Signer, entry *SM2BatchEntry) bool {
+	if entry.PublicKey == nil {
+		return false
+	}
+	var parameters crypto.CipherParameters = params.NewECPublicKeyParameters(entry.PublicKey, v.domain)
+	if entry.UserID != nil {
+		parameters = crypto.NewParametersWithID(parameters, entry.UserID)
+	}
+	return signer.Init(false, parameters) == nil
+}
+
+// verifyOne verifies a DER or plain signature with SM2Signer.
+func (v *SM2BatchVerifier) verifyOne(signer *SM2Signer, entries []SM2BatchEntry, item *sm2BatchItem) bool {
+	entry := &entries[item.index]
+	if !v.initSigner(signer, entry) {
+		return false
+	}
+	valid, err := signer.VerifyDigest(item.eHash, entry.Signature)
+	return err == nil && valid
+}
+
+// coefficients returns a_0 = 1 and random a_i for the other entries of a
+// batch.
+func (v *SM2BatchVerifier) coefficients(count int) ([]*big.Int, error) {
+	a := make([]*big.Int, count)
+	a[0] = big.NewInt(1)
+	buf := make([]byte, sm2BatchRandomizerBits/8)
+	for i := 1; i < count; i++ {
+		if _, err := io.ReadFull(v.random, buf); err != nil {
+			return nil, err
+		}
+		a[i] = new(big.Int).SetBytes(buf)
+		if a[i].Sign() == 0 {
+			a[i].SetInt64(1)
+		}
+	}
+	return a, nil
+}
+
+// combination returns the terms of [Σ a_i*s_i]G + Σ[a_i*t_i]P_i. The terms
+// are merged per key, so a batch from one signer costs a single
+// full-length scalar besides that of G.
+func (v *SM2BatchVerifier) combination(items []*sm2BatchItem, a []*big.Int) ([]*ec.Point, []*big.Int) {
+	n := v.domain.GetN()
+	baseK := new(big.Int)
+	keyIndex := make(map[string]int)
+	points := make([]*ec.Point, 0, 2*len(items)+1)
+	scalars := make([]*big.Int, 0, 2*len(items)+1)
+	for i, item := range items {
+		baseK.Add(baseK, new(big.Int).Mul(a[i], item.s))
+		at := new(big.Int).Mul(a[i], item.t)
+		key := string(item.pub.GetEncoded(true))
+		if j, ok := keyIndex[key]; ok {
+			scalars[j].Add(scalars[j], at)
+		} else {
+			keyIndex[key] = len(points)
+			points = append(points, item.pub)
+			scalars = append(scalars, at)
+		}
+	}
+	for _, k := range scalars {
+		k.Mod(k, n)
+	}
+	points = append(points, v.domain.GetG())
+	scalars = append(scalars, baseK.Mod(baseK, n))
+	return points, scalars
+}
+
+// verifyBatch checks a batch of recoverable signatures with one
+// multi-scalar multiplication and returns the indices of its invalid
+// entries. A failing batch is split in halves, and a single entry is
+// checked against its own equation.
+func (v *SM2BatchVerifier) verifyBatch(batch []*sm2BatchItem) ([]int, error) {
+	if len(batch) == 1 {
+		item := batch[0]
+		if ec.SumOfTwoMultiplies(v.domain.GetG(), item.s, item.pub, item.t).Equals(item.r) {
+			return nil, nil
+		}
+		return []int{item.index}, nil
+	}
+
+	a, err := v.coefficients(len(batch))
+	if err != nil {
+		return nil, err
+	}
+	points, scalars := v.combination(batch, a)
+	// -[a_i]R_i keeps the short coefficient
+	for i, item := range batch {
+		points = append(points, item.r)
+		scalars = append(scalars, a[i].Neg(a[i]))
+	}
+	if ec.SumOfMultiplies(points, scalars).IsInfinity() {
+		return nil, nil
+	}
+
+	half := len(batch) / 2
+	invalid, err := v.verifyBatch(batch[:half])
+	if err != nil {
+		return nil, err
+	}
+	bad, err := v.verifyBatch(batch[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(invalid, bad...), nil
+}
+
+// verifyGroup checks a group of DER or plain signatures with one
+// multi-scalar multiplication and returns the indices of its invalid
+// entries.
+func (v *SM2BatchVerifier) verifyGroup(signer *SM2Signer, entries []SM2BatchEntry, group []*sm2BatchItem) ([]int, error) {
+	a, err := v.coefficients(len(group))
+	if err != nil {
+		return nil, err
+	}
+	points, scalars := v.combination(group, a)
+	if matchesSignedSum(ec.SumOfMultiplies(points, scalars), group, a) {
+		return nil, nil
+	}
+
+	// Fall back to identifying the invalid entries
+	var invalid []int
+	for _, item := range group {
+		if !v.verifyOne(signer, entries, item) {
+			invalid = append(invalid, item.index)
+		}
+	}
+	return invalid, nil
+}
+
+// matchesSignedSum reports whether l = Σ±[a_i]R_i for some choice of signs,
+// walking the sign patterns in Gray code order so that each step is a
+// single addition.
+func matchesSignedSum(l *ec.Point, group []*sm2BatchItem, a []*big.Int) bool {
+	terms := make([]*ec.Point, len(group))
+	sum := group[0].r
+	terms[0] = group[0].r
+	for i := 1; i < len(group); i++ {
+		terms[i] = group[i].r.Multiply(a[i])
+		sum = sum.Add(terms[i])
+	}
+
+	// Patterns differing only in the sign of the first term are covered by
+	// also comparing against -l
+	negL := l.Negate()
+	negative := make([]bool, len(group))
+	for step := 1; ; step++ {
+		if sum.Equals(l) || sum.Equals(negL) {
+			return true
+		}
+		if step == 1<<(len(group)-1) {
+			return false
+		}
+
+		// Flip the sign of the term selected by the lowest set bit of step
+		j := 1
+		for step>>(j-1)&1 == 0 {
+			j++
+		}
+		twice := terms[j].Twice()
+		if negative[j] {
+			sum = sum.Add(twice)
+		} else {
+			sum = sum.Subtract(twice)
+		}
+		negative[j] = !negative[j]
+	}
+}
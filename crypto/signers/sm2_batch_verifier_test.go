@@ -0,0 +1,315 @@
+package signers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+)
+
+// batchEntries signs count messages with keys keys on the curve of domain,
+// cycling through them, and gives every third entry its own user ID. The
+// signatures are recoverable if encoding is nil and produced by
+// GenerateSignature with encoding otherwise.
+func batchEntries(t testing.TB, domain *params.ECDomainParameters, encoding DSAEncoding, count, keys int) []SM2BatchEntry {
+	t.Helper()
+
+	privs := make([]*big.Int, keys)
+	for i := range privs {
+		d, err := rand.Int(rand.Reader, new(big.Int).Sub(domain.GetN(), big.NewInt(2)))
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		privs[i] = d.Add(d, big.NewInt(1))
+	}
+
+	entries := make([]SM2BatchEntry, count)
+	for i := range entries {
+		d := privs[i%keys]
+		message := []byte(fmt.Sprintf("audit record %d", i))
+		var userID []byte
+		var parameters crypto.CipherParameters = params.NewECPrivateKeyParameters(d, domain)
+		if i%3 == 0 {
+			userID = []byte(fmt.Sprintf("user%d@example.com", i))
+			parameters = crypto.NewParametersWithID(parameters, userID)
+		}
+
+		signer := NewSM2SignerWithEncoding(encoding, nil)
+		if err := signer.Init(true, parameters); err != nil {
+			t.Fatalf("Failed to init signer: %v", err)
+		}
+		signer.BlockUpdate(message, 0, len(message))
+		var signature []byte
+		var err error
+		if encoding == nil {
+			signature, err = signer.SignRecoverable(signer.digestDoFinal())
+		} else {
+			signature, err = signer.GenerateSignature()
+		}
+		if err != nil {
+			t.Fatalf("Failed to generate signature: %v", err)
+		}
+
+		entries[i] = SM2BatchEntry{
+			PublicKey:   domain.GetG().Multiply(d),
+			UserID:      userID,
+			Message:     message,
+			Signature:   signature,
+			Recoverable: encoding == nil,
+		}
+	}
+	return entries
+}
+
+// verifyEntry verifies an entry with SM2Signer, using r || s of a
+// recoverable signature and encoding otherwise.
+func verifyEntry(domain *params.ECDomainParameters, encoding DSAEncoding, entry SM2BatchEntry) bool {
+	signature := entry.Signature
+	if entry.Recoverable {
+		if len(signature) != SM2RecoverableSignatureSize {
+			return false
+		}
+		encoding, signature = PlainDSAEncoding{}, signature[:64]
+	}
+	if entry.PublicKey == nil {
+		return false
+	}
+	var parameters crypto.CipherParameters = params.NewECPublicKeyParameters(entry.PublicKey, domain)
+	if entry.UserID != nil {
+		parameters = crypto.NewParametersWithID(parameters, entry.UserID)
+	}
+	verifier := NewSM2SignerWithEncoding(encoding, nil)
+	if err := verifier.Init(false, parameters); err != nil {
+		return false
+	}
+	verifier.BlockUpdate(entry.Message, 0, len(entry.Message))
+	valid, _ := verifier.VerifySignature(signature)
+	return valid
+}
+
+func TestSM2BatchVerifierValid(t *testing.T) {
+	domain := sm2.GetECDomainParameters()
+	entries := batchEntries(t, domain, nil, 23, 5)
+
+	for _, count := range []int{0, 1, 2, 4, 5, 23} {
+		invalid, err := NewSM2BatchVerifier().Verify(entries[:count])
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		if len(invalid) != 0 {
+			t.Errorf("%d entries: valid signatures reported invalid: %v", count, invalid)
+		}
+	}
+
+	// A single key across the whole batch
+	entries = batchEntries(t, domain, nil, 9, 1)
+	invalid, err := NewSM2BatchVerifier().Verify(entries)
+	if err != nil || len(invalid) != 0 {
+		t.Errorf("Single-key batch: invalid %v, err %v", invalid, err)
+	}
+
+	// The curve is taken from the verifier's domain parameters
+	testDomain, _ := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	entries = batchEntries(t, testDomain, nil, 6, 2)
+	invalid, err = NewSM2BatchVerifierWithDomain(testDomain, nil, nil).Verify(entries)
+	if err != nil || len(invalid) != 0 {
+		t.Errorf("Test curve batch: invalid %v, err %v", invalid, err)
+	}
+	if invalid, _ := NewSM2BatchVerifier().Verify(entries); len(invalid) != len(entries) {
+		t.Errorf("Test curve entries checked on sm2p256v1: invalid %v", invalid)
+	}
+}
+
+func TestSM2BatchVerifierInvalid(t *testing.T) {
+	domain := sm2.GetECDomainParameters()
+	entries := batchEntries(t, domain, nil, 16, 3)
+	other, _ := sm2.GenerateKey(nil)
+
+	tamper := func(i, pos int) {
+		entries[i].Signature = append([]byte{}, entries[i].Signature...)
+		entries[i].Signature[pos] ^= 0x01
+	}
+
+	entries[1].Message = []byte("altered record")
+	entries[4].PublicKey = other.PublicKey
+	tamper(5, 63)
+	entries[6].UserID = []byte("someone else")
+	entries[9].Signature = entries[9].Signature[:64]
+	entries[10].PublicKey = nil
+	entries[11].Signature = append(sm2.GetN().FillBytes(make([]byte, 32)), entries[11].Signature[32:]...)
+	// A signature moved to another entry
+	entries[13].Signature = entries[12].Signature
+	// A recovery id for -R, or out of range
+	tamper(14, 64)
+	entries[15].Signature = append([]byte{}, entries[15].Signature...)
+	entries[15].Signature[64] = 4
+
+	invalid, err := NewSM2BatchVerifier().Verify(entries)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	expected := []int{1, 4, 5, 6, 9, 10, 11, 13, 14, 15}
+	if !reflect.DeepEqual(invalid, expected) {
+		t.Errorf("Expected invalid entries %v, got %v", expected, invalid)
+	}
+
+	// The result agrees with SM2Signer entry by entry, apart from the
+	// entries whose r || s is intact but whose recovery id is wrong
+	for i, entry := range entries {
+		if i == 14 || i == 15 {
+			continue
+		}
+		reported := false
+		for _, j := range invalid {
+			reported = reported || i == j
+		}
+		if valid := verifyEntry(domain, nil, entry); valid == reported {
+			t.Errorf("Entry %d: SM2Signer says valid=%v, batch says invalid=%v", i, valid, reported)
+		}
+	}
+}
+
+// TestSM2BatchVerifierStandard batch-verifies DER and plain signatures
+// from GenerateSignature, which only fix the x coordinate of R.
+func TestSM2BatchVerifierStandard(t *testing.T) {
+	domain := sm2.GetECDomainParameters()
+	for _, encoding := range []DSAEncoding{StandardDSAEncoding{}, PlainDSAEncoding{}} {
+		entries := batchEntries(t, domain, encoding, 11, 3)
+		verifier := NewSM2BatchVerifierWithEncoding(encoding, nil)
+		invalid, err := verifier.Verify(entries)
+		if err != nil || len(invalid) != 0 {
+			t.Errorf("%T: valid signatures: invalid %v, err %v", encoding, invalid, err)
+		}
+
+		entries[2].Message = []byte("altered record")
+		entries[7].Signature = append([]byte{}, entries[7].Signature...)
+		entries[7].Signature[len(entries[7].Signature)-1] ^= 0x01
+		entries[8].Signature = entries[9].Signature
+		entries[10].Signature = entries[10].Signature[:20]
+
+		invalid, err = verifier.Verify(entries)
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		expected := []int{2, 7, 8, 10}
+		if !reflect.DeepEqual(invalid, expected) {
+			t.Errorf("%T: expected invalid entries %v, got %v", encoding, expected, invalid)
+		}
+		for i, entry := range entries {
+			reported := false
+			for _, j := range invalid {
+				reported = reported || i == j
+			}
+			if valid := verifyEntry(domain, encoding, entry); valid == reported {
+				t.Errorf("%T entry %d: SM2Signer says valid=%v, batch says invalid=%v", encoding, i, valid, reported)
+			}
+		}
+	}
+
+	// DER is the default, and recoverable entries may be mixed in
+	entries := append(batchEntries(t, domain, StandardDSAEncoding{}, 6, 2), batchEntries(t, domain, nil, 6, 2)...)
+	entries[1].Message = []byte("altered record")
+	entries[9].Message = []byte("altered record")
+	invalid, err := NewSM2BatchVerifier().Verify(entries)
+	if err != nil || !reflect.DeepEqual(invalid, []int{1, 9}) {
+		t.Errorf("Mixed batch: invalid %v, err %v", invalid, err)
+	}
+
+	// DER signatures on the test curve
+	testDomain, _ := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	entries = batchEntries(t, testDomain, StandardDSAEncoding{}, 6, 2)
+	invalid, err = NewSM2BatchVerifierWithDomain(testDomain, nil, nil).Verify(entries)
+	if err != nil || len(invalid) != 0 {
+		t.Errorf("Test curve batch: invalid %v, err %v", invalid, err)
+	}
+}
+
+// TestSM2BatchVerifierLarge spreads invalid entries over more than one
+// multi-scalar multiplication.
+func TestSM2BatchVerifierLarge(t *testing.T) {
+	entries := batchEntries(t, sm2.GetECDomainParameters(), nil, sm2BatchSize+10, 4)
+	bad := []int{0, 77, sm2BatchSize - 1, sm2BatchSize + 3}
+	for _, i := range bad {
+		entries[i].Message = []byte("altered record")
+	}
+
+	invalid, err := NewSM2BatchVerifier().Verify(entries)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !reflect.DeepEqual(invalid, bad) {
+		t.Errorf("Expected invalid entries %v, got %v", bad, invalid)
+	}
+}
+
+func TestSM2BatchVerifierRandomFailure(t *testing.T) {
+	entries := batchEntries(t, sm2.GetECDomainParameters(), nil, 3, 1)
+
+	verifier := NewSM2BatchVerifier()
+	verifier.SetRandom(bytes.NewReader(nil))
+	if _, err := verifier.Verify(entries); err == nil {
+		t.Error("Expected error from exhausted random source")
+	}
+}
+
+// TestSM2BatchVerifierSpeedup checks that a batch verifies at least 1.5
+// times faster than the same signatures one at a time with SM2Signer.
+func TestSM2BatchVerifierSpeedup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test")
+	}
+	domain := sm2.GetECDomainParameters()
+	for _, keys := range []int{1, 64} {
+		entries := batchEntries(t, domain, nil, 64, keys)
+		batch := testing.Benchmark(func(b *testing.B) {
+			benchmarkBatchVerify(b, entries)
+		})
+		single := testing.Benchmark(func(b *testing.B) {
+			benchmarkSingleVerify(b, domain, entries)
+		})
+		if 3*batch.NsPerOp() > 2*single.NsPerOp() {
+			t.Errorf("keys=%d: batch of 64 takes %v, one at a time %v",
+				keys, time.Duration(batch.NsPerOp()), time.Duration(single.NsPerOp()))
+		}
+	}
+}
+
+func benchmarkBatchVerify(b *testing.B, entries []SM2BatchEntry) {
+	verifier := NewSM2BatchVerifier()
+	for i := 0; i < b.N; i++ {
+		if invalid, err := verifier.Verify(entries); err != nil || len(invalid) != 0 {
+			b.Fatalf("batch verification failed: %v %v", invalid, err)
+		}
+	}
+}
+
+func benchmarkSingleVerify(b *testing.B, domain *params.ECDomainParameters, entries []SM2BatchEntry) {
+	for i := 0; i < b.N; i++ {
+		for _, entry := range entries {
+			if !verifyEntry(domain, nil, entry) {
+				b.Fatal("verification failed")
+			}
+		}
+	}
+}
+
+func BenchmarkSM2BatchVerify(b *testing.B) {
+	domain := sm2.GetECDomainParameters()
+	for _, keys := range []int{1, 64} {
+		entries := batchEntries(b, domain, nil, 64, keys)
+		b.Run(fmt.Sprintf("keys=%d/batch", keys), func(b *testing.B) {
+			benchmarkBatchVerify(b, entries)
+		})
+		b.Run(fmt.Sprintf("keys=%d/single", keys), func(b *testing.B) {
+			benchmarkSingleVerify(b, domain, entries)
+		})
+	}
+}
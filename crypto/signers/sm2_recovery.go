@@ -0,0 +1,139 @@
+package signers
+
+import (
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// SM2RecoverableSignatureSize is the length of a recoverable signature on
+// sm2p256v1: r and s as 32-byte big-endian integers followed by the
+// recovery id v. On other curves r and s take the byte length of a field
+// element.
+const SM2RecoverableSignatureSize = 65
+
+// Bits of the recovery id v.
+const (
+	// sm2RecoveryYOdd is set when y1 of (x1, y1) = [k]G is odd.
+	sm2RecoveryYOdd = 1
+	// sm2RecoveryXOverflow is set when x1 >= n, i.e. x1 = ((r - e) mod n) + n.
+	sm2RecoveryXOverflow = 2
+)
+
+// SignRecoverable signs a precomputed digest like SignDigest and returns
+// the signature in the recoverable form r || s || v, from which
+// RecoverPublicKey derives the signer's public key.
+//
+// Z depends on the public key, so a party that has to recover the key
+// cannot compute e = H(Z || M). Platforms using recovery therefore sign a
+// digest that does not involve Z, such as e = H(M).
+func (s *SM2Signer) SignRecoverable(eHash []byte) ([]byte, error) {
+	r, sig, p1, err := s.sign(eHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var v byte
+	if p1.GetYCoord().TestBitZero() {
+		v |= sm2RecoveryYOdd
+	}
+	if p1.GetXCoord().ToBigInt().Cmp(s.domain.GetN()) >= 0 {
+		v |= sm2RecoveryXOverflow
+	}
+
+	size := recoverableScalarSize(s.domain)
+	out := make([]byte, 2*size+1)
+	r.FillBytes(out[:size])
+	sig.FillBytes(out[size : 2*size])
+	out[2*size] = v
+	return out, nil
+}
+
+// RecoverPublicKey returns the public key P that produced a recoverable
+// signature r || s || v of the digest e. With t = (r + s) mod n, the point
+// R = (x1, y1) = [s]G + [t]P is rebuilt from x1 = (r - e) mod n and v, and
+// P = [t^-1](R - [s]G). The r || s part of the signature then verifies
+// against P with PlainDSAEncoding. The signature is one made on
+// sm2p256v1.
+func RecoverPublicKey(eHash, signature []byte) (*ec.Point, error) {
+	return RecoverPublicKeyWithDomain(sm2.GetECDomainParameters(), eHash, signature)
+}
+
+// RecoverPublicKeyWithDomain recovers like RecoverPublicKey the public key
+// of a signature made by a signer on the curve of domain.
+func RecoverPublicKeyWithDomain(domain *params.ECDomainParameters, eHash, signature []byte) (*ec.Point, error) {
+	if err := checkDomain(domain); err != nil {
+		return nil, err
+	}
+	size := recoverableScalarSize(domain)
+	if len(signature) != 2*size+1 {
+		return nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid recoverable signature length")
+	}
+	n := domain.GetN()
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size : 2*size])
+	if r.Sign() <= 0 || r.Cmp(n) >= 0 || s.Sign() <= 0 || s.Cmp(n) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidSignature, "signature values out of range")
+	}
+
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid signature")
+	}
+
+	rPoint, err := recoverR(domain, r, eHash, signature[2*size])
+	if err != nil {
+		return nil, err
+	}
+
+	// P = [t^-1]R + [-s * t^-1]G
+	tInv := new(big.Int).ModInverse(t, n)
+	u := new(big.Int).Mul(s, tInv)
+	u.Neg(u).Mod(u, n)
+	pub := ec.SumOfTwoMultiplies(rPoint, tInv, domain.GetG(), u)
+	if pub.IsInfinity() {
+		return nil, exceptions.New(exceptions.ErrInvalidSignature, "recovered point at infinity")
+	}
+	return pub, nil
+}
+
+// recoverR rebuilds the point R = (x1, y1) = [k]G of a signature from
+// x1 = (r - e) mod n and the recovery id v.
+func recoverR(domain *params.ECDomainParameters, r *big.Int, eHash []byte, v byte) (*ec.Point, error) {
+	if v > sm2RecoveryYOdd|sm2RecoveryXOverflow {
+		return nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid recovery id")
+	}
+	curve := domain.GetCurve()
+	n := domain.GetN()
+
+	// x1 = (r - e) mod n, plus n if the recovery id says so
+	x1 := new(big.Int).Sub(r, new(big.Int).SetBytes(eHash))
+	x1.Mod(x1, n)
+	if v&sm2RecoveryXOverflow != 0 {
+		x1.Add(x1, n)
+		if x1.Cmp(curve.GetP()) >= 0 {
+			return nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid recovery id")
+		}
+	}
+
+	// Rebuild R from its compressed encoding
+	byteLen := (curve.GetFieldSize() + 7) / 8
+	encoded := make([]byte, 1+byteLen)
+	encoded[0] = 0x02 | v&sm2RecoveryYOdd
+	x1.FillBytes(encoded[1:])
+	rPoint, err := curve.DecodePoint(encoded)
+	if err != nil {
+		return nil, exceptions.New(exceptions.ErrInvalidSignature, "no curve point for recovered x coordinate")
+	}
+	return rPoint, nil
+}
+
+// recoverableScalarSize returns the length of r and s in a recoverable
+// signature on the curve of domain.
+func recoverableScalarSize(domain *params.ECDomainParameters) int {
+	return (domain.GetCurve().GetFieldSize() + 7) / 8
+}
@@ -0,0 +1,172 @@
+package signers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+)
+
+func sm3Sum(data []byte) []byte {
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate(data, 0, len(data))
+	out := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(out, 0)
+	return out
+}
+
+// TestSM2RecoverGMT0003Example recovers the public key of the GM/T 0003.5
+// signature example, whose [k]G has an even y coordinate below n.
+func TestSM2RecoverGMT0003Example(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	z, _ := hex.DecodeString("B2E14C5C79C6DF5B85F4FE7ED8DB7A262B9DA7E07CCB0EA9F4747B8CCDA8A4F3")
+	e := sm3Sum(append(z, "message digest"...))
+
+	signer := NewSM2Signer()
+	signer.SetRandom(bytes.NewReader(k))
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signature, err := signer.SignRecoverable(e)
+	if err != nil {
+		t.Fatalf("SignRecoverable failed: %v", err)
+	}
+
+	expected, _ := hex.DecodeString("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3" +
+		"B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA" + "00")
+	if !bytes.Equal(signature, expected) {
+		t.Errorf("Signature mismatch\nExpected: %X\nGot:      %X", expected, signature)
+	}
+
+	pub, err := RecoverPublicKey(e, signature)
+	if err != nil {
+		t.Fatalf("RecoverPublicKey failed: %v", err)
+	}
+	if !pub.Equals(sm2.GetG().Multiply(privKey)) {
+		t.Errorf("Recovered wrong public key: %v", pub)
+	}
+}
+
+func TestSM2RecoverRoundTrip(t *testing.T) {
+	seen := map[byte]bool{}
+	for i := 0; i < 16; i++ {
+		keyPair, err := sm2.GenerateKey(nil)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		e := sm3Sum([]byte{byte(i)})
+
+		signer := NewSM2Signer()
+		_ = signer.Init(true, privateKeyParams(keyPair.PrivateKey))
+		signature, err := signer.SignRecoverable(e)
+		if err != nil {
+			t.Fatalf("SignRecoverable failed: %v", err)
+		}
+		if len(signature) != SM2RecoverableSignatureSize {
+			t.Fatalf("Unexpected signature length %d", len(signature))
+		}
+		seen[signature[64]] = true
+
+		pub, err := RecoverPublicKey(e, signature)
+		if err != nil {
+			t.Fatalf("RecoverPublicKey failed: %v", err)
+		}
+		if !pub.Equals(keyPair.PublicKey) {
+			t.Fatalf("Recovered wrong public key")
+		}
+
+		// r || s is an ordinary plain signature of e
+		verifier := NewSM2SignerWithEncoding(PlainDSAEncoding{}, nil)
+		_ = verifier.Init(false, publicKeyParams(pub))
+		if valid, err := verifier.VerifyDigest(e, signature[:64]); err != nil || !valid {
+			t.Errorf("Recoverable signature failed verification: %v", err)
+		}
+
+		// The other parity yields a different key, if any
+		flipped := append([]byte{}, signature...)
+		flipped[64] ^= 1
+		if other, err := RecoverPublicKey(e, flipped); err == nil && other.Equals(keyPair.PublicKey) {
+			t.Error("Wrong recovery id recovered the signing key")
+		}
+		if other, err := RecoverPublicKey(sm3Sum([]byte("other")), signature); err == nil && other.Equals(keyPair.PublicKey) {
+			t.Error("Wrong digest recovered the signing key")
+		}
+	}
+	if !seen[0] || !seen[1] {
+		t.Errorf("Expected both y parities among recovery ids, got %v", seen)
+	}
+}
+
+// TestSM2RecoverWithDomain recovers keys of a signer on the test curve,
+// which RecoverPublicKey, bound to sm2p256v1, does not.
+func TestSM2RecoverWithDomain(t *testing.T) {
+	domain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		d, err := rand.Int(rand.Reader, new(big.Int).Sub(domain.GetN(), big.NewInt(2)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Add(d, big.NewInt(1))
+		e := sm3Sum([]byte{byte(i)})
+
+		signer := NewSM2Signer()
+		if err := signer.Init(true, params.NewECPrivateKeyParameters(d, domain)); err != nil {
+			t.Fatalf("Failed to init signer: %v", err)
+		}
+		signature, err := signer.SignRecoverable(e)
+		if err != nil {
+			t.Fatalf("SignRecoverable failed: %v", err)
+		}
+
+		pub, err := RecoverPublicKeyWithDomain(domain, e, signature)
+		if err != nil {
+			t.Fatalf("RecoverPublicKeyWithDomain failed: %v", err)
+		}
+		if !pub.Equals(domain.GetG().Multiply(d)) {
+			t.Fatal("Recovered wrong public key")
+		}
+		if other, err := RecoverPublicKey(e, signature); err == nil && other.Equals(pub) {
+			t.Error("RecoverPublicKey recovered a test curve key")
+		}
+	}
+
+	if _, err := RecoverPublicKeyWithDomain(nil, sm3Sum(nil), make([]byte, SM2RecoverableSignatureSize)); err == nil {
+		t.Error("Expected error for missing domain parameters")
+	}
+}
+
+func TestSM2RecoverInvalid(t *testing.T) {
+	e := sm3Sum([]byte("message"))
+	keyPair, _ := sm2.GenerateKey(nil)
+	signer := NewSM2Signer()
+	_ = signer.Init(true, privateKeyParams(keyPair.PrivateKey))
+	signature, _ := signer.SignRecoverable(e)
+
+	n := sm2.GetN().Bytes()
+	for name, sig := range map[string][]byte{
+		"short":       signature[:64],
+		"recovery id": append(append([]byte{}, signature[:64]...), 4),
+		"zero r":      append(make([]byte, 32), signature[32:]...),
+		"s = n":       append(append(append([]byte{}, signature[:32]...), n...), 0),
+		// r - e + n exceeds p for almost every r
+		"x overflow": append(append([]byte{}, signature[:64]...), signature[64]|2),
+	} {
+		if _, err := RecoverPublicKey(e, sig); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	verifier := NewSM2Signer()
+	_ = verifier.Init(false, publicKeyParams(keyPair.PublicKey))
+	if _, err := verifier.SignRecoverable(e); err == nil {
+		t.Error("Expected error signing with a verification instance")
+	}
+}
@@ -2,204 +2,275 @@ package signers
 
 import (
 	"crypto/rand"
-	"errors"
+	"io"
 	"math/big"
 
+	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/internal/sm2core"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // SM2Signer implements SM2 digital signature algorithm.
+// An SM2Signer is not safe for concurrent use; SM2SignerPool shares
+// signers between goroutines.
 // Reference: GM/T 0003-2012 Part 2: Digital Signature Algorithm
+// Based on: org.bouncycastle.crypto.signers.SM2Signer
 type SM2Signer struct {
-	forSigning   bool
-	digest       *digests.SM3Digest
-	curve        *ec.Curve
-	publicKey    *ec.Point
-	privateKey   *big.Int
-	userID       []byte
-	z            []byte
-	curveLength  int
+	forSigning  bool
+	digest      crypto.Digest
+	encoding    DSAEncoding
+	domain      *params.ECDomainParameters
+	publicKey   *ec.Point
+	privateKey  *big.Int
+	userID      []byte
+	z           []byte
+	curveLength int
+	kCalculator DSAKCalculator
+	random      io.Reader
 }
 
-// NewSM2Signer creates a new SM2 signer.
+// NewSM2Signer creates a new SM2 signer that hashes with SM3, encodes
+// signatures in DER and draws k from crypto/rand.Reader.
 func NewSM2Signer() *SM2Signer {
-	curve := sm2.GetCurve()
+	return NewSM2SignerWithKCalculator(NewRandomDSAKCalculator())
+}
+
+// NewSM2SignerWithKCalculator creates a new SM2 signer that obtains k from
+// the given calculator, e.g. NewHMacDSAKCalculator(digests.NewSM3Digest())
+// for deterministic signatures.
+func NewSM2SignerWithKCalculator(kCalculator DSAKCalculator) *SM2Signer {
+	domain := sm2.GetECDomainParameters()
 	return &SM2Signer{
 		digest:      digests.NewSM3Digest(),
-		curve:       curve,
-		userID:      []byte("1234567812345678"), // Default user ID
-		curveLength: (curve.GetFieldSize() + 7) / 8,
+		encoding:    StandardDSAEncoding{},
+		domain:      domain,
+		userID:      sm2.DefaultUserID,
+		curveLength: (domain.GetCurve().GetFieldSize() + 7) / 8,
+		kCalculator: kCalculator,
+		random:      rand.Reader,
+	}
+}
+
+// NewSM2SignerWithEncoding creates a new SM2 signer with the given
+// signature encoding, e.g. PlainDSAEncoding{} for the 64-byte r || s form,
+// and digest. A nil encoding means StandardDSAEncoding and a nil digest
+// means SM3.
+func NewSM2SignerWithEncoding(encoding DSAEncoding, digest crypto.Digest) *SM2Signer {
+	s := NewSM2Signer()
+	if encoding != nil {
+		s.encoding = encoding
+	}
+	if digest != nil {
+		s.digest = digest
 	}
+	return s
 }
 
-// SetUserID sets the user ID for Z value computation.
+// SetRandom sets the random source passed to a non-deterministic k
+// calculator. If random is nil, crypto/rand.Reader is used.
+func (s *SM2Signer) SetRandom(random io.Reader) {
+	if random == nil {
+		random = rand.Reader
+	}
+	s.random = random
+}
+
+// SetUserID sets the user ID for Z value computation when Init is not given
+// a crypto.ParametersWithID.
 func (s *SM2Signer) SetUserID(userID []byte) {
 	s.userID = userID
 }
 
-// Init initializes the signer for signing or verification.
-func (s *SM2Signer) Init(forSigning bool, publicKey *ec.Point, privateKey *big.Int) error {
-	s.forSigning = forSigning
+// Init initializes the signer. For signing, parameters must be an
+// *params.ECPrivateKeyParameters, optionally wrapped in a
+// *params.ParametersWithRandom supplying the random source; for
+// verification, an *params.ECPublicKeyParameters. Either may be wrapped in
+// a *crypto.ParametersWithID to set the user ID. The curve is taken from
+// the key's domain parameters, e.g. sm2.GetECDomainParameters() or
+// params.NewECDomainParametersByName(ec.SM2TestFp256).
+func (s *SM2Signer) Init(forSigning bool, parameters crypto.CipherParameters) error {
+	baseParam := parameters
+	userID := s.userID
+	if pwid, ok := parameters.(*crypto.ParametersWithID); ok {
+		baseParam = pwid.GetParameters()
+		userID = pwid.GetID()
+	}
+	if err := sm2core.CheckUserID(userID); err != nil {
+		return err
+	}
 
 	if forSigning {
-		if privateKey == nil {
-			return errors.New("private key required for signing")
+		if rParam, ok := baseParam.(*params.ParametersWithRandom); ok {
+			s.random = rParam.GetRandom()
+			baseParam = rParam.GetParameters()
 		}
-		if !sm2.ValidatePrivateKey(privateKey) {
-			return errors.New("invalid private key")
+		privParam, ok := baseParam.(*params.ECPrivateKeyParameters)
+		if !ok {
+			return exceptions.New(exceptions.ErrInvalidKey, "SM2 signing requires ECPrivateKeyParameters")
 		}
-		s.privateKey = privateKey
+		domain := privParam.GetParameters()
+		if err := checkDomain(domain); err != nil {
+			return err
+		}
+
+		// d = n - 1 is excluded as 1 + d must be invertible
+		d := privParam.GetD()
+		n := domain.GetN()
+		if d == nil || d.Sign() <= 0 || new(big.Int).Add(d, big.NewInt(1)).Cmp(n) >= 0 {
+			return exceptions.New(exceptions.ErrInvalidKey, "invalid private key")
+		}
+		s.domain = domain
+		util.ClearBigInt(s.privateKey)
+		s.privateKey = new(big.Int).Set(d)
 		// Derive public key from private key
-		s.publicKey = sm2.GetG().Multiply(privateKey)
+		s.publicKey = domain.GetG().MultiplySecret(d)
 	} else {
-		if publicKey == nil || publicKey.IsInfinity() {
-			return errors.New("public key required for verification")
+		pubParam, ok := baseParam.(*params.ECPublicKeyParameters)
+		if !ok {
+			return exceptions.New(exceptions.ErrInvalidKey, "SM2 verification requires ECPublicKeyParameters")
+		}
+		domain := pubParam.GetParameters()
+		if err := checkDomain(domain); err != nil {
+			return err
+		}
+
+		q := pubParam.GetQ()
+		if q == nil || q.IsInfinity() {
+			return exceptions.New(exceptions.ErrInvalidKey, "public key required for verification")
 		}
-		if !sm2.ValidatePublicKey(publicKey) {
-			return errors.New("invalid public key")
+		if !validPublicKey(domain, q) {
+			return exceptions.New(exceptions.ErrInvalidKey, "invalid public key")
 		}
-		s.publicKey = publicKey
+		s.domain = domain
+		util.ClearBigInt(s.privateKey)
+		s.privateKey = nil
+		s.publicKey = q
 	}
+	s.forSigning = forSigning
+	s.curveLength = (s.domain.GetCurve().GetFieldSize() + 7) / 8
 
-	// Compute Z value
-	s.z = s.computeZ()
+	// Compute Z value and initialize digest with Z; the user ID has been
+	// checked above
+	s.z, _ = sm2core.ComputeZ(s.digest, s.domain, userID, s.publicKey)
+	s.Reset()
 
-	// Initialize digest with Z
-	s.digest.Reset()
-	s.digest.BlockUpdate(s.z, 0, len(s.z))
+	return nil
+}
 
+// checkDomain rejects missing domain parameters and parameters whose order
+// does not match the curve, which the constant-time scalar arithmetic of
+// the curve relies on.
+func checkDomain(domain *params.ECDomainParameters) error {
+	if domain == nil || domain.GetCurve() == nil || domain.GetG() == nil {
+		return exceptions.New(exceptions.ErrInvalidKey, "EC domain parameters required")
+	}
+	curve := domain.GetCurve()
+	if curve.GetScalarField() == nil || domain.GetN().Cmp(curve.GetOrder()) != 0 {
+		return exceptions.New(exceptions.ErrUnsupportedAlgorithm, "unsupported EC domain parameters")
+	}
 	return nil
 }
 
-// Update updates the digest with message data.
-func (s *SM2Signer) Update(data []byte) {
-	s.digest.BlockUpdate(data, 0, len(data))
+// validPublicKey reports whether q is a point of order n on the curve of
+// the domain parameters.
+func validPublicKey(domain *params.ECDomainParameters, q *ec.Point) bool {
+	if q.IsInfinity() || !q.GetCurve().Equals(domain.GetCurve()) || !q.IsValid() {
+		return false
+	}
+	// With cofactor 1 every point other than O has order n
+	if domain.GetH().Cmp(big.NewInt(1)) == 0 {
+		return true
+	}
+	return q.Multiply(domain.GetN()).IsInfinity()
 }
 
-// UpdateByte updates the digest with a single byte.
-func (s *SM2Signer) UpdateByte(b byte) {
+// Update updates the digest with a single byte of message data.
+func (s *SM2Signer) Update(b byte) {
 	s.digest.Update(b)
 }
 
-// GenerateSignature generates an SM2 signature.
-// Returns signature in format: r || s (64 bytes total)
+// BlockUpdate updates the digest with len bytes of in starting at inOff.
+func (s *SM2Signer) BlockUpdate(in []byte, inOff int, len int) {
+	s.digest.BlockUpdate(in, inOff, len)
+}
+
+// GenerateSignature generates an SM2 signature of the data passed to Update.
 func (s *SM2Signer) GenerateSignature() ([]byte, error) {
 	if !s.forSigning {
-		return nil, errors.New("not initialized for signing")
+		return nil, exceptions.New(exceptions.ErrInvalidState, "not initialized for signing")
 	}
 
-	n := sm2.GetN()
-
 	// Compute e = H(Z || M)
-	eHash := make([]byte, s.digest.GetDigestSize())
-	s.digest.DoFinal(eHash, 0)
-	e := new(big.Int).SetBytes(eHash)
-
-	// Generate signature
-	for {
-		// Generate random k in [1, n-1]
-		k, err := randRange(n)
-		if err != nil {
-			return nil, err
-		}
-
-		// Compute (x1, y1) = [k]G
-		p1 := sm2.GetG().Multiply(k)
-		x1 := p1.GetXCoord().ToBigInt()
-
-		// Compute r = (e + x1) mod n
-		r := new(big.Int).Add(e, x1)
-		r.Mod(r, n)
-
-		// Check if r == 0 or r + k == n
-		if r.Sign() == 0 {
-			continue
-		}
-		rPlusK := new(big.Int).Add(r, k)
-		if rPlusK.Cmp(n) == 0 {
-			continue
-		}
-
-		// Compute s = d^-1 * (k - r * d) mod n
-		// Rewritten as: s = (1 + d)^-1 * (k - r * d) mod n
-		dPlus1 := new(big.Int).Add(s.privateKey, big.NewInt(1))
-		dPlus1.Mod(dPlus1, n)
-		dPlus1Inv := new(big.Int).ModInverse(dPlus1, n)
-		if dPlus1Inv == nil {
-			return nil, errors.New("failed to compute modular inverse")
-		}
-
-		rd := new(big.Int).Mul(r, s.privateKey)
-		rd.Mod(rd, n)
-		kMinusRd := new(big.Int).Sub(k, rd)
-		kMinusRd.Mod(kMinusRd, n)
-
-		sig := new(big.Int).Mul(dPlus1Inv, kMinusRd)
-		sig.Mod(sig, n)
-
-		// Check if s == 0
-		if sig.Sign() == 0 {
-			continue
-		}
+	return s.SignDigest(s.digestDoFinal())
+}
 
-		// Encode signature as r || s
-		return s.encodeSignature(r, sig), nil
+// SignDigest generates an SM2 signature of a precomputed digest
+// e = H(Z || M), bypassing the signer's own digest. Z is available from
+// ComputeZ. The signature is encoded as by GenerateSignature.
+func (s *SM2Signer) SignDigest(eHash []byte) ([]byte, error) {
+	r, sig, _, err := s.sign(eHash)
+	if err != nil {
+		return nil, err
 	}
+	return s.encoding.Encode(s.domain.GetN(), r, sig)
 }
 
-// VerifySignature verifies an SM2 signature.
-func (s *SM2Signer) VerifySignature(signature []byte) (bool, error) {
-	if s.forSigning {
-		return false, errors.New("not initialized for verification")
+// sign computes the signature (r, s) of e = H(Z || M) together with the
+// point (x1, y1) = [k]G.
+func (s *SM2Signer) sign(eHash []byte) (*big.Int, *big.Int, *ec.Point, error) {
+	if !s.forSigning {
+		return nil, nil, nil, exceptions.New(exceptions.ErrInvalidState, "not initialized for signing")
+	}
+	if len(eHash) != s.digest.GetDigestSize() {
+		return nil, nil, nil, exceptions.New(exceptions.ErrDataLength, "invalid digest length")
 	}
 
-	n := sm2.GetN()
-
-	// Decode signature
-	r, sig, err := s.decodeSignature(signature)
-	if err != nil {
-		return false, err
+	n := s.domain.GetN()
+	if s.kCalculator.IsDeterministic() {
+		s.kCalculator.InitDeterministic(n, s.privateKey, eHash)
+	} else {
+		s.kCalculator.Init(n, s.random)
 	}
+	return sm2core.Sign(s.domain, s.privateKey, eHash, s.kCalculator.NextK)
+}
 
-	// Check r, s in [1, n-1]
-	if r.Sign() <= 0 || r.Cmp(n) >= 0 || sig.Sign() <= 0 || sig.Cmp(n) >= 0 {
-		return false, nil
+// VerifySignature verifies an SM2 signature of the data passed to Update.
+func (s *SM2Signer) VerifySignature(signature []byte) (bool, error) {
+	if s.forSigning {
+		return false, exceptions.New(exceptions.ErrInvalidState, "not initialized for verification")
 	}
 
 	// Compute e = H(Z || M)
-	eHash := make([]byte, s.digest.GetDigestSize())
-	s.digest.DoFinal(eHash, 0)
-	e := new(big.Int).SetBytes(eHash)
-
-	// Compute t = (r + s) mod n
-	t := new(big.Int).Add(r, sig)
-	t.Mod(t, n)
+	return s.VerifyDigest(s.digestDoFinal(), signature)
+}
 
-	if t.Sign() == 0 {
-		return false, nil
+// VerifyDigest verifies an SM2 signature of a precomputed digest
+// e = H(Z || M), bypassing the signer's own digest.
+func (s *SM2Signer) VerifyDigest(eHash, signature []byte) (bool, error) {
+	if s.forSigning || s.domain == nil {
+		return false, exceptions.New(exceptions.ErrInvalidState, "not initialized for verification")
+	}
+	if len(eHash) != s.digest.GetDigestSize() {
+		return false, exceptions.New(exceptions.ErrDataLength, "invalid digest length")
 	}
 
-	// Compute (x1, y1) = [s]G + [t]P
-	sG := sm2.GetG().Multiply(sig)
-	tP := s.publicKey.Multiply(t)
-	p1 := sG.Add(tP)
+	n := s.domain.GetN()
 
-	if p1.IsInfinity() {
-		return false, nil
+	// Decode signature
+	r, sig, err := s.encoding.Decode(n, signature)
+	if err != nil {
+		return false, err
 	}
 
-	// Compute v = (e + x1) mod n
-	x1 := p1.GetXCoord().ToBigInt()
-	v := new(big.Int).Add(e, x1)
-	v.Mod(v, n)
-
-	// Check if v == r
-	return v.Cmp(r) == 0, nil
+	return sm2core.Verify(s.domain, s.publicKey, eHash, r, sig), nil
 }
 
-// Reset resets the signer state.
+// Reset resets the signer state, discarding any message data.
 func (s *SM2Signer) Reset() {
 	s.digest.Reset()
 	if len(s.z) > 0 {
@@ -207,152 +278,46 @@ func (s *SM2Signer) Reset() {
 	}
 }
 
-// computeZ computes the Z value for SM2 signature.
-// Z = SM3(ENTL || ID || a || b || xG || yG || xA || yA)
-func (s *SM2Signer) computeZ() []byte {
-	zDigest := digests.NewSM3Digest()
-
-	// ENTL: user ID length in bits (2 bytes, big-endian)
-	entl := len(s.userID) * 8
-	zDigest.Update(byte(entl >> 8))
-	zDigest.Update(byte(entl & 0xFF))
-
-	// ID: user ID
-	zDigest.BlockUpdate(s.userID, 0, len(s.userID))
-
-	// Curve parameters a, b
-	s.addFieldElement(zDigest, s.curve.GetA().ToBigInt())
-	s.addFieldElement(zDigest, s.curve.GetB().ToBigInt())
-
-	// Base point G coordinates
-	g := sm2.GetG()
-	s.addFieldElement(zDigest, g.GetXCoord().ToBigInt())
-	s.addFieldElement(zDigest, g.GetYCoord().ToBigInt())
-
-	// Public key coordinates
-	s.addFieldElement(zDigest, s.publicKey.GetXCoord().ToBigInt())
-	s.addFieldElement(zDigest, s.publicKey.GetYCoord().ToBigInt())
-
-	// Finalize
-	z := make([]byte, zDigest.GetDigestSize())
-	zDigest.DoFinal(z, 0)
-	return z
-}
-
-// addFieldElement adds a field element to the digest as fixed-length bytes.
-func (s *SM2Signer) addFieldElement(digest *digests.SM3Digest, value *big.Int) {
-	bytes := value.Bytes()
-	// Pad to curve length
-	if len(bytes) < s.curveLength {
-		padded := make([]byte, s.curveLength)
-		copy(padded[s.curveLength-len(bytes):], bytes)
-		bytes = padded
-	}
-	digest.BlockUpdate(bytes, 0, len(bytes))
-}
-
-// encodeSignature encodes r and s in DER format for interoperability.
-func (s *SM2Signer) encodeSignature(r, sig *big.Int) []byte {
-	return encodeDERSignature(r, sig)
-}
-
-// decodeSignature decodes r and s from DER format signature bytes.
-func (s *SM2Signer) decodeSignature(signature []byte) (*big.Int, *big.Int, error) {
-	return decodeDERSignature(signature)
+// Destroy overwrites the signer's copy of the private key and the state of
+// its digest and k calculator. The signer must be initialized again
+// before use.
+func (s *SM2Signer) Destroy() {
+	util.ClearBigInt(s.privateKey)
+	s.privateKey = nil
+	s.publicKey = nil
+	s.domain = nil
+	s.forSigning = false
+	s.z = nil
+	s.digest.Reset()
+	crypto.Destroy(s.kCalculator)
 }
 
-// encodeDERSignature encodes r and s in ASN.1 DER format.
-// DER format: 0x30 || length || 0x02 || r_length || r || 0x02 || s_length || s
-func encodeDERSignature(r, s *big.Int) []byte {
-	rBytes := r.Bytes()
-	sBytes := s.Bytes()
-	
-	// Add leading zero if high bit is set (to keep positive)
-	if len(rBytes) > 0 && rBytes[0]&0x80 != 0 {
-		rBytes = append([]byte{0x00}, rBytes...)
-	}
-	if len(sBytes) > 0 && sBytes[0]&0x80 != 0 {
-		sBytes = append([]byte{0x00}, sBytes...)
-	}
-	
-	// Build DER sequence
-	der := make([]byte, 0, 6+len(rBytes)+len(sBytes))
-	
-	// SEQUENCE tag
-	der = append(der, 0x30)
-	// Total length (will be filled later)
-	totalLen := 2 + len(rBytes) + 2 + len(sBytes)
-	der = append(der, byte(totalLen))
-	
-	// INTEGER tag for r
-	der = append(der, 0x02)
-	der = append(der, byte(len(rBytes)))
-	der = append(der, rBytes...)
-	
-	// INTEGER tag for s
-	der = append(der, 0x02)
-	der = append(der, byte(len(sBytes)))
-	der = append(der, sBytes...)
-	
-	return der
+// digestDoFinal returns e = H(Z || M) and resets the signer for the next
+// message.
+func (s *SM2Signer) digestDoFinal() []byte {
+	eHash := make([]byte, s.digest.GetDigestSize())
+	s.digest.DoFinal(eHash, 0)
+	s.Reset()
+	return eHash
 }
 
-// decodeDERSignature decodes r and s from ASN.1 DER format.
-func decodeDERSignature(signature []byte) (*big.Int, *big.Int, error) {
-	if len(signature) < 8 {
-		return nil, nil, errors.New("invalid signature length")
-	}
-	
-	// Check SEQUENCE tag
-	if signature[0] != 0x30 {
-		return nil, nil, errors.New("invalid DER signature: expected SEQUENCE tag")
-	}
-	
-	// Get total length
-	totalLen := int(signature[1])
-	if len(signature) != totalLen+2 {
-		return nil, nil, errors.New("invalid DER signature: length mismatch")
-	}
-	
-	pos := 2
-	
-	// Parse r
-	if signature[pos] != 0x02 {
-		return nil, nil, errors.New("invalid DER signature: expected INTEGER tag for r")
-	}
-	pos++
-	rLen := int(signature[pos])
-	pos++
-	if pos+rLen > len(signature) {
-		return nil, nil, errors.New("invalid DER signature: r length out of bounds")
+// ComputeZ computes the Z value that is hashed in front of the message:
+// Z = H(ENTL || ID || a || b || xG || yG || xA || yA).
+// A caller holding only Z and M can then sign or verify e = H(Z || M)
+// with SignDigest and VerifyDigest. Z is computed with a copy of the
+// signer's digest, which must implement crypto.Memoable as SM3Digest does,
+// so message data already passed to Update is kept.
+// A user ID of 8192 bytes or more is rejected with ErrInvalidParameter.
+func (s *SM2Signer) ComputeZ(userID []byte, pub *ec.Point) ([]byte, error) {
+	var digest crypto.Digest
+	if m, ok := s.digest.(crypto.Memoable); ok {
+		digest, _ = m.Copy().(crypto.Digest)
 	}
-	r := new(big.Int).SetBytes(signature[pos : pos+rLen])
-	pos += rLen
-	
-	// Parse s
-	if signature[pos] != 0x02 {
-		return nil, nil, errors.New("invalid DER signature: expected INTEGER tag for s")
+	if digest == nil {
+		return nil, exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "ComputeZ requires a crypto.Memoable digest, got %s", s.digest.GetAlgorithmName())
 	}
-	pos++
-	sLen := int(signature[pos])
-	pos++
-	if pos+sLen > len(signature) {
-		return nil, nil, errors.New("invalid DER signature: s length out of bounds")
-	}
-	s := new(big.Int).SetBytes(signature[pos : pos+sLen])
-	
-	return r, s, nil
+	return sm2core.ComputeZ(digest, s.domain, userID, pub)
 }
 
-// randRange generates a random number in [1, max-1].
-func randRange(max *big.Int) (*big.Int, error) {
-	for {
-		k, err := rand.Int(rand.Reader, max)
-		if err != nil {
-			return nil, err
-		}
-		if k.Sign() > 0 {
-			return k, nil
-		}
-	}
-}
+var _ crypto.Signer = (*SM2Signer)(nil)
+var _ crypto.Destroyable = (*SM2Signer)(nil)
@@ -0,0 +1,163 @@
+package signers
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"sync"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// SM2SignerPool signs and verifies messages with SM2Signer instances that
+// are kept per public key and user ID and reused. An SM2Signer holds the
+// state of one message and must not be shared between goroutines; a pool
+// is safe for concurrent use. Each call takes an idle signer for its key,
+// or initializes a new one, and returns it when done, so Z and, for
+// signing, the public key are computed once per signer rather than once
+// per message.
+//
+// Idle signers are kept until Destroy is called. A pool suits a server
+// working with a bounded set of keys; for one-off keys use
+// sm2.PrivateKey.Sign and sm2.PublicKey.Verify, which are also safe for
+// concurrent use.
+type SM2SignerPool struct {
+	encoding DSAEncoding
+	mu       sync.Mutex
+	idle     map[signerPoolKey][]*SM2Signer
+}
+
+// signerPoolKey identifies the signers of one key and user ID.
+type signerPoolKey struct {
+	forSigning bool
+	publicKey  string
+	userID     string
+}
+
+// NewSM2SignerPool creates a pool whose signers encode signatures with the
+// given encoding. A nil encoding means StandardDSAEncoding.
+func NewSM2SignerPool(encoding DSAEncoding) *SM2SignerPool {
+	if encoding == nil {
+		encoding = StandardDSAEncoding{}
+	}
+	return &SM2SignerPool{
+		encoding: encoding,
+		idle:     make(map[signerPoolKey][]*SM2Signer),
+	}
+}
+
+// Sign signs message with priv for the given user ID; nil means
+// sm2.DefaultUserID. priv.PublicKey must be the public key of priv.D, as
+// for keys from sm2.NewPrivateKey.
+func (p *SM2SignerPool) Sign(priv *sm2.PrivateKey, userID, message []byte) ([]byte, error) {
+	if priv == nil || priv.D == nil || priv.Q == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "SM2 private key required")
+	}
+	key := newSignerPoolKey(true, priv.PublicKey, userID)
+
+	signer := p.get(key)
+	if signer != nil && !sameScalar(signer.privateKey, priv) {
+		// The signer was initialized with a different d for this public key
+		signer.Destroy()
+		signer = nil
+	}
+	if signer == nil {
+		signer = NewSM2SignerWithEncoding(p.encoding, nil)
+		privParams := params.NewECPrivateKeyParameters(priv.D, sm2.GetECDomainParameters())
+		if err := signer.Init(true, crypto.NewParametersWithID(privParams, []byte(key.userID))); err != nil {
+			return nil, err
+		}
+	}
+
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	p.put(key, signer)
+	return signature, err
+}
+
+// Verify checks a signature of message by pub for the given user ID; nil
+// means sm2.DefaultUserID.
+func (p *SM2SignerPool) Verify(pub *sm2.PublicKey, userID, message, signature []byte) (bool, error) {
+	if pub == nil || pub.Q == nil {
+		return false, exceptions.New(exceptions.ErrInvalidKey, "SM2 public key required")
+	}
+	key := newSignerPoolKey(false, *pub, userID)
+
+	signer := p.get(key)
+	if signer == nil {
+		signer = NewSM2SignerWithEncoding(p.encoding, nil)
+		pubParams := params.NewECPublicKeyParameters(pub.Q, sm2.GetECDomainParameters())
+		if err := signer.Init(false, crypto.NewParametersWithID(pubParams, []byte(key.userID))); err != nil {
+			return false, err
+		}
+	}
+
+	signer.BlockUpdate(message, 0, len(message))
+	valid, err := signer.VerifySignature(signature)
+	p.put(key, signer)
+	return valid, err
+}
+
+// Destroy destroys the idle signers, clearing their copies of the private
+// keys. It must not be called while Sign or Verify is running; the pool
+// can be used again afterwards.
+func (p *SM2SignerPool) Destroy() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, signers := range p.idle {
+		for _, signer := range signers {
+			signer.Destroy()
+		}
+		delete(p.idle, key)
+	}
+}
+
+// get takes an idle signer for key, or returns nil if there is none.
+func (p *SM2SignerPool) get(key signerPoolKey) *SM2Signer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	signers := p.idle[key]
+	if len(signers) == 0 {
+		return nil
+	}
+	signer := signers[len(signers)-1]
+	p.idle[key] = signers[:len(signers)-1]
+	return signer
+}
+
+// put returns a signer for key to the pool. The signer has been reset for
+// the next message by GenerateSignature or VerifySignature.
+func (p *SM2SignerPool) put(key signerPoolKey, signer *SM2Signer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle[key] = append(p.idle[key], signer)
+}
+
+// newSignerPoolKey returns the pool key of a public key and user ID.
+func newSignerPoolKey(forSigning bool, pub sm2.PublicKey, userID []byte) signerPoolKey {
+	if userID == nil {
+		userID = sm2.DefaultUserID
+	}
+	return signerPoolKey{
+		forSigning: forSigning,
+		publicKey:  string(pub.Q.GetEncoded(false)),
+		userID:     string(userID),
+	}
+}
+
+// sameScalar reports in constant time whether the signer's copy d of the
+// private key equals priv.D.
+func sameScalar(d *big.Int, priv *sm2.PrivateKey) bool {
+	if priv.D.Sign() <= 0 || priv.D.BitLen() > 256 {
+		return false
+	}
+	a := d.FillBytes(make([]byte, 32))
+	b := priv.D.FillBytes(make([]byte, 32))
+	equal := subtle.ConstantTimeCompare(a, b) == 1
+	util.Clear(a)
+	util.Clear(b)
+	return equal
+}
@@ -0,0 +1,146 @@
+package signers
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+func newPoolTestKey(t *testing.T) *sm2.PrivateKey {
+	t.Helper()
+	keyPair, err := sm2.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	priv, err := sm2.NewPrivateKey(keyPair.PrivateKey)
+	if err != nil {
+		t.Fatalf("NewPrivateKey failed: %v", err)
+	}
+	return priv
+}
+
+func TestSM2SignerPool(t *testing.T) {
+	priv := newPoolTestKey(t)
+	pool := NewSM2SignerPool(PlainDSAEncoding{})
+	message := []byte("pooled message")
+
+	for i := 0; i < 3; i++ {
+		signature, err := pool.Sign(priv, nil, message)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		if len(signature) != 64 {
+			t.Errorf("Expected a 64-byte plain signature, got %d bytes", len(signature))
+		}
+		if valid, err := pool.Verify(&priv.PublicKey, nil, message, signature); err != nil || !valid {
+			t.Fatalf("Verify failed: %v, %v", valid, err)
+		}
+		if valid, _ := pool.Verify(&priv.PublicKey, []byte("other user"), message, signature); valid {
+			t.Error("Signature verified with a different user ID")
+		}
+		if valid, _ := pool.Verify(&priv.PublicKey, nil, []byte("other message"), signature); valid {
+			t.Error("Signature verified for a different message")
+		}
+	}
+
+	// Signatures interoperate with sm2.PublicKey and SM2Signer
+	signature, _ := NewSM2SignerPool(nil).Sign(priv, []byte("alice"), message)
+	if !priv.PublicKey.Verify(message, signature, &sm2.SignerOpts{UID: []byte("alice")}) {
+		t.Error("Pooled signature rejected by sm2.PublicKey.Verify")
+	}
+
+	if _, err := pool.Sign(nil, nil, message); !errors.Is(err, exceptions.ErrInvalidKey) {
+		t.Errorf("Sign with nil key = %v, want ErrInvalidKey", err)
+	}
+	if _, err := pool.Verify(&sm2.PublicKey{}, nil, message, signature); !errors.Is(err, exceptions.ErrInvalidKey) {
+		t.Errorf("Verify with nil point = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestSM2SignerPoolKeyMismatch(t *testing.T) {
+	priv := newPoolTestKey(t)
+	pool := NewSM2SignerPool(nil)
+	message := []byte("message")
+	if _, err := pool.Sign(priv, nil, message); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	// A key claiming the same public key with another d must not use the
+	// pooled signer
+	other := newPoolTestKey(t)
+	forged := &sm2.PrivateKey{PublicKey: priv.PublicKey, D: other.D}
+	signature, err := pool.Sign(forged, nil, message)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if valid, _ := pool.Verify(&priv.PublicKey, nil, message, signature); valid {
+		t.Error("Signature made with the pooled signer of another key")
+	}
+}
+
+func TestSM2SignerPoolDestroy(t *testing.T) {
+	priv := newPoolTestKey(t)
+	d := new(big.Int).Set(priv.D)
+	pool := NewSM2SignerPool(nil)
+	if _, err := pool.Sign(priv, nil, []byte("message")); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	signer := pool.idle[newSignerPoolKey(true, priv.PublicKey, nil)][0]
+	key := signer.privateKey
+
+	pool.Destroy()
+	if key.Sign() != 0 {
+		t.Error("Pooled private key not cleared")
+	}
+	if priv.D.Cmp(d) != 0 {
+		t.Error("Destroy cleared the caller's private key")
+	}
+	if _, err := pool.Sign(priv, nil, []byte("message")); err != nil {
+		t.Errorf("Sign after Destroy failed: %v", err)
+	}
+}
+
+// TestSM2SignerPoolConcurrent signs and verifies with a few keys from many
+// goroutines; run with -race.
+func TestSM2SignerPoolConcurrent(t *testing.T) {
+	keys := []*sm2.PrivateKey{newPoolTestKey(t), newPoolTestKey(t), newPoolTestKey(t)}
+	pool := NewSM2SignerPool(nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			priv := keys[i%len(keys)]
+			userID := []byte(fmt.Sprintf("user%d", i%2))
+			for j := 0; j < 8; j++ {
+				message := []byte(fmt.Sprintf("message %d-%d", i, j))
+				signature, err := pool.Sign(priv, userID, message)
+				if err != nil {
+					errs <- fmt.Errorf("goroutine %d: Sign failed: %v", i, err)
+					return
+				}
+				if valid, err := pool.Verify(&priv.PublicKey, userID, message, signature); err != nil || !valid {
+					errs <- fmt.Errorf("goroutine %d: Verify failed: %v, %v", i, valid, err)
+					return
+				}
+				other := keys[(i+1)%len(keys)]
+				if valid, _ := pool.Verify(&other.PublicKey, userID, message, signature); valid {
+					errs <- fmt.Errorf("goroutine %d: signature verified with another key", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -1,11 +1,19 @@
 package signers
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
+	"io"
 	"math/big"
 	"testing"
 
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 func TestSM2SignerBasic(t *testing.T) {
@@ -18,13 +26,13 @@ func TestSM2SignerBasic(t *testing.T) {
 
 	// Create signer for signing
 	signer := NewSM2Signer()
-	err := signer.Init(true, nil, privKey)
+	err := signer.Init(true, privateKeyParams(privKey))
 	if err != nil {
 		t.Fatalf("Init for signing failed: %v", err)
 	}
 
 	// Sign the message
-	signer.Update(message)
+	signer.BlockUpdate(message, 0, len(message))
 	signature, err := signer.GenerateSignature()
 	if err != nil {
 		t.Fatalf("GenerateSignature failed: %v", err)
@@ -37,12 +45,12 @@ func TestSM2SignerBasic(t *testing.T) {
 
 	// Verify the signature
 	verifier := NewSM2Signer()
-	err = verifier.Init(false, pubKey, nil)
+	err = verifier.Init(false, publicKeyParams(pubKey))
 	if err != nil {
 		t.Fatalf("Init for verification failed: %v", err)
 	}
 
-	verifier.Update(message)
+	verifier.BlockUpdate(message, 0, len(message))
 	valid, err := verifier.VerifySignature(signature)
 	if err != nil {
 		t.Fatalf("VerifySignature failed: %v", err)
@@ -57,16 +65,19 @@ func TestSM2SignerWrongMessage(t *testing.T) {
 	privKey := fromHex("128B2FA8BD433C6C068C8D803DFF79792A519A55171B1B650C23661D15897263")
 	pubKey := sm2.GetG().Multiply(privKey)
 
+	message1 := []byte("message 1")
+	message2 := []byte("message 2")
+
 	// Sign message 1
 	signer := NewSM2Signer()
-	_ = signer.Init(true, nil, privKey)
-	signer.Update([]byte("message 1"))
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate(message1, 0, len(message1))
 	signature, _ := signer.GenerateSignature()
 
 	// Verify with message 2
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update([]byte("message 2"))
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message2, 0, len(message2))
 	valid, _ := verifier.VerifySignature(signature)
 
 	if valid {
@@ -83,14 +94,14 @@ func TestSM2SignerWrongKey(t *testing.T) {
 
 	// Sign with privKey1
 	signer := NewSM2Signer()
-	_ = signer.Init(true, nil, privKey1)
-	signer.Update(message)
+	_ = signer.Init(true, privateKeyParams(privKey1))
+	signer.BlockUpdate(message, 0, len(message))
 	signature, _ := signer.GenerateSignature()
 
 	// Verify with pubKey2
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey2, nil)
-	verifier.Update(message)
+	_ = verifier.Init(false, publicKeyParams(pubKey2))
+	verifier.BlockUpdate(message, 0, len(message))
 	valid, _ := verifier.VerifySignature(signature)
 
 	if valid {
@@ -104,8 +115,8 @@ func TestSM2SignerEmptyMessage(t *testing.T) {
 
 	// Sign empty message
 	signer := NewSM2Signer()
-	_ = signer.Init(true, nil, privKey)
-	signer.Update([]byte{})
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate([]byte{}, 0, 0)
 	signature, err := signer.GenerateSignature()
 	if err != nil {
 		t.Fatalf("GenerateSignature failed: %v", err)
@@ -113,8 +124,8 @@ func TestSM2SignerEmptyMessage(t *testing.T) {
 
 	// Verify empty message
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update([]byte{})
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate([]byte{}, 0, 0)
 	valid, _ := verifier.VerifySignature(signature)
 
 	if !valid {
@@ -134,8 +145,8 @@ func TestSM2SignerLongMessage(t *testing.T) {
 
 	// Sign long message
 	signer := NewSM2Signer()
-	_ = signer.Init(true, nil, privKey)
-	signer.Update(message)
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate(message, 0, len(message))
 	signature, err := signer.GenerateSignature()
 	if err != nil {
 		t.Fatalf("GenerateSignature failed: %v", err)
@@ -143,8 +154,8 @@ func TestSM2SignerLongMessage(t *testing.T) {
 
 	// Verify long message
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update(message)
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message, 0, len(message))
 	valid, _ := verifier.VerifySignature(signature)
 
 	if !valid {
@@ -158,15 +169,16 @@ func TestSM2SignerMultipleUpdates(t *testing.T) {
 
 	// Sign with multiple updates
 	signer := NewSM2Signer()
-	_ = signer.Init(true, nil, privKey)
-	signer.Update([]byte("hello "))
-	signer.Update([]byte("world"))
+	_ = signer.Init(true, privateKeyParams(privKey))
+	message := []byte("hello world")
+	signer.BlockUpdate(message, 0, 6)
+	signer.BlockUpdate(message, 6, 5)
 	signature, _ := signer.GenerateSignature()
 
 	// Verify with single update
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update([]byte("hello world"))
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message, 0, len(message))
 	valid, _ := verifier.VerifySignature(signature)
 
 	if !valid {
@@ -183,18 +195,18 @@ func TestSM2SignerReset(t *testing.T) {
 
 	// Sign message1, reset, then sign message2
 	signer := NewSM2Signer()
-	_ = signer.Init(true, nil, privKey)
+	_ = signer.Init(true, privateKeyParams(privKey))
 
-	signer.Update(message1)
+	signer.BlockUpdate(message1, 0, len(message1))
 	signer.Reset() // Reset should clear the digest
 
-	signer.Update(message2)
+	signer.BlockUpdate(message2, 0, len(message2))
 	signature, _ := signer.GenerateSignature()
 
 	// Verify with message2
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update(message2)
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message2, 0, len(message2))
 	valid, _ := verifier.VerifySignature(signature)
 
 	if !valid {
@@ -203,7 +215,7 @@ func TestSM2SignerReset(t *testing.T) {
 
 	// Should NOT verify with message1
 	verifier.Reset()
-	verifier.Update(message1)
+	verifier.BlockUpdate(message1, 0, len(message1))
 	valid, _ = verifier.VerifySignature(signature)
 
 	if valid {
@@ -221,15 +233,15 @@ func TestSM2SignerCustomUserID(t *testing.T) {
 	// Sign with custom user ID
 	signer := NewSM2Signer()
 	signer.SetUserID(customUserID)
-	_ = signer.Init(true, nil, privKey)
-	signer.Update(message)
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate(message, 0, len(message))
 	signature, _ := signer.GenerateSignature()
 
 	// Verify with same custom user ID
 	verifier := NewSM2Signer()
 	verifier.SetUserID(customUserID)
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update(message)
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message, 0, len(message))
 	valid, _ := verifier.VerifySignature(signature)
 
 	if !valid {
@@ -238,8 +250,8 @@ func TestSM2SignerCustomUserID(t *testing.T) {
 
 	// Should NOT verify with default user ID
 	verifier2 := NewSM2Signer()
-	_ = verifier2.Init(false, pubKey, nil)
-	verifier2.Update(message)
+	_ = verifier2.Init(false, publicKeyParams(pubKey))
+	verifier2.BlockUpdate(message, 0, len(message))
 	valid, _ = verifier2.VerifySignature(signature)
 
 	if valid {
@@ -250,7 +262,7 @@ func TestSM2SignerCustomUserID(t *testing.T) {
 func TestSM2SignerInvalidPrivateKey(t *testing.T) {
 	// Zero private key
 	signer := NewSM2Signer()
-	err := signer.Init(true, nil, big.NewInt(0))
+	err := signer.Init(true, privateKeyParams(big.NewInt(0)))
 	if err == nil {
 		t.Error("Should fail with zero private key")
 	}
@@ -258,7 +270,7 @@ func TestSM2SignerInvalidPrivateKey(t *testing.T) {
 	// Private key >= n
 	n := sm2.GetN()
 	signer2 := NewSM2Signer()
-	err = signer2.Init(true, nil, n)
+	err = signer2.Init(true, privateKeyParams(n))
 	if err == nil {
 		t.Error("Should fail with private key >= n")
 	}
@@ -268,7 +280,7 @@ func TestSM2SignerInvalidPublicKey(t *testing.T) {
 	// Point at infinity
 	infinity := sm2.GetCurve().GetInfinity()
 	verifier := NewSM2Signer()
-	err := verifier.Init(false, infinity, nil)
+	err := verifier.Init(false, publicKeyParams(infinity))
 	if err == nil {
 		t.Error("Should fail with point at infinity")
 	}
@@ -284,8 +296,8 @@ func TestSM2SignerInvalidSignature(t *testing.T) {
 	invalidSig := make([]byte, 32)
 
 	verifier := NewSM2Signer()
-	_ = verifier.Init(false, pubKey, nil)
-	verifier.Update(message)
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message, 0, len(message))
 	_, err := verifier.VerifySignature(invalidSig)
 
 	if err == nil {
@@ -293,7 +305,516 @@ func TestSM2SignerInvalidSignature(t *testing.T) {
 	}
 }
 
+// TestSM2SignerGMT0003Example reproduces the sm2p256v1 digital signature
+// example of GM/T 0003.5-2012 with the standard's random number k.
+func TestSM2SignerGMT0003Example(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	message := []byte("message digest")
+
+	expectedZ, _ := hex.DecodeString("B2E14C5C79C6DF5B85F4FE7ED8DB7A262B9DA7E07CCB0EA9F4747B8CCDA8A4F3")
+	expectedR := fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")
+	expectedS := fromHex("B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA")
+
+	signer := NewSM2Signer()
+	signer.SetRandom(bytes.NewReader(k))
+	if err := signer.Init(true, privateKeyParams(privKey)); err != nil {
+		t.Fatalf("Failed to init signer: %v", err)
+	}
+	if !bytes.Equal(signer.z, expectedZ) {
+		t.Errorf("Z mismatch\nExpected: %X\nGot:      %X", expectedZ, signer.z)
+	}
+
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	if err != nil {
+		t.Fatalf("Failed to generate signature: %v", err)
+	}
+
+	r, s, err := decodeDERSignature(signature)
+	if err != nil {
+		t.Fatalf("Failed to decode signature: %v", err)
+	}
+	if r.Cmp(expectedR) != 0 || s.Cmp(expectedS) != 0 {
+		t.Errorf("Signature mismatch\nExpected: r=%X s=%X\nGot:      r=%X s=%X", expectedR, expectedS, r, s)
+	}
+
+	verifier := NewSM2Signer()
+	_ = verifier.Init(false, publicKeyParams(sm2.GetG().Multiply(privKey)))
+	verifier.BlockUpdate(message, 0, len(message))
+	valid, err := verifier.VerifySignature(signature)
+	if err != nil || !valid {
+		t.Errorf("Known-answer signature failed verification: %v", err)
+	}
+}
+
+// TestSM2SignerTestCurveExample reproduces the digital signature example of
+// GM/T 0003.2-2012 Appendix A on the 256-bit Fp test curve.
+func TestSM2SignerTestCurveExample(t *testing.T) {
+	domain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey := fromHex("128B2FA8BD433C6C068C8D803DFF79792A519A55171B1B650C23661D15897263")
+	k, _ := hex.DecodeString("6CB28D99385C175C94F94E934817663FC176D925DD72B727260DBAAE1FB2F96F")
+	userID := []byte("ALICE123@YAHOO.COM")
+	message := []byte("message digest")
+
+	expectedZ, _ := hex.DecodeString("F4A38489E32B45B6F876E3AC2168CA392362DC8F23459C1D1146FC3DBFB7BC9A")
+	expectedR := fromHex("40F1EC59F793D9F49E09DCEF49130D4194F79FB1EED2CAA55BACDB49C4E755D1")
+	expectedS := fromHex("6FC6DAC32C5D5CF10C77DFB20F7C2EB667A457872FB09EC56327A67EC7DEEBE7")
+
+	signer := NewSM2Signer()
+	signer.SetRandom(bytes.NewReader(k))
+	priv := params.NewECPrivateKeyParameters(privKey, domain)
+	if err := signer.Init(true, crypto.NewParametersWithID(priv, userID)); err != nil {
+		t.Fatalf("Failed to init signer: %v", err)
+	}
+	if !bytes.Equal(signer.z, expectedZ) {
+		t.Errorf("Z mismatch\nExpected: %X\nGot:      %X", expectedZ, signer.z)
+	}
+
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	if err != nil {
+		t.Fatalf("Failed to generate signature: %v", err)
+	}
+	r, s, _ := decodeDERSignature(signature)
+	if r.Cmp(expectedR) != 0 || s.Cmp(expectedS) != 0 {
+		t.Errorf("Signature mismatch\nExpected: r=%X s=%X\nGot:      r=%X s=%X", expectedR, expectedS, r, s)
+	}
+
+	pubKey := domain.GetG().Multiply(privKey)
+	if pubKey.GetXCoord().ToBigInt().Cmp(fromHex("0AE4C7798AA0F119471BEE11825BE46202BB79E2A5844495E97C04FF4DF2548A")) != 0 {
+		t.Errorf("Public key mismatch: %X", pubKey.GetXCoord().ToBigInt())
+	}
+	verifier := NewSM2Signer()
+	pub := params.NewECPublicKeyParameters(pubKey, domain)
+	_ = verifier.Init(false, crypto.NewParametersWithID(pub, userID))
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(signature); err != nil || !valid {
+		t.Errorf("Known-answer signature failed verification: %v", err)
+	}
+
+	// A key on one curve is not accepted with the other's parameters
+	if err := verifier.Init(false, params.NewECPublicKeyParameters(pubKey, sm2.GetECDomainParameters())); err == nil {
+		t.Error("Expected error for a public key on another curve")
+	}
+}
+
+// TestSM2SignerDigest signs and verifies e = SM3(Z || M) computed outside
+// the signer, as for a remote signing device.
+func TestSM2SignerDigest(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	pubKey := sm2.GetG().Multiply(privKey)
+	message := []byte("message digest")
+
+	signer := NewSM2Signer()
+	signer.SetRandom(bytes.NewReader(k))
+	if err := signer.Init(true, privateKeyParams(privKey)); err != nil {
+		t.Fatalf("Failed to init signer: %v", err)
+	}
+
+	z, err := signer.ComputeZ([]byte("1234567812345678"), pubKey)
+	if err != nil {
+		t.Fatalf("ComputeZ failed: %v", err)
+	}
+	expectedZ, _ := hex.DecodeString("B2E14C5C79C6DF5B85F4FE7ED8DB7A262B9DA7E07CCB0EA9F4747B8CCDA8A4F3")
+	if !bytes.Equal(z, expectedZ) {
+		t.Errorf("Z mismatch\nExpected: %X\nGot:      %X", expectedZ, z)
+	}
+	if _, err := signer.ComputeZ(make([]byte, 8192), pubKey); !errors.Is(err, exceptions.ErrInvalidParameter) {
+		t.Errorf("Expected ErrInvalidParameter for a long user ID, got %v", err)
+	}
+
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate(z, 0, len(z))
+	digest.BlockUpdate(message, 0, len(message))
+	e := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(e, 0)
+
+	signature, err := signer.SignDigest(e)
+	if err != nil {
+		t.Fatalf("SignDigest failed: %v", err)
+	}
+	r, s, _ := decodeDERSignature(signature)
+	if r.Cmp(fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")) != 0 ||
+		s.Cmp(fromHex("B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA")) != 0 {
+		t.Errorf("Signature mismatch: r=%X s=%X", r, s)
+	}
+	if _, err := signer.SignDigest(e[:31]); err == nil {
+		t.Error("Expected error for short digest")
+	}
+	if _, err := signer.VerifyDigest(e, signature); err == nil {
+		t.Error("Expected error verifying with a signing instance")
+	}
+
+	verifier := NewSM2Signer()
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	if valid, err := verifier.VerifyDigest(e, signature); err != nil || !valid {
+		t.Errorf("VerifyDigest rejected a valid signature: %v", err)
+	}
+
+	// A digest signature verifies against the message, and vice versa
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(signature); err != nil || !valid {
+		t.Errorf("VerifySignature rejected a digest signature: %v", err)
+	}
+
+	e[0] ^= 0x01
+	if valid, _ := verifier.VerifyDigest(e, signature); valid {
+		t.Error("VerifyDigest accepted a modified digest")
+	}
+	if _, err := verifier.VerifyDigest(e[:31], signature); err == nil {
+		t.Error("Expected error for short digest")
+	}
+	if _, err := verifier.SignDigest(e); err == nil {
+		t.Error("Expected error signing with a verification instance")
+	}
+}
+
+// TestSM2SignerParameters initialises the signer through the generic
+// crypto.Signer interface with ID and random wrappers around the key.
+func TestSM2SignerParameters(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	pubKey := sm2.GetG().Multiply(privKey)
+	message := []byte("message digest")
+	userID := []byte("1234567812345678")
+
+	var signer crypto.Signer = NewSM2SignerWithEncoding(PlainDSAEncoding{}, nil)
+	err := signer.Init(true, crypto.NewParametersWithID(
+		params.NewParametersWithRandom(privateKeyParams(privKey), bytes.NewReader(k)), userID))
+	if err != nil {
+		t.Fatalf("Failed to init signer: %v", err)
+	}
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	if err != nil {
+		t.Fatalf("Failed to generate signature: %v", err)
+	}
+	expected, _ := hex.DecodeString("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3" +
+		"B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA")
+	if !bytes.Equal(signature, expected) {
+		t.Errorf("Signature mismatch\nExpected: %X\nGot:      %X", expected, signature)
+	}
+
+	var verifier crypto.Signer = NewSM2SignerWithEncoding(PlainDSAEncoding{}, nil)
+	if err := verifier.Init(false, crypto.NewParametersWithID(publicKeyParams(pubKey), userID)); err != nil {
+		t.Fatalf("Failed to init verifier: %v", err)
+	}
+	for _, b := range message {
+		verifier.Update(b)
+	}
+	if valid, err := verifier.VerifySignature(signature); err != nil || !valid {
+		t.Errorf("Plain signature failed verification: %v", err)
+	}
+
+	// The signer is ready for the next message after each signature
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, _ := verifier.VerifySignature(signature); !valid {
+		t.Error("Verifier not reset after VerifySignature")
+	}
+
+	// A different ID gives a different Z
+	_ = verifier.Init(false, crypto.NewParametersWithID(publicKeyParams(pubKey), []byte("ALICE123@YAHOO.COM")))
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, _ := verifier.VerifySignature(signature); valid {
+		t.Error("Signature verified under a different user ID")
+	}
+
+	// DER signatures are not accepted by a plain verifier, nor vice versa
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	der, _ := StandardDSAEncoding{}.Encode(sm2.GetN(), fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3"),
+		fromHex("B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA"))
+	if _, err := verifier.VerifySignature(der); err == nil {
+		t.Error("Plain verifier accepted a DER signature")
+	}
+	derVerifier := NewSM2Signer()
+	_ = derVerifier.Init(false, publicKeyParams(pubKey))
+	derVerifier.BlockUpdate(message, 0, len(message))
+	if valid, err := derVerifier.VerifySignature(der); err != nil || !valid {
+		t.Errorf("DER signature failed verification: %v", err)
+	}
+	derVerifier.BlockUpdate(message, 0, len(message))
+	if _, err := derVerifier.VerifySignature(signature); err == nil {
+		t.Error("DER verifier accepted a plain signature")
+	}
+}
+
+func TestSM2SignerInitParameters(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	pubKey := sm2.GetG().Multiply(privKey)
+	n := sm2.GetN()
+
+	for name, tc := range map[string]struct {
+		forSigning bool
+		parameters crypto.CipherParameters
+	}{
+		"public key for signing":    {true, publicKeyParams(pubKey)},
+		"private key for verifying": {false, privateKeyParams(privKey)},
+		"nil parameters":            {true, nil},
+		"missing domain":            {true, params.NewECPrivateKeyParameters(privKey, nil)},
+		"d = n - 1":                 {true, privateKeyParams(new(big.Int).Sub(n, big.NewInt(1)))},
+		"long user ID":              {true, crypto.NewParametersWithID(privateKeyParams(privKey), make([]byte, 8192))},
+	} {
+		if err := NewSM2Signer().Init(tc.forSigning, tc.parameters); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestSM2SignerComputeZStreaming calls ComputeZ between Update calls,
+// which must not disturb the message being signed.
+func TestSM2SignerComputeZStreaming(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	pubKey := sm2.GetG().Multiply(privKey)
+	message := []byte("message digest")
+
+	for _, digest := range []crypto.Digest{nil, newSHA256Digest()} {
+		signer := NewSM2SignerWithEncoding(nil, digest)
+		if err := signer.Init(true, privateKeyParams(privKey)); err != nil {
+			t.Fatalf("Failed to init signer: %v", err)
+		}
+		signer.BlockUpdate(message, 0, 7)
+		if _, err := signer.ComputeZ([]byte("someone else"), pubKey); err != nil {
+			t.Fatalf("ComputeZ failed: %v", err)
+		}
+		signer.BlockUpdate(message, 7, len(message)-7)
+		signature, err := signer.GenerateSignature()
+		if err != nil {
+			t.Fatalf("Failed to generate signature: %v", err)
+		}
+
+		verifier := NewSM2SignerWithEncoding(nil, digest)
+		_ = verifier.Init(false, publicKeyParams(pubKey))
+		verifier.BlockUpdate(message, 0, len(message))
+		if valid, err := verifier.VerifySignature(signature); err != nil || !valid {
+			t.Errorf("%T: signature over the whole message failed to verify: %v", digest, err)
+		}
+	}
+
+	// A digest that cannot be copied is rejected
+	signer := NewSM2SignerWithEncoding(nil, struct{ crypto.Digest }{digests.NewSM3Digest()})
+	if _, err := signer.ComputeZ(sm2.DefaultUserID, pubKey); !errors.Is(err, exceptions.ErrUnsupportedAlgorithm) {
+		t.Errorf("ComputeZ with an opaque digest: got %v, want ErrUnsupportedAlgorithm", err)
+	}
+}
+
+// TestSM2SignerDigestOption signs with a digest other than SM3, which is
+// used for both Z and e.
+func TestSM2SignerDigestOption(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	pubKey := sm2.GetG().Multiply(privKey)
+	message := []byte("message digest")
+
+	signer := NewSM2SignerWithEncoding(nil, newSHA256Digest())
+	_ = signer.Init(true, privateKeyParams(privKey))
+	z, _ := signer.ComputeZ(sm2.DefaultUserID, pubKey)
+	sm3Z, _ := NewSM2Signer().ComputeZ(sm2.DefaultUserID, pubKey)
+	if len(z) != 32 || bytes.Equal(z, sm3Z) {
+		t.Errorf("Z not computed with the configured digest: %X", z)
+	}
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	if err != nil {
+		t.Fatalf("Failed to generate signature: %v", err)
+	}
+
+	verifier := NewSM2SignerWithEncoding(nil, newSHA256Digest())
+	_ = verifier.Init(false, publicKeyParams(pubKey))
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(signature); err != nil || !valid {
+		t.Errorf("Signature failed verification: %v", err)
+	}
+
+	sm3Verifier := NewSM2Signer()
+	_ = sm3Verifier.Init(false, publicKeyParams(pubKey))
+	sm3Verifier.BlockUpdate(message, 0, len(message))
+	if valid, _ := sm3Verifier.VerifySignature(signature); valid {
+		t.Error("SHA-256 signature verified with SM3")
+	}
+}
+
+func TestSM2SignerInteropWithPrivateKey(t *testing.T) {
+	keyPair, _ := sm2.GenerateKey(nil)
+	priv, _ := sm2.NewPrivateKey(keyPair.PrivateKey)
+	message := []byte("crypto.Signer interop")
+
+	// Signed through crypto.Signer, verified by SM2Signer
+	sig, err := priv.Sign(nil, message, &sm2.SignerOpts{})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	verifier := NewSM2Signer()
+	_ = verifier.Init(false, publicKeyParams(priv.Q))
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(sig); err != nil || !valid {
+		t.Errorf("SM2Signer rejected a crypto.Signer signature: %v", err)
+	}
+
+	// Signed by SM2Signer, verified through PublicKey
+	signer := NewSM2Signer()
+	_ = signer.Init(true, privateKeyParams(priv.D))
+	signer.BlockUpdate(message, 0, len(message))
+	sig, _ = signer.GenerateSignature()
+	if !priv.PublicKey.Verify(message, sig, &sm2.SignerOpts{}) {
+		t.Error("PublicKey rejected an SM2Signer signature")
+	}
+}
+
+// fixedKCalculator returns a fixed sequence of k values.
+type fixedKCalculator struct {
+	ks []*big.Int
+}
+
+func (c *fixedKCalculator) IsDeterministic() bool                     { return false }
+func (c *fixedKCalculator) Init(n *big.Int, random io.Reader)         {}
+func (c *fixedKCalculator) InitDeterministic(n, d *big.Int, m []byte) {}
+func (c *fixedKCalculator) NextK() (*big.Int, error) {
+	if len(c.ks) == 0 {
+		return nil, io.EOF
+	}
+	k := c.ks[0]
+	c.ks = c.ks[1:]
+	return k, nil
+}
+
+func TestSM2SignerWithKCalculator(t *testing.T) {
+	privKey := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k := fromHex("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	message := []byte("message digest")
+
+	signer := NewSM2SignerWithKCalculator(&fixedKCalculator{ks: []*big.Int{k}})
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	if err != nil {
+		t.Fatalf("Failed to generate signature: %v", err)
+	}
+	r, _, _ := decodeDERSignature(signature)
+	if r.Cmp(fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")) != 0 {
+		t.Errorf("Unexpected r: %X", r)
+	}
+
+	// An exhausted calculator is reported
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate(message, 0, len(message))
+	if _, err := signer.GenerateSignature(); err == nil {
+		t.Error("Expected error from exhausted k calculator")
+	}
+}
+
+func TestRandomDSAKCalculator(t *testing.T) {
+	n := sm2.GetN()
+	nBytes := n.FillBytes(make([]byte, 32))
+	k := fromHex("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+
+	// 0 and n are rejected before k is accepted
+	var stream []byte
+	stream = append(stream, make([]byte, 32)...)
+	stream = append(stream, nBytes...)
+	stream = append(stream, k.FillBytes(make([]byte, 32))...)
+
+	calc := NewRandomDSAKCalculator()
+	if calc.IsDeterministic() {
+		t.Error("RandomDSAKCalculator should not be deterministic")
+	}
+	calc.Init(n, bytes.NewReader(stream))
+	got, err := calc.NextK()
+	if err != nil {
+		t.Fatalf("NextK failed: %v", err)
+	}
+	if got.Cmp(k) != 0 {
+		t.Errorf("Expected k=%X, got %X", k, got)
+	}
+	if _, err := calc.NextK(); err == nil {
+		t.Error("Expected error from exhausted random source")
+	}
+}
+
 func fromHex(s string) *big.Int {
 	b, _ := hex.DecodeString(s)
 	return new(big.Int).SetBytes(b)
 }
+
+// Benchmark tests
+func BenchmarkSM2Sign(b *testing.B) {
+	privKey := fromHex("128B2FA8BD433C6C068C8D803DFF79792A519A55171B1B650C23661D15897263")
+	message := []byte("message digest")
+
+	signer := NewSM2Signer()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = signer.Init(true, privateKeyParams(privKey))
+		signer.BlockUpdate(message, 0, len(message))
+		if _, err := signer.GenerateSignature(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSM2Verify(b *testing.B) {
+	privKey := fromHex("128B2FA8BD433C6C068C8D803DFF79792A519A55171B1B650C23661D15897263")
+	pubKey := sm2.GetG().Multiply(privKey)
+	message := []byte("message digest")
+
+	signer := NewSM2Signer()
+	_ = signer.Init(true, privateKeyParams(privKey))
+	signer.BlockUpdate(message, 0, len(message))
+	signature, err := signer.GenerateSignature()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	verifier := NewSM2Signer()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = verifier.Init(false, publicKeyParams(pubKey))
+		verifier.BlockUpdate(message, 0, len(message))
+		if ok, _ := verifier.VerifySignature(signature); !ok {
+			b.Fatal("verification failed")
+		}
+	}
+}
+
+func privateKeyParams(d *big.Int) crypto.CipherParameters {
+	return params.NewECPrivateKeyParameters(d, sm2.GetECDomainParameters())
+}
+
+func publicKeyParams(q *ec.Point) crypto.CipherParameters {
+	return params.NewECPublicKeyParameters(q, sm2.GetECDomainParameters())
+}
+
+func TestSM2SignerDestroy(t *testing.T) {
+	keyPair, err := sm2.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	d := new(big.Int).Set(keyPair.PrivateKey)
+	message := []byte("destroy")
+
+	signer := NewSM2Signer()
+	if err := signer.Init(true, privateKeyParams(keyPair.PrivateKey)); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	signer.BlockUpdate(message, 0, len(message))
+	key := signer.privateKey
+	signer.Destroy()
+
+	if key.Sign() != 0 {
+		t.Error("Private key not cleared")
+	}
+	if keyPair.PrivateKey.Cmp(d) != 0 {
+		t.Error("Destroy cleared the caller's private key")
+	}
+	if _, err := signer.GenerateSignature(); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("GenerateSignature after Destroy = %v, want ErrInvalidState", err)
+	}
+	if _, err := signer.VerifySignature(make([]byte, 64)); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("VerifySignature after Destroy = %v, want ErrInvalidState", err)
+	}
+}
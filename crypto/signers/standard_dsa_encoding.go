@@ -0,0 +1,117 @@
+package signers
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// StandardDSAEncoding encodes a signature as the DER SEQUENCE of the two
+// INTEGERs r and s, as used in X.509 and by GM/T 0009.
+// Based on: org.bouncycastle.crypto.signers.StandardDSAEncoding
+type StandardDSAEncoding struct{}
+
+// Decode parses a DER signature. Encodings that are not canonical DER, such
+// as those with superfluous leading zeros or trailing data, are rejected.
+func (StandardDSAEncoding) Decode(n *big.Int, encoding []byte) (*big.Int, *big.Int, error) {
+	r, s, err := decodeDERSignature(encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(encodeDERSignature(r, s), encoding) {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "malformed signature")
+	}
+	return r, s, nil
+}
+
+// Encode returns the DER encoding of (r, s).
+func (StandardDSAEncoding) Encode(n, r, s *big.Int) ([]byte, error) {
+	if r.Sign() < 0 || r.Cmp(n) >= 0 || s.Sign() < 0 || s.Cmp(n) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "value out of range")
+	}
+	return encodeDERSignature(r, s), nil
+}
+
+// encodeDERSignature encodes r and s in ASN.1 DER format.
+// DER format: 0x30 || length || 0x02 || r_length || r || 0x02 || s_length || s
+func encodeDERSignature(r, s *big.Int) []byte {
+	rBytes := r.Bytes()
+	sBytes := s.Bytes()
+
+	// Add leading zero if high bit is set (to keep positive)
+	if len(rBytes) > 0 && rBytes[0]&0x80 != 0 {
+		rBytes = append([]byte{0x00}, rBytes...)
+	}
+	if len(sBytes) > 0 && sBytes[0]&0x80 != 0 {
+		sBytes = append([]byte{0x00}, sBytes...)
+	}
+
+	// Build DER sequence
+	der := make([]byte, 0, 6+len(rBytes)+len(sBytes))
+
+	// SEQUENCE tag
+	der = append(der, 0x30)
+	// Total length (will be filled later)
+	totalLen := 2 + len(rBytes) + 2 + len(sBytes)
+	der = append(der, byte(totalLen))
+
+	// INTEGER tag for r
+	der = append(der, 0x02)
+	der = append(der, byte(len(rBytes)))
+	der = append(der, rBytes...)
+
+	// INTEGER tag for s
+	der = append(der, 0x02)
+	der = append(der, byte(len(sBytes)))
+	der = append(der, sBytes...)
+
+	return der
+}
+
+// decodeDERSignature decodes r and s from ASN.1 DER format.
+func decodeDERSignature(signature []byte) (*big.Int, *big.Int, error) {
+	if len(signature) < 8 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid signature length")
+	}
+
+	// Check SEQUENCE tag
+	if signature[0] != 0x30 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid DER signature: expected SEQUENCE tag")
+	}
+
+	// Get total length
+	totalLen := int(signature[1])
+	if len(signature) != totalLen+2 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid DER signature: length mismatch")
+	}
+
+	pos := 2
+
+	// Parse r
+	if signature[pos] != 0x02 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid DER signature: expected INTEGER tag for r")
+	}
+	pos++
+	rLen := int(signature[pos])
+	pos++
+	if pos+rLen > len(signature) {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid DER signature: r length out of bounds")
+	}
+	r := new(big.Int).SetBytes(signature[pos : pos+rLen])
+	pos += rLen
+
+	// Parse s
+	if pos+2 > len(signature) || signature[pos] != 0x02 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid DER signature: expected INTEGER tag for s")
+	}
+	pos++
+	sLen := int(signature[pos])
+	pos++
+	if pos+sLen > len(signature) {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "invalid DER signature: s length out of bounds")
+	}
+	s := new(big.Int).SetBytes(signature[pos : pos+sLen])
+
+	return r, s, nil
+}
@@ -0,0 +1,326 @@
+package threshold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+const keyGenLabel = "SM2-THRESHOLD-KEYGEN"
+
+// KeyGen runs one party's side of distributed key generation. Every party
+// runs the same rounds:
+//
+//  1. Round1 draws the party's polynomials and broadcasts a hash
+//     commitment to their Feldman commitments.
+//  2. Round2 takes all KeyGenRound1 messages, broadcasts the Feldman
+//     commitments and returns a KeyGenShare for every other party.
+//  3. Round3 takes all KeyGenRound2 messages and the shares addressed to
+//     the party, verifies them and broadcasts the masked value μ_i.
+//  4. Finish takes all KeyGenRound3 messages and returns the KeyShare.
+//
+// A KeyGen is used for one run and is not safe for concurrent use.
+type KeyGen struct {
+	id, m, n int
+	random   io.Reader
+	round    int
+
+	// Secret polynomials: key sharing w, mask sharing a and zero sharing
+	// (without its zero constant term) randomising μ
+	key, mask, zero []*big.Int
+
+	own       *KeyGenRound2
+	hashes    map[int][]byte
+	keyComm   []*ec.Point
+	maskComm  []*ec.Point
+	zeroComm  []*ec.Point
+	keyShare  *big.Int
+	maskShare *big.Int
+	zeroShare *big.Int
+}
+
+// NewKeyGen creates party id's side of an m-of-n key generation. Party IDs
+// are 1 to n. If random is nil, crypto/rand.Reader is used.
+func NewKeyGen(id, m, n int, random io.Reader) (*KeyGen, error) {
+	if err := checkParameters(m, n); err != nil {
+		return nil, err
+	}
+	if id < 1 || id > n {
+		return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "invalid party ID %d", id)
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	return &KeyGen{id: id, m: m, n: n, random: random}, nil
+}
+
+// Round1 draws the secret polynomials and returns the broadcast hash
+// commitment to their Feldman commitments.
+func (g *KeyGen) Round1() (*KeyGenRound1, error) {
+	if g.round != 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "KeyGen: Round1 called out of order")
+	}
+
+	var err error
+	if g.key, err = randomPolynomial(g.random, g.m-1); err != nil {
+		return nil, err
+	}
+	if g.mask, err = randomPolynomial(g.random, g.m-1); err != nil {
+		return nil, err
+	}
+	// The zero sharing has degree 2m-2 and constant term 0, which is left
+	// out; a threshold of one needs no randomisation
+	zero, err := randomPolynomial(g.random, 2*g.m-2)
+	if err != nil {
+		return nil, err
+	}
+	g.zero = zero[1:]
+
+	g.own = &KeyGenRound2{
+		From:            g.id,
+		KeyCommitments:  commitPoints(g.key),
+		MaskCommitments: commitPoints(g.mask),
+		ZeroCommitments: commitPoints(g.zero),
+	}
+	g.round = 1
+	return &KeyGenRound1{From: g.id, Commitment: g.own.hash()}, nil
+}
+
+// Round2 records the commitments of all parties and returns this party's
+// broadcast Feldman commitments and the shares for the other parties,
+// ordered by recipient.
+func (g *KeyGen) Round2(round1 []*KeyGenRound1) (*KeyGenRound2, []*KeyGenShare, error) {
+	if g.round != 1 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidState, "KeyGen: Round2 called out of order")
+	}
+
+	g.hashes = make(map[int][]byte, g.n)
+	for _, msg := range round1 {
+		if msg == nil || msg.From < 1 || msg.From > g.n || g.hashes[msg.From] != nil {
+			return nil, nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: invalid or duplicate round 1 message")
+		}
+		g.hashes[msg.From] = msg.Commitment
+	}
+	if len(g.hashes) != g.n {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: round 1 messages missing")
+	}
+	if !bytes.Equal(g.hashes[g.id], g.own.hash()) {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: own round 1 commitment altered")
+	}
+
+	shares := make([]*KeyGenShare, 0, g.n-1)
+	for to := 1; to <= g.n; to++ {
+		if to == g.id {
+			continue
+		}
+		shares = append(shares, g.shareFor(to))
+	}
+	g.round = 2
+	return g.own, shares, nil
+}
+
+// shareFor evaluates the secret polynomials at party to.
+func (g *KeyGen) shareFor(to int) *KeyGenShare {
+	zero := evalPolynomial(append([]*big.Int{new(big.Int)}, g.zero...), to)
+	return &KeyGenShare{
+		From: g.id,
+		To:   to,
+		Key:  evalPolynomial(g.key, to),
+		Mask: evalPolynomial(g.mask, to),
+		Zero: zero,
+	}
+}
+
+// Round3 verifies the other parties' commitments and the shares addressed
+// to this party, and returns the broadcast masked value
+// μ_i = w_i*a_i + z_i with a proof of its correctness.
+func (g *KeyGen) Round3(round2 []*KeyGenRound2, shares []*KeyGenShare) (*KeyGenRound3, error) {
+	if g.round != 2 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "KeyGen: Round3 called out of order")
+	}
+
+	broadcasts := make(map[int]*KeyGenRound2, g.n)
+	for _, msg := range round2 {
+		if msg == nil || msg.From < 1 || msg.From > g.n || broadcasts[msg.From] != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: invalid or duplicate round 2 message")
+		}
+		if len(msg.KeyCommitments) != g.m || len(msg.MaskCommitments) != g.m ||
+			len(msg.ZeroCommitments) != 2*g.m-2 || !bytes.Equal(msg.hash(), g.hashes[msg.From]) {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "KeyGen: party %d revealed commitments that do not match round 1", msg.From)
+		}
+		broadcasts[msg.From] = msg
+	}
+	if len(broadcasts) != g.n {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: round 2 messages missing")
+	}
+
+	received := map[int]*KeyGenShare{g.id: g.shareFor(g.id)}
+	for _, share := range shares {
+		if share == nil || share.To != g.id || share.From < 1 || share.From > g.n || received[share.From] != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: invalid or duplicate share")
+		}
+		received[share.From] = share
+	}
+	if len(received) != g.n {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: shares missing")
+	}
+
+	// Verify every share against its sender's Feldman commitments
+	g.keyComm = make([]*ec.Point, g.m)
+	g.maskComm = make([]*ec.Point, g.m)
+	g.zeroComm = make([]*ec.Point, 2*g.m-2)
+	sf := sm2.GetCurve().GetScalarField()
+	g.keyShare, g.maskShare, g.zeroShare = new(big.Int), new(big.Int), new(big.Int)
+	for from := 1; from <= g.n; from++ {
+		msg, share := broadcasts[from], received[from]
+		zeroComm := append([]*ec.Point{sm2.GetCurve().GetInfinity()}, msg.ZeroCommitments...)
+		if !checkShare(share.Key, msg.KeyCommitments, g.id) ||
+			!checkShare(share.Mask, msg.MaskCommitments, g.id) ||
+			!checkShare(share.Zero, zeroComm, g.id) {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "KeyGen: party %d sent a share that does not match its commitments", from)
+		}
+
+		g.keyShare = sf.Add(g.keyShare, share.Key)
+		g.maskShare = sf.Add(g.maskShare, share.Mask)
+		g.zeroShare = sf.Add(g.zeroShare, share.Zero)
+		addPoints(g.keyComm, msg.KeyCommitments)
+		addPoints(g.maskComm, msg.MaskCommitments)
+		addPoints(g.zeroComm, msg.ZeroCommitments)
+	}
+
+	// μ_i = w_i*a_i + z_i, proving [μ_i]G - [z_i]G = [a_i]([w_i]G) for the
+	// a_i behind [a_i]G
+	mu := sf.Add(sf.Mul(g.keyShare, g.maskShare), g.zeroShare)
+	w, a, z := g.publicShares(g.id)
+	proof, err := proveDLEQ(g.random, g.maskShare, sm2.GetG(), a, w, sm2.GetG().Multiply(mu).Subtract(z))
+	if err != nil {
+		return nil, err
+	}
+
+	// The polynomials and the mask and zero shares are no longer needed
+	clearScalars(g.key)
+	clearScalars(g.mask)
+	clearScalars(g.zero)
+	g.key, g.mask, g.zero = nil, nil, nil
+	util.ClearBigInt(received[g.id].Key)
+	util.ClearBigInt(received[g.id].Mask)
+	util.ClearBigInt(received[g.id].Zero)
+	util.ClearBigInt(g.maskShare)
+	util.ClearBigInt(g.zeroShare)
+	g.maskShare, g.zeroShare = nil, nil
+	g.round = 3
+	return &KeyGenRound3{From: g.id, Mu: mu, Proof: proof}, nil
+}
+
+// publicShares returns [w_j]G, [a_j]G and [z_j]G for party j.
+func (g *KeyGen) publicShares(j int) (w, a, z *ec.Point) {
+	zeroComm := append([]*ec.Point{sm2.GetCurve().GetInfinity()}, g.zeroComm...)
+	return evalCommitments(g.keyComm, j), evalCommitments(g.maskComm, j), evalCommitments(zeroComm, j)
+}
+
+// Finish verifies every party's masked value, interpolates μ = w*a and
+// derives the public key P = [μ^-1]([a]G) - G.
+func (g *KeyGen) Finish(round3 []*KeyGenRound3) (*KeyShare, error) {
+	if g.round != 3 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "KeyGen: Finish called out of order")
+	}
+
+	n := sm2.GetN()
+	values := make(map[int]*big.Int, g.n)
+	for _, msg := range round3 {
+		if msg == nil || msg.From < 1 || msg.From > g.n || values[msg.From] != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: invalid or duplicate round 3 message")
+		}
+		if msg.Mu == nil || msg.Mu.Sign() < 0 || msg.Mu.Cmp(n) >= 0 {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "KeyGen: party %d sent an invalid masked value", msg.From)
+		}
+		w, a, z := g.publicShares(msg.From)
+		if !verifyDLEQ(msg.Proof, sm2.GetG(), a, w, sm2.GetG().Multiply(msg.Mu).Subtract(z)) {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "KeyGen: party %d sent an invalid masked value", msg.From)
+		}
+		values[msg.From] = msg.Mu
+	}
+	if len(values) != g.n {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "KeyGen: round 3 messages missing")
+	}
+
+	// μ lies on a polynomial of degree 2m-2; interpolate over all parties
+	set := make([]int, 0, g.n)
+	for id := 1; id <= g.n; id++ {
+		set = append(set, id)
+	}
+	mu := new(big.Int)
+	for _, id := range set {
+		mu.Add(mu, new(big.Int).Mul(lagrange(id, set), values[id]))
+	}
+	mu.Mod(mu, n)
+	if mu.Sign() == 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "KeyGen: degenerate key, run key generation again")
+	}
+
+	// [w^-1]G = [(w*a)^-1]([a]G), and P = [w^-1]G - G
+	q := g.maskComm[0].Multiply(new(big.Int).ModInverse(mu, n))
+	pub := q.Subtract(sm2.GetG())
+	if pub.IsInfinity() {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "KeyGen: degenerate key, run key generation again")
+	}
+
+	// The share now belongs to the KeyShare
+	share := g.keyShare
+	g.keyShare = nil
+	g.round = 4
+	return &KeyShare{
+		ID:          g.id,
+		Threshold:   g.m,
+		Parties:     g.n,
+		Share:       share,
+		PublicKey:   pub,
+		Commitments: g.keyComm,
+	}, nil
+}
+
+// Destroy clears the secret polynomials and shares held by an unfinished
+// key generation and ends it. The KeyShare returned by Finish is not
+// affected.
+func (g *KeyGen) Destroy() {
+	clearScalars(g.key)
+	clearScalars(g.mask)
+	clearScalars(g.zero)
+	util.ClearBigInt(g.keyShare)
+	util.ClearBigInt(g.maskShare)
+	util.ClearBigInt(g.zeroShare)
+	g.key, g.mask, g.zero = nil, nil, nil
+	g.keyShare, g.maskShare, g.zeroShare = nil, nil, nil
+	g.round = 4
+}
+
+// checkShare reports whether [share]G matches the commitments evaluated at
+// id.
+func checkShare(share *big.Int, commitments []*ec.Point, id int) bool {
+	if share == nil || share.Sign() < 0 || share.Cmp(sm2.GetN()) >= 0 {
+		return false
+	}
+	return sm2.GetG().Multiply(share).Equals(evalCommitments(commitments, id))
+}
+
+// addPoints adds src to dst element-wise, treating nil as infinity.
+func addPoints(dst, src []*ec.Point) {
+	for i, p := range src {
+		if dst[i] == nil {
+			dst[i] = p
+		} else {
+			dst[i] = dst[i].Add(p)
+		}
+	}
+}
+
+// hash returns the round 1 commitment to the message.
+func (m *KeyGenRound2) hash() []byte {
+	return hashCommitment(keyGenLabel, m.From, m.KeyCommitments, m.MaskCommitments, m.ZeroCommitments)
+}
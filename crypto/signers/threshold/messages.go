@@ -0,0 +1,446 @@
+package threshold
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// Message type tags. Each binary message starts with its tag so that a
+// message cannot be mistaken for one of another protocol step.
+const (
+	tagKeyGenRound1 byte = 1
+	tagKeyGenRound2 byte = 2
+	tagKeyGenShare  byte = 3
+	tagKeyGenRound3 byte = 4
+	tagSignRound1   byte = 5
+	tagSignRound2   byte = 6
+	tagSignRound3   byte = 7
+	tagKeyShare     byte = 8
+)
+
+// Sizes of the encoded fields: party IDs and counts are 16-bit big-endian,
+// points are uncompressed, scalars and hashes are 32-byte big-endian.
+const (
+	idSize     = 2
+	pointSize  = 65
+	scalarSize = 32
+)
+
+// KeyGenRound1 is broadcast by every party in the first round of key
+// generation.
+type KeyGenRound1 struct {
+	From int
+	// Commitment is the hash of the party's KeyGenRound2 message.
+	Commitment []byte
+}
+
+// KeyGenRound2 is broadcast by every party in the second round of key
+// generation.
+type KeyGenRound2 struct {
+	From int
+	// KeyCommitments are [c_k]G for the coefficients of the party's
+	// polynomial sharing its part of w.
+	KeyCommitments []*ec.Point
+	// MaskCommitments are the commitments to the mask polynomial.
+	MaskCommitments []*ec.Point
+	// ZeroCommitments are the commitments to the coefficients of degree 1
+	// and higher of the zero-sharing polynomial.
+	ZeroCommitments []*ec.Point
+}
+
+// KeyGenShare is sent privately from one party to another in the second
+// round of key generation. It must travel over a confidential channel.
+type KeyGenShare struct {
+	From, To int
+	// Key, Mask and Zero are the sender's polynomials evaluated at To.
+	Key, Mask, Zero *big.Int
+}
+
+// KeyGenRound3 is broadcast by every party in the third round of key
+// generation.
+type KeyGenRound3 struct {
+	From int
+	// Mu = w_From * a_From + z_From mod n
+	Mu *big.Int
+	// Proof shows that Mu is consistent with the commitments.
+	Proof *Proof
+}
+
+// SignRound1 is broadcast by every signer in the first round of signing.
+type SignRound1 struct {
+	From int
+	// Commitment is the hash of the signer's SignRound2 message and the
+	// digest being signed.
+	Commitment []byte
+}
+
+// SignRound2 is broadcast by every signer in the second round of signing.
+type SignRound2 struct {
+	From int
+	// A = [ρ_From]G
+	A *ec.Point
+	// B = [ρ_From](P + G)
+	B *ec.Point
+	// Proof shows that A and B share ρ_From.
+	Proof *Proof
+}
+
+// SignRound3 is broadcast by every signer in the third round of signing.
+type SignRound3 struct {
+	From int
+	// S = ρ_From + r*λ_From*w_From mod n
+	S *big.Int
+}
+
+// MarshalBinary encodes the message.
+func (m *KeyGenRound1) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagKeyGenRound1, m.From)
+	w.hash(m.Commitment)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message.
+func (m *KeyGenRound1) UnmarshalBinary(data []byte) error {
+	r := newReader(tagKeyGenRound1, data)
+	from, commitment := r.id(), r.hash()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.Commitment = from, commitment
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *KeyGenRound2) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagKeyGenRound2, m.From)
+	w.points(m.KeyCommitments)
+	w.points(m.MaskCommitments)
+	w.points(m.ZeroCommitments)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message and validates the points.
+func (m *KeyGenRound2) UnmarshalBinary(data []byte) error {
+	r := newReader(tagKeyGenRound2, data)
+	from := r.id()
+	key, mask, zero := r.points(), r.points(), r.points()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.KeyCommitments, m.MaskCommitments, m.ZeroCommitments = from, key, mask, zero
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *KeyGenShare) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagKeyGenShare, m.From)
+	w.id(m.To)
+	w.scalar(m.Key)
+	w.scalar(m.Mask)
+	w.scalar(m.Zero)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message and checks that the values are
+// reduced modulo n.
+func (m *KeyGenShare) UnmarshalBinary(data []byte) error {
+	r := newReader(tagKeyGenShare, data)
+	from, to := r.id(), r.id()
+	key, mask, zero := r.scalar(), r.scalar(), r.scalar()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.To, m.Key, m.Mask, m.Zero = from, to, key, mask, zero
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *KeyGenRound3) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagKeyGenRound3, m.From)
+	w.scalar(m.Mu)
+	w.proof(m.Proof)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message and checks that the values are
+// reduced modulo n.
+func (m *KeyGenRound3) UnmarshalBinary(data []byte) error {
+	r := newReader(tagKeyGenRound3, data)
+	from, mu, proof := r.id(), r.scalar(), r.proof()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.Mu, m.Proof = from, mu, proof
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *SignRound1) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagSignRound1, m.From)
+	w.hash(m.Commitment)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message.
+func (m *SignRound1) UnmarshalBinary(data []byte) error {
+	r := newReader(tagSignRound1, data)
+	from, commitment := r.id(), r.hash()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.Commitment = from, commitment
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *SignRound2) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagSignRound2, m.From)
+	w.point(m.A)
+	w.point(m.B)
+	w.proof(m.Proof)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message and validates the points.
+func (m *SignRound2) UnmarshalBinary(data []byte) error {
+	r := newReader(tagSignRound2, data)
+	from, a, b, proof := r.id(), r.point(), r.point(), r.proof()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.A, m.B, m.Proof = from, a, b, proof
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *SignRound3) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagSignRound3, m.From)
+	w.scalar(m.S)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes the message and checks that S is reduced modulo
+// n.
+func (m *SignRound3) UnmarshalBinary(data []byte) error {
+	r := newReader(tagSignRound3, data)
+	from, s := r.id(), r.scalar()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	m.From, m.S = from, s
+	return nil
+}
+
+// MarshalBinary encodes the key share for storage. The encoding contains
+// the secret share and must be protected accordingly.
+func (k *KeyShare) MarshalBinary() ([]byte, error) {
+	w := newWriter(tagKeyShare, k.ID)
+	w.id(k.Threshold)
+	w.id(k.Parties)
+	w.scalar(k.Share)
+	w.point(k.PublicKey)
+	w.points(k.Commitments)
+	return w.bytes()
+}
+
+// UnmarshalBinary decodes a stored key share and checks that the share is
+// consistent with its commitments.
+func (k *KeyShare) UnmarshalBinary(data []byte) error {
+	r := newReader(tagKeyShare, data)
+	id, m, n := r.id(), r.id(), r.id()
+	share, pub, commitments := r.scalar(), r.point(), r.points()
+	if err := r.finish(); err != nil {
+		return err
+	}
+	if err := checkParameters(m, n); err != nil {
+		return err
+	}
+	if id < 1 || id > n || len(commitments) != m || !checkShare(share, commitments, id) {
+		return exceptions.New(exceptions.ErrInvalidKey, "inconsistent key share")
+	}
+	k.ID, k.Threshold, k.Parties = id, m, n
+	k.Share, k.PublicKey, k.Commitments = share, pub, commitments
+	return nil
+}
+
+// writer encodes message fields, remembering the first error.
+type writer struct {
+	buf []byte
+	err error
+}
+
+func newWriter(tag byte, from int) *writer {
+	w := &writer{buf: []byte{tag}}
+	w.id(from)
+	return w
+}
+
+func (w *writer) id(v int) {
+	if v < 0 || v > 0xFFFF {
+		w.fail("party ID or count out of range")
+		return
+	}
+	w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(v))
+}
+
+func (w *writer) hash(h []byte) {
+	if len(h) != scalarSize {
+		w.fail("invalid commitment length")
+		return
+	}
+	w.buf = append(w.buf, h...)
+}
+
+func (w *writer) scalar(v *big.Int) {
+	if v == nil || v.Sign() < 0 || v.Cmp(sm2.GetN()) >= 0 {
+		w.fail("scalar out of range")
+		return
+	}
+	w.buf = append(w.buf, v.FillBytes(make([]byte, scalarSize))...)
+}
+
+func (w *writer) point(p *ec.Point) {
+	if p == nil || p.IsInfinity() {
+		w.fail("cannot encode point at infinity")
+		return
+	}
+	w.buf = append(w.buf, p.GetEncoded(false)...)
+}
+
+func (w *writer) points(ps []*ec.Point) {
+	w.id(len(ps))
+	for _, p := range ps {
+		w.point(p)
+	}
+}
+
+func (w *writer) proof(p *Proof) {
+	if p == nil {
+		w.fail("missing proof")
+		return
+	}
+	w.scalar(p.C)
+	w.scalar(p.Z)
+}
+
+func (w *writer) fail(msg string) {
+	if w.err == nil {
+		w.err = exceptions.New(exceptions.ErrInvalidParameter, msg)
+	}
+}
+
+func (w *writer) bytes() ([]byte, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf, nil
+}
+
+// reader decodes message fields, remembering the first error.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func newReader(tag byte, data []byte) *reader {
+	r := &reader{}
+	if len(data) == 0 || data[0] != tag {
+		r.err = exceptions.New(exceptions.ErrInvalidMessage, "invalid message")
+		return r
+	}
+	r.data = data[1:]
+	return r
+}
+
+func (r *reader) next(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < size {
+		r.err = exceptions.New(exceptions.ErrInvalidMessage, "truncated message")
+		return nil
+	}
+	b := r.data[:size]
+	r.data = r.data[size:]
+	return b
+}
+
+func (r *reader) id() int {
+	b := r.next(idSize)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+func (r *reader) hash() []byte {
+	b := r.next(scalarSize)
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (r *reader) scalar() *big.Int {
+	b := r.next(scalarSize)
+	if b == nil {
+		return nil
+	}
+	v := new(big.Int).SetBytes(b)
+	if v.Cmp(sm2.GetN()) >= 0 {
+		r.err = exceptions.New(exceptions.ErrInvalidMessage, "scalar out of range")
+		return nil
+	}
+	return v
+}
+
+// point decodes an uncompressed point and checks that it is a valid point
+// of the curve other than infinity.
+func (r *reader) point() *ec.Point {
+	b := r.next(pointSize)
+	if b == nil {
+		return nil
+	}
+	p, err := sm2.GetCurve().DecodePoint(b)
+	if b[0] != 0x04 || err != nil {
+		r.err = exceptions.New(exceptions.ErrInvalidMessage, "invalid point in message")
+		return nil
+	}
+	return p
+}
+
+func (r *reader) points() []*ec.Point {
+	count := r.id()
+	if r.err != nil {
+		return nil
+	}
+	if count*pointSize > len(r.data) {
+		r.err = exceptions.New(exceptions.ErrInvalidMessage, "truncated message")
+		return nil
+	}
+	ps := make([]*ec.Point, count)
+	for i := range ps {
+		ps[i] = r.point()
+	}
+	return ps
+}
+
+func (r *reader) proof() *Proof {
+	c, z := r.scalar(), r.scalar()
+	if r.err != nil {
+		return nil
+	}
+	return &Proof{C: c, Z: z}
+}
+
+// finish reports the first decoding error or trailing data.
+func (r *reader) finish() error {
+	if r.err == nil && len(r.data) != 0 {
+		r.err = exceptions.New(exceptions.ErrInvalidMessage, "trailing data in message")
+	}
+	return r.err
+}
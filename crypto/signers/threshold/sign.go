@@ -0,0 +1,304 @@
+package threshold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+const signLabel = "SM2-THRESHOLD-SIGN"
+
+// SignSession runs one signer's side of a threshold signature. All signers
+// in the set run the same rounds:
+//
+//  1. Round1 draws the nonce share ρ_i and broadcasts a hash commitment.
+//  2. Round2 takes all SignRound1 messages and reveals [ρ_i]G and
+//     [ρ_i](P + G) with a proof that they share ρ_i.
+//  3. Round3 takes all SignRound2 messages, computes R and broadcasts the
+//     partial signature s_i.
+//  4. Finish takes all SignRound3 messages and returns the signature.
+//
+// A SignSession signs one message once and is not safe for concurrent use.
+type SignSession struct {
+	share    *KeyShare
+	signers  []int
+	e        []byte
+	random   io.Reader
+	encoding signers.DSAEncoding
+	round    int
+
+	rho     *big.Int
+	own     *SignRound2
+	hashes  map[int][]byte
+	reveals map[int]*SignRound2
+	r       *big.Int
+}
+
+// NewSignSession starts signing message for the given user ID (nil means
+// sm2.DefaultUserID) together with the parties in signerIDs, which must
+// include share.ID and contain exactly share.Threshold parties. If random
+// is nil, crypto/rand.Reader is used.
+func NewSignSession(share *KeyShare, signerIDs []int, userID, message []byte, random io.Reader) (*SignSession, error) {
+	if share == nil || share.PublicKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid key share")
+	}
+	if userID == nil {
+		userID = []byte(sm2.DefaultUserID)
+	}
+	z, err := signers.NewSM2Signer().ComputeZ(userID, share.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate(z, 0, len(z))
+	digest.BlockUpdate(message, 0, len(message))
+	e := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(e, 0)
+
+	return NewSignSessionDigest(share, signerIDs, e, random)
+}
+
+// NewSignSessionDigest starts signing a precomputed digest e = SM3(Z || M).
+func NewSignSessionDigest(share *KeyShare, signerIDs []int, e []byte, random io.Reader) (*SignSession, error) {
+	if share == nil || share.PublicKey == nil || share.Share == nil ||
+		len(share.Commitments) != share.Threshold {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid key share")
+	}
+	if len(e) != 32 {
+		return nil, exceptions.New(exceptions.ErrDataLength, "invalid digest length")
+	}
+	set, err := checkSet(signerIDs, share.Parties)
+	if err != nil {
+		return nil, err
+	}
+	if len(set) != share.Threshold {
+		return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "need exactly %d signers, got %d", share.Threshold, len(set))
+	}
+	member := false
+	for _, id := range set {
+		member = member || id == share.ID
+	}
+	if !member {
+		return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "party %d is not among the signers", share.ID)
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	return &SignSession{
+		share:    share,
+		signers:  set,
+		e:        append([]byte{}, e...),
+		random:   random,
+		encoding: signers.StandardDSAEncoding{},
+	}, nil
+}
+
+// SetEncoding sets the signature encoding; the default is DER.
+func (s *SignSession) SetEncoding(encoding signers.DSAEncoding) {
+	if encoding == nil {
+		encoding = signers.StandardDSAEncoding{}
+	}
+	s.encoding = encoding
+}
+
+// Round1 draws the nonce share and returns the broadcast hash commitment
+// to the values revealed in Round2.
+func (s *SignSession) Round1() (*SignRound1, error) {
+	if s.round != 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: Round1 called out of order")
+	}
+
+	rho, err := randomScalar(s.random)
+	if err != nil {
+		return nil, err
+	}
+	g := sm2.GetG()
+	q := s.share.PublicKey.Add(g)
+	a := g.MultiplySecret(rho)
+	b := q.MultiplySecret(rho)
+	proof, err := proveDLEQ(s.random, rho, g, a, q, b)
+	if err != nil {
+		return nil, err
+	}
+
+	s.rho = rho
+	s.own = &SignRound2{From: s.share.ID, A: a, B: b, Proof: proof}
+	s.round = 1
+	return &SignRound1{From: s.share.ID, Commitment: s.own.hash(s.e)}, nil
+}
+
+// Round2 records the other signers' commitments and reveals this signer's
+// nonce points.
+func (s *SignSession) Round2(round1 []*SignRound1) (*SignRound2, error) {
+	if s.round != 1 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: Round2 called out of order")
+	}
+
+	s.hashes = make(map[int][]byte, len(s.signers))
+	for _, msg := range round1 {
+		if msg == nil || !s.isSigner(msg.From) || s.hashes[msg.From] != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: invalid or duplicate round 1 message")
+		}
+		s.hashes[msg.From] = msg.Commitment
+	}
+	if len(s.hashes) != len(s.signers) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: round 1 messages missing")
+	}
+	if !bytes.Equal(s.hashes[s.share.ID], s.own.hash(s.e)) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: own round 1 commitment altered")
+	}
+
+	s.round = 2
+	return s.own, nil
+}
+
+// Round3 verifies the revealed nonce points, computes R = [ρ](P + G) and
+// returns this signer's partial signature s_i = ρ_i + r*λ_i*w_i.
+func (s *SignSession) Round3(round2 []*SignRound2) (*SignRound3, error) {
+	if s.round != 2 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: Round3 called out of order")
+	}
+
+	g := sm2.GetG()
+	q := s.share.PublicKey.Add(g)
+	s.reveals = make(map[int]*SignRound2, len(s.signers))
+	for _, msg := range round2 {
+		if msg == nil || !s.isSigner(msg.From) || s.reveals[msg.From] != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: invalid or duplicate round 2 message")
+		}
+		// The hash binds the nonce points to e, so a signer that was shown
+		// another message is detected here
+		if msg.A == nil || msg.B == nil || !bytes.Equal(msg.hash(s.e), s.hashes[msg.From]) {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "SignSession: party %d revealed nonce points that do not match round 1", msg.From)
+		}
+		if !verifyDLEQ(msg.Proof, g, msg.A, q, msg.B) {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "SignSession: party %d sent an invalid nonce proof", msg.From)
+		}
+		s.reveals[msg.From] = msg
+	}
+	if len(s.reveals) != len(s.signers) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: round 2 messages missing")
+	}
+
+	bs := make([]*ec.Point, 0, len(s.signers))
+	for _, id := range s.signers {
+		bs = append(bs, s.reveals[id].B)
+	}
+	point := sumPoints(bs)
+	if point.IsInfinity() {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: degenerate nonce, start a new session")
+	}
+	n := sm2.GetN()
+	r := new(big.Int).SetBytes(s.e)
+	r.Add(r, point.GetXCoord().ToBigInt())
+	r.Mod(r, n)
+	if r.Sign() == 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: degenerate nonce, start a new session")
+	}
+	s.r = r
+
+	sf := sm2.GetCurve().GetScalarField()
+	lambda := lagrange(s.share.ID, s.signers)
+	partial := sf.Add(s.rho, sf.Mul(sf.Mul(r, lambda), s.share.Share))
+
+	util.ClearBigInt(s.rho)
+	s.rho = nil
+	s.round = 3
+	return &SignRound3{From: s.share.ID, S: partial}, nil
+}
+
+// Finish checks every partial signature against the signer's public share,
+// combines them into s = Σ s_i - r and returns the encoded signature. The
+// signature is verified against the public key before it is returned.
+func (s *SignSession) Finish(round3 []*SignRound3) ([]byte, error) {
+	if s.round != 3 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: Finish called out of order")
+	}
+	s.round = 4
+
+	n := sm2.GetN()
+	partials := make(map[int]*big.Int, len(s.signers))
+	for _, msg := range round3 {
+		if msg == nil || !s.isSigner(msg.From) || partials[msg.From] != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: invalid or duplicate round 3 message")
+		}
+		if msg.S == nil || msg.S.Sign() < 0 || msg.S.Cmp(n) >= 0 || !s.checkPartial(msg.From, msg.S) {
+			return nil, exceptions.Newf(exceptions.ErrInvalidMessage, "SignSession: party %d sent an invalid partial signature", msg.From)
+		}
+		partials[msg.From] = msg.S
+	}
+	if len(partials) != len(s.signers) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: round 3 messages missing")
+	}
+
+	sig := new(big.Int).Neg(s.r)
+	for _, id := range s.signers {
+		sig.Add(sig, partials[id])
+	}
+	sig.Mod(sig, n)
+	if sig.Sign() == 0 || new(big.Int).Add(s.r, sig).Cmp(n) == 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "SignSession: degenerate signature, start a new session")
+	}
+
+	signature, err := s.encoding.Encode(n, s.r, sig)
+	if err != nil {
+		return nil, err
+	}
+	verifier := signers.NewSM2SignerWithEncoding(s.encoding, nil)
+	if err := verifier.Init(false, params.NewECPublicKeyParameters(s.share.PublicKey, sm2.GetECDomainParameters())); err != nil {
+		return nil, err
+	}
+	if valid, err := verifier.VerifyDigest(s.e, signature); err != nil || !valid {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "SignSession: combined signature failed verification")
+	}
+	return signature, nil
+}
+
+// Destroy clears the nonce share and ends the session. The KeyShare is
+// owned by the caller and is not cleared.
+func (s *SignSession) Destroy() {
+	util.ClearBigInt(s.rho)
+	s.rho = nil
+	s.round = 4
+}
+
+// checkPartial verifies [s_j]G = A_j + [r*λ_j]W_j for signer j.
+func (s *SignSession) checkPartial(j int, partial *big.Int) bool {
+	n := sm2.GetN()
+	scalar := new(big.Int).Mul(s.r, lagrange(j, s.signers))
+	scalar.Mod(scalar, n)
+	expected := ec.SumOfTwoMultiplies(s.reveals[j].A, big.NewInt(1), s.share.publicShare(j), scalar)
+	return sm2.GetG().Multiply(partial).Equals(expected)
+}
+
+// isSigner reports whether id is in the signing set.
+func (s *SignSession) isSigner(id int) bool {
+	for _, j := range s.signers {
+		if j == id {
+			return true
+		}
+	}
+	return false
+}
+
+// hash returns the round 1 commitment to the message, bound to the digest
+// being signed.
+func (m *SignRound2) hash(e []byte) []byte {
+	h := hashCommitment(signLabel, m.From, []*ec.Point{m.A, m.B})
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate(h, 0, len(h))
+	digest.BlockUpdate(e, 0, len(e))
+	out := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(out, 0)
+	return out
+}
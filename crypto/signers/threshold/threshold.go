@@ -0,0 +1,205 @@
+// Package threshold implements m-of-n threshold SM2 signatures with
+// distributed key generation: no party, and no dealer, ever holds the
+// private key d, and any m of the n parties can sign together.
+//
+// The parties hold Shamir shares of w = (1 + d)^-1 of degree m-1. Key
+// generation is a Feldman-verifiable distributed key generation for w,
+// followed by the masked inversion μ = w*a of a second shared random value
+// a, which yields [w^-1]G = [μ^-1]([a]G) and thus the public key
+// P = [w^-1]G - G. Multiplying two shared values needs 2m-1 parties, so
+// key generation requires n >= 2m-1; signing needs only m.
+//
+// Signing uses the nonce k = ρ*(1 + d) for a jointly random ρ, so that
+// R = [k]G = [ρ](P + G) and
+//
+//	s = w*(k + r) - r = ρ + w*r - r
+//
+// is linear in the shares: each signer contributes s_i = ρ_i + r*λ_i*w_i.
+// Every contribution is checked against public commitments, and the result
+// is a standard SM2 signature that verifies with signers.SM2Signer.
+//
+// Each protocol step broadcasts a hash commitment to the values revealed
+// in the next step, so that no party can choose its values after seeing
+// the others'. All messages implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler. Broadcast messages must reach every party
+// unchanged; KeyGenShare messages must travel over confidential,
+// authenticated channels.
+package threshold
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// KeyShare is one party's result of key generation.
+type KeyShare struct {
+	// ID is the party's index in [1, Parties].
+	ID int
+	// Threshold is the number m of parties needed to sign.
+	Threshold int
+	// Parties is the total number n of parties.
+	Parties int
+	// Share is the party's Shamir share w_ID of w = (1 + d)^-1.
+	Share *big.Int
+	// PublicKey is the joint SM2 public key P.
+	PublicKey *ec.Point
+	// Commitments are the Feldman commitments [c_k]G to the coefficients
+	// of the polynomial sharing w, from which the public share [w_j]G of
+	// every party j is derived.
+	Commitments []*ec.Point
+}
+
+// Destroy clears the share. The KeyShare cannot be used to sign
+// afterwards.
+func (k *KeyShare) Destroy() {
+	util.ClearBigInt(k.Share)
+}
+
+// publicShare returns [w_id]G.
+func (k *KeyShare) publicShare(id int) *ec.Point {
+	return evalCommitments(k.Commitments, id)
+}
+
+// checkParameters validates a threshold m and party count n.
+func checkParameters(m, n int) error {
+	if m < 1 || 2*m-1 > n {
+		return exceptions.Newf(exceptions.ErrInvalidParameter, "threshold %d of %d parties not supported: key generation needs at least 2m-1 parties", m, n)
+	}
+	if n > 0xFFFF {
+		return exceptions.New(exceptions.ErrInvalidParameter, "too many parties")
+	}
+	return nil
+}
+
+// checkSet validates a set of party IDs in [1, n], returning it sorted.
+func checkSet(ids []int, n int) ([]int, error) {
+	set := append([]int{}, ids...)
+	sort.Ints(set)
+	for i, id := range set {
+		if id < 1 || id > n {
+			return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "invalid party ID %d", id)
+		}
+		if i > 0 && set[i-1] == id {
+			return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "duplicate party ID %d", id)
+		}
+	}
+	return set, nil
+}
+
+// lagrange returns the Lagrange coefficient of party id for interpolating
+// at zero over the given set: Π j/(j - id) for j != id.
+func lagrange(id int, set []int) *big.Int {
+	n := sm2.GetN()
+	num := big.NewInt(1)
+	den := big.NewInt(1)
+	for _, j := range set {
+		if j == id {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		den.Mul(den, big.NewInt(int64(j-id)))
+	}
+	den.Mod(den, n)
+	num.Mul(num, den.ModInverse(den, n))
+	return num.Mod(num, n)
+}
+
+// clearScalars clears secret scalars, such as polynomial coefficients.
+func clearScalars(xs []*big.Int) {
+	for _, x := range xs {
+		util.ClearBigInt(x)
+	}
+}
+
+// randomPolynomial returns degree+1 random coefficients in [1, n-1].
+func randomPolynomial(random io.Reader, degree int) ([]*big.Int, error) {
+	coeffs := make([]*big.Int, degree+1)
+	for i := range coeffs {
+		c, err := randomScalar(random)
+		if err != nil {
+			return nil, err
+		}
+		coeffs[i] = c
+	}
+	return coeffs, nil
+}
+
+// evalPolynomial evaluates a secret polynomial at x in constant time.
+func evalPolynomial(coeffs []*big.Int, x int) *big.Int {
+	sf := sm2.GetCurve().GetScalarField()
+	xBig := big.NewInt(int64(x))
+	result := new(big.Int)
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		result = sf.Add(sf.Mul(result, xBig), coeffs[i])
+	}
+	return result
+}
+
+// evalCommitments evaluates the committed polynomial at x in the exponent:
+// Σ [x^k]C_k.
+func evalCommitments(commitments []*ec.Point, x int) *ec.Point {
+	n := sm2.GetN()
+	scalars := make([]*big.Int, len(commitments))
+	power := big.NewInt(1)
+	for k := range commitments {
+		scalars[k] = new(big.Int).Set(power)
+		power.Mul(power, big.NewInt(int64(x)))
+		power.Mod(power, n)
+	}
+	return ec.SumOfMultiplies(commitments, scalars)
+}
+
+// commitPoints multiplies each coefficient with G.
+func commitPoints(coeffs []*big.Int) []*ec.Point {
+	points := make([]*ec.Point, len(coeffs))
+	for i, c := range coeffs {
+		points[i] = sm2.GetG().MultiplySecret(c)
+	}
+	return points
+}
+
+// hashCommitment returns SM3(label || encoded points), binding a party to
+// the points it reveals in the next step.
+func hashCommitment(label string, id int, groups ...[]*ec.Point) []byte {
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate([]byte(label), 0, len(label))
+	digest.Update(byte(id >> 8))
+	digest.Update(byte(id))
+	for _, group := range groups {
+		for _, p := range group {
+			enc := p.GetEncoded(false)
+			digest.BlockUpdate(enc, 0, len(enc))
+		}
+	}
+	out := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(out, 0)
+	return out
+}
+
+// randomScalar returns a uniformly random scalar in [1, n-1].
+func randomScalar(random io.Reader) (*big.Int, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	calc := signers.NewRandomDSAKCalculator()
+	calc.Init(sm2.GetN(), random)
+	return calc.NextK()
+}
+
+// sumPoints adds points.
+func sumPoints(points []*ec.Point) *ec.Point {
+	sum := sm2.GetCurve().GetInfinity()
+	for _, p := range points {
+		sum = sum.Add(p)
+	}
+	return sum
+}
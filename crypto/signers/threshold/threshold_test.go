@@ -0,0 +1,391 @@
+package threshold
+
+import (
+	"bytes"
+	"encoding"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+)
+
+type message interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// transmit copies a message into out through its binary form, as if it
+// had been sent to the other parties.
+func transmit(t *testing.T, in, out message) {
+	t.Helper()
+	data, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+}
+
+// runKeyGen runs an m-of-n key generation among n in-process parties,
+// passing every message through its binary form.
+func runKeyGen(t *testing.T, m, n int) []*KeyShare {
+	t.Helper()
+	parties := make([]*KeyGen, n)
+	round1 := make([]*KeyGenRound1, n)
+	for i := range parties {
+		party, err := NewKeyGen(i+1, m, n, nil)
+		if err != nil {
+			t.Fatalf("NewKeyGen failed: %v", err)
+		}
+		msg, err := party.Round1()
+		if err != nil {
+			t.Fatalf("Round1 failed: %v", err)
+		}
+		parties[i] = party
+		round1[i] = new(KeyGenRound1)
+		transmit(t, msg, round1[i])
+	}
+
+	round2 := make([]*KeyGenRound2, n)
+	shares := make([][]*KeyGenShare, n)
+	for i, party := range parties {
+		msg, out, err := party.Round2(round1)
+		if err != nil {
+			t.Fatalf("Round2 failed: %v", err)
+		}
+		round2[i] = new(KeyGenRound2)
+		transmit(t, msg, round2[i])
+		for _, share := range out {
+			received := new(KeyGenShare)
+			transmit(t, share, received)
+			shares[received.To-1] = append(shares[received.To-1], received)
+		}
+	}
+
+	round3 := make([]*KeyGenRound3, n)
+	for i, party := range parties {
+		msg, err := party.Round3(round2, shares[i])
+		if err != nil {
+			t.Fatalf("Round3 failed: %v", err)
+		}
+		round3[i] = new(KeyGenRound3)
+		transmit(t, msg, round3[i])
+	}
+
+	keys := make([]*KeyShare, n)
+	for i, party := range parties {
+		key, err := party.Finish(round3)
+		if err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// startSign creates sessions for the given signers and runs the first two
+// rounds, returning the sessions and the revealed nonce points.
+func startSign(t *testing.T, keys []*KeyShare, ids []int, message []byte) ([]*SignSession, []*SignRound2) {
+	t.Helper()
+	sessions := make([]*SignSession, len(ids))
+	round1 := make([]*SignRound1, len(ids))
+	for i, id := range ids {
+		session, err := NewSignSession(keys[id-1], ids, nil, message, nil)
+		if err != nil {
+			t.Fatalf("NewSignSession failed: %v", err)
+		}
+		msg, err := session.Round1()
+		if err != nil {
+			t.Fatalf("Round1 failed: %v", err)
+		}
+		sessions[i] = session
+		round1[i] = new(SignRound1)
+		transmit(t, msg, round1[i])
+	}
+
+	round2 := make([]*SignRound2, len(ids))
+	for i, session := range sessions {
+		msg, err := session.Round2(round1)
+		if err != nil {
+			t.Fatalf("Round2 failed: %v", err)
+		}
+		round2[i] = new(SignRound2)
+		transmit(t, msg, round2[i])
+	}
+	return sessions, round2
+}
+
+func runSign(t *testing.T, keys []*KeyShare, ids []int, message []byte) []byte {
+	t.Helper()
+	sessions, round2 := startSign(t, keys, ids, message)
+
+	round3 := make([]*SignRound3, len(ids))
+	for i, session := range sessions {
+		msg, err := session.Round3(round2)
+		if err != nil {
+			t.Fatalf("Round3 failed: %v", err)
+		}
+		round3[i] = new(SignRound3)
+		transmit(t, msg, round3[i])
+	}
+
+	var signature []byte
+	for _, session := range sessions {
+		sig, err := session.Finish(round3)
+		if err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+		if signature != nil && !bytes.Equal(sig, signature) {
+			t.Fatal("Signers produced different signatures")
+		}
+		signature = sig
+	}
+	return signature
+}
+
+func verify(key *KeyShare, message, signature []byte) bool {
+	verifier := signers.NewSM2Signer()
+	if err := verifier.Init(false, params.NewECPublicKeyParameters(key.PublicKey, sm2.GetECDomainParameters())); err != nil {
+		return false
+	}
+	verifier.BlockUpdate(message, 0, len(message))
+	valid, err := verifier.VerifySignature(signature)
+	return err == nil && valid
+}
+
+func TestThresholdKeyGen(t *testing.T) {
+	for _, tc := range []struct{ m, n int }{{1, 1}, {2, 3}, {3, 5}} {
+		keys := runKeyGen(t, tc.m, tc.n)
+		for _, key := range keys[1:] {
+			if !key.PublicKey.Equals(keys[0].PublicKey) {
+				t.Fatalf("%d-of-%d: parties disagree on the public key", tc.m, tc.n)
+			}
+		}
+		if !sm2.ValidatePublicKey(keys[0].PublicKey) {
+			t.Errorf("%d-of-%d: invalid public key", tc.m, tc.n)
+		}
+
+		// Any m shares interpolate to w = (1 + d)^-1
+		set := make([]int, tc.m)
+		for i := range set {
+			set[i] = tc.n - i
+		}
+		set, _ = checkSet(set, tc.n)
+		n := sm2.GetN()
+		w := new(big.Int)
+		for _, id := range set {
+			w.Add(w, new(big.Int).Mul(lagrange(id, set), keys[id-1].Share))
+		}
+		d := w.ModInverse(w.Mod(w, n), n)
+		d.Sub(d, big.NewInt(1))
+		if !sm2.GetG().Multiply(d).Equals(keys[0].PublicKey) {
+			t.Errorf("%d-of-%d: shares do not match the public key", tc.m, tc.n)
+		}
+	}
+}
+
+func TestThresholdSign(t *testing.T) {
+	keys := runKeyGen(t, 2, 3)
+	message := []byte("threshold message")
+
+	for _, ids := range [][]int{{1, 2}, {1, 3}, {3, 2}} {
+		signature := runSign(t, keys, ids, message)
+		if !verify(keys[0], message, signature) {
+			t.Errorf("signers %v: signature failed verification", ids)
+		}
+		if verify(keys[0], []byte("other message"), signature) {
+			t.Errorf("signers %v: signature verified for another message", ids)
+		}
+	}
+
+	keys = runKeyGen(t, 3, 5)
+	signature := runSign(t, keys, []int{2, 4, 5}, message)
+	if !verify(keys[0], message, signature) {
+		t.Error("3-of-5 signature failed verification")
+	}
+
+	// Plain encoding and a custom user ID
+	userID := []byte("alice@example.com")
+	ids := []int{1, 3, 5}
+	sessions := make([]*SignSession, len(ids))
+	round1 := make([]*SignRound1, len(ids))
+	for i, id := range ids {
+		sessions[i], _ = NewSignSession(keys[id-1], ids, userID, message, nil)
+		sessions[i].SetEncoding(signers.PlainDSAEncoding{})
+		round1[i], _ = sessions[i].Round1()
+	}
+	round2 := make([]*SignRound2, len(ids))
+	for i, session := range sessions {
+		round2[i], _ = session.Round2(round1)
+	}
+	round3 := make([]*SignRound3, len(ids))
+	for i, session := range sessions {
+		round3[i], _ = session.Round3(round2)
+	}
+	signature, err := sessions[0].Finish(round3)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	verifier := signers.NewSM2SignerWithEncoding(signers.PlainDSAEncoding{}, nil)
+	pub := params.NewECPublicKeyParameters(keys[0].PublicKey, sm2.GetECDomainParameters())
+	_ = verifier.Init(false, crypto.NewParametersWithID(pub, userID))
+	verifier.BlockUpdate(message, 0, len(message))
+	if valid, err := verifier.VerifySignature(signature); err != nil || !valid || len(signature) != 64 {
+		t.Errorf("Plain signature with custom user ID failed verification: %v", err)
+	}
+}
+
+func TestThresholdKeyGenCheating(t *testing.T) {
+	const m, n = 2, 3
+	parties := make([]*KeyGen, n)
+	round1 := make([]*KeyGenRound1, n)
+	for i := range parties {
+		parties[i], _ = NewKeyGen(i+1, m, n, nil)
+		round1[i], _ = parties[i].Round1()
+	}
+	round2 := make([]*KeyGenRound2, n)
+	shares := make([][]*KeyGenShare, n)
+	for i, party := range parties {
+		var out []*KeyGenShare
+		round2[i], out, _ = party.Round2(round1)
+		for _, share := range out {
+			shares[share.To-1] = append(shares[share.To-1], share)
+		}
+	}
+
+	// Party 2 sends party 1 a share that does not match its commitments
+	for _, share := range shares[0] {
+		if share.From == 2 {
+			share.Key = new(big.Int).Add(share.Key, big.NewInt(1))
+		}
+	}
+	_, err := parties[0].Round3(round2, shares[0])
+	if err == nil || err.Error() != "KeyGen: party 2 sent a share that does not match its commitments" {
+		t.Errorf("Expected bad share from party 2 to be detected, got %v", err)
+	}
+
+	// Party 3 reveals commitments other than those it committed to
+	swapped := *round2[2]
+	swapped.KeyCommitments = round2[1].KeyCommitments
+	tampered := []*KeyGenRound2{round2[0], round2[1], &swapped}
+	if _, err := parties[1].Round3(tampered, shares[1]); err == nil {
+		t.Error("Expected mismatched commitments from party 3 to be detected")
+	}
+
+	round3 := make([]*KeyGenRound3, n)
+	for i := 1; i < n; i++ {
+		if round3[i], err = parties[i].Round3(round2, shares[i]); err != nil {
+			t.Fatalf("Round3 failed: %v", err)
+		}
+	}
+	// Party 1 lies about its masked value
+	round3[0] = &KeyGenRound3{From: 1, Mu: big.NewInt(1), Proof: round3[1].Proof}
+	if _, err := parties[1].Finish(round3); err == nil {
+		t.Error("Expected invalid masked value to be detected")
+	}
+
+	if _, err := parties[2].Round1(); err == nil {
+		t.Error("Expected error calling Round1 out of order")
+	}
+}
+
+func TestThresholdSignCheating(t *testing.T) {
+	keys := runKeyGen(t, 2, 3)
+	ids := []int{1, 2}
+	message := []byte("threshold message")
+
+	// A bad partial signature is attributed to its sender
+	sessions, round2 := startSign(t, keys, ids, message)
+	round3 := make([]*SignRound3, len(ids))
+	for i, session := range sessions {
+		round3[i], _ = session.Round3(round2)
+	}
+	round3[1].S = new(big.Int).Add(round3[1].S, big.NewInt(1))
+	_, err := sessions[0].Finish(round3)
+	if err == nil || err.Error() != "SignSession: party 2 sent an invalid partial signature" {
+		t.Errorf("Expected bad partial signature from party 2 to be detected, got %v", err)
+	}
+	if _, err := sessions[0].Finish(round3); err == nil {
+		t.Error("Expected error reusing a session")
+	}
+
+	// Signers that were shown different messages do not agree on round 1
+	first, _ := NewSignSession(keys[0], ids, nil, message, nil)
+	second, _ := NewSignSession(keys[1], ids, nil, []byte("other message"), nil)
+	r1a, _ := first.Round1()
+	r1b, _ := second.Round1()
+	r2a, _ := first.Round2([]*SignRound1{r1a, r1b})
+	r2b, _ := second.Round2([]*SignRound1{r1a, r1b})
+	if _, err := first.Round3([]*SignRound2{r2a, r2b}); err == nil {
+		t.Error("Expected signers with different messages to be detected")
+	}
+
+	// Nonce points with a bad proof
+	sessions, round2 = startSign(t, keys, ids, message)
+	round2[1].B = round2[1].A
+	if _, err := sessions[0].Round3(round2); err == nil {
+		t.Error("Expected altered nonce points to be detected")
+	}
+}
+
+func TestThresholdInvalidParameters(t *testing.T) {
+	for _, tc := range []struct{ id, m, n int }{{1, 3, 3}, {1, 0, 3}, {1, 2, 2}, {0, 2, 3}, {4, 2, 3}} {
+		if _, err := NewKeyGen(tc.id, tc.m, tc.n, nil); err == nil {
+			t.Errorf("Expected error for party %d of %d-of-%d", tc.id, tc.m, tc.n)
+		}
+	}
+
+	keys := runKeyGen(t, 2, 3)
+	message := []byte("message")
+	for _, ids := range [][]int{{1}, {1, 2, 3}, {2, 3}, {1, 1}, {1, 4}} {
+		if _, err := NewSignSession(keys[0], ids, nil, message, nil); err == nil {
+			t.Errorf("Expected error for signers %v", ids)
+		}
+	}
+}
+
+func TestThresholdMessages(t *testing.T) {
+	keys := runKeyGen(t, 2, 3)
+
+	var restored KeyShare
+	transmit(t, keys[1], &restored)
+	if restored.ID != 2 || restored.Share.Cmp(keys[1].Share) != 0 || !restored.PublicKey.Equals(keys[1].PublicKey) {
+		t.Error("Key share changed in round trip")
+	}
+	if signature := runSign(t, []*KeyShare{keys[0], &restored}, []int{1, 2}, []byte("message")); !verify(keys[0], []byte("message"), signature) {
+		t.Error("Signature with restored key share failed verification")
+	}
+
+	data, _ := keys[1].MarshalBinary()
+	corrupted := append([]byte{}, data...)
+	corrupted[1+3*idSize+scalarSize-1] ^= 0x01
+	if err := new(KeyShare).UnmarshalBinary(corrupted); err == nil {
+		t.Error("Key share inconsistent with its commitments accepted")
+	}
+
+	// Tags keep messages of different steps apart
+	_, round2 := startSign(t, keys, []int{1, 2}, []byte("message"))
+	data, _ = round2[0].MarshalBinary()
+	if err := new(KeyGenRound2).UnmarshalBinary(data); err == nil {
+		t.Error("Sign message accepted as key generation message")
+	}
+	if err := new(SignRound2).UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("Truncated message accepted")
+	}
+	if err := new(SignRound2).UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("Message with trailing data accepted")
+	}
+	corrupted = append([]byte{}, data...)
+	corrupted[1+idSize+pointSize-1] ^= 0x01
+	if err := new(SignRound2).UnmarshalBinary(corrupted); err == nil {
+		t.Error("Message with off-curve point accepted")
+	}
+
+	if _, err := (&SignRound3{From: 1, S: sm2.GetN()}).MarshalBinary(); err == nil {
+		t.Error("Expected error encoding an unreduced scalar")
+	}
+}
@@ -0,0 +1,116 @@
+package twoparty
+
+import (
+	"crypto/subtle"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// DecryptSession is the client side of one decryption. It is used once.
+type DecryptSession struct {
+	c1     *ec.Point
+	c2, c3 []byte
+	done   bool
+}
+
+// StartDecrypt starts decrypting an SM2 ciphertext in the given layout
+// (sm2.Mode_C1C2C3, sm2.Mode_C1C3C2 or sm2.Mode_DER) and returns the
+// request T1 = [d1^-1]C1 for the server.
+func (c *Client) StartDecrypt(ciphertext []byte, mode int) (*DecryptSession, *DecryptRequest, error) {
+	if c.publicKey == nil {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidState, "key generation not completed")
+	}
+	raw, err := sm2.ConvertCiphertext(ciphertext, mode, sm2.Mode_C1C3C2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c1Len := pointSize
+	if raw[0] == 0x02 || raw[0] == 0x03 {
+		c1Len = 1 + scalarSize
+	}
+	c1, err := sm2.GetCurve().DecodePoint(raw[:c1Len])
+	if err != nil {
+		return nil, nil, exceptions.Newf(exceptions.ErrInvalidCipherText, "invalid C1 point: %w", err)
+	}
+
+	sf := sm2.GetCurve().GetScalarField()
+	d1Inv := sf.Inverse(c.d1)
+	t1 := c1.MultiplySecret(d1Inv)
+	util.ClearBigInt(d1Inv)
+
+	session := &DecryptSession{
+		c1: c1,
+		c3: raw[c1Len : c1Len+scalarSize],
+		c2: raw[c1Len+scalarSize:],
+	}
+	return session, &DecryptRequest{T1: t1}, nil
+}
+
+// Finish completes the decryption with the server's response. The shared
+// point is [d]C1 = T2 - C1 = [(d1*d2)^-1 - 1]C1; from there decryption
+// proceeds as in GM/T 0003.4, including the check of C3.
+func (s *DecryptSession) Finish(resp *DecryptResponse) ([]byte, error) {
+	if s.done {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "decrypt session already finished")
+	}
+	s.done = true
+
+	if resp == nil || resp.T2 == nil || !sm2.ValidatePublicKey(resp.T2) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid decrypt response")
+	}
+	shared := resp.T2.Subtract(s.c1)
+	if shared.IsInfinity() {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid decrypt response")
+	}
+
+	x2 := shared.GetXCoord().ToBigInt().FillBytes(make([]byte, scalarSize))
+	y2 := shared.GetYCoord().ToBigInt().FillBytes(make([]byte, scalarSize))
+
+	kdfInput := append(append([]byte{}, x2...), y2...)
+	t := sm2.KDF(kdfInput, len(s.c2))
+	defer util.Clear(kdfInput)
+	defer util.Clear(t)
+	defer util.Clear(x2)
+	defer util.Clear(y2)
+	zeroKey := 0
+	if len(s.c2) > 0 && sm2.IsAllZero(t) {
+		zeroKey = 1
+	}
+	plaintext := make([]byte, len(s.c2))
+	for i := range plaintext {
+		plaintext[i] = s.c2[i] ^ t[i]
+	}
+
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate(x2, 0, len(x2))
+	digest.BlockUpdate(plaintext, 0, len(plaintext))
+	digest.BlockUpdate(y2, 0, len(y2))
+	u := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(u, 0)
+
+	// Both checks are made, and fail with the same error
+	if zeroKey|(1^subtle.ConstantTimeCompare(u, s.c3)) != 0 {
+		util.Clear(plaintext)
+		return nil, exceptions.New(exceptions.ErrAuthenticationFailed, "decryption failed")
+	}
+	return plaintext, nil
+}
+
+// Decrypt answers a decrypt request with T2 = [d2^-1]T1.
+func (s *Server) Decrypt(req *DecryptRequest) (*DecryptResponse, error) {
+	if req == nil || req.T1 == nil || !sm2.ValidatePublicKey(req.T1) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid decrypt request")
+	}
+	if s.d2 == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "server destroyed")
+	}
+	sf := sm2.GetCurve().GetScalarField()
+	d2Inv := sf.Inverse(s.d2)
+	defer util.ClearBigInt(d2Inv)
+	return &DecryptResponse{T2: req.T1.MultiplySecret(d2Inv)}, nil
+}
@@ -0,0 +1,208 @@
+package twoparty
+
+import (
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// Message type tags. Each binary message starts with its tag so that a
+// message cannot be mistaken for one of another protocol step.
+const (
+	tagKeyGenRequest   byte = 1
+	tagKeyGenResponse  byte = 2
+	tagSignRequest     byte = 3
+	tagSignResponse    byte = 4
+	tagDecryptRequest  byte = 5
+	tagDecryptResponse byte = 6
+)
+
+// Sizes of the encoded fields: points are uncompressed, scalars and digests
+// are 32-byte big-endian.
+const (
+	pointSize  = 65
+	scalarSize = 32
+)
+
+// KeyGenRequest is sent by the client to start key generation.
+type KeyGenRequest struct {
+	// P1 = [d1^-1]G
+	P1 *ec.Point
+}
+
+// KeyGenResponse carries the joint public key from the server.
+type KeyGenResponse struct {
+	// PublicKey = [d2^-1]P1 - G
+	PublicKey *ec.Point
+}
+
+// SignRequest is sent by the client to start a signature.
+type SignRequest struct {
+	// E = H(Z || M), the digest to sign
+	E []byte
+	// Q1 = [k1]G
+	Q1 *ec.Point
+}
+
+// SignResponse carries the server's part of a signature.
+type SignResponse struct {
+	// R = (e + x1) mod n, where (x1, y1) = [k3]Q1 + [k2]G
+	R *big.Int
+	// S2 = d2 * k3 mod n
+	S2 *big.Int
+	// S3 = d2 * (r + k2) mod n
+	S3 *big.Int
+}
+
+// DecryptRequest is sent by the client to start a decryption.
+type DecryptRequest struct {
+	// T1 = [d1^-1]C1
+	T1 *ec.Point
+}
+
+// DecryptResponse carries the server's part of a decryption.
+type DecryptResponse struct {
+	// T2 = [d2^-1]T1
+	T2 *ec.Point
+}
+
+// MarshalBinary encodes the message.
+func (m *KeyGenRequest) MarshalBinary() ([]byte, error) {
+	return marshalPoint(tagKeyGenRequest, m.P1)
+}
+
+// UnmarshalBinary decodes the message and validates P1.
+func (m *KeyGenRequest) UnmarshalBinary(data []byte) error {
+	p, err := unmarshalPoint(tagKeyGenRequest, data)
+	if err != nil {
+		return err
+	}
+	m.P1 = p
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *KeyGenResponse) MarshalBinary() ([]byte, error) {
+	return marshalPoint(tagKeyGenResponse, m.PublicKey)
+}
+
+// UnmarshalBinary decodes the message and validates the public key.
+func (m *KeyGenResponse) UnmarshalBinary(data []byte) error {
+	p, err := unmarshalPoint(tagKeyGenResponse, data)
+	if err != nil {
+		return err
+	}
+	m.PublicKey = p
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *SignRequest) MarshalBinary() ([]byte, error) {
+	if len(m.E) != scalarSize {
+		return nil, exceptions.New(exceptions.ErrDataLength, "invalid digest length")
+	}
+	q1, err := marshalPoint(tagSignRequest, m.Q1)
+	if err != nil {
+		return nil, err
+	}
+	return append(q1, m.E...), nil
+}
+
+// UnmarshalBinary decodes the message and validates Q1.
+func (m *SignRequest) UnmarshalBinary(data []byte) error {
+	if len(data) != 1+pointSize+scalarSize {
+		return exceptions.New(exceptions.ErrInvalidMessage, "invalid sign request length")
+	}
+	q1, err := unmarshalPoint(tagSignRequest, data[:1+pointSize])
+	if err != nil {
+		return err
+	}
+	m.Q1 = q1
+	m.E = append([]byte{}, data[1+pointSize:]...)
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *SignResponse) MarshalBinary() ([]byte, error) {
+	n := sm2.GetN()
+	out := make([]byte, 1+3*scalarSize)
+	out[0] = tagSignResponse
+	for i, v := range []*big.Int{m.R, m.S2, m.S3} {
+		if v == nil || v.Sign() < 0 || v.Cmp(n) >= 0 {
+			return nil, exceptions.New(exceptions.ErrInvalidMessage, "sign response value out of range")
+		}
+		v.FillBytes(out[1+i*scalarSize : 1+(i+1)*scalarSize])
+	}
+	return out, nil
+}
+
+// UnmarshalBinary decodes the message and checks that the values are
+// reduced modulo n.
+func (m *SignResponse) UnmarshalBinary(data []byte) error {
+	if len(data) != 1+3*scalarSize || data[0] != tagSignResponse {
+		return exceptions.New(exceptions.ErrInvalidMessage, "invalid sign response")
+	}
+	n := sm2.GetN()
+	values := make([]*big.Int, 3)
+	for i := range values {
+		values[i] = new(big.Int).SetBytes(data[1+i*scalarSize : 1+(i+1)*scalarSize])
+		if values[i].Cmp(n) >= 0 {
+			return exceptions.New(exceptions.ErrInvalidMessage, "sign response value out of range")
+		}
+	}
+	m.R, m.S2, m.S3 = values[0], values[1], values[2]
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *DecryptRequest) MarshalBinary() ([]byte, error) {
+	return marshalPoint(tagDecryptRequest, m.T1)
+}
+
+// UnmarshalBinary decodes the message and validates T1.
+func (m *DecryptRequest) UnmarshalBinary(data []byte) error {
+	p, err := unmarshalPoint(tagDecryptRequest, data)
+	if err != nil {
+		return err
+	}
+	m.T1 = p
+	return nil
+}
+
+// MarshalBinary encodes the message.
+func (m *DecryptResponse) MarshalBinary() ([]byte, error) {
+	return marshalPoint(tagDecryptResponse, m.T2)
+}
+
+// UnmarshalBinary decodes the message and validates T2.
+func (m *DecryptResponse) UnmarshalBinary(data []byte) error {
+	p, err := unmarshalPoint(tagDecryptResponse, data)
+	if err != nil {
+		return err
+	}
+	m.T2 = p
+	return nil
+}
+
+// marshalPoint encodes a tag followed by an uncompressed point.
+func marshalPoint(tag byte, p *ec.Point) ([]byte, error) {
+	if p == nil || p.IsInfinity() {
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "cannot encode point at infinity")
+	}
+	return append([]byte{tag}, p.GetEncoded(false)...), nil
+}
+
+// unmarshalPoint decodes a tagged uncompressed point and checks that it is
+// a valid point of the curve other than infinity.
+func unmarshalPoint(tag byte, data []byte) (*ec.Point, error) {
+	if len(data) != 1+pointSize || data[0] != tag {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid message")
+	}
+	p, err := sm2.GetCurve().DecodePoint(data[1:])
+	if data[1] != 0x04 || err != nil {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid point in message")
+	}
+	return p, nil
+}
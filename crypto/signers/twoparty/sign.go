@@ -0,0 +1,174 @@
+package twoparty
+
+import (
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// SignSession is the client side of one signature. It is used once.
+type SignSession struct {
+	client *Client
+	e      []byte
+	k1     *big.Int
+}
+
+// StartSign starts signing message. It computes e = SM3(Z || M) with the
+// client's user ID and returns the request for the server.
+func (c *Client) StartSign(message []byte) (*SignSession, *SignRequest, error) {
+	if c.publicKey == nil {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidState, "key generation not completed")
+	}
+
+	z, err := signers.NewSM2Signer().ComputeZ(c.userID, c.publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	digest := digests.NewSM3Digest()
+	digest.BlockUpdate(z, 0, len(z))
+	digest.BlockUpdate(message, 0, len(message))
+	e := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(e, 0)
+
+	return c.StartSignDigest(e)
+}
+
+// StartSignDigest starts signing a precomputed digest e = SM3(Z || M) and
+// returns the request for the server.
+func (c *Client) StartSignDigest(e []byte) (*SignSession, *SignRequest, error) {
+	if c.publicKey == nil {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidState, "key generation not completed")
+	}
+	if len(e) != scalarSize {
+		return nil, nil, exceptions.New(exceptions.ErrDataLength, "invalid digest length")
+	}
+
+	k1, err := randomScalar(c.random)
+	if err != nil {
+		return nil, nil, err
+	}
+	q1 := sm2.GetG().MultiplySecret(k1)
+
+	e = append([]byte{}, e...)
+	return &SignSession{client: c, e: e, k1: k1}, &SignRequest{E: e, Q1: q1}, nil
+}
+
+// Finish combines the server's response into the signature
+// s = d1*k1*s2 + d1*s3 - r mod n, encoded with the client's encoding. The
+// signature is verified against the public key before it is returned, so a
+// faulty or malicious server response is reported as an error.
+func (s *SignSession) Finish(resp *SignResponse) ([]byte, error) {
+	if s.k1 == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "sign session already finished")
+	}
+	k1 := s.k1
+	s.k1 = nil
+	defer util.ClearBigInt(k1)
+	if s.client.publicKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "client destroyed")
+	}
+
+	n := sm2.GetN()
+	if resp == nil || !inRange(resp.R, n) || !inRange(resp.S2, n) || resp.S3 == nil ||
+		resp.S3.Sign() < 0 || resp.S3.Cmp(n) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid sign response")
+	}
+	r := resp.R
+
+	sf := sm2.GetCurve().GetScalarField()
+	d1 := s.client.d1
+	sig := sf.Sub(sf.Add(sf.Mul(sf.Mul(d1, k1), resp.S2), sf.Mul(d1, resp.S3)), r)
+
+	// s = 0 or r + s = n cannot be verified; the client starts over
+	if sig.Sign() == 0 || new(big.Int).Add(r, sig).Cmp(n) == 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "degenerate signature, sign again")
+	}
+
+	signature, err := s.client.encoding.Encode(n, r, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier := signers.NewSM2SignerWithEncoding(s.client.encoding, nil)
+	if err := verifier.Init(false, params.NewECPublicKeyParameters(s.client.publicKey, sm2.GetECDomainParameters())); err != nil {
+		return nil, err
+	}
+	if valid, err := verifier.VerifyDigest(s.e, signature); err != nil || !valid {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "server response does not produce a valid signature")
+	}
+	return signature, nil
+}
+
+// Destroy clears the nonce share k1 and ends the session.
+func (s *SignSession) Destroy() {
+	util.ClearBigInt(s.k1)
+	s.k1 = nil
+}
+
+// Sign answers a sign request. It draws k2 and k3, computes
+// (x1, y1) = [k3]Q1 + [k2]G and r = (e + x1) mod n, and returns r with
+// s2 = d2*k3 and s3 = d2*(r + k2). The joint nonce is k = k1*k3 + k2.
+func (s *Server) Sign(req *SignRequest) (*SignResponse, error) {
+	if req == nil || len(req.E) != scalarSize {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid sign request")
+	}
+	if req.Q1 == nil || !sm2.ValidatePublicKey(req.Q1) {
+		return nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid sign request")
+	}
+	if s.d2 == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "server destroyed")
+	}
+
+	n := sm2.GetN()
+	sf := sm2.GetCurve().GetScalarField()
+	e := new(big.Int).SetBytes(req.E)
+
+	for {
+		k2, err := randomScalar(s.random)
+		if err != nil {
+			return nil, err
+		}
+		k3, err := randomScalar(s.random)
+		if err != nil {
+			util.ClearBigInt(k2)
+			return nil, err
+		}
+
+		p := ec.SumOfTwoMultipliesSecret(req.Q1, k3, sm2.GetG(), k2)
+		if p.IsInfinity() {
+			util.ClearBigInt(k2)
+			util.ClearBigInt(k3)
+			continue
+		}
+
+		r := new(big.Int).Add(e, p.GetXCoord().ToBigInt())
+		r.Mod(r, n)
+		if r.Sign() == 0 {
+			util.ClearBigInt(k2)
+			util.ClearBigInt(k3)
+			continue
+		}
+
+		rk2 := sf.Add(r, k2)
+		resp := &SignResponse{
+			R:  r,
+			S2: sf.Mul(s.d2, k3),
+			S3: sf.Mul(s.d2, rk2),
+		}
+		util.ClearBigInt(k2)
+		util.ClearBigInt(k3)
+		util.ClearBigInt(rk2)
+		return resp, nil
+	}
+}
+
+// inRange reports whether v lies in [1, n-1].
+func inRange(v, n *big.Int) bool {
+	return v != nil && v.Sign() > 0 && v.Cmp(n) < 0
+}
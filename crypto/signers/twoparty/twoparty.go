@@ -0,0 +1,193 @@
+// Package twoparty implements two-party cooperative SM2 signing and
+// decryption, in which the private key d never exists in one place.
+//
+// The client (typically a mobile device) holds a share d1 and the server
+// holds a share d2, related to the SM2 private key by
+//
+//	(1 + d)^-1 = d1 * d2 mod n
+//
+// The public key P = [d]G = [(d1*d2)^-1]G - G is fixed during key
+// generation. Signatures and decryptions are produced in one round trip:
+// the client sends a request, the server answers with a response and the
+// client completes the operation. Every message implements
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler so that it can
+// travel over any transport.
+//
+// Signatures are ordinary SM2 signatures that verify with
+// signers.SM2Signer, and ciphertexts are ordinary SM2 ciphertexts.
+package twoparty
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// Client is the party holding the share d1. It starts every protocol and
+// obtains the result.
+type Client struct {
+	d1        *big.Int
+	publicKey *ec.Point
+	userID    []byte
+	encoding  signers.DSAEncoding
+	random    io.Reader
+}
+
+// Server is the party holding the share d2. It answers client requests.
+type Server struct {
+	d2     *big.Int
+	random io.Reader
+}
+
+// NewClient draws the client share d1 and returns the first key generation
+// message, P1 = [d1^-1]G, for the server. The client is usable once
+// CompleteKeyGen has processed the server's answer. If random is nil,
+// crypto/rand.Reader is used.
+func NewClient(random io.Reader) (*Client, *KeyGenRequest, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	d1, err := randomScalar(random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sf := sm2.GetCurve().GetScalarField()
+	d1Inv := sf.Inverse(d1)
+	p1 := sm2.GetG().MultiplySecret(d1Inv)
+	util.ClearBigInt(d1Inv)
+
+	c := &Client{
+		d1:       d1,
+		userID:   sm2.DefaultUserID,
+		encoding: signers.StandardDSAEncoding{},
+		random:   random,
+	}
+	return c, &KeyGenRequest{P1: p1}, nil
+}
+
+// CompleteKeyGen records the joint public key sent by the server.
+func (c *Client) CompleteKeyGen(resp *KeyGenResponse) error {
+	if c.publicKey != nil {
+		return exceptions.New(exceptions.ErrInvalidState, "key generation already completed")
+	}
+	if resp == nil || resp.PublicKey == nil || !sm2.ValidatePublicKey(resp.PublicKey) {
+		return exceptions.New(exceptions.ErrInvalidKey, "invalid public key")
+	}
+	c.publicKey = resp.PublicKey
+	return nil
+}
+
+// RestoreClient recreates a client from a stored share and public key.
+func RestoreClient(d1 *big.Int, publicKey *ec.Point, random io.Reader) (*Client, error) {
+	if d1 == nil || !sm2.ValidatePrivateKey(d1) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid key share")
+	}
+	if publicKey == nil || !sm2.ValidatePublicKey(publicKey) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid public key")
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	return &Client{
+		d1:        new(big.Int).Set(d1),
+		publicKey: publicKey,
+		userID:    sm2.DefaultUserID,
+		encoding:  signers.StandardDSAEncoding{},
+		random:    random,
+	}, nil
+}
+
+// Share returns the client's key share d1, for storage.
+func (c *Client) Share() *big.Int {
+	return new(big.Int).Set(c.d1)
+}
+
+// Destroy clears the share d1. The client cannot be used afterwards.
+func (c *Client) Destroy() {
+	util.ClearBigInt(c.d1)
+	c.publicKey = nil
+}
+
+// PublicKey returns the joint SM2 public key, or nil before key generation
+// has completed.
+func (c *Client) PublicKey() *ec.Point {
+	return c.publicKey
+}
+
+// SetUserID sets the user ID hashed into Z when signing. The default is
+// sm2.DefaultUserID.
+func (c *Client) SetUserID(userID []byte) {
+	c.userID = userID
+}
+
+// SetEncoding sets the signature encoding. The default is
+// signers.StandardDSAEncoding.
+func (c *Client) SetEncoding(encoding signers.DSAEncoding) {
+	c.encoding = encoding
+}
+
+// NewServer draws the server share d2 from a client's key generation
+// message and returns the joint public key P = [d2^-1]P1 - G, which is
+// sent back to the client. If random is nil, crypto/rand.Reader is used.
+func NewServer(req *KeyGenRequest, random io.Reader) (*Server, *KeyGenResponse, error) {
+	if req == nil || req.P1 == nil || !sm2.ValidatePublicKey(req.P1) {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidMessage, "invalid key generation request")
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	sf := sm2.GetCurve().GetScalarField()
+	for {
+		d2, err := randomScalar(random)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// d1 * d2 = 1 would make d = 0
+		d2Inv := sf.Inverse(d2)
+		pub := req.P1.MultiplySecret(d2Inv).Subtract(sm2.GetG())
+		util.ClearBigInt(d2Inv)
+		if pub.IsInfinity() {
+			util.ClearBigInt(d2)
+			continue
+		}
+		return &Server{d2: d2, random: random}, &KeyGenResponse{PublicKey: pub}, nil
+	}
+}
+
+// RestoreServer recreates a server from a stored share.
+func RestoreServer(d2 *big.Int, random io.Reader) (*Server, error) {
+	if d2 == nil || !sm2.ValidatePrivateKey(d2) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid key share")
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	return &Server{d2: new(big.Int).Set(d2), random: random}, nil
+}
+
+// Share returns the server's key share d2, for storage.
+func (s *Server) Share() *big.Int {
+	return new(big.Int).Set(s.d2)
+}
+
+// Destroy clears the share d2. The server cannot be used afterwards.
+func (s *Server) Destroy() {
+	util.ClearBigInt(s.d2)
+	s.d2 = nil
+}
+
+// randomScalar returns a uniformly random scalar in [1, n-1].
+func randomScalar(random io.Reader) (*big.Int, error) {
+	calc := signers.NewRandomDSAKCalculator()
+	calc.Init(sm2.GetN(), random)
+	return calc.NextK()
+}
@@ -0,0 +1,299 @@
+package twoparty
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
+	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+type message interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// transmit copies a message into out through its binary form, as if it
+// had been sent to the other party.
+func transmit(t *testing.T, in, out message) {
+	t.Helper()
+	data, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+}
+
+func keyGen(t *testing.T) (*Client, *Server) {
+	t.Helper()
+	client, req, err := NewClient(nil)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	var wireReq KeyGenRequest
+	transmit(t, req, &wireReq)
+	server, resp, err := NewServer(&wireReq, nil)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	var wireResp KeyGenResponse
+	transmit(t, resp, &wireResp)
+	if err := client.CompleteKeyGen(&wireResp); err != nil {
+		t.Fatalf("CompleteKeyGen failed: %v", err)
+	}
+	return client, server
+}
+
+func sign(t *testing.T, client *Client, server *Server, message []byte) []byte {
+	t.Helper()
+	session, req, err := client.StartSign(message)
+	if err != nil {
+		t.Fatalf("StartSign failed: %v", err)
+	}
+	var wireReq SignRequest
+	transmit(t, req, &wireReq)
+	resp, err := server.Sign(&wireReq)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	var wireResp SignResponse
+	transmit(t, resp, &wireResp)
+	signature, err := session.Finish(&wireResp)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	return signature
+}
+
+func verify(client *Client, userID, message, signature []byte, encoding signers.DSAEncoding) bool {
+	verifier := signers.NewSM2SignerWithEncoding(encoding, nil)
+	var parameters crypto.CipherParameters = params.NewECPublicKeyParameters(client.PublicKey(), sm2.GetECDomainParameters())
+	if userID != nil {
+		parameters = crypto.NewParametersWithID(parameters, userID)
+	}
+	if err := verifier.Init(false, parameters); err != nil {
+		return false
+	}
+	verifier.BlockUpdate(message, 0, len(message))
+	valid, err := verifier.VerifySignature(signature)
+	return err == nil && valid
+}
+
+func TestTwoPartyKeyGen(t *testing.T) {
+	client, server := keyGen(t)
+
+	// (1 + d)^-1 = d1 * d2
+	n := sm2.GetN()
+	d := new(big.Int).Mul(client.Share(), server.Share())
+	d.ModInverse(d, n)
+	d.Sub(d, big.NewInt(1))
+	if !sm2.GetG().Multiply(d).Equals(client.PublicKey()) {
+		t.Error("Public key does not match the shares")
+	}
+
+	if err := client.CompleteKeyGen(&KeyGenResponse{PublicKey: client.PublicKey()}); err == nil {
+		t.Error("Expected error completing key generation twice")
+	}
+}
+
+func TestTwoPartySign(t *testing.T) {
+	client, server := keyGen(t)
+	message := []byte("two-party message")
+
+	signature := sign(t, client, server, message)
+	if !verify(client, nil, message, signature, nil) {
+		t.Error("Two-party signature failed verification")
+	}
+	if verify(client, nil, []byte("other message"), signature, nil) {
+		t.Error("Two-party signature verified for another message")
+	}
+
+	// Custom user ID and plain encoding
+	userID := []byte("alice@example.com")
+	client.SetUserID(userID)
+	client.SetEncoding(signers.PlainDSAEncoding{})
+	signature = sign(t, client, server, message)
+	if len(signature) != 64 {
+		t.Errorf("Expected 64-byte plain signature, got %d bytes", len(signature))
+	}
+	if !verify(client, userID, message, signature, signers.PlainDSAEncoding{}) {
+		t.Error("Signature with custom user ID failed verification")
+	}
+
+	// Shares restored from storage keep working
+	restoredClient, err := RestoreClient(client.Share(), client.PublicKey(), nil)
+	if err != nil {
+		t.Fatalf("RestoreClient failed: %v", err)
+	}
+	restoredServer, err := RestoreServer(server.Share(), nil)
+	if err != nil {
+		t.Fatalf("RestoreServer failed: %v", err)
+	}
+	signature = sign(t, restoredClient, restoredServer, message)
+	if !verify(client, nil, message, signature, nil) {
+		t.Error("Signature from restored parties failed verification")
+	}
+}
+
+func TestTwoPartySignInvalid(t *testing.T) {
+	client, server := keyGen(t)
+	_, otherServer := keyGen(t)
+
+	session, req, _ := client.StartSign([]byte("message"))
+	resp, _ := otherServer.Sign(req)
+	if _, err := session.Finish(resp); err == nil {
+		t.Error("Expected error for a response made with another share")
+	}
+	if _, err := session.Finish(resp); err == nil {
+		t.Error("Expected error reusing a session")
+	}
+
+	session, req, _ = client.StartSign([]byte("message"))
+	resp, _ = server.Sign(req)
+	resp.S3 = new(big.Int).Add(resp.S3, big.NewInt(1))
+	if _, err := session.Finish(resp); err == nil {
+		t.Error("Expected error for a modified response")
+	}
+
+	if _, err := server.Sign(&SignRequest{E: req.E[:31], Q1: req.Q1}); err == nil {
+		t.Error("Expected error for a short digest")
+	}
+	if _, err := server.Sign(&SignRequest{E: req.E, Q1: sm2.GetCurve().GetInfinity()}); err == nil {
+		t.Error("Expected error for Q1 at infinity")
+	}
+
+	pending, _, _ := NewClient(nil)
+	if _, _, err := pending.StartSign([]byte("message")); err == nil {
+		t.Error("Expected error signing before key generation completes")
+	}
+}
+
+func TestTwoPartyDecrypt(t *testing.T) {
+	client, server := keyGen(t)
+	plaintext := []byte("encrypted for a key nobody holds")
+
+	for _, mode := range []int{sm2.Mode_C1C2C3, sm2.Mode_C1C3C2, sm2.Mode_DER} {
+		engine := sm2.NewSM2Engine()
+		engine.SetMode(mode)
+		if err := engine.Init(true, client.PublicKey(), nil); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		ciphertext, err := engine.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+
+		session, req, err := client.StartDecrypt(ciphertext, mode)
+		if err != nil {
+			t.Fatalf("mode %d: StartDecrypt failed: %v", mode, err)
+		}
+		var wireReq DecryptRequest
+		transmit(t, req, &wireReq)
+		resp, err := server.Decrypt(&wireReq)
+		if err != nil {
+			t.Fatalf("mode %d: Decrypt failed: %v", mode, err)
+		}
+		var wireResp DecryptResponse
+		transmit(t, resp, &wireResp)
+		decrypted, err := session.Finish(&wireResp)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("mode %d: decryption failed: %q, %v", mode, decrypted, err)
+		}
+	}
+
+	// Tampered ciphertext and a response from the wrong server
+	engine := sm2.NewSM2Engine()
+	engine.SetMode(sm2.Mode_C1C3C2)
+	_ = engine.Init(true, client.PublicKey(), nil)
+	ciphertext, _ := engine.Encrypt(plaintext)
+	ciphertext[len(ciphertext)-1] ^= 0x01
+	session, req, _ := client.StartDecrypt(ciphertext, sm2.Mode_C1C3C2)
+	resp, _ := server.Decrypt(req)
+	if _, err := session.Finish(resp); err == nil {
+		t.Error("Expected error for tampered ciphertext")
+	}
+
+	ciphertext[len(ciphertext)-1] ^= 0x01
+	_, otherServer := keyGen(t)
+	session, req, _ = client.StartDecrypt(ciphertext, sm2.Mode_C1C3C2)
+	resp, _ = otherServer.Decrypt(req)
+	if _, err := session.Finish(resp); err == nil {
+		t.Error("Expected error for a response made with another share")
+	}
+	if _, _, err := client.StartDecrypt(ciphertext[:40], sm2.Mode_C1C3C2); err == nil {
+		t.Error("Expected error for truncated ciphertext")
+	}
+}
+
+func TestTwoPartyMessages(t *testing.T) {
+	client, server := keyGen(t)
+	_, req, _ := client.StartSign([]byte("message"))
+	data, _ := req.MarshalBinary()
+
+	// Tags keep messages of different steps apart
+	if err := new(KeyGenRequest).UnmarshalBinary(data[:1+pointSize]); err == nil {
+		t.Error("Sign request accepted as key generation request")
+	}
+	if err := new(SignRequest).UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("Truncated sign request accepted")
+	}
+
+	corrupted := append([]byte{}, data...)
+	corrupted[1+pointSize-1] ^= 0x01
+	if err := new(SignRequest).UnmarshalBinary(corrupted); err == nil {
+		t.Error("Sign request with off-curve Q1 accepted")
+	}
+
+	resp, _ := server.Sign(req)
+	data, _ = resp.MarshalBinary()
+	copy(data[1:1+scalarSize], bytes.Repeat([]byte{0xFF}, scalarSize))
+	if err := new(SignResponse).UnmarshalBinary(data); err == nil {
+		t.Error("Sign response with r >= n accepted")
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	client, server := keyGen(t)
+	d1, d2 := client.d1, server.d2
+	session, req, err := client.StartSign([]byte("destroy"))
+	if err != nil {
+		t.Fatalf("StartSign failed: %v", err)
+	}
+	k1 := session.k1
+
+	session.Destroy()
+	client.Destroy()
+	server.Destroy()
+	if d1.Sign() != 0 || d2.Sign() != 0 || k1.Sign() != 0 {
+		t.Error("Secrets not cleared")
+	}
+
+	if _, _, err := client.StartSign([]byte("destroy")); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("StartSign after Destroy = %v, want ErrInvalidState", err)
+	}
+	if _, err := server.Sign(req); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("Sign after Destroy = %v, want ErrInvalidState", err)
+	}
+	if _, err := session.Finish(&SignResponse{}); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("Finish after Destroy = %v, want ErrInvalidState", err)
+	}
+
+	// Restored parties hold their own copies of the shares
+	share := big.NewInt(12345)
+	restored, err := RestoreServer(share, nil)
+	if err != nil {
+		t.Fatalf("RestoreServer failed: %v", err)
+	}
+	restored.Destroy()
+	if share.Int64() != 12345 {
+		t.Error("Destroy cleared the caller's share")
+	}
+}
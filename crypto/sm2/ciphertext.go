@@ -0,0 +1,164 @@
+package sm2
+
+import (
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// sm2Cipher is the GM/T 0009-2012 ASN.1 form of an SM2 ciphertext:
+//
+//	SM2Cipher ::= SEQUENCE {
+//	    XCoordinate INTEGER,
+//	    YCoordinate INTEGER,
+//	    HASH        OCTET STRING SIZE(32),
+//	    CipherText  OCTET STRING
+//	}
+type sm2Cipher struct {
+	XCoordinate *big.Int
+	YCoordinate *big.Int
+	Hash        []byte
+	CipherText  []byte
+}
+
+// ciphertextParts holds the components of an SM2 ciphertext. c1 keeps its
+// point encoding (uncompressed or compressed).
+type ciphertextParts struct {
+	c1, c2, c3 []byte
+}
+
+// hashSize is the length of C3 for the SM3 digest.
+const hashSize = 32
+
+// ConvertCiphertext converts an SM2 ciphertext between the raw C1C2C3,
+// raw C1C3C2 and DER (GM/T 0009) layouts. Raw outputs keep the C1 encoding
+// of a raw input; C1 decoded from DER is written uncompressed. The
+// ciphertext is one of the default SM2Engine, on sm2p256v1 with SM3.
+func ConvertCiphertext(ciphertext []byte, from, to int) ([]byte, error) {
+	return ConvertCiphertextWithParams(ciphertext, from, to, GetECDomainParameters(), hashSize)
+}
+
+// ConvertCiphertextWithParams converts like ConvertCiphertext a ciphertext
+// whose C1 is a point on the curve of domain and whose C3 is digestSize
+// bytes long, as produced by an SM2Engine set up with InitWithDomain and
+// SetDigest.
+func ConvertCiphertextWithParams(ciphertext []byte, from, to int, domain *params.ECDomainParameters, digestSize int) ([]byte, error) {
+	if domain == nil || domain.GetCurve() == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "EC domain parameters required")
+	}
+	if digestSize <= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "digest size must be positive")
+	}
+	curve := domain.GetCurve()
+	parts, err := parseCiphertext(ciphertext, from, (curve.GetFieldSize()+7)/8, digestSize)
+	if err != nil {
+		return nil, err
+	}
+	return parts.encode(to, curve)
+}
+
+// parseCiphertext splits a ciphertext in the given layout, with field
+// elements of fieldSize bytes and a C3 of hashSize bytes. DER input is
+// recognised by its SEQUENCE tag whatever the mode, since raw ciphertexts
+// always start with a point encoding; raw input in Mode_DER is read as
+// C1C3C2, the GM/T 0003-2012 order.
+func parseCiphertext(ciphertext []byte, mode, fieldSize, hashSize int) (*ciphertextParts, error) {
+	if len(ciphertext) > 0 && ciphertext[0] == 0x30 {
+		return parseDERCiphertext(ciphertext, fieldSize, hashSize)
+	}
+
+	var c1Len int
+	if len(ciphertext) > 0 {
+		switch ciphertext[0] {
+		case 0x04, 0x06, 0x07:
+			c1Len = 1 + 2*fieldSize
+		case 0x02, 0x03:
+			c1Len = 1 + fieldSize
+		default:
+			return nil, exceptions.New(exceptions.ErrInvalidCipherText, "invalid ciphertext format")
+		}
+	}
+	if len(ciphertext) < c1Len+hashSize {
+		return nil, exceptions.New(exceptions.ErrInvalidCipherText, "ciphertext too short")
+	}
+
+	parts := &ciphertextParts{c1: ciphertext[:c1Len]}
+	rest := ciphertext[c1Len:]
+	switch mode {
+	case Mode_C1C2C3:
+		parts.c2 = rest[:len(rest)-hashSize]
+		parts.c3 = rest[len(rest)-hashSize:]
+	case Mode_C1C3C2, Mode_DER:
+		parts.c3 = rest[:hashSize]
+		parts.c2 = rest[hashSize:]
+	default:
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "unknown ciphertext mode")
+	}
+	return parts, nil
+}
+
+// parseDERCiphertext decodes a GM/T 0009 SM2Cipher structure.
+func parseDERCiphertext(ciphertext []byte, size, hashSize int) (*ciphertextParts, error) {
+	var v sm2Cipher
+	rest, err := asn1.Unmarshal(ciphertext, &v)
+	if err != nil {
+		return nil, exceptions.Newf(exceptions.ErrInvalidCipherText, "invalid DER ciphertext: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidCipherText, "invalid DER ciphertext: trailing data")
+	}
+	if len(v.Hash) != hashSize {
+		return nil, exceptions.Newf(exceptions.ErrInvalidCipherText, "invalid DER ciphertext: hash must be %d bytes", hashSize)
+	}
+
+	if v.XCoordinate.Sign() < 0 || v.XCoordinate.BitLen() > 8*size ||
+		v.YCoordinate.Sign() < 0 || v.YCoordinate.BitLen() > 8*size {
+		return nil, exceptions.New(exceptions.ErrInvalidCipherText, "invalid DER ciphertext: coordinate out of range")
+	}
+	c1 := make([]byte, 0, 1+2*size)
+	c1 = append(c1, 0x04)
+	c1 = append(c1, util.BigIntToBytes(v.XCoordinate, size)...)
+	c1 = append(c1, util.BigIntToBytes(v.YCoordinate, size)...)
+
+	return &ciphertextParts{c1: c1, c2: v.CipherText, c3: v.Hash}, nil
+}
+
+// encode assembles the parts in the given layout; C1 is a point on curve.
+func (p *ciphertextParts) encode(mode int, curve *ec.Curve) ([]byte, error) {
+	switch mode {
+	case Mode_C1C2C3:
+		return concat(p.c1, p.c2, p.c3), nil
+	case Mode_C1C3C2:
+		return concat(p.c1, p.c3, p.c2), nil
+	case Mode_DER:
+		point, err := curve.DecodePoint(p.c1)
+		if err != nil {
+			return nil, exceptions.Newf(exceptions.ErrInvalidCipherText, "invalid C1 point: %w", err)
+		}
+		return asn1.Marshal(sm2Cipher{
+			XCoordinate: point.GetXCoord().ToBigInt(),
+			YCoordinate: point.GetYCoord().ToBigInt(),
+			Hash:        p.c3,
+			CipherText:  p.c2,
+		})
+	default:
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "unknown ciphertext mode")
+	}
+}
+
+// concat joins byte slices into a new slice.
+func concat(parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := make([]byte, 0, n)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
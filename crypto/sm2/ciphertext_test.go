@@ -0,0 +1,244 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+)
+
+// GM/T 0003.5-2012 encryption example components
+var (
+	exampleC1, _ = hex.DecodeString("04" +
+		"04EBFC718E8D1798620432268E77FEB6415E2EDE0E073C0F4F640ECD2E149A73" +
+		"E858F9D81E5430A57B36DAAB8F950A3C64E6EE6A63094D99283AFF767E124DF0")
+	exampleC3, _ = hex.DecodeString("59983C18F809E262923C53AEC295D30383B54E39D609D160AFCB1908D0BD8766")
+	exampleC2, _ = hex.DecodeString("21886CA989CA9C7D58087307CA93092D651EFA")
+)
+
+func TestConvertCiphertext(t *testing.T) {
+	c1c2c3 := concat(exampleC1, exampleC2, exampleC3)
+	c1c3c2 := concat(exampleC1, exampleC3, exampleC2)
+
+	der, err := ConvertCiphertext(c1c3c2, Mode_C1C3C2, Mode_DER)
+	if err != nil {
+		t.Fatalf("C1C3C2 -> DER failed: %v", err)
+	}
+	var v sm2Cipher
+	if _, err := asn1.Unmarshal(der, &v); err != nil {
+		t.Fatalf("DER output does not parse: %v", err)
+	}
+	if !bytes.Equal(v.Hash, exampleC3) || !bytes.Equal(v.CipherText, exampleC2) ||
+		v.XCoordinate.Cmp(fromHex("04EBFC718E8D1798620432268E77FEB6415E2EDE0E073C0F4F640ECD2E149A73")) != 0 {
+		t.Error("DER fields mismatch")
+	}
+
+	for _, tc := range []struct {
+		name     string
+		in       []byte
+		from, to int
+		expected []byte
+	}{
+		{"C1C3C2 to C1C2C3", c1c3c2, Mode_C1C3C2, Mode_C1C2C3, c1c2c3},
+		{"C1C2C3 to C1C3C2", c1c2c3, Mode_C1C2C3, Mode_C1C3C2, c1c3c2},
+		{"C1C2C3 to DER", c1c2c3, Mode_C1C2C3, Mode_DER, der},
+		{"DER to C1C2C3", der, Mode_DER, Mode_C1C2C3, c1c2c3},
+		{"DER to C1C3C2", der, Mode_DER, Mode_C1C3C2, c1c3c2},
+		{"DER detected whatever the mode", der, Mode_C1C2C3, Mode_C1C3C2, c1c3c2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := ConvertCiphertext(tc.in, tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("ConvertCiphertext failed: %v", err)
+			}
+			if !bytes.Equal(out, tc.expected) {
+				t.Errorf("Mismatch\nExpected: %X\nGot:      %X", tc.expected, out)
+			}
+		})
+	}
+}
+
+func TestConvertCiphertextCompressedC1(t *testing.T) {
+	point, err := GetCurve().DecodePoint(exampleC1)
+	if err != nil {
+		t.Fatalf("DecodePoint failed: %v", err)
+	}
+	compressed := point.GetEncoded(true)
+	in := concat(compressed, exampleC3, exampleC2)
+
+	// Raw to raw keeps the compressed encoding
+	out, err := ConvertCiphertext(in, Mode_C1C3C2, Mode_C1C2C3)
+	if err != nil {
+		t.Fatalf("ConvertCiphertext failed: %v", err)
+	}
+	if !bytes.Equal(out, concat(compressed, exampleC2, exampleC3)) {
+		t.Error("Compressed C1 not preserved")
+	}
+
+	// DER needs both coordinates and round-trips to an uncompressed C1
+	der, err := ConvertCiphertext(in, Mode_C1C3C2, Mode_DER)
+	if err != nil {
+		t.Fatalf("Compressed C1 -> DER failed: %v", err)
+	}
+	out, _ = ConvertCiphertext(der, Mode_DER, Mode_C1C3C2)
+	if !bytes.Equal(out, concat(exampleC1, exampleC3, exampleC2)) {
+		t.Error("DER round trip mismatch")
+	}
+}
+
+func TestConvertCiphertextInvalid(t *testing.T) {
+	valid, _ := asn1.Marshal(sm2Cipher{
+		XCoordinate: fromHex("01"),
+		YCoordinate: fromHex("02"),
+		Hash:        exampleC3,
+		CipherText:  exampleC2,
+	})
+	shortHash, _ := asn1.Marshal(sm2Cipher{
+		XCoordinate: fromHex("01"),
+		YCoordinate: fromHex("02"),
+		Hash:        exampleC3[:20],
+		CipherText:  exampleC2,
+	})
+
+	for name, in := range map[string][]byte{
+		"empty":         {},
+		"bad prefix":    concat([]byte{0x05}, exampleC1[1:], exampleC3),
+		"too short":     concat(exampleC1, exampleC3[:31]),
+		"trailing data": concat(valid, []byte{0x00}),
+		"short hash":    shortHash,
+		"truncated DER": valid[:len(valid)-1],
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ConvertCiphertext(in, Mode_C1C3C2, Mode_C1C2C3); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	// Unknown modes are rejected
+	if _, err := ConvertCiphertext(concat(exampleC1, exampleC3), Mode_C1C3C2, 7); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+// TestConvertCiphertextWithParams converts the output of an engine on the
+// test curve with a 64-byte digest between all layouts.
+func TestConvertCiphertextWithParams(t *testing.T) {
+	domain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := randRange(rand.Reader, domain.GetN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("converted with engine settings")
+	modes := []int{Mode_C1C2C3, Mode_C1C3C2, Mode_DER}
+
+	for _, from := range modes {
+		encryptor := NewSM2Engine()
+		encryptor.SetMode(from)
+		encryptor.SetDigest(newSHA512Digest())
+		if err := encryptor.InitWithDomain(true, domain, domain.GetG().Multiply(d), nil); err != nil {
+			t.Fatalf("Failed to init for encryption: %v", err)
+		}
+		ciphertext, err := encryptor.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		if from == Mode_DER {
+			if _, err := ConvertCiphertext(ciphertext, from, Mode_C1C3C2); err == nil {
+				t.Error("ConvertCiphertext accepted a 64-byte C3")
+			}
+		}
+
+		for _, to := range modes {
+			out, err := ConvertCiphertextWithParams(ciphertext, from, to, domain, 64)
+			if err != nil {
+				t.Fatalf("%d -> %d: conversion failed: %v", from, to, err)
+			}
+			decryptor := NewSM2Engine()
+			decryptor.SetMode(to)
+			decryptor.SetDigest(newSHA512Digest())
+			if err := decryptor.InitWithDomain(false, domain, nil, d); err != nil {
+				t.Fatalf("Failed to init for decryption: %v", err)
+			}
+			if decrypted, err := decryptor.Decrypt(out); err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("%d -> %d: decryption of converted ciphertext failed: %v", from, to, err)
+			}
+		}
+	}
+
+	if _, err := ConvertCiphertextWithParams(concat(exampleC1, exampleC3), Mode_C1C3C2, Mode_C1C2C3, nil, 32); err == nil {
+		t.Error("Expected error for missing domain parameters")
+	}
+}
+
+func TestSM2EngineDERMode(t *testing.T) {
+	d := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	plaintext := []byte("encryption standard")
+
+	engine := NewSM2Engine()
+	engine.SetMode(Mode_DER)
+	engine.SetRandom(bytes.NewReader(k))
+	_ = engine.Init(true, GetG().Multiply(d), nil)
+	der, err := engine.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	expected, _ := ConvertCiphertext(concat(exampleC1, exampleC3, exampleC2), Mode_C1C3C2, Mode_DER)
+	if !bytes.Equal(der, expected) {
+		t.Errorf("DER ciphertext mismatch\nExpected: %X\nGot:      %X", expected, der)
+	}
+
+	// DER is detected by every decryption mode
+	for _, mode := range []int{Mode_C1C2C3, Mode_C1C3C2, Mode_DER} {
+		engine := NewSM2Engine()
+		engine.SetMode(mode)
+		_ = engine.Init(false, nil, d)
+		decrypted, err := engine.Decrypt(der)
+		if err != nil {
+			t.Fatalf("Mode %d: decryption failed: %v", mode, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("Mode %d: plaintext mismatch", mode)
+		}
+	}
+}
+
+func TestSM2EngineDecryptCompressedC1(t *testing.T) {
+	keyPair, _ := GenerateKey(rand.Reader)
+
+	for _, plaintext := range [][]byte{[]byte("compressed point"), {}} {
+		engine := NewSM2Engine()
+		engine.SetMode(Mode_C1C3C2)
+		_ = engine.Init(true, keyPair.PublicKey, nil)
+		ciphertext, err := engine.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+
+		point, err := GetCurve().DecodePoint(ciphertext[:65])
+		if err != nil {
+			t.Fatalf("DecodePoint failed: %v", err)
+		}
+		c1 := point.GetEncoded(true)
+		compressed := concat(c1, ciphertext[65:])
+
+		engine = NewSM2Engine()
+		engine.SetMode(Mode_C1C3C2)
+		_ = engine.Init(false, nil, keyPair.PrivateKey)
+		decrypted, err := engine.Decrypt(compressed)
+		if err != nil {
+			t.Fatalf("Decryption of %d-byte ciphertext failed: %v", len(compressed), err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Error("Plaintext mismatch")
+		}
+	}
+}
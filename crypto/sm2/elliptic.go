@@ -0,0 +1,26 @@
+package sm2
+
+import (
+	"crypto/elliptic"
+	"sync"
+
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+)
+
+var (
+	p256Once sync.Once
+	p256     elliptic.Curve
+)
+
+// P256 returns the SM2 curve sm2p256v1 as a crypto/elliptic.Curve, for use
+// with code written against crypto/elliptic such as elliptic.Marshal and
+// elliptic.Unmarshal. Use ec.PointToXY and ec.PointFromXY with GetCurve()
+// to convert between ec.Point and the (x, y) pairs it works with.
+//
+// The curve is backed by GetCurve(); see ec.NewEllipticCurve.
+func P256() elliptic.Curve {
+	p256Once.Do(func() {
+		p256 = ec.NewEllipticCurve(GetCurve(), ec.SM2P256V1)
+	})
+	return p256
+}
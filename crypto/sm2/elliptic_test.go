@@ -0,0 +1,61 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+)
+
+func TestP256(t *testing.T) {
+	curve := P256()
+	if curve != P256() {
+		t.Error("P256 should return the same curve")
+	}
+	params := curve.Params()
+	if params.Name != "sm2p256v1" || params.P.Cmp(SM2_P) != 0 || params.N.Cmp(SM2_N) != 0 ||
+		params.B.Cmp(SM2_B) != 0 || params.Gx.Cmp(SM2_Gx) != 0 || params.Gy.Cmp(SM2_Gy) != 0 {
+		t.Errorf("Unexpected parameters %+v", params)
+	}
+
+	a, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys round trip through elliptic.Marshal and elliptic.Unmarshal
+	ax, ay := ec.PointToXY(a.PublicKey)
+	encoded := elliptic.Marshal(curve, ax, ay)
+	if !bytes.Equal(encoded, a.PublicKey.GetEncoded(false)) {
+		t.Error("elliptic.Marshal differs from GetEncoded")
+	}
+	ux, uy := elliptic.Unmarshal(curve, encoded)
+	if ux == nil {
+		t.Fatal("elliptic.Unmarshal rejected a valid point")
+	}
+	q, err := ec.PointFromXY(GetCurve(), ux, uy)
+	if err != nil || !q.Equals(a.PublicKey) {
+		t.Errorf("Public key did not round trip: %v", err)
+	}
+	if x, y := curve.ScalarBaseMult(a.PrivateKey.Bytes()); x.Cmp(ax) != 0 || y.Cmp(ay) != 0 {
+		t.Error("ScalarBaseMult does not match the key pair")
+	}
+
+	// ECDH through the interface
+	bx, by := ec.PointToXY(b.PublicKey)
+	s1, _ := curve.ScalarMult(bx, by, a.PrivateKey.Bytes())
+	s2, _ := curve.ScalarMult(ax, ay, b.PrivateKey.Bytes())
+	if s1.Cmp(s2) != 0 {
+		t.Error("Shared secrets differ")
+	}
+	want := b.PublicKey.Multiply(a.PrivateKey)
+	if s1.Cmp(want.X) != 0 {
+		t.Error("ScalarMult differs from Point.Multiply")
+	}
+}
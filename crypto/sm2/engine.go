@@ -2,21 +2,31 @@ package sm2
 
 import (
 	"crypto/rand"
-	"errors"
+	"crypto/subtle"
+	"io"
 	"math/big"
+	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
-// SM2Engine implements SM2 public key encryption.
+// SM2Engine implements SM2 public key encryption. The curve is sm2p256v1
+// and the digest SM3 unless set with InitWithDomain and SetDigest.
+// An SM2Engine is not safe for concurrent use; PublicKey.Encrypt and
+// PrivateKey.Decrypt create an engine per call and are.
 // Reference: GM/T 0003-2012 Part 4: Public Key Encryption
 type SM2Engine struct {
 	forEncryption bool
 	publicKey     *ec.Point
 	privateKey    *big.Int
-	curve         *ec.Curve
-	mode          int // 0 = C1C2C3, 1 = C1C3C2
+	domain        *params.ECDomainParameters
+	digest        crypto.Digest
+	mode          int // 0 = C1C2C3, 1 = C1C3C2, 2 = DER
+	pointCompression bool
+	random        io.Reader
 }
 
 const (
@@ -24,46 +34,100 @@ const (
 	Mode_C1C2C3 = 0
 	// Mode_C1C3C2 is the new standard mode
 	Mode_C1C3C2 = 1
+	// Mode_DER is the GM/T 0009 ASN.1 SM2Cipher encoding
+	Mode_DER = 2
 )
 
+// errDecryption is returned when the key stream or C3 of a ciphertext is
+// invalid. The checks share one error so that it does not tell which
+// failed.
+var errDecryption = exceptions.New(exceptions.ErrAuthenticationFailed, "SM2 decryption failed")
+
 // NewSM2Engine creates a new SM2 encryption engine.
 func NewSM2Engine() *SM2Engine {
 	return &SM2Engine{
-		curve: GetCurve(),
-		mode:  Mode_C1C2C3, // Default to old standard for compatibility with JS/other implementations
+		domain: GetECDomainParameters(),
+		digest: digests.NewSM3Digest(),
+		mode:   Mode_C1C2C3, // Default to old standard for compatibility with JS/other implementations
+		random: rand.Reader,
 	}
 }
 
-// SetMode sets the output mode (C1C2C3 or C1C3C2).
+// SetRandom sets the source from which the ephemeral key k is drawn.
+// If random is nil, crypto/rand.Reader is used.
+func (e *SM2Engine) SetRandom(random io.Reader) {
+	if random == nil {
+		random = rand.Reader
+	}
+	e.random = random
+}
+
+// SetDigest sets the digest used for the KDF and C3. If digest is nil,
+// SM3 is used.
+func (e *SM2Engine) SetDigest(digest crypto.Digest) {
+	if digest == nil {
+		digest = digests.NewSM3Digest()
+	}
+	e.digest = digest
+}
+
+// SetMode sets the output mode (C1C2C3, C1C3C2 or DER).
 func (e *SM2Engine) SetMode(mode int) {
 	e.mode = mode
 }
 
-// Init initializes the engine for encryption or decryption.
+// SetPointCompression sets whether C1 is written in compressed form.
+// Compressed, uncompressed and hybrid C1 are all accepted for decryption.
+func (e *SM2Engine) SetPointCompression(compressed bool) {
+	e.pointCompression = compressed
+}
+
+// Init initializes the engine for encryption or decryption on the
+// sm2p256v1 curve.
 func (e *SM2Engine) Init(forEncryption bool, publicKey *ec.Point, privateKey *big.Int) error {
-	e.forEncryption = forEncryption
+	return e.InitWithDomain(forEncryption, GetECDomainParameters(), publicKey, privateKey)
+}
+
+// InitWithDomain initializes the engine for encryption or decryption on
+// the curve of domain, e.g. params.NewECDomainParametersByName(ec.SM2TestFp256).
+// For encryption publicKey must be a point of order n on that curve, and
+// for decryption privateKey must be in [1, n-1].
+func (e *SM2Engine) InitWithDomain(forEncryption bool, domain *params.ECDomainParameters, publicKey *ec.Point, privateKey *big.Int) error {
+	if domain == nil || domain.GetCurve() == nil || domain.GetG() == nil {
+		return exceptions.New(exceptions.ErrInvalidKey, "EC domain parameters required")
+	}
 	
 	if forEncryption {
-		if publicKey == nil || publicKey.IsInfinity() {
-			return errors.New("invalid public key")
-		}
-		if !ValidatePublicKey(publicKey) {
-			return errors.New("public key validation failed")
+		// CheckPoint also rejects a point on another curve, and a point
+		// outside the subgroup, for which S = [h]Pb could be infinity
+		if err := domain.GetCurve().CheckPoint(publicKey); err != nil {
+			return exceptions.Newf(exceptions.ErrInvalidKey, "invalid public key: %w", err)
 		}
+		e.Destroy()
 		e.publicKey = publicKey
 	} else {
-		if privateKey == nil {
-			return errors.New("invalid private key")
-		}
-		if !ValidatePrivateKey(privateKey) {
-			return errors.New("private key validation failed")
+		if privateKey == nil || privateKey.Sign() <= 0 || privateKey.Cmp(domain.GetN()) >= 0 {
+			return exceptions.New(exceptions.ErrInvalidKey, "invalid private key")
 		}
-		e.privateKey = privateKey
+		e.Destroy()
+		e.privateKey = new(big.Int).Set(privateKey)
 	}
+	e.forEncryption = forEncryption
+	e.domain = domain
 	
 	return nil
 }
 
+// Destroy overwrites the engine's copy of the private key and discards
+// the public key. The engine must be initialized again before use.
+func (e *SM2Engine) Destroy() {
+	util.ClearBigInt(e.privateKey)
+	e.privateKey = nil
+	e.publicKey = nil
+	e.forEncryption = false
+	e.digest.Reset()
+}
+
 // Encrypt encrypts plaintext using SM2 public key encryption.
 // Output format: C1 || C3 || C2 (new standard) or C1 || C2 || C3 (old)
 // where:
@@ -72,40 +136,20 @@ func (e *SM2Engine) Init(forEncryption bool, publicKey *ec.Point, privateKey *bi
 //   C3 = hash/MAC (32 bytes for SM3)
 func (e *SM2Engine) Encrypt(plaintext []byte) ([]byte, error) {
 	if !e.forEncryption {
-		return nil, errors.New("engine not initialized for encryption")
+		return nil, exceptions.New(exceptions.ErrInvalidState, "engine not initialized for encryption")
 	}
 	
-	curve := e.curve
-	n := curve.GetOrder()
-	
 	for {
-		// Step 1: Generate random k in [1, n-1]
-		k, err := randRange(n)
+		// Steps 1-4: C1 = [k]G and (x2, y2) = [k]Pb for a random k
+		c1, x2Bytes, y2Bytes, err := e.ephemeralKey()
 		if err != nil {
 			return nil, err
 		}
 		
-		// Step 2: Compute C1 = [k]G
-		c1Point := GetG().Multiply(k)
-		c1 := c1Point.GetEncoded(false) // Uncompressed
-		
-		// Step 3: Compute S = h * Pb (h=1 for SM2, so S = Pb)
-		// Check if S is infinity (should not happen for valid key)
-		if e.publicKey.IsInfinity() {
-			return nil, errors.New("invalid public key point")
-		}
-		
-		// Step 4: Compute [k]Pb = (x2, y2)
-		kPb := e.publicKey.Multiply(k)
-		x2 := kPb.GetXCoord().ToBigInt()
-		y2 := kPb.GetYCoord().ToBigInt()
-		
 		// Step 5: Compute t = KDF(x2 || y2, klen)
-		x2Bytes := util.BigIntToBytes(x2, 32)
-		y2Bytes := util.BigIntToBytes(y2, 32)
-		
 		kdfInput := append(x2Bytes, y2Bytes...)
-		t := KDF(kdfInput, len(plaintext))
+		t := kdf(e.digest, kdfInput, len(plaintext))
+		util.Clear(kdfInput)
 		
 		// Check if t is all zeros (retry if so)
 		// Skip check if plaintext is empty
@@ -118,6 +162,8 @@ func (e *SM2Engine) Encrypt(plaintext []byte) ([]byte, error) {
 				}
 			}
 			if allZero {
+				util.Clear(x2Bytes)
+				util.Clear(y2Bytes)
 				continue // Retry with different k
 			}
 		}
@@ -127,107 +173,60 @@ func (e *SM2Engine) Encrypt(plaintext []byte) ([]byte, error) {
 		for i := 0; i < len(plaintext); i++ {
 			c2[i] = plaintext[i] ^ t[i]
 		}
+		util.Clear(t)
 		
 		// Step 7: Compute C3 = Hash(x2 || M || y2)
-		digest := digests.NewSM3Digest()
+		digest := e.digest
+		digest.Reset()
 		digest.BlockUpdate(x2Bytes, 0, len(x2Bytes))
 		digest.BlockUpdate(plaintext, 0, len(plaintext))
 		digest.BlockUpdate(y2Bytes, 0, len(y2Bytes))
 		
 		c3 := make([]byte, digest.GetDigestSize())
 		digest.DoFinal(c3, 0)
+		util.Clear(x2Bytes)
+		util.Clear(y2Bytes)
 		
-		// Step 8: Output C = C1 || C3 || C2 or C1 || C2 || C3
-		var ciphertext []byte
-		if e.mode == Mode_C1C3C2 {
-			ciphertext = append(c1, c3...)
-			ciphertext = append(ciphertext, c2...)
-		} else {
-			ciphertext = append(c1, c2...)
-			ciphertext = append(ciphertext, c3...)
-		}
-		
-		return ciphertext, nil
+		// Step 8: Output C = C1 || C3 || C2, C1 || C2 || C3 or DER
+		parts := &ciphertextParts{c1: c1, c2: c2, c3: c3}
+		return parts.encode(e.mode, e.domain.GetCurve())
 	}
 }
 
 // Decrypt decrypts ciphertext using SM2 private key.
 func (e *SM2Engine) Decrypt(ciphertext []byte) ([]byte, error) {
-	if e.forEncryption {
-		return nil, errors.New("engine not initialized for decryption")
-	}
-	
-	// Parse ciphertext
-	// C1 is either 65 bytes (uncompressed) or 33 bytes (compressed)
-	if len(ciphertext) < 97 { // Minimum: 65 (C1) + 32 (C3) + 0 (C2)
-		return nil, errors.New("ciphertext too short")
+	if e.forEncryption || e.privateKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "engine not initialized for decryption")
 	}
 	
-	// Determine C1 length based on first byte
-	var c1Len int
-	if ciphertext[0] == 0x04 {
-		c1Len = 65 // Uncompressed
-	} else if ciphertext[0] == 0x02 || ciphertext[0] == 0x03 {
-		c1Len = 33 // Compressed
-	} else {
-		return nil, errors.New("invalid ciphertext format")
+	// Parse ciphertext. DER input and the C1 encoding (compressed or
+	// uncompressed) are detected automatically; the mode gives the order
+	// of C2 and C3 in raw input.
+	parts, err := parseCiphertext(ciphertext, e.mode, e.fieldBytes(), e.digest.GetDigestSize())
+	if err != nil {
+		return nil, err
 	}
+	c2, c3 := parts.c2, parts.c3
 	
-	c3Len := 32 // SM3 output size
-	c2Len := len(ciphertext) - c1Len - c3Len
-	
-	if c2Len < 0 {
-		return nil, errors.New("invalid ciphertext length")
+	// Steps 1-3: Decode C1, check it is on the curve and compute [d]C1
+	x2Bytes, y2Bytes, err := e.sharedPoint(parts.c1)
+	if err != nil {
+		return nil, err
 	}
 	
-	// Extract C1, C2, C3 based on mode
-	c1 := ciphertext[0:c1Len]
-	var c2, c3 []byte
-	
-	if e.mode == Mode_C1C3C2 {
-		c3 = ciphertext[c1Len : c1Len+c3Len]
-		c2 = ciphertext[c1Len+c3Len:]
-	} else {
-		c2 = ciphertext[c1Len : c1Len+c2Len]
-		c3 = ciphertext[c1Len+c2Len:]
-	}
-	
-	// Step 1: Decode C1 to point
-	c1Point := e.curve.DecodePoint(c1)
-	if c1Point.IsInfinity() {
-		return nil, errors.New("invalid C1 point")
-	}
-	
-	// Step 2: Compute S = h * C1 (h=1, so S = C1)
-	// Check if S is infinity
-	if c1Point.IsInfinity() {
-		return nil, errors.New("invalid point")
-	}
-	
-	// Step 3: Compute [d]C1 = (x2, y2)
-	dC1 := c1Point.Multiply(e.privateKey)
-	x2 := dC1.GetXCoord().ToBigInt()
-	y2 := dC1.GetYCoord().ToBigInt()
-	
 	// Step 4: Compute t = KDF(x2 || y2, klen)
-	x2Bytes := util.BigIntToBytes(x2, 32)
-	y2Bytes := util.BigIntToBytes(y2, 32)
-	
 	kdfInput := append(x2Bytes, y2Bytes...)
-	t := KDF(kdfInput, len(c2))
+	t := kdf(e.digest, kdfInput, len(c2))
+	util.Clear(kdfInput)
 	
-	// Check if t is all zeros (skip if c2 is empty)
+	// t must not be all zeros (unless c2 is empty); checked with C3 below
+	var nonZero byte
+	for _, b := range t {
+		nonZero |= b
+	}
+	zeroKey := 0
 	if len(c2) > 0 {
-		allZero := true
-		for _, b := range t {
-			if b != 0 {
-				allZero = false
-				break
-			}
-		}
-		if allZero {
-			return nil, errors.New("KDF output is all zeros")
-		}
+		zeroKey = subtle.ConstantTimeByteEq(nonZero, 0)
 	}
 	
 	// Step 5: Compute M' = C2 ⊕ t
@@ -235,36 +234,102 @@ func (e *SM2Engine) Decrypt(ciphertext []byte) ([]byte, error) {
 	for i := 0; i < len(c2); i++ {
 		plaintext[i] = c2[i] ^ t[i]
 	}
+	util.Clear(t)
 	
 	// Step 6: Compute u = Hash(x2 || M' || y2)
-	digest := digests.NewSM3Digest()
+	digest := e.digest
+	digest.Reset()
 	digest.BlockUpdate(x2Bytes, 0, len(x2Bytes))
 	digest.BlockUpdate(plaintext, 0, len(plaintext))
 	digest.BlockUpdate(y2Bytes, 0, len(y2Bytes))
 	
 	u := make([]byte, digest.GetDigestSize())
 	digest.DoFinal(u, 0)
+	util.Clear(x2Bytes)
+	util.Clear(y2Bytes)
 	
-	// Step 7: Verify u == C3
-	for i := 0; i < len(c3); i++ {
-		if c3[i] != u[i] {
-			return nil, errors.New("MAC verification failed")
-		}
+	// Step 7: Verify u == C3 in constant time. Both checks fail with the
+	// same error, which does not tell which one failed.
+	if zeroKey|(1^subtle.ConstantTimeCompare(u, c3)) != 0 {
+		util.Clear(plaintext)
+		return nil, errDecryption
 	}
 	
 	return plaintext, nil
 }
 
-// randRange generates a random number in [1, max-1].
-func randRange(max *big.Int) (*big.Int, error) {
-	// Generate random in [1, max-1]
+// ephemeralKey draws k in [1, n-1] and returns the encoding of C1 = [k]G
+// together with the coordinates of [k]Pb.
+func (e *SM2Engine) ephemeralKey() (c1, x2, y2 []byte, err error) {
+	k, err := randRange(e.random, e.domain.GetN())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	c1 = e.domain.GetG().MultiplySecret(k).GetEncoded(e.pointCompression)
+
+	// S = [h]Pb = Pb as h = 1 for SM2; it must not be infinity
+	if e.publicKey.IsInfinity() {
+		util.ClearBigInt(k)
+		return nil, nil, nil, exceptions.New(exceptions.ErrInvalidKey, "invalid public key point")
+	}
+
+	kPb := e.publicKey.MultiplySecret(k)
+	util.ClearBigInt(k)
+	x2 = util.BigIntToBytes(kPb.GetXCoord().ToBigInt(), e.fieldBytes())
+	y2 = util.BigIntToBytes(kPb.GetYCoord().ToBigInt(), e.fieldBytes())
+	return c1, x2, y2, nil
+}
+
+// sharedPoint decodes C1, checks that it is a point of order n and returns
+// the coordinates of [d]C1.
+func (e *SM2Engine) sharedPoint(c1 []byte) (x2, y2 []byte, err error) {
+	c1Point, err := e.domain.GetCurve().DecodePoint(c1)
+	if err != nil {
+		return nil, nil, exceptions.Newf(exceptions.ErrInvalidCipherText, "invalid C1 point: %w", err)
+	}
+
+	dC1 := c1Point.MultiplySecret(e.privateKey)
+	x2 = util.BigIntToBytes(dC1.GetXCoord().ToBigInt(), e.fieldBytes())
+	y2 = util.BigIntToBytes(dC1.GetYCoord().ToBigInt(), e.fieldBytes())
+	return x2, y2, nil
+}
+
+// newDigest returns a new instance of the engine's digest, as streams
+// need one for the KDF and one for C3 besides e.digest. The instance is
+// made with crypto.Memoable, and digests without it are not supported.
+func (e *SM2Engine) newDigest() (crypto.Digest, error) {
+	if m, ok := e.digest.(crypto.Memoable); ok {
+		if digest, ok := m.Copy().(crypto.Digest); ok {
+			digest.Reset()
+			return digest, nil
+		}
+	}
+	return nil, exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "stream mode requires a crypto.Memoable digest, got %s", e.digest.GetAlgorithmName())
+}
+
+// fieldBytes returns the byte length of a field element of the engine's
+// curve.
+func (e *SM2Engine) fieldBytes() int {
+	return (e.domain.GetCurve().GetFieldSize() + 7) / 8
+}
+
+// randRange draws a number uniformly from [1, max-1] by rejection sampling
+// on bytes read from random, so a deterministic reader reproduces a chosen
+// value exactly.
+func randRange(random io.Reader, max *big.Int) (*big.Int, error) {
+	buf := make([]byte, (max.BitLen()+7)/8)
+	excess := uint(len(buf)*8 - max.BitLen())
+
 	for {
-		k, err := rand.Int(rand.Reader, max)
-		if err != nil {
+		if _, err := io.ReadFull(random, buf); err != nil {
 			return nil, err
 		}
-		// Ensure k is not zero
-		if k.Sign() > 0 {
+		buf[0] &= byte(0xFF >> excess)
+
+		k := new(big.Int).SetBytes(buf)
+		if k.Sign() > 0 && k.Cmp(max) < 0 {
+			util.Clear(buf)
 			return k, nil
 		}
 	}
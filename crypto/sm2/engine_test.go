@@ -2,8 +2,14 @@ package sm2
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"math/big"
 	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 func TestKDF(t *testing.T) {
@@ -225,6 +231,44 @@ func TestSM2EngineInvalidCiphertext(t *testing.T) {
 	if err == nil {
 		t.Error("Should fail with invalid C1 format")
 	}
+	
+	// Test with C1 off the curve
+	invalid[0] = 0x04
+	_, err = engine.Decrypt(invalid)
+	if !errors.Is(err, ec.ErrPointNotOnCurve) {
+		t.Errorf("Off-curve C1: got %v, want ec.ErrPointNotOnCurve", err)
+	}
+	if !errors.Is(err, exceptions.ErrInvalidCipherText) {
+		t.Errorf("Off-curve C1: got %v, want exceptions.ErrInvalidCipherText", err)
+	}
+}
+
+func TestSM2EnginePointCompression(t *testing.T) {
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("compressed C1")
+	
+	engine := NewSM2Engine()
+	engine.SetMode(Mode_C1C3C2)
+	engine.SetPointCompression(true)
+	_ = engine.Init(true, keyPair.PublicKey, nil)
+	ciphertext, err := engine.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if len(ciphertext) != 33+32+len(plaintext) || (ciphertext[0] != 0x02 && ciphertext[0] != 0x03) {
+		t.Fatalf("C1 is not compressed: %x", ciphertext[:1])
+	}
+	
+	engine = NewSM2Engine()
+	engine.SetMode(Mode_C1C3C2)
+	_ = engine.Init(false, nil, keyPair.PrivateKey)
+	decrypted, err := engine.Decrypt(ciphertext)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decryption failed: %v", err)
+	}
 }
 
 func TestSM2EngineWrongKey(t *testing.T) {
@@ -247,8 +291,8 @@ func TestSM2EngineWrongKey(t *testing.T) {
 	engine2.Init(false, nil, privateKey2)
 	
 	_, err = engine2.Decrypt(ciphertext)
-	if err == nil {
-		t.Error("Should fail to decrypt with wrong key")
+	if !errors.Is(err, exceptions.ErrAuthenticationFailed) {
+		t.Errorf("Should fail to decrypt with wrong key, got %v", err)
 	}
 }
 
@@ -288,3 +332,135 @@ func TestSM2EngineMultipleMessages(t *testing.T) {
 		}
 	}
 }
+
+// TestSM2EngineGMT0003Example reproduces the sm2p256v1 public key
+// encryption example of GM/T 0003.5-2012 with the standard's ephemeral key k.
+func TestSM2EngineGMT0003Example(t *testing.T) {
+	d := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	publicKey := GetG().Multiply(d)
+	plaintext := []byte("encryption standard")
+
+	c1, _ := hex.DecodeString("04" +
+		"04EBFC718E8D1798620432268E77FEB6415E2EDE0E073C0F4F640ECD2E149A73" +
+		"E858F9D81E5430A57B36DAAB8F950A3C64E6EE6A63094D99283AFF767E124DF0")
+	c3, _ := hex.DecodeString("59983C18F809E262923C53AEC295D30383B54E39D609D160AFCB1908D0BD8766")
+	c2, _ := hex.DecodeString("21886CA989CA9C7D58087307CA93092D651EFA")
+
+	for _, tc := range []struct {
+		name     string
+		mode     int
+		expected []byte
+	}{
+		{"C1C3C2", Mode_C1C3C2, bytes.Join([][]byte{c1, c3, c2}, nil)},
+		{"C1C2C3", Mode_C1C2C3, bytes.Join([][]byte{c1, c2, c3}, nil)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := NewSM2Engine()
+			engine.SetMode(tc.mode)
+			engine.SetRandom(bytes.NewReader(k))
+			if err := engine.Init(true, publicKey, nil); err != nil {
+				t.Fatalf("Failed to init for encryption: %v", err)
+			}
+			ciphertext, err := engine.Encrypt(plaintext)
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			if !bytes.Equal(ciphertext, tc.expected) {
+				t.Errorf("Ciphertext mismatch\nExpected: %X\nGot:      %X", tc.expected, ciphertext)
+			}
+
+			engine = NewSM2Engine()
+			engine.SetMode(tc.mode)
+			if err := engine.Init(false, nil, d); err != nil {
+				t.Fatalf("Failed to init for decryption: %v", err)
+			}
+			decrypted, err := engine.Decrypt(tc.expected)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("Decrypted text mismatch: %q", decrypted)
+			}
+		})
+	}
+}
+
+func TestSM2EngineRandomSourceExhausted(t *testing.T) {
+	publicKey := GetG().Multiply(big.NewInt(123456789))
+
+	engine := NewSM2Engine()
+	engine.SetRandom(bytes.NewReader(make([]byte, 16)))
+	if err := engine.Init(true, publicKey, nil); err != nil {
+		t.Fatalf("Failed to init for encryption: %v", err)
+	}
+	if _, err := engine.Encrypt([]byte("test")); err == nil {
+		t.Error("Expected error from exhausted random source")
+	}
+}
+
+// TestSM2EngineInitWithDomain reproduces the encryption example of
+// GM/T 0003.4-2012 Appendix A on the 256-bit Fp test curve and checks that
+// keys are validated against the curve of the domain.
+func TestSM2EngineInitWithDomain(t *testing.T) {
+	domain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := fromHex("1649AB77A00637BD5E2EFE283FBF353534AA7F7CB89463F208DDBC2920BB0DA0")
+	k, _ := hex.DecodeString("4C62EEFD6ECFC2B95B92FD6C3D9575148AFA17425546D49018E5388D49DD7B4F")
+	plaintext := []byte("encryption standard")
+	expected, _ := hex.DecodeString("04" +
+		"245C26FB68B1DDDDB12C4B6BF9F2B6D5FE60A383B0D18D1C4144ABF17F6252E7" +
+		"76CB9264C2A7E88E52B19903FDC47378F605E36811F5C07423A24B84400F01B8" +
+		"650053A89B41C418B0C3AAD00D886C00286467" +
+		"9C3D7360C30156FAB7C80A0276712DA9D8094A634B766D3A285E07480653426D")
+
+	engine := NewSM2Engine()
+	engine.SetRandom(bytes.NewReader(k))
+	if err := engine.InitWithDomain(true, domain, domain.GetG().Multiply(d), nil); err != nil {
+		t.Fatalf("Failed to init for encryption: %v", err)
+	}
+	ciphertext, err := engine.Encrypt(plaintext)
+	if err != nil || !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext mismatch: %v\nExpected: %X\nGot:      %X", err, expected, ciphertext)
+	}
+
+	if err := engine.InitWithDomain(false, domain, nil, d); err != nil {
+		t.Fatalf("Failed to init for decryption: %v", err)
+	}
+	decrypted, err := engine.Decrypt(expected)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decryption failed: %q, %v", decrypted, err)
+	}
+
+	// Points of one curve are not keys for the other
+	if err := engine.Init(true, domain.GetG(), nil); !errors.Is(err, ec.ErrPointNotOnCurve) {
+		t.Errorf("Test curve key on sm2p256v1: got %v, want ec.ErrPointNotOnCurve", err)
+	}
+	if err := engine.InitWithDomain(true, domain, GetG(), nil); !errors.Is(err, ec.ErrPointNotOnCurve) {
+		t.Errorf("sm2p256v1 key on the test curve: got %v, want ec.ErrPointNotOnCurve", err)
+	}
+	if ValidatePublicKey(domain.GetG()) {
+		t.Error("ValidatePublicKey accepted a point on another curve")
+	}
+}
+
+func TestSM2EngineDestroy(t *testing.T) {
+	d := big.NewInt(123456789)
+	engine := NewSM2Engine()
+	if err := engine.Init(false, nil, d); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	key := engine.privateKey
+	engine.Destroy()
+	if key.Sign() != 0 {
+		t.Error("Private key not cleared")
+	}
+	if d.Cmp(big.NewInt(123456789)) != 0 {
+		t.Error("Destroy cleared the caller's private key")
+	}
+	if _, err := engine.Decrypt(make([]byte, 97)); !errors.Is(err, exceptions.ErrInvalidState) {
+		t.Errorf("Decrypt after Destroy = %v, want ErrInvalidState", err)
+	}
+}
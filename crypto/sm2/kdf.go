@@ -1,6 +1,7 @@
 package sm2
 
 import (
+	"github.com/lihongjie0209/sm-go-bc/crypto"
 	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
 	"github.com/lihongjie0209/sm-go-bc/util"
 )
@@ -8,18 +9,25 @@ import (
 // KDF implements the Key Derivation Function defined in GM/T 0003-2012.
 // KDF(Z, klen) = K₁ || K₂ || ... || Kₙ
 // where Kᵢ = Hash(Z || Counter(i))
+// Intermediate hashes are cleared; the caller should clear the result once
+// it is no longer needed.
 func KDF(z []byte, klen int) []byte {
+	return kdf(digests.NewSM3Digest(), z, klen)
+}
+
+// kdf computes KDF(Z, klen) with the given hash, which is reset first.
+func kdf(digest crypto.Digest, z []byte, klen int) []byte {
 	if klen <= 0 {
 		return []byte{}
 	}
 	
-	digest := digests.NewSM3Digest()
 	hashLen := digest.GetDigestSize()
 	
 	// Calculate number of hash iterations needed
 	numBlocks := (klen + hashLen - 1) / hashLen
 	
 	result := make([]byte, 0, numBlocks*hashLen)
+	hash := make([]byte, hashLen)
 	
 	for i := 1; i <= numBlocks; i++ {
 		// Hash(Z || Counter)
@@ -30,12 +38,13 @@ func KDF(z []byte, klen int) []byte {
 		counter := util.IntToBytes(i)
 		digest.BlockUpdate(counter, 0, len(counter))
 		
-		hash := make([]byte, hashLen)
 		digest.DoFinal(hash, 0)
 		result = append(result, hash...)
 	}
+	util.Clear(hash)
 	
-	// Return exactly klen bytes
+	// Clear the bytes beyond klen and return exactly klen bytes
+	util.Clear(result[klen:])
 	return result[:klen]
 }
 
@@ -48,3 +57,63 @@ func IsAllZero(data []byte) bool {
 	}
 	return true
 }
+
+// kdfStream produces the KDF output incrementally, one hash block at a time,
+// so that arbitrarily long messages can be processed without computing the
+// whole key stream up front. It also tracks whether every byte produced so
+// far was zero, as GM/T 0003 rejects an all-zero t.
+type kdfStream struct {
+	digest  crypto.Digest
+	z       []byte
+	counter int
+	block   []byte
+	off     int
+	allZero bool
+}
+
+// newKDFStream creates a key stream for KDF(z, ·) computed with digest,
+// which the stream takes over.
+func newKDFStream(digest crypto.Digest, z []byte) *kdfStream {
+	return &kdfStream{digest: digest, z: z, allZero: true}
+}
+
+// XORKeyStream sets dst[i] = src[i] ^ t[i] for the next len(src) bytes t of
+// the key stream. dst and src may be the same slice.
+func (s *kdfStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.off == len(s.block) {
+			s.nextBlock()
+		}
+		t := s.block[s.off]
+		s.off++
+		if t != 0 {
+			s.allZero = false
+		}
+		dst[i] = src[i] ^ t
+	}
+}
+
+// nextBlock computes Hash(Z || Counter) for the next counter value.
+func (s *kdfStream) nextBlock() {
+	s.counter++
+	digest := s.digest
+	digest.Reset()
+	digest.BlockUpdate(s.z, 0, len(s.z))
+	counter := util.IntToBytes(s.counter)
+	digest.BlockUpdate(counter, 0, len(counter))
+
+	if s.block == nil {
+		s.block = make([]byte, digest.GetDigestSize())
+	}
+	digest.DoFinal(s.block, 0)
+	s.off = 0
+}
+
+// destroy clears Z, the current block and the digest state of the key
+// stream.
+func (s *kdfStream) destroy() {
+	util.Clear(s.z)
+	util.Clear(s.block)
+	s.off = len(s.block)
+	s.digest.Reset()
+}
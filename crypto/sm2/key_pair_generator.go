@@ -0,0 +1,82 @@
+package sm2
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// SM2KeyPairGenerator generates SM2 key pairs on sm2p256v1.
+// Based on: org.bouncycastle.crypto.generators.ECKeyPairGenerator
+type SM2KeyPairGenerator struct {
+	random io.Reader
+}
+
+// NewSM2KeyPairGenerator creates a key pair generator that uses
+// crypto/rand.Reader until Init supplies another source.
+func NewSM2KeyPairGenerator() *SM2KeyPairGenerator {
+	return &SM2KeyPairGenerator{random: rand.Reader}
+}
+
+// Init sets the random source. param may be nil, to use crypto/rand.Reader,
+// or *params.ParametersWithRandom carrying a DRBG or a deterministic reader.
+func (g *SM2KeyPairGenerator) Init(param crypto.CipherParameters) error {
+	switch p := param.(type) {
+	case nil:
+		g.random = rand.Reader
+	case *params.ParametersWithRandom:
+		g.random = p.GetRandom()
+	default:
+		return exceptions.New(exceptions.ErrInvalidParameter, "SM2KeyPairGenerator: unsupported parameters")
+	}
+	return nil
+}
+
+// GenerateKeyPair generates a key pair with d uniformly distributed in
+// [1, n-2] and Q = [d]G.
+func (g *SM2KeyPairGenerator) GenerateKeyPair() (*KeyPair, error) {
+	d, err := randPrivateKey(g.random)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		PrivateKey: d,
+		PublicKey:  GetG().MultiplySecret(d),
+	}, nil
+}
+
+// GenerateKey generates an SM2 key pair using the given random source.
+// If random is nil, crypto/rand.Reader is used.
+func GenerateKey(random io.Reader) (*KeyPair, error) {
+	g := NewSM2KeyPairGenerator()
+	if random != nil {
+		g.random = random
+	}
+	return g.GenerateKeyPair()
+}
+
+// randPrivateKey samples d uniformly from [1, n-2] by rejection sampling.
+// n-1 is excluded because SM2 signing needs (1 + d) to be invertible.
+func randPrivateKey(random io.Reader) (*big.Int, error) {
+	max := new(big.Int).Sub(sm2N, big.NewInt(2))
+	buf := make([]byte, (max.BitLen()+7)/8)
+	excess := uint(len(buf)*8 - max.BitLen())
+
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, err
+		}
+		// Drop bits above the bit length of n-2 so that the expected
+		// number of attempts stays below two
+		buf[0] &= byte(0xFF >> excess)
+
+		d := new(big.Int).SetBytes(buf)
+		if d.Sign() > 0 && d.Cmp(max) <= 0 {
+			return d, nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+)
+
+func TestGenerateKey(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		keyPair, err := GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey failed: %v", err)
+		}
+
+		d := keyPair.PrivateKey
+		if d.Sign() <= 0 || d.Cmp(new(big.Int).Sub(GetN(), big.NewInt(1))) >= 0 {
+			t.Fatalf("Private key out of range [1, n-2]: %x", d)
+		}
+		if !keyPair.PublicKey.Equals(GetG().Multiply(d)) {
+			t.Fatal("Public key is not [d]G")
+		}
+		if !ValidatePublicKey(keyPair.PublicKey) {
+			t.Fatal("Generated public key is invalid")
+		}
+	}
+}
+
+func TestSM2KeyPairGeneratorDeterministic(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x5A}, 64)
+
+	gen1 := NewSM2KeyPairGenerator()
+	if err := gen1.Init(params.NewParametersWithRandom(nil, bytes.NewReader(seed))); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	kp1, err := gen1.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	kp2, err := GenerateKey(bytes.NewReader(seed))
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	expected := new(big.Int).SetBytes(seed[:32])
+	if kp1.PrivateKey.Cmp(expected) != 0 || kp2.PrivateKey.Cmp(expected) != 0 {
+		t.Errorf("Private key not taken from the supplied reader")
+	}
+	if !kp1.PublicKey.Equals(kp2.PublicKey) {
+		t.Error("Same reader produced different public keys")
+	}
+}
+
+func TestSM2KeyPairGeneratorRejectsOutOfRange(t *testing.T) {
+	nMinus1 := new(big.Int).Sub(GetN(), big.NewInt(1)).FillBytes(make([]byte, 32))
+	nMinus2 := new(big.Int).Sub(GetN(), big.NewInt(2)).FillBytes(make([]byte, 32))
+
+	// 0, n-1 and 2^256-1 must all be rejected before n-2 is accepted
+	var stream []byte
+	stream = append(stream, make([]byte, 32)...)
+	stream = append(stream, nMinus1...)
+	stream = append(stream, bytes.Repeat([]byte{0xFF}, 32)...)
+	stream = append(stream, nMinus2...)
+
+	keyPair, err := GenerateKey(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	if keyPair.PrivateKey.Cmp(new(big.Int).SetBytes(nMinus2)) != 0 {
+		t.Errorf("Expected d = n-2, got %x", keyPair.PrivateKey)
+	}
+
+	// An exhausted reader is reported
+	if _, err := GenerateKey(bytes.NewReader(make([]byte, 40))); err == nil {
+		t.Error("Expected error from exhausted reader")
+	}
+}
+
+func TestSM2KeyPairGeneratorInit(t *testing.T) {
+	gen := NewSM2KeyPairGenerator()
+	if err := gen.Init(nil); err != nil {
+		t.Errorf("Init(nil) failed: %v", err)
+	}
+	if err := gen.Init(params.NewKeyParameter(make([]byte, 16))); err == nil {
+		t.Error("Expected error for unsupported parameters")
+	}
+}
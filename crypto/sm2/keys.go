@@ -0,0 +1,184 @@
+package sm2
+
+import (
+	"crypto"
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// PublicKey is an SM2 public key. It implements crypto.PublicKey. Its
+// methods are safe for concurrent use.
+type PublicKey struct {
+	Q *ec.Point
+}
+
+// PrivateKey is an SM2 private key. It implements crypto.Signer and
+// crypto.Decrypter, so it can be used wherever the standard library accepts
+// those interfaces. Its methods are safe for concurrent use, except
+// Destroy.
+type PrivateKey struct {
+	PublicKey
+	D *big.Int
+}
+
+// SignerOpts selects how PrivateKey.Sign treats its digest argument.
+//
+// When *SignerOpts is passed, the digest argument is the raw message and the
+// signature covers SM3(Z || M), with Z computed from UID. With any other
+// opts (including nil) the digest argument must already be the 32-byte hash
+// e = SM3(Z || M).
+type SignerOpts struct {
+	// UID is the signer's user ID; DefaultUserID is used if nil.
+	UID []byte
+}
+
+// HashFunc returns 0: SM3 has no crypto.Hash identifier, and the message is
+// hashed by Sign itself.
+func (o *SignerOpts) HashFunc() crypto.Hash {
+	return 0
+}
+
+// uid returns the user ID, falling back to the default.
+func (o *SignerOpts) uid() []byte {
+	if o.UID == nil {
+		return DefaultUserID
+	}
+	return o.UID
+}
+
+// EncrypterOpts selects the ciphertext layout for PublicKey.Encrypt.
+type EncrypterOpts struct {
+	Mode int // Mode_C1C2C3 (default), Mode_C1C3C2 or Mode_DER
+}
+
+// DecrypterOpts selects the ciphertext layout for PrivateKey.Decrypt.
+type DecrypterOpts struct {
+	Mode int // Mode_C1C2C3 (default), Mode_C1C3C2 or Mode_DER
+}
+
+// NewPublicKey wraps the point q after checking that it is a valid public key.
+func NewPublicKey(q *ec.Point) (*PublicKey, error) {
+	if q == nil || !ValidatePublicKey(q) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid public key")
+	}
+	return &PublicKey{Q: q}, nil
+}
+
+// NewPrivateKey creates a private key from d in [1, n-2] and derives its
+// public key.
+func NewPrivateKey(d *big.Int) (*PrivateKey, error) {
+	if d == nil || d.Sign() <= 0 || d.Cmp(new(big.Int).Sub(sm2N, big.NewInt(1))) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid private key")
+	}
+	return &PrivateKey{
+		PublicKey: PublicKey{Q: GetG().MultiplySecret(d)},
+		D:         new(big.Int).Set(d),
+	}, nil
+}
+
+// Equal reports whether pub and x have the same value.
+func (pub *PublicKey) Equal(x crypto.PublicKey) bool {
+	other, ok := x.(*PublicKey)
+	return ok && pub.Q.Equals(other.Q)
+}
+
+// Verify checks a DER-encoded signature. opts has the same meaning as for
+// PrivateKey.Sign; a digest made with another hash function never
+// verifies.
+func (pub *PublicKey) Verify(digest, sig []byte, opts crypto.SignerOpts) bool {
+	if o, ok := opts.(*SignerOpts); ok {
+		var err error
+		if digest, err = hashMessage(o.uid(), pub.Q, digest); err != nil {
+			return false
+		}
+	} else if (opts != nil && opts.HashFunc() != 0) || len(digest) != 32 {
+		return false
+	}
+
+	r, s, err := unmarshalSignature(sig)
+	if err != nil {
+		return false
+	}
+	return verifyDigest(pub.Q, digest, r, s)
+}
+
+// Encrypt encrypts msg to pub. opts may be nil or *EncrypterOpts.
+func (pub *PublicKey) Encrypt(random io.Reader, msg []byte, opts *EncrypterOpts) ([]byte, error) {
+	engine := NewSM2Engine()
+	engine.SetRandom(random)
+	if opts != nil {
+		engine.SetMode(opts.Mode)
+	}
+	if err := engine.Init(true, pub.Q, nil); err != nil {
+		return nil, err
+	}
+	return engine.Encrypt(msg)
+}
+
+// Public returns the public key.
+func (priv *PrivateKey) Public() crypto.PublicKey {
+	return &priv.PublicKey
+}
+
+// Equal reports whether priv and x have the same value.
+func (priv *PrivateKey) Equal(x crypto.PrivateKey) bool {
+	other, ok := x.(*PrivateKey)
+	return ok && priv.D.Cmp(other.D) == 0
+}
+
+// Sign signs digest with priv and returns a DER-encoded signature. If opts
+// is *SignerOpts, digest is the message itself and is hashed together with
+// Z; otherwise it must be the 32-byte hash e = SM3(Z || M) and opts must be
+// nil or have a HashFunc of 0. A digest made with another hash function,
+// such as crypto.SHA256 passed by crypto/tls, is rejected with
+// ErrInvalidParameter rather than signed as if it were e. If random is nil,
+// crypto/rand.Reader is used.
+func (priv *PrivateKey) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	if o, ok := opts.(*SignerOpts); ok {
+		var err error
+		if digest, err = hashMessage(o.uid(), priv.Q, digest); err != nil {
+			return nil, err
+		}
+	} else if opts != nil && opts.HashFunc() != 0 {
+		return nil, exceptions.Newf(exceptions.ErrInvalidParameter, "SM2 signs SM3(Z || M), not a %v digest", opts.HashFunc())
+	} else if len(digest) != 32 {
+		return nil, exceptions.New(exceptions.ErrDataLength, "digest must be 32 bytes unless SignerOpts is used")
+	}
+
+	r, s, err := signDigest(random, priv.D, digest)
+	if err != nil {
+		return nil, err
+	}
+	return marshalSignature(r, s)
+}
+
+// Decrypt decrypts msg with priv. opts may be nil or *DecrypterOpts;
+// random is unused.
+func (priv *PrivateKey) Decrypt(random io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	engine := NewSM2Engine()
+	switch o := opts.(type) {
+	case nil:
+	case *DecrypterOpts:
+		engine.SetMode(o.Mode)
+	default:
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "unsupported decrypter options")
+	}
+	if err := engine.Init(false, nil, priv.D); err != nil {
+		return nil, err
+	}
+	defer engine.Destroy()
+	return engine.Decrypt(msg)
+}
+
+// Destroy overwrites D. The key must not be used afterwards.
+func (priv *PrivateKey) Destroy() {
+	util.ClearBigInt(priv.D)
+}
@@ -0,0 +1,213 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+var (
+	_ crypto.Signer    = (*PrivateKey)(nil)
+	_ crypto.Decrypter = (*PrivateKey)(nil)
+)
+
+func TestPrivateKeySignGMT0003Example(t *testing.T) {
+	priv, err := NewPrivateKey(fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8"))
+	if err != nil {
+		t.Fatalf("NewPrivateKey failed: %v", err)
+	}
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	message := []byte("message digest")
+
+	var signer crypto.Signer = priv
+	sig, err := signer.Sign(bytes.NewReader(k), message, &SignerOpts{UID: []byte("1234567812345678")})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	r, s, err := unmarshalSignature(sig)
+	if err != nil {
+		t.Fatalf("Failed to decode signature: %v", err)
+	}
+	if r.Cmp(fromHex("F5A03B0648D2C4630EEAC513E1BB81A15944DA3827D5B74143AC7EACEEE720B3")) != 0 ||
+		s.Cmp(fromHex("B1B6AA29DF212FD8763182BC0D421CA1BB9038FD1F7F42D4840B69C485BBC1AA")) != 0 {
+		t.Errorf("Signature mismatch: r=%X s=%X", r, s)
+	}
+
+	pub := signer.Public().(*PublicKey)
+	if !pub.Verify(message, sig, &SignerOpts{}) {
+		t.Error("Signature failed verification with the default user ID")
+	}
+	if pub.Verify(message, sig, &SignerOpts{UID: []byte("ALICE123@YAHOO.COM")}) {
+		t.Error("Signature verified with a different user ID")
+	}
+	if pub.Verify([]byte("message digesT"), sig, &SignerOpts{}) {
+		t.Error("Signature verified for a different message")
+	}
+}
+
+func TestPrivateKeySignPrehashed(t *testing.T) {
+	keyPair, _ := GenerateKey(rand.Reader)
+	priv, _ := NewPrivateKey(keyPair.PrivateKey)
+	e, _ := hashMessage(DefaultUserID, priv.Q, []byte("hello"))
+
+	sig, err := priv.Sign(rand.Reader, e, crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !priv.PublicKey.Verify(e, sig, nil) {
+		t.Error("Pre-hashed signature failed verification")
+	}
+	if !priv.PublicKey.Verify([]byte("hello"), sig, &SignerOpts{}) {
+		t.Error("Pre-hashed signature failed verification against the message")
+	}
+
+	if _, err := priv.Sign(rand.Reader, []byte("not a digest"), nil); err == nil {
+		t.Error("Expected error for a digest of the wrong length")
+	}
+
+	// A SHA-256 digest, as crypto/tls would pass, is not e
+	if _, err := priv.Sign(rand.Reader, e, crypto.SHA256); !errors.Is(err, exceptions.ErrInvalidParameter) {
+		t.Errorf("Sign with crypto.SHA256 = %v, want ErrInvalidParameter", err)
+	}
+	if priv.PublicKey.Verify(e, sig, crypto.SHA256) {
+		t.Error("Signature verified as a SHA-256 digest")
+	}
+}
+
+func TestPrivateKeyDecryptGMT0003Example(t *testing.T) {
+	priv, _ := NewPrivateKey(fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8"))
+	ciphertext, _ := hex.DecodeString("04" +
+		"04EBFC718E8D1798620432268E77FEB6415E2EDE0E073C0F4F640ECD2E149A73" +
+		"E858F9D81E5430A57B36DAAB8F950A3C64E6EE6A63094D99283AFF767E124DF0" +
+		"59983C18F809E262923C53AEC295D30383B54E39D609D160AFCB1908D0BD8766" +
+		"21886CA989CA9C7D58087307CA93092D651EFA")
+
+	var decrypter crypto.Decrypter = priv
+	plaintext, err := decrypter.Decrypt(nil, ciphertext, &DecrypterOpts{Mode: Mode_C1C3C2})
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if string(plaintext) != "encryption standard" {
+		t.Errorf("Unexpected plaintext %q", plaintext)
+	}
+
+	if _, err := decrypter.Decrypt(nil, ciphertext, "C1C3C2"); err == nil {
+		t.Error("Expected error for unsupported options")
+	}
+}
+
+func TestPublicKeyEncryptRoundTrip(t *testing.T) {
+	keyPair, _ := GenerateKey(rand.Reader)
+	priv, _ := NewPrivateKey(keyPair.PrivateKey)
+	message := []byte("round trip through crypto.Decrypter")
+
+	for _, mode := range []int{Mode_C1C2C3, Mode_C1C3C2} {
+		ciphertext, err := priv.PublicKey.Encrypt(rand.Reader, message, &EncrypterOpts{Mode: mode})
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		plaintext, err := priv.Decrypt(rand.Reader, ciphertext, &DecrypterOpts{Mode: mode})
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if !bytes.Equal(plaintext, message) {
+			t.Errorf("Mode %d: round trip mismatch", mode)
+		}
+	}
+
+	// nil options use the engine's default layout on both sides
+	ciphertext, _ := priv.PublicKey.Encrypt(rand.Reader, message, nil)
+	if plaintext, err := priv.Decrypt(nil, ciphertext, nil); err != nil || !bytes.Equal(plaintext, message) {
+		t.Errorf("Default mode round trip failed: %v", err)
+	}
+}
+
+func TestNewPrivateKeyValidation(t *testing.T) {
+	n := GetN()
+	for _, d := range []*big.Int{nil, big.NewInt(0), big.NewInt(-1), new(big.Int).Sub(n, big.NewInt(1)), n} {
+		if _, err := NewPrivateKey(d); err == nil {
+			t.Errorf("Expected error for d=%v", d)
+		}
+	}
+
+	priv, err := NewPrivateKey(big.NewInt(12345))
+	if err != nil {
+		t.Fatalf("NewPrivateKey failed: %v", err)
+	}
+	other, _ := NewPrivateKey(big.NewInt(54321))
+	same, _ := NewPrivateKey(big.NewInt(12345))
+
+	if !priv.Equal(same) || priv.Equal(other) {
+		t.Error("PrivateKey.Equal mismatch")
+	}
+	if !priv.PublicKey.Equal(priv.Public()) || priv.PublicKey.Equal(other.Public()) {
+		t.Error("PublicKey.Equal mismatch")
+	}
+
+	if _, err := NewPublicKey(GetCurve().GetInfinity()); err == nil {
+		t.Error("Expected error for the point at infinity")
+	}
+	if _, err := NewPublicKey(priv.Q); err != nil {
+		t.Errorf("NewPublicKey failed: %v", err)
+	}
+}
+
+// TestKeysConcurrentUse signs, verifies, encrypts and decrypts with shared
+// keys from many goroutines; run with -race.
+func TestKeysConcurrentUse(t *testing.T) {
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	priv, err := NewPrivateKey(keyPair.PrivateKey)
+	if err != nil {
+		t.Fatalf("NewPrivateKey failed: %v", err)
+	}
+	pub := &priv.PublicKey
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if GetCurve() == nil || !GetG().IsValid() || GetECDomainParameters().GetN().Cmp(SM2_N) != 0 {
+				errs <- fmt.Errorf("goroutine %d: invalid domain parameters", i)
+				return
+			}
+			for j := 0; j < 4; j++ {
+				msg := []byte(fmt.Sprintf("message %d-%d", i, j))
+				opts := &SignerOpts{UID: []byte(fmt.Sprintf("user%d", i))}
+				sig, err := priv.Sign(nil, msg, opts)
+				if err != nil || !pub.Verify(msg, sig, opts) {
+					errs <- fmt.Errorf("goroutine %d: sign/verify failed: %v", i, err)
+					return
+				}
+				ct, err := pub.Encrypt(nil, msg, &EncrypterOpts{Mode: Mode_C1C3C2})
+				if err != nil {
+					errs <- fmt.Errorf("goroutine %d: Encrypt failed: %v", i, err)
+					return
+				}
+				pt, err := priv.Decrypt(nil, ct, &DecrypterOpts{Mode: Mode_C1C3C2})
+				if err != nil || !bytes.Equal(pt, msg) {
+					errs <- fmt.Errorf("goroutine %d: Decrypt failed: %v", i, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,68 @@
+package sm2
+
+import (
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/internal/sm2core"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+// DefaultUserID is the user ID used for Z when none is given
+// (GM/T 0009-2012).
+var DefaultUserID = []byte("1234567812345678")
+
+// sm2Signature is the ASN.1 form of an SM2 signature (GM/T 0009-2012).
+type sm2Signature struct {
+	R, S *big.Int
+}
+
+// hashMessage returns e = SM3(Z || M).
+func hashMessage(userID []byte, pub *ec.Point, msg []byte) ([]byte, error) {
+	digest := digests.NewSM3Digest()
+	z, err := sm2core.ComputeZ(digest, GetECDomainParameters(), userID, pub)
+	if err != nil {
+		return nil, err
+	}
+	digest.BlockUpdate(z, 0, len(z))
+	digest.BlockUpdate(msg, 0, len(msg))
+	e := make([]byte, digest.GetDigestSize())
+	digest.DoFinal(e, 0)
+	return e, nil
+}
+
+// signDigest computes an SM2 signature (r, s) of the hash e with the private
+// key d, drawing k from random.
+func signDigest(random io.Reader, d *big.Int, e []byte) (*big.Int, *big.Int, error) {
+	n := GetN()
+	r, s, _, err := sm2core.Sign(GetECDomainParameters(), d, e, func() (*big.Int, error) {
+		return randRange(random, n)
+	})
+	return r, s, err
+}
+
+// verifyDigest checks the SM2 signature (r, s) of the hash e.
+func verifyDigest(pub *ec.Point, e []byte, r, s *big.Int) bool {
+	return sm2core.Verify(GetECDomainParameters(), pub, e, r, s)
+}
+
+// marshalSignature encodes (r, s) as a DER SEQUENCE of two INTEGERs.
+func marshalSignature(r, s *big.Int) ([]byte, error) {
+	return asn1.Marshal(sm2Signature{R: r, S: s})
+}
+
+// unmarshalSignature decodes a DER signature, rejecting trailing data.
+func unmarshalSignature(sig []byte) (*big.Int, *big.Int, error) {
+	var v sm2Signature
+	rest, err := asn1.Unmarshal(sig, &v)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) != 0 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidSignature, "trailing data after signature")
+	}
+	return v.R, v.S, nil
+}
@@ -1,13 +1,21 @@
 // Package sm2 implements SM2 elliptic curve cryptography.
+//
+// The curve, its base point and the domain parameters are built once, on
+// first use, and are shared read-only by all goroutines. The package-level
+// functions and the methods of PublicKey and PrivateKey are safe for
+// concurrent use; engines and signers hold per-operation state and are not.
+//
 // Reference: GM/T 0003-2012
 package sm2
 
 import (
 "math/big"
+"github.com/lihongjie0209/sm-go-bc/crypto/params"
 "github.com/lihongjie0209/sm-go-bc/math/ec"
 )
 
-// SM2 curve parameters (SM2P256V1)
+// SM2 curve parameters (SM2P256V1). They are shared and must not be
+// modified; GetN and GetP return copies.
 var (
 // Prime p
 SM2_P = fromHex("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF")
@@ -29,35 +37,33 @@ SM2_Gx = fromHex("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74
 SM2_Gy = fromHex("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0")
 )
 
-var sm2Curve *ec.Curve
-var sm2BasePoint *ec.Point
+// Private copies of n and p, so that a caller modifying the exported values
+// cannot change key validation for other goroutines.
+var (
+sm2N = new(big.Int).Set(SM2_N)
+sm2P = new(big.Int).Set(SM2_P)
+)
 
-// GetCurve returns the SM2 curve.
+// GetCurve returns the SM2 curve, the ec.SM2P256V1 entry of the curve
+// registry. The curve is built once and shared; it must not be modified.
 func GetCurve() *ec.Curve {
-if sm2Curve == nil {
-sm2Curve = ec.NewCurve(SM2_P, SM2_A, SM2_B, SM2_N, SM2_H)
-sm2BasePoint = sm2Curve.CreatePoint(SM2_Gx, SM2_Gy)
-sm2Curve.SetG(sm2BasePoint)
-}
-return sm2Curve
+return ec.GetNamedCurve(ec.SM2P256V1)
 }
 
-// GetG returns the base point G.
+// GetG returns the base point G. The point is shared and must not be
+// modified.
 func GetG() *ec.Point {
-if sm2BasePoint == nil {
-GetCurve() // Initialize if not already done
-}
-return sm2BasePoint
+return GetCurve().GetG()
 }
 
 // GetN returns the order n.
 func GetN() *big.Int {
-return new(big.Int).Set(SM2_N)
+return new(big.Int).Set(sm2N)
 }
 
 // GetP returns the prime p.
 func GetP() *big.Int {
-return new(big.Int).Set(SM2_P)
+return new(big.Int).Set(sm2P)
 }
 
 // ValidatePublicKey validates a public key point.
@@ -66,18 +72,25 @@ if Q.IsInfinity() {
 return false
 }
 
-if !Q.IsValid() {
+// IsValid only checks Q against its own curve
+if !Q.GetCurve().Equals(GetCurve()) || !Q.IsValid() {
 return false
 }
 
+// With cofactor 1 every point on the curve other than O has order n,
+// so [n]Q = O holds and the costly multiplication can be skipped.
+if SM2_H == 1 {
+return true
+}
+
 // Check [n]Q = O
-nQ := Q.Multiply(SM2_N)
+nQ := Q.Multiply(sm2N)
 return nQ.IsInfinity()
 }
 
 // ValidatePrivateKey validates a private key.
 func ValidatePrivateKey(d *big.Int) bool {
-return d.Sign() > 0 && d.Cmp(SM2_N) < 0
+return d.Sign() > 0 && d.Cmp(sm2N) < 0
 }
 
 // fromHex converts a hex string to big.Int.
@@ -109,3 +122,10 @@ N:     GetN(),
 H:     SM2_H,
 }
 }
+
+// GetECDomainParameters returns the SM2 domain parameters in the form taken
+// by params.ECPrivateKeyParameters and params.ECPublicKeyParameters. Each
+// call returns new parameters around the shared curve and base point.
+func GetECDomainParameters() *params.ECDomainParameters {
+return params.NewECDomainParameters(GetCurve(), GetG(), GetN(), big.NewInt(int64(SM2_H)), nil)
+}
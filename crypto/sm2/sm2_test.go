@@ -161,17 +161,17 @@ func TestSM2PointDecoding(t *testing.T) {
 	
 	// Encode and decode uncompressed
 	encoded := g.GetEncoded(false)
-	decoded := curve.DecodePoint(encoded)
+	decoded, err := curve.DecodePoint(encoded)
 	
-	if !decoded.Equals(g) {
+	if err != nil || !decoded.Equals(g) {
 		t.Error("Decoded point doesn't match original (uncompressed)")
 	}
 	
 	// Encode and decode compressed
 	encodedComp := g.GetEncoded(true)
-	decodedComp := curve.DecodePoint(encodedComp)
+	decodedComp, err := curve.DecodePoint(encodedComp)
 	
-	if !decodedComp.Equals(g) {
+	if err != nil || !decodedComp.Equals(g) {
 		t.Error("Decoded point doesn't match original (compressed)")
 	}
 }
@@ -0,0 +1,471 @@
+package sm2
+
+import (
+	"crypto/subtle"
+	"io"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+	"github.com/lihongjie0209/sm-go-bc/util"
+)
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// and writes the ciphertext, in the engine's mode, to dst. The key stream
+// and C3 are computed incrementally, so the plaintext is never held in
+// memory as a whole. Close must be called to write C3; it does not close dst.
+//
+// In Mode_C1C3C2, C3 precedes C2 but is only known once all plaintext has
+// been written, so dst must implement io.WriteSeeker: a placeholder is
+// written after C1 and filled in by Close. Mode_DER is not supported, as
+// its length prefixes are not known in advance.
+//
+// The stream computes the KDF and C3 with copies of the engine's digest,
+// which must therefore implement crypto.Memoable, as SM3Digest does.
+func (e *SM2Engine) NewEncryptWriter(dst io.Writer) (io.WriteCloser, error) {
+	if !e.forEncryption {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "engine not initialized for encryption")
+	}
+
+	w := &encryptWriter{engine: e, dst: dst, hashSize: e.digest.GetDigestSize()}
+	switch e.mode {
+	case Mode_C1C2C3:
+	case Mode_C1C3C2:
+		ws, ok := dst.(io.WriteSeeker)
+		if !ok {
+			return nil, exceptions.New(exceptions.ErrInvalidParameter, "C1C3C2 stream encryption requires an io.WriteSeeker")
+		}
+		w.seeker = ws
+	case Mode_DER:
+		return nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "stream encryption does not support DER mode")
+	default:
+		return nil, exceptions.New(exceptions.ErrInvalidParameter, "unknown ciphertext mode")
+	}
+
+	if err := w.rekey(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewDecryptReader returns a reader that decrypts the ciphertext read from
+// src, which must be positioned at the start of C1 and extend to the end of
+// the ciphertext. C3 is verified in a first pass over src before any
+// plaintext is returned; the plaintext is then decrypted in a second pass,
+// which is checked against C3 again at EOF in case src changed in between.
+// Mode_DER is not supported, and the digest must implement crypto.Memoable.
+func (e *SM2Engine) NewDecryptReader(src io.ReadSeeker) (io.Reader, error) {
+	if e.forEncryption || e.privateKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "engine not initialized for decryption")
+	}
+	if e.mode != Mode_C1C2C3 && e.mode != Mode_C1C3C2 {
+		return nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "stream decryption supports only C1C2C3 and C1C3C2 modes")
+	}
+
+	x2, y2, err := e.readC1(src)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Clear(x2)
+	defer util.Clear(y2)
+
+	hashSize := int64(e.digest.GetDigestSize())
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end-start < hashSize {
+		return nil, exceptions.New(exceptions.ErrInvalidCipherText, "ciphertext too short")
+	}
+
+	c2Start, c3Start := start, end-hashSize
+	if e.mode == Mode_C1C3C2 {
+		c2Start, c3Start = start+hashSize, start
+	}
+	c2Len := end - start - hashSize
+
+	c3 := make([]byte, hashSize)
+	if _, err := src.Seek(c3Start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(src, c3); err != nil {
+		return nil, err
+	}
+
+	// First pass: verify C3 without releasing any plaintext
+	if _, err := src.Seek(c2Start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	check, err := e.newDecryptReader(io.LimitReader(src, c2Len), x2, y2, c3)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, check); err != nil {
+		return nil, err
+	}
+
+	// Second pass: decrypt
+	if _, err := src.Seek(c2Start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return e.newDecryptReader(io.LimitReader(src, c2Len), x2, y2, c3)
+}
+
+// NewUnverifiedDecryptReader returns a reader that decrypts the ciphertext
+// read from src in a single pass. Unlike NewDecryptReader it does not need
+// to seek, but plaintext is released BEFORE C3 has been verified: a
+// tampered ciphertext is only detected when the final Read returns an error
+// in place of io.EOF. Callers must discard everything read if any error
+// occurs. Mode_DER is not supported, and the digest must implement
+// crypto.Memoable.
+func (e *SM2Engine) NewUnverifiedDecryptReader(src io.Reader) (io.Reader, error) {
+	if e.forEncryption || e.privateKey == nil {
+		return nil, exceptions.New(exceptions.ErrInvalidState, "engine not initialized for decryption")
+	}
+
+	x2, y2, err := e.readC1(src)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Clear(x2)
+	defer util.Clear(y2)
+
+	hashSize := e.digest.GetDigestSize()
+	switch e.mode {
+	case Mode_C1C2C3:
+		// C3 is the last hashSize bytes; hold them back from C2
+		h := newHoldbackReader(src, hashSize)
+		r, err := e.newDecryptReader(h, x2, y2, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.trailer = h.trailer
+		return r, nil
+	case Mode_C1C3C2:
+		c3 := make([]byte, hashSize)
+		if _, err := io.ReadFull(src, c3); err != nil {
+			return nil, exceptions.New(exceptions.ErrInvalidCipherText, "ciphertext too short")
+		}
+		return e.newDecryptReader(src, x2, y2, c3)
+	default:
+		return nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "stream decryption supports only C1C2C3 and C1C3C2 modes")
+	}
+}
+
+// readC1 reads C1, whose length follows from its point encoding and the
+// field size of the engine's curve, and returns the coordinates of [d]C1.
+func (e *SM2Engine) readC1(src io.Reader) (x2, y2 []byte, err error) {
+	prefix := make([]byte, 1)
+	if _, err := io.ReadFull(src, prefix); err != nil {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidCipherText, "ciphertext too short")
+	}
+
+	var c1 []byte
+	switch prefix[0] {
+	case 0x04, 0x06, 0x07:
+		c1 = make([]byte, 1+2*e.fieldBytes())
+	case 0x02, 0x03:
+		c1 = make([]byte, 1+e.fieldBytes())
+	default:
+		return nil, nil, exceptions.New(exceptions.ErrInvalidCipherText, "invalid ciphertext format")
+	}
+	c1[0] = prefix[0]
+	if _, err := io.ReadFull(src, c1[1:]); err != nil {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidCipherText, "ciphertext too short")
+	}
+
+	return e.sharedPoint(c1)
+}
+
+// encryptWriter streams C2 = M ⊕ t while hashing x2 || M for C3.
+//
+// GM/T 0003 requires a new k when t is all zero. Until a non-zero key
+// stream byte has been seen nothing is written to dst, and as C2 equals M
+// over an all-zero key stream, the buffered C2 is also the plaintext to
+// replay under a new k should Close find the whole key stream zero.
+type encryptWriter struct {
+	engine *SM2Engine
+	dst    io.Writer
+	seeker io.WriteSeeker // set in C1C3C2 mode
+
+	c1, x2, y2 []byte
+	keyStream  *kdfStream
+	digest     crypto.Digest
+	hashSize   int
+
+	pending []byte // C2 not yet written, while the key stream is all zero
+	started bool   // C1 has been written
+	c3Pos   int64  // offset of the C3 placeholder in C1C3C2 mode
+	err     error
+}
+
+// rekey draws a new k and restarts the key stream and C3 digest.
+func (w *encryptWriter) rekey() error {
+	kdfDigest, err := w.engine.newDigest()
+	if err != nil {
+		return err
+	}
+	digest, err := w.engine.newDigest()
+	if err != nil {
+		return err
+	}
+	c1, x2, y2, err := w.engine.ephemeralKey()
+	if err != nil {
+		return err
+	}
+	w.wipe()
+	w.c1, w.x2, w.y2 = c1, x2, y2
+	w.keyStream = newKDFStream(kdfDigest, concat(x2, y2))
+	w.digest = digest
+	w.digest.BlockUpdate(x2, 0, len(x2))
+	return nil
+}
+
+// Write encrypts p and writes the result to dst.
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if err := w.write(p); err != nil {
+		w.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *encryptWriter) write(p []byte) error {
+	w.digest.BlockUpdate(p, 0, len(p))
+	c2 := make([]byte, len(p))
+	w.keyStream.XORKeyStream(c2, p)
+
+	if !w.started {
+		w.pending = append(w.pending, c2...)
+		if w.keyStream.allZero {
+			return nil
+		}
+		if err := w.start(); err != nil {
+			return err
+		}
+		c2, w.pending = w.pending, nil
+	}
+
+	_, err := w.dst.Write(c2)
+	return err
+}
+
+// start writes C1 and, in C1C3C2 mode, a placeholder for C3.
+func (w *encryptWriter) start() error {
+	w.started = true
+	if w.seeker == nil {
+		_, err := w.dst.Write(w.c1)
+		return err
+	}
+
+	pos, err := w.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	w.c3Pos = pos + int64(len(w.c1))
+	_, err = w.dst.Write(concat(w.c1, make([]byte, w.hashSize)))
+	return err
+}
+
+// Close writes C3 and, for a C1C3C2 destination, leaves it positioned at
+// the end of the ciphertext. Further writes fail.
+func (w *encryptWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.close()
+	if w.err == nil {
+		w.err = exceptions.New(exceptions.ErrInvalidState, "write to closed SM2 encrypt writer")
+		return nil
+	}
+	return w.err
+}
+
+func (w *encryptWriter) close() error {
+	// An empty message is not subject to the all-zero check; otherwise the
+	// whole key stream was zero and the message is encrypted again
+	for !w.started && len(w.pending) > 0 {
+		plaintext := w.pending
+		if err := w.rekey(); err != nil {
+			return err
+		}
+		w.pending = nil
+		err := w.write(plaintext)
+		util.Clear(plaintext)
+		if err != nil {
+			return err
+		}
+	}
+	if !w.started {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+
+	w.digest.BlockUpdate(w.y2, 0, len(w.y2))
+	c3 := make([]byte, w.digest.GetDigestSize())
+	w.digest.DoFinal(c3, 0)
+	w.wipe()
+
+	if w.seeker == nil {
+		_, err := w.dst.Write(c3)
+		return err
+	}
+
+	end, err := w.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := w.seeker.Seek(w.c3Pos, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.seeker.Write(c3); err != nil {
+		return err
+	}
+	_, err = w.seeker.Seek(end, io.SeekStart)
+	return err
+}
+
+// wipe clears the key stream and the coordinates of [k]Pb.
+func (w *encryptWriter) wipe() {
+	if w.keyStream != nil {
+		w.keyStream.destroy()
+	}
+	util.Clear(w.x2)
+	util.Clear(w.y2)
+}
+
+// decryptReader decrypts C2 read from src and checks C3 once src is
+// exhausted, returning an error instead of io.EOF on mismatch.
+type decryptReader struct {
+	src       io.Reader
+	y2        []byte
+	c3        []byte
+	trailer   func() ([]byte, error) // supplies C3 at EOF when c3 is nil
+	keyStream *kdfStream
+	digest    crypto.Digest
+	length    int64
+	err       error
+}
+
+// newDecryptReader creates a reader for C2 from src with copies of the
+// engine's digest.
+func (e *SM2Engine) newDecryptReader(src io.Reader, x2, y2, c3 []byte) (*decryptReader, error) {
+	kdfDigest, err := e.newDigest()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := e.newDigest()
+	if err != nil {
+		return nil, err
+	}
+	digest.BlockUpdate(x2, 0, len(x2))
+	return &decryptReader{
+		src:       src,
+		y2:        concat(y2),
+		c3:        c3,
+		keyStream: newKDFStream(kdfDigest, concat(x2, y2)),
+		digest:    digest,
+	}, nil
+}
+
+// Read decrypts up to len(p) bytes of C2 into p.
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.src.Read(p)
+	r.keyStream.XORKeyStream(p[:n], p[:n])
+	r.digest.BlockUpdate(p, 0, n)
+	r.length += int64(n)
+
+	if err == io.EOF {
+		if verr := r.verify(); verr != nil {
+			err = verr
+		}
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// verify checks the key stream and C3 after the last byte of C2.
+func (r *decryptReader) verify() error {
+	c3 := r.c3
+	if r.trailer != nil {
+		var err error
+		if c3, err = r.trailer(); err != nil {
+			return err
+		}
+	}
+
+	r.digest.BlockUpdate(r.y2, 0, len(r.y2))
+	u := make([]byte, r.digest.GetDigestSize())
+	r.digest.DoFinal(u, 0)
+	util.Clear(r.y2)
+
+	// Both checks are made, and fail with the same error
+	zeroKey := 0
+	if r.length > 0 && r.keyStream.allZero {
+		zeroKey = 1
+	}
+	r.keyStream.destroy()
+	if zeroKey|(1^subtle.ConstantTimeCompare(u, c3)) != 0 {
+		return errDecryption
+	}
+	return nil
+}
+
+// holdbackReader passes through everything read from r except the final
+// size bytes, which trailer returns once r is exhausted.
+type holdbackReader struct {
+	r    io.Reader
+	size int
+	buf  []byte
+	n    int
+	eof  bool
+}
+
+func newHoldbackReader(r io.Reader, size int) *holdbackReader {
+	return &holdbackReader{r: r, size: size, buf: make([]byte, size+4096)}
+}
+
+func (h *holdbackReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if h.n > h.size {
+			n := copy(p, h.buf[:h.n-h.size])
+			h.n = copy(h.buf, h.buf[n:h.n])
+			return n, nil
+		}
+		if h.eof {
+			return 0, io.EOF
+		}
+
+		m, err := h.r.Read(h.buf[h.n:])
+		h.n += m
+		if err == io.EOF {
+			h.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// trailer returns the held-back bytes.
+func (h *holdbackReader) trailer() ([]byte, error) {
+	if h.n < h.size {
+		return nil, exceptions.New(exceptions.ErrInvalidCipherText, "ciphertext too short")
+	}
+	return h.buf[:h.size], nil
+}
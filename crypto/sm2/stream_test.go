@@ -0,0 +1,432 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"github.com/lihongjie0209/sm-go-bc/crypto"
+	"github.com/lihongjie0209/sm-go-bc/crypto/digests"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
+	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
+)
+
+func TestKDFStream(t *testing.T) {
+	z := []byte("test input for KDF")
+	expected := KDF(z, 100)
+
+	// Consume the key stream in chunks that straddle block boundaries
+	ks := newKDFStream(digests.NewSM3Digest(), z)
+	got := make([]byte, 0, len(expected))
+	for _, n := range []int{1, 30, 2, 0, 40, 27} {
+		chunk := make([]byte, n)
+		ks.XORKeyStream(chunk, chunk)
+		got = append(got, chunk...)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Key stream mismatch\nExpected: %X\nGot:      %X", expected, got)
+	}
+	if ks.allZero {
+		t.Error("Key stream reported as all zero")
+	}
+}
+
+// streamEncrypt encrypts plaintext through NewEncryptWriter, a few bytes per
+// Write. C1C3C2 output goes through a temporary file, which can seek.
+func streamEncrypt(t *testing.T, engine *SM2Engine, plaintext []byte) []byte {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), "sm2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, err := engine.NewEncryptWriter(f)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter failed: %v", err)
+	}
+	for off := 0; off < len(plaintext); off += 7 {
+		end := off + 7
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+		if _, err := w.Write(plaintext[off:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := w.Write([]byte{0}); err == nil {
+		t.Error("Expected error writing after Close")
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func TestSM2StreamGMT0003Example(t *testing.T) {
+	d := fromHex("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k, _ := hex.DecodeString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21")
+	publicKey := GetG().Multiply(d)
+	plaintext := []byte("encryption standard")
+
+	for _, tc := range []struct {
+		name     string
+		mode     int
+		expected []byte
+	}{
+		{"C1C3C2", Mode_C1C3C2, concat(exampleC1, exampleC3, exampleC2)},
+		{"C1C2C3", Mode_C1C2C3, concat(exampleC1, exampleC2, exampleC3)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := NewSM2Engine()
+			engine.SetMode(tc.mode)
+			engine.SetRandom(bytes.NewReader(k))
+			if err := engine.Init(true, publicKey, nil); err != nil {
+				t.Fatalf("Failed to init for encryption: %v", err)
+			}
+			ciphertext := streamEncrypt(t, engine, plaintext)
+			if !bytes.Equal(ciphertext, tc.expected) {
+				t.Errorf("Ciphertext mismatch\nExpected: %X\nGot:      %X", tc.expected, ciphertext)
+			}
+
+			engine = NewSM2Engine()
+			engine.SetMode(tc.mode)
+			if err := engine.Init(false, nil, d); err != nil {
+				t.Fatalf("Failed to init for decryption: %v", err)
+			}
+			r, err := engine.NewDecryptReader(bytes.NewReader(tc.expected))
+			if err != nil {
+				t.Fatalf("NewDecryptReader failed: %v", err)
+			}
+			decrypted, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("Decrypted text mismatch: %q", decrypted)
+			}
+		})
+	}
+}
+
+func TestSM2StreamRoundTrip(t *testing.T) {
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	for _, mode := range []int{Mode_C1C2C3, Mode_C1C3C2} {
+		for _, size := range []int{0, 1, 31, 32, 33, 100000} {
+			plaintext := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+
+			encryptor := NewSM2Engine()
+			encryptor.SetMode(mode)
+			if err := encryptor.Init(true, keyPair.PublicKey, nil); err != nil {
+				t.Fatalf("Failed to init for encryption: %v", err)
+			}
+			ciphertext := streamEncrypt(t, encryptor, plaintext)
+
+			decryptor := NewSM2Engine()
+			decryptor.SetMode(mode)
+			if err := decryptor.Init(false, nil, keyPair.PrivateKey); err != nil {
+				t.Fatalf("Failed to init for decryption: %v", err)
+			}
+
+			// The stream format is the one-shot format
+			decrypted, err := decryptor.Decrypt(ciphertext)
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("mode %d, size %d: one-shot decryption failed: %v", mode, size, err)
+			}
+
+			r, err := decryptor.NewDecryptReader(bytes.NewReader(ciphertext))
+			if err != nil {
+				t.Fatalf("mode %d, size %d: NewDecryptReader failed: %v", mode, size, err)
+			}
+			decrypted, err = io.ReadAll(iotest.HalfReader(r))
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("mode %d, size %d: verified stream decryption failed: %v", mode, size, err)
+			}
+
+			r, err = decryptor.NewUnverifiedDecryptReader(iotest.OneByteReader(bytes.NewReader(ciphertext)))
+			if err != nil {
+				t.Fatalf("mode %d, size %d: NewUnverifiedDecryptReader failed: %v", mode, size, err)
+			}
+			decrypted, err = io.ReadAll(r)
+			if err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("mode %d, size %d: unverified stream decryption failed: %v", mode, size, err)
+			}
+		}
+	}
+}
+
+func TestSM2StreamTampered(t *testing.T) {
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	plaintext := bytes.Repeat([]byte("stream"), 100)
+
+	for _, mode := range []int{Mode_C1C2C3, Mode_C1C3C2} {
+		encryptor := NewSM2Engine()
+		encryptor.SetMode(mode)
+		encryptor.Init(true, keyPair.PublicKey, nil)
+		ciphertext, err := encryptor.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+
+		decryptor := NewSM2Engine()
+		decryptor.SetMode(mode)
+		decryptor.Init(false, nil, keyPair.PrivateKey)
+
+		// Flip a byte of C2 and a byte of C3
+		for _, pos := range []int{65 + 40, len(ciphertext) - 1, 65} {
+			tampered := append([]byte{}, ciphertext...)
+			tampered[pos] ^= 0x01
+
+			r, err := decryptor.NewDecryptReader(bytes.NewReader(tampered))
+			if err == nil {
+				t.Errorf("mode %d, pos %d: NewDecryptReader accepted tampered ciphertext", mode, pos)
+				io.ReadAll(r)
+			}
+
+			r, err = decryptor.NewUnverifiedDecryptReader(bytes.NewReader(tampered))
+			if err != nil {
+				t.Fatalf("mode %d, pos %d: NewUnverifiedDecryptReader failed: %v", mode, pos, err)
+			}
+			if _, err := io.ReadAll(r); err == nil {
+				t.Errorf("mode %d, pos %d: unverified reader reached EOF on tampered ciphertext", mode, pos)
+			}
+		}
+
+		// Truncated ciphertext
+		truncated := ciphertext[:65+20]
+		if _, err := decryptor.NewDecryptReader(bytes.NewReader(truncated)); err == nil {
+			t.Errorf("mode %d: NewDecryptReader accepted truncated ciphertext", mode)
+		}
+		r, err := decryptor.NewUnverifiedDecryptReader(bytes.NewReader(truncated))
+		if err == nil {
+			if _, err := io.ReadAll(r); err == nil {
+				t.Errorf("mode %d: unverified reader accepted truncated ciphertext", mode)
+			}
+		}
+	}
+}
+
+func TestSM2StreamUnsupported(t *testing.T) {
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	encryptor := NewSM2Engine()
+	encryptor.Init(true, keyPair.PublicKey, nil)
+	if _, err := encryptor.NewDecryptReader(bytes.NewReader(nil)); err == nil {
+		t.Error("Expected error decrypting with an encryption engine")
+	}
+	encryptor.SetMode(Mode_C1C3C2)
+	if _, err := encryptor.NewEncryptWriter(&bytes.Buffer{}); err == nil {
+		t.Error("Expected error for C1C3C2 without io.WriteSeeker")
+	}
+	encryptor.SetMode(Mode_DER)
+	if _, err := encryptor.NewEncryptWriter(&bytes.Buffer{}); err == nil {
+		t.Error("Expected error for DER mode")
+	}
+
+	decryptor := NewSM2Engine()
+	decryptor.Init(false, nil, keyPair.PrivateKey)
+	if _, err := decryptor.NewEncryptWriter(&bytes.Buffer{}); err == nil {
+		t.Error("Expected error encrypting with a decryption engine")
+	}
+	decryptor.SetMode(Mode_DER)
+	if _, err := decryptor.NewDecryptReader(bytes.NewReader(nil)); err == nil {
+		t.Error("Expected error for DER mode")
+	}
+	if _, err := decryptor.NewUnverifiedDecryptReader(bytes.NewReader(nil)); err == nil {
+		t.Error("Expected error for DER mode")
+	}
+}
+
+// TestSM2StreamHybridC1 decrypts ciphertexts whose C1 uses the hybrid
+// encoding, which the one-shot Decrypt accepts too.
+func TestSM2StreamHybridC1(t *testing.T) {
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	plaintext := []byte("hybrid C1")
+
+	for _, mode := range []int{Mode_C1C2C3, Mode_C1C3C2} {
+		engine := NewSM2Engine()
+		engine.SetMode(mode)
+		engine.Init(true, keyPair.PublicKey, nil)
+		ciphertext, err := engine.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		// 0x06 for even y, 0x07 for odd y
+		ciphertext[0] = 0x06 | ciphertext[64]&1
+
+		engine.Init(false, nil, keyPair.PrivateKey)
+		if decrypted, err := engine.Decrypt(ciphertext); err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("mode %d: one-shot decryption failed: %v", mode, err)
+		}
+		r, err := engine.NewDecryptReader(bytes.NewReader(ciphertext))
+		if err != nil {
+			t.Fatalf("mode %d: NewDecryptReader failed: %v", mode, err)
+		}
+		if decrypted, err := io.ReadAll(r); err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("mode %d: verified stream decryption failed: %v", mode, err)
+		}
+		r, err = engine.NewUnverifiedDecryptReader(bytes.NewReader(ciphertext))
+		if err != nil {
+			t.Fatalf("mode %d: NewUnverifiedDecryptReader failed: %v", mode, err)
+		}
+		if decrypted, err := io.ReadAll(r); err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("mode %d: unverified stream decryption failed: %v", mode, err)
+		}
+	}
+}
+
+// sha512Digest adapts crypto/sha512 to crypto.Digest and crypto.Memoable,
+// giving the engine a C3 of another length than SM3's.
+type sha512Digest struct {
+	h hash.Hash
+}
+
+func newSHA512Digest() crypto.Digest {
+	return &sha512Digest{h: sha512.New()}
+}
+
+func (d *sha512Digest) GetAlgorithmName() string { return "SHA-512" }
+func (d *sha512Digest) GetDigestSize() int       { return sha512.Size }
+func (d *sha512Digest) Update(in byte)           { d.h.Write([]byte{in}) }
+func (d *sha512Digest) BlockUpdate(in []byte, inOff int, len int) {
+	d.h.Write(in[inOff : inOff+len])
+}
+func (d *sha512Digest) DoFinal(out []byte, outOff int) int {
+	copy(out[outOff:], d.h.Sum(nil))
+	d.h.Reset()
+	return sha512.Size
+}
+func (d *sha512Digest) Reset() { d.h.Reset() }
+
+func (d *sha512Digest) Copy() crypto.Memoable {
+	c := &sha512Digest{h: sha512.New()}
+	c.ResetMemoable(d)
+	return c
+}
+
+func (d *sha512Digest) ResetMemoable(other crypto.Memoable) {
+	state, _ := other.(*sha512Digest).h.(encoding.BinaryMarshaler).MarshalBinary()
+	d.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+}
+
+// TestSM2StreamEngineSettings streams with engines set up with
+// InitWithDomain and SetDigest, whose output must match Encrypt and
+// Decrypt of the same engine.
+func TestSM2StreamEngineSettings(t *testing.T) {
+	testDomain, err := params.NewECDomainParametersByName(ec.SM2TestFp256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := bytes.Repeat([]byte("engine settings"), 10)
+
+	for _, tc := range []struct {
+		name   string
+		domain *params.ECDomainParameters
+		digest crypto.Digest
+	}{
+		{"test curve", testDomain, nil},
+		{"SHA-512", GetECDomainParameters(), newSHA512Digest()},
+		{"test curve SHA-512", testDomain, newSHA512Digest()},
+	} {
+		d, err := randRange(rand.Reader, tc.domain.GetN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []int{Mode_C1C2C3, Mode_C1C3C2} {
+			encryptor := NewSM2Engine()
+			encryptor.SetMode(mode)
+			encryptor.SetDigest(tc.digest)
+			if err := encryptor.InitWithDomain(true, tc.domain, tc.domain.GetG().Multiply(d), nil); err != nil {
+				t.Fatalf("%s: failed to init for encryption: %v", tc.name, err)
+			}
+			decryptor := NewSM2Engine()
+			decryptor.SetMode(mode)
+			decryptor.SetDigest(tc.digest)
+			if err := decryptor.InitWithDomain(false, tc.domain, nil, d); err != nil {
+				t.Fatalf("%s: failed to init for decryption: %v", tc.name, err)
+			}
+
+			ciphertext := streamEncrypt(t, encryptor, plaintext)
+			if expected := 65 + len(plaintext) + encryptor.digest.GetDigestSize(); len(ciphertext) != expected {
+				t.Errorf("%s, mode %d: ciphertext length %d, want %d", tc.name, mode, len(ciphertext), expected)
+			}
+			if decrypted, err := decryptor.Decrypt(ciphertext); err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("%s, mode %d: one-shot decryption of stream output failed: %v", tc.name, mode, err)
+			}
+
+			ciphertext, err = encryptor.Encrypt(plaintext)
+			if err != nil {
+				t.Fatalf("%s, mode %d: encryption failed: %v", tc.name, mode, err)
+			}
+			r, err := decryptor.NewDecryptReader(bytes.NewReader(ciphertext))
+			if err != nil {
+				t.Fatalf("%s, mode %d: NewDecryptReader failed: %v", tc.name, mode, err)
+			}
+			if decrypted, err := io.ReadAll(r); err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("%s, mode %d: verified stream decryption failed: %v", tc.name, mode, err)
+			}
+			r, err = decryptor.NewUnverifiedDecryptReader(iotest.OneByteReader(bytes.NewReader(ciphertext)))
+			if err != nil {
+				t.Fatalf("%s, mode %d: NewUnverifiedDecryptReader failed: %v", tc.name, mode, err)
+			}
+			if decrypted, err := io.ReadAll(r); err != nil || !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("%s, mode %d: unverified stream decryption failed: %v", tc.name, mode, err)
+			}
+		}
+	}
+
+	// A digest that cannot be copied is rejected by the streams only
+	keyPair, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	opaque := struct{ crypto.Digest }{digests.NewSM3Digest()}
+	engine := NewSM2Engine()
+	engine.SetDigest(opaque)
+	engine.Init(true, keyPair.PublicKey, nil)
+	if _, err := engine.NewEncryptWriter(&bytes.Buffer{}); !errors.Is(err, exceptions.ErrUnsupportedAlgorithm) {
+		t.Errorf("NewEncryptWriter with an opaque digest: got %v, want ErrUnsupportedAlgorithm", err)
+	}
+	ciphertext, err := engine.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encryption with an opaque digest failed: %v", err)
+	}
+	engine.Init(false, nil, keyPair.PrivateKey)
+	if _, err := engine.NewDecryptReader(bytes.NewReader(ciphertext)); !errors.Is(err, exceptions.ErrUnsupportedAlgorithm) {
+		t.Errorf("NewDecryptReader with an opaque digest: got %v, want ErrUnsupportedAlgorithm", err)
+	}
+	if _, err := engine.NewUnverifiedDecryptReader(bytes.NewReader(ciphertext)); !errors.Is(err, exceptions.ErrUnsupportedAlgorithm) {
+		t.Errorf("NewUnverifiedDecryptReader with an opaque digest: got %v, want ErrUnsupportedAlgorithm", err)
+	}
+}
@@ -37,18 +37,20 @@
 // # SM2 Digital Signature Example
 //
 //	import (
+//	    "github.com/lihongjie0209/sm-go-bc/crypto/params"
 //	    "github.com/lihongjie0209/sm-go-bc/crypto/signers"
-//	    "github.com/lihongjie0209/sm-go-bc/math/ec"
+//	    "github.com/lihongjie0209/sm-go-bc/crypto/sm2"
 //	)
 //
 //	// Generate key pair
-//	curve := ec.SM2P256V1()
-//	// ... key generation logic
+//	keyPair, err := sm2.GenerateKey(nil)
+//	d := keyPair.PrivateKey
 //
-//	// Sign and verify
+//	// Sign
 //	signer := signers.NewSM2Signer()
-//	signer.Init(true, privParams)
-//	signature := signer.GenerateSignature(message)
+//	signer.Init(true, params.NewECPrivateKeyParameters(d, sm2.GetECDomainParameters()))
+//	signer.BlockUpdate(message, 0, len(message))
+//	signature, err := signer.GenerateSignature()
 //
 // For more examples, see the examples/ directory in the repository.
 package smgobc
@@ -7,7 +7,6 @@ import (
 	"encoding/hex"
 	"encoding/pem"
 	"fmt"
-	"io"
 	"math/big"
 	
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
@@ -179,25 +178,8 @@ func demonstratePEMEncoding() {
 
 // Helper functions
 func generateKeyPair() (*big.Int, *ec.Point) {
-	n := sm2.GetN()
-	d, _ := randFieldElement(rand.Reader, n)
-	G := sm2.GetG()
-	Q := G.Multiply(d)
-	return d, Q
-}
-
-func randFieldElement(random io.Reader, n *big.Int) (*big.Int, error) {
-	b := make([]byte, (n.BitLen()+7)/8)
-	for {
-		_, err := io.ReadFull(random, b)
-		if err != nil {
-			return nil, err
-		}
-		k := new(big.Int).SetBytes(b)
-		if k.Sign() > 0 && k.Cmp(n) < 0 {
-			return k, nil
-		}
-	}
+	keyPair, _ := sm2.GenerateKey(rand.Reader)
+	return keyPair.PrivateKey, keyPair.PublicKey
 }
 
 func min(a, b int) int {
@@ -20,27 +20,52 @@ type Curve struct {
 	order    *big.Int     // Order of base point (private)
 	cofactor int          // Cofactor (private)
 	g        *Point       // Base point (private)
+
+	field       fieldArith                  // Fixed-width field arithmetic for Jacobian formulas
+	aMont       []uint64                    // Coefficient a in the field's internal form
+	newElement  func(*big.Int) FieldElement // Field element constructor
+	scalarField *ScalarField                // Arithmetic modulo the order
+	baseTable   *fixedBaseTable             // Precomputed multiples of G
 }
 
 // NewCurve creates a new elliptic curve.
+// Curves over the sm2p256v1 prime automatically use the dedicated
+// SM2P256V1FieldElement arithmetic; all other curves use the generic Fp.
 func NewCurve(p, a, b, order *big.Int, cofactor int) *Curve {
-	aField := NewFp(p, a)
-	bField := NewFp(p, b)
 	pCopy := new(big.Int).Set(p)
 	orderCopy := new(big.Int).Set(order)
-	
-	return &Curve{
+
+	c := &Curve{
 		P:        pCopy,
-		A:        aField,
-		B:        bField,
 		N:        orderCopy,
 		H:        cofactor,
 		p:        pCopy,
-		a:        aField,
-		b:        bField,
 		order:    orderCopy,
 		cofactor: cofactor,
 	}
+
+	if pCopy.Cmp(sm2P256V1P) == 0 {
+		c.field = sm2P256V1Field
+		c.newElement = func(x *big.Int) FieldElement {
+			return NewSM2P256V1FieldElement(x)
+		}
+	} else {
+		c.field = newMontField(pCopy)
+		c.newElement = func(x *big.Int) FieldElement {
+			return NewFp(pCopy, x)
+		}
+	}
+	if orderCopy.Bit(0) == 1 {
+		c.scalarField = newScalarField(orderCopy)
+	}
+
+	c.a = c.newElement(a)
+	c.b = c.newElement(b)
+	c.A = c.a
+	c.B = c.b
+	c.aMont = c.field.fromBig(a)
+
+	return c
 }
 
 // GetP returns the field modulus.
@@ -73,10 +98,12 @@ func (c *Curve) GetG() *Point {
 	return c.g
 }
 
-// SetG sets the base point.
+// SetG sets the base point. Multiples of G are then computed from a
+// precomputed table, which is built on first use.
 func (c *Curve) SetG(g *Point) {
 	c.G = g
 	c.g = g
+	c.baseTable = newFixedBaseTable(g)
 }
 
 // GetFieldSize returns the bit length of the field.
@@ -91,9 +118,7 @@ func (c *Curve) FieldSize() int {
 
 // CreatePoint creates a point on the curve from big.Int coordinates.
 func (c *Curve) CreatePoint(x, y *big.Int) *Point {
-	xField := NewFp(c.p, x)
-	yField := NewFp(c.p, y)
-	return NewPoint(c, xField, yField)
+	return NewPoint(c, c.newElement(x), c.newElement(y))
 }
 
 // GetInfinity returns the point at infinity for this curve.
@@ -114,11 +139,34 @@ func (c *Curve) ValidatePoint(p *Point) bool {
 
 // FromBigInteger creates a field element from big.Int.
 func (c *Curve) FromBigInteger(x *big.Int) FieldElement {
-	return NewFp(c.p, x)
+	return c.newElement(x)
+}
+
+// GetScalarField returns constant-time arithmetic modulo the order n.
+// It returns nil if the order is even.
+func (c *Curve) GetScalarField() *ScalarField {
+	return c.scalarField
+}
+
+// CheckPoint checks that p is a finite point of order n on the curve, as
+// required of public keys and other points received from a peer. The error
+// wraps ErrPointAtInfinity, ErrPointNotOnCurve or ErrPointNotInSubgroup.
+func (c *Curve) CheckPoint(p *Point) error {
+	if p == nil || p.isInfinity {
+		return ErrPointAtInfinity
+	}
+	if !c.Equals(p.curve) || !p.IsValid() {
+		return ErrPointNotOnCurve
+	}
+	// With cofactor 1 every point on the curve other than O has order n
+	if c.cofactor != 1 && !p.Multiply(c.order).IsInfinity() {
+		return ErrPointNotInSubgroup
+	}
+	return nil
 }
 
-// DecodePoint decodes a point from bytes.
-func (c *Curve) DecodePoint(encoded []byte) *Point {
+// DecodePoint decodes and validates a point; see DecodePoint.
+func (c *Curve) DecodePoint(encoded []byte) (*Point, error) {
 	return DecodePoint(c, encoded)
 }
 
@@ -133,18 +181,20 @@ func (c *Curve) Equals(other *Curve) bool {
 }
 
 // ScalarBaseMult multiplies the base point G by a scalar k.
+// The scalar is treated as secret; the precomputed table for G is used.
 func (c *Curve) ScalarBaseMult(k []byte) *Point {
 	if c.G == nil {
 		panic("base point G is not set")
 	}
 	kInt := new(big.Int).SetBytes(k)
-	return c.G.Multiply(kInt)
+	return c.G.MultiplySecret(kInt)
 }
 
 // ScalarMult multiplies a point P by a scalar k.
+// The scalar is treated as secret (see Point.MultiplySecret).
 func (c *Curve) ScalarMult(p *Point, k []byte) *Point {
 	kInt := new(big.Int).SetBytes(k)
-	return p.Multiply(kInt)
+	return p.MultiplySecret(kInt)
 }
 
 // Add adds two points on the curve.
@@ -0,0 +1,140 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+)
+
+// wnafWidth is the window width used for variable-time multi-scalar
+// multiplication; each point gets 2^(w-2) = 8 precomputed odd multiples.
+const wnafWidth = 5
+
+// SumOfTwoMultiplies computes [a]P + [b]Q with a single shared chain of
+// doublings (Shamir's trick). It runs in variable time and must only be used
+// with public scalars, as in signature verification.
+// Reference: org.bouncycastle.math.ec.ECAlgorithms.sumOfTwoMultiplies
+func SumOfTwoMultiplies(p *Point, a *big.Int, q *Point, b *big.Int) *Point {
+	return SumOfMultiplies([]*Point{p, q}, []*big.Int{a, b})
+}
+
+// SumOfMultiplies computes [k0]P0 + [k1]P1 + ... using interleaved wNAF
+// (Straus' method). Terms on the curve's base point are taken from its
+// precomputed table instead. It runs in variable time and must only be used
+// with public scalars.
+// Reference: org.bouncycastle.math.ec.ECAlgorithms.sumOfMultiplies
+func SumOfMultiplies(ps []*Point, ks []*big.Int) *Point {
+	c := checkMultiplies(ps, ks)
+
+	// Collect base point terms into a single scalar, drop trivial terms and
+	// make the remaining scalars positive
+	var baseK *big.Int
+	var points []*Point
+	var scalars []*big.Int
+	for i, p := range ps {
+		k := ks[i]
+		if p.isInfinity || k.Sign() == 0 {
+			continue
+		}
+		if c.isBasePoint(p) {
+			if baseK == nil {
+				baseK = new(big.Int)
+			}
+			baseK.Add(baseK, k)
+			continue
+		}
+		if k.Sign() < 0 {
+			p, k = p.Negate(), new(big.Int).Neg(k)
+		}
+		points = append(points, p)
+		scalars = append(scalars, k)
+	}
+
+	acc := c.interleavedWNAF(points, scalars)
+	if baseK != nil {
+		acc = c.addBaseVartime(acc, baseK)
+	}
+	return c.toAffine(acc)
+}
+
+// SumOfTwoMultipliesSecret computes [a]P + [b]Q in constant time, sharing
+// the doublings between both terms. Use it when a or b is secret, as in SM2
+// key exchange.
+func SumOfTwoMultipliesSecret(p *Point, a *big.Int, q *Point, b *big.Int) *Point {
+	c := checkMultiplies([]*Point{p, q}, []*big.Int{a, b})
+	return c.toAffine(c.sumOfMultipliesSecret([]*Point{p, q}, []*big.Int{a, b}))
+}
+
+// checkMultiplies validates the arguments of a multi-scalar multiplication
+// and returns the common curve.
+func checkMultiplies(ps []*Point, ks []*big.Int) *Curve {
+	if len(ps) == 0 || len(ps) != len(ks) {
+		panic("ec: point and scalar counts must be equal and non-zero")
+	}
+	c := ps[0].curve
+	for _, p := range ps[1:] {
+		if p.curve != c && !p.curve.Equals(c) {
+			panic("ec: points must be on the same curve")
+		}
+	}
+	return c
+}
+
+// interleavedWNAF computes Σ[ks[i]]ps[i] for positive scalars, adding the
+// signed odd multiples selected by each wNAF digit into one accumulator.
+func (c *Curve) interleavedWNAF(ps []*Point, ks []*big.Int) *jacobianPoint {
+	const tableSize = 1 << (wnafWidth - 2)
+
+	tables := make([][tableSize]*jacobianPoint, len(ps))
+	nafs := make([][]int8, len(ps))
+	length := 0
+	for i, p := range ps {
+		// tables[i][j] = [2j+1]P
+		table := &tables[i]
+		table[0] = c.toJacobian(p)
+		twice := c.jacobianDouble(table[0])
+		for j := 1; j < tableSize; j++ {
+			table[j] = c.jacobianAddVartime(table[j-1], twice)
+		}
+		nafs[i] = wnaf(ks[i], wnafWidth)
+		length = max(length, len(nafs[i]))
+	}
+
+	acc := c.newJacobianInfinity()
+	for bit := length - 1; bit >= 0; bit-- {
+		acc = c.jacobianDouble(acc)
+		for i, naf := range nafs {
+			if bit >= len(naf) {
+				continue
+			}
+			if d := naf[bit]; d > 0 {
+				acc = c.jacobianAddVartime(acc, tables[i][d/2])
+			} else if d < 0 {
+				acc = c.jacobianAddVartime(acc, c.jacobianNegate(tables[i][-d/2]))
+			}
+		}
+	}
+	return acc
+}
+
+// wnaf returns the width-w non-adjacent form of k > 0, least significant
+// digit first. Every non-zero digit is odd and lies in (-2^(w-1), 2^(w-1)),
+// and any w consecutive digits contain at most one non-zero digit.
+func wnaf(k *big.Int, w uint) []int8 {
+	d := new(big.Int).Set(k)
+	window := int64(1) << w
+	naf := make([]int8, 0, d.BitLen()+1)
+
+	for d.Sign() > 0 {
+		var digit int64
+		if d.Bit(0) == 1 {
+			digit = int64(uint64(d.Bits()[0]) & uint64(window-1))
+			if digit >= window/2 {
+				digit -= window
+			}
+			d.Sub(d, big.NewInt(digit))
+		}
+		naf = append(naf, int8(digit))
+		d.Rsh(d, 1)
+	}
+	return naf
+}
@@ -0,0 +1,143 @@
+package ec
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestSumOfTwoMultiplies(t *testing.T) {
+	for name, curve := range map[string]*Curve{
+		"sm2p256v1": newTestSM2Curve(),
+		"fp256test": newTestFp256Curve(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := curve.GetG()
+			n := curve.GetOrder()
+
+			for i := 0; i < 10; i++ {
+				a, _ := rand.Int(rand.Reader, n)
+				b, _ := rand.Int(rand.Reader, n)
+				d, _ := rand.Int(rand.Reader, n)
+				q := g.Multiply(d)
+
+				expected := g.Multiply(a).Add(q.Multiply(b))
+				if !SumOfTwoMultiplies(g, a, q, b).Equals(expected) {
+					t.Fatalf("SumOfTwoMultiplies mismatch for a=%x b=%x", a, b)
+				}
+				if !SumOfTwoMultipliesSecret(g, a, q, b).Equals(expected) {
+					t.Fatalf("SumOfTwoMultipliesSecret mismatch for a=%x b=%x", a, b)
+				}
+
+				// Neither term on the base point
+				r := q.Twice()
+				expected = q.Multiply(a).Add(r.Multiply(b))
+				if !SumOfTwoMultiplies(q, a, r, b).Equals(expected) {
+					t.Fatalf("SumOfTwoMultiplies mismatch without G for a=%x b=%x", a, b)
+				}
+				if !SumOfTwoMultipliesSecret(q, a, r, b).Equals(expected) {
+					t.Fatalf("SumOfTwoMultipliesSecret mismatch without G for a=%x b=%x", a, b)
+				}
+			}
+		})
+	}
+}
+
+func TestSumOfMultipliesEdgeCases(t *testing.T) {
+	curve := newTestSM2Curve()
+	g := curve.GetG()
+	q := g.Multiply(big.NewInt(1234567))
+	k := big.NewInt(98765)
+
+	// Terms that cancel out
+	if !SumOfTwoMultiplies(q, k, q, new(big.Int).Neg(k)).IsInfinity() {
+		t.Error("[k]Q + [-k]Q should be infinity")
+	}
+	if !SumOfTwoMultipliesSecret(q, k, q, new(big.Int).Neg(k)).IsInfinity() {
+		t.Error("Secret [k]Q + [-k]Q should be infinity")
+	}
+	if !SumOfTwoMultiplies(g, k, g, new(big.Int).Neg(k)).IsInfinity() {
+		t.Error("[k]G + [-k]G should be infinity")
+	}
+
+	// Equal intermediate points force the doubling case of addition
+	expected := q.Multiply(big.NewInt(2 * 98765))
+	if !SumOfTwoMultiplies(q, k, q, k).Equals(expected) {
+		t.Error("[k]Q + [k]Q != [2k]Q")
+	}
+	if !SumOfTwoMultipliesSecret(q, k, q, k).Equals(expected) {
+		t.Error("Secret [k]Q + [k]Q != [2k]Q")
+	}
+
+	// Infinity and zero scalars
+	inf := curve.GetInfinity()
+	if !SumOfTwoMultiplies(inf, k, q, k).Equals(q.Multiply(k)) {
+		t.Error("Infinity term not ignored")
+	}
+	if !SumOfTwoMultiplies(g, big.NewInt(0), q, k).Equals(q.Multiply(k)) {
+		t.Error("Zero scalar term not ignored")
+	}
+	if !SumOfTwoMultiplies(g, big.NewInt(0), q, big.NewInt(0)).IsInfinity() {
+		t.Error("All-zero scalars should give infinity")
+	}
+
+	// Several points, repeated base point terms and scalars above n
+	n := curve.GetOrder()
+	ps := []*Point{g, q, q.Twice(), g}
+	ks := []*big.Int{big.NewInt(5), new(big.Int).Add(n, big.NewInt(7)), big.NewInt(-3), big.NewInt(11)}
+	expected = g.Multiply(big.NewInt(16)).Add(q.Multiply(big.NewInt(7))).Add(q.Twice().Multiply(big.NewInt(-3)))
+	if !SumOfMultiplies(ps, ks).Equals(expected) {
+		t.Error("SumOfMultiplies mismatch for mixed terms")
+	}
+}
+
+func TestSumOfMultipliesPanics(t *testing.T) {
+	curve := newTestSM2Curve()
+	other := newTestFp256Curve()
+
+	for name, f := range map[string]func(){
+		"length mismatch": func() { SumOfMultiplies([]*Point{curve.GetG()}, nil) },
+		"empty":           func() { SumOfMultiplies(nil, nil) },
+		"curve mismatch": func() {
+			SumOfTwoMultiplies(curve.GetG(), big.NewInt(1), other.GetG(), big.NewInt(1))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic")
+				}
+			}()
+			f()
+		})
+	}
+}
+
+func TestWNAF(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		k, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 256))
+		naf := wnaf(k, wnafWidth)
+
+		sum := new(big.Int)
+		lastNonZero := -wnafWidth
+		for j := len(naf) - 1; j >= 0; j-- {
+			sum.Lsh(sum, 1)
+			sum.Add(sum, big.NewInt(int64(naf[j])))
+		}
+		for j, d := range naf {
+			if d == 0 {
+				continue
+			}
+			if d%2 == 0 || d >= 1<<(wnafWidth-1) || d <= -(1<<(wnafWidth-1)) {
+				t.Fatalf("Invalid digit %d", d)
+			}
+			if j-lastNonZero < wnafWidth {
+				t.Fatalf("Non-zero digits too close at position %d", j)
+			}
+			lastNonZero = j
+		}
+		if sum.Cmp(k) != 0 {
+			t.Fatalf("wNAF of %x does not sum back", k)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ec
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+)
+
+// ellipticCurve adapts a Curve to crypto/elliptic.Curve.
+type ellipticCurve struct {
+	curve  *Curve
+	params *elliptic.CurveParams
+}
+
+// NewEllipticCurve returns c as a crypto/elliptic.Curve named name, so that
+// code written against crypto/elliptic (point marshalling, ECDH helpers)
+// can use it. The base point of c must be set.
+//
+// As in crypto/elliptic, the point at infinity is represented as (0, 0) and
+// the methods panic if called with a point that is not on the curve. The
+// arithmetic is done by c; the methods of the returned CurveParams assume
+// a = -3 and must not be used directly.
+func NewEllipticCurve(c *Curve, name string) elliptic.Curve {
+	if c.GetG() == nil {
+		panic("base point G is not set")
+	}
+	g := c.GetG()
+	return &ellipticCurve{
+		curve: c,
+		params: &elliptic.CurveParams{
+			P:       c.GetP(),
+			N:       c.GetOrder(),
+			B:       c.GetB().ToBigInt(),
+			Gx:      new(big.Int).Set(g.X),
+			Gy:      new(big.Int).Set(g.Y),
+			BitSize: c.GetFieldSize(),
+			Name:    name,
+		},
+	}
+}
+
+// PointToXY returns the affine coordinates of p, or (0, 0) for the point at
+// infinity. The returned values are copies.
+func PointToXY(p *Point) (x, y *big.Int) {
+	if p.IsInfinity() {
+		return new(big.Int), new(big.Int)
+	}
+	return new(big.Int).Set(p.X), new(big.Int).Set(p.Y)
+}
+
+// PointFromXY returns the point (x, y) of curve, or the point at infinity
+// for (0, 0). Other points are checked with Curve.CheckPoint; coordinates
+// out of range are reported as ErrPointNotOnCurve.
+func PointFromXY(curve *Curve, x, y *big.Int) (*Point, error) {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return curve.GetInfinity(), nil
+	}
+	if x.Sign() < 0 || x.Cmp(curve.p) >= 0 || y.Sign() < 0 || y.Cmp(curve.p) >= 0 {
+		return nil, fmt.Errorf("%w: coordinate out of range", ErrPointNotOnCurve)
+	}
+	point := curve.CreatePoint(x, y)
+	if err := curve.CheckPoint(point); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// Params returns the parameters of the curve.
+func (e *ellipticCurve) Params() *elliptic.CurveParams {
+	return e.params
+}
+
+// IsOnCurve reports whether (x, y) is a point of the curve. The point at
+// infinity (0, 0) is not.
+func (e *ellipticCurve) IsOnCurve(x, y *big.Int) bool {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return false
+	}
+	_, err := PointFromXY(e.curve, x, y)
+	return err == nil
+}
+
+// Add returns the sum of (x1, y1) and (x2, y2).
+func (e *ellipticCurve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	return PointToXY(e.point(x1, y1, "Add").Add(e.point(x2, y2, "Add")))
+}
+
+// Double returns 2 * (x1, y1).
+func (e *ellipticCurve) Double(x1, y1 *big.Int) (x, y *big.Int) {
+	return PointToXY(e.point(x1, y1, "Double").Twice())
+}
+
+// ScalarMult returns k * (x1, y1), where k is a big-endian integer. k is
+// treated as secret (see Point.MultiplySecret).
+func (e *ellipticCurve) ScalarMult(x1, y1 *big.Int, k []byte) (x, y *big.Int) {
+	p := e.point(x1, y1, "ScalarMult")
+	if p.IsInfinity() {
+		return PointToXY(p)
+	}
+	return PointToXY(p.MultiplySecret(e.scalar(k)))
+}
+
+// ScalarBaseMult returns k * G, where G is the base point of the curve and
+// k is a big-endian integer.
+func (e *ellipticCurve) ScalarBaseMult(k []byte) (x, y *big.Int) {
+	return PointToXY(e.curve.GetG().MultiplySecret(e.scalar(k)))
+}
+
+// point converts (x, y) to a Point, panicking like crypto/elliptic if it is
+// not on the curve.
+func (e *ellipticCurve) point(x, y *big.Int, method string) *Point {
+	p, err := PointFromXY(e.curve, x, y)
+	if err != nil {
+		panic("ec: " + method + " was called on an invalid point")
+	}
+	return p
+}
+
+// scalar reduces the big-endian integer k modulo the order.
+func (e *ellipticCurve) scalar(k []byte) *big.Int {
+	return new(big.Int).Mod(new(big.Int).SetBytes(k), e.curve.GetOrder())
+}
+
+var _ elliptic.Curve = (*ellipticCurve)(nil)
@@ -0,0 +1,101 @@
+package ec
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestPointXYConversion(t *testing.T) {
+	curve := newTestSM2Curve()
+	p := curve.GetG().Multiply(big.NewInt(12345))
+
+	x, y := PointToXY(p)
+	q, err := PointFromXY(curve, x, y)
+	if err != nil {
+		t.Fatalf("PointFromXY failed: %v", err)
+	}
+	if !q.Equals(p) {
+		t.Error("Round trip changed the point")
+	}
+	x.SetInt64(1)
+	if !q.Equals(p) || p.X.Cmp(x) == 0 {
+		t.Error("PointToXY should return copies")
+	}
+
+	// (0, 0) is the point at infinity
+	x, y = PointToXY(curve.GetInfinity())
+	if x.Sign() != 0 || y.Sign() != 0 {
+		t.Errorf("Infinity converted to (%v, %v)", x, y)
+	}
+	inf, err := PointFromXY(curve, x, y)
+	if err != nil || !inf.IsInfinity() {
+		t.Errorf("(0, 0) not converted to infinity: %v", err)
+	}
+
+	px, py := PointToXY(p)
+	if _, err := PointFromXY(curve, px, new(big.Int).Add(py, big.NewInt(1))); err == nil {
+		t.Error("Expected error for a point off the curve")
+	}
+	if _, err := PointFromXY(curve, new(big.Int).Add(px, curve.GetP()), py); err == nil {
+		t.Error("Expected error for an unreduced coordinate")
+	}
+	if _, err := PointFromXY(curve, new(big.Int).Neg(px), py); err == nil {
+		t.Error("Expected error for a negative coordinate")
+	}
+}
+
+func TestEllipticCurve(t *testing.T) {
+	// The adapter works on any curve, including ones where a != -3 as a
+	// CurveParams curve would assume
+	curve := GetNamedCurve(SM2TestFp256)
+	e := NewEllipticCurve(curve, SM2TestFp256)
+
+	if e.Params().Name != SM2TestFp256 || e.Params().BitSize != 256 || e.Params().N.Cmp(curve.GetOrder()) != 0 {
+		t.Errorf("Unexpected parameters %+v", e.Params())
+	}
+	gx, gy := e.Params().Gx, e.Params().Gy
+	if !e.IsOnCurve(gx, gy) {
+		t.Fatal("Base point is not on the curve")
+	}
+	if e.IsOnCurve(new(big.Int), new(big.Int)) {
+		t.Error("Infinity should not be on the curve")
+	}
+
+	k := big.NewInt(7)
+	x, y := e.ScalarBaseMult(k.Bytes())
+	want := curve.GetG().Multiply(k)
+	if x.Cmp(want.X) != 0 || y.Cmp(want.Y) != 0 {
+		t.Error("ScalarBaseMult mismatch")
+	}
+	x2, y2 := e.ScalarMult(gx, gy, k.Bytes())
+	if x2.Cmp(x) != 0 || y2.Cmp(y) != 0 {
+		t.Error("ScalarMult mismatch")
+	}
+
+	// 7G = 2G + 5G
+	dx, dy := e.Double(gx, gy)
+	fx, fy := e.ScalarBaseMult([]byte{5})
+	sx, sy := e.Add(dx, dy, fx, fy)
+	if sx.Cmp(x) != 0 || sy.Cmp(y) != 0 {
+		t.Error("Add/Double mismatch")
+	}
+
+	// [n]G and G + (-G) are infinity
+	if x, y := e.ScalarBaseMult(curve.GetOrder().Bytes()); x.Sign() != 0 || y.Sign() != 0 {
+		t.Error("[n]G should be (0, 0)")
+	}
+	negY := new(big.Int).Sub(curve.GetP(), gy)
+	if x, y := e.Add(gx, gy, gx, negY); x.Sign() != 0 || y.Sign() != 0 {
+		t.Error("G + (-G) should be (0, 0)")
+	}
+	if x, y := e.Add(gx, gy, new(big.Int), new(big.Int)); x.Cmp(gx) != 0 || y.Cmp(gy) != 0 {
+		t.Error("G + O should be G")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for an invalid point")
+		}
+	}()
+	e.Double(gx, new(big.Int).Add(gy, big.NewInt(1)))
+}
@@ -0,0 +1,21 @@
+package ec
+
+import "errors"
+
+// Errors returned when decoding or validating points. They are wrapped with
+// details of the failure, so test for them with errors.Is.
+var (
+	// ErrInvalidPointEncoding reports a malformed point encoding: an
+	// unknown prefix, a wrong length, an unreduced coordinate or a hybrid
+	// encoding whose prefix does not match y.
+	ErrInvalidPointEncoding = errors.New("ec: invalid point encoding")
+	// ErrPointNotOnCurve reports coordinates that do not satisfy the curve
+	// equation.
+	ErrPointNotOnCurve = errors.New("ec: point not on curve")
+	// ErrPointAtInfinity reports the point at infinity where a finite
+	// point is required.
+	ErrPointAtInfinity = errors.New("ec: point at infinity")
+	// ErrPointNotInSubgroup reports a point outside the subgroup of order
+	// n, which is only possible on curves with a cofactor other than 1.
+	ErrPointNotInSubgroup = errors.New("ec: point not in subgroup of order n")
+)
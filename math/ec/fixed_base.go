@@ -0,0 +1,132 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+const (
+	fixedBaseWindow    = 4
+	fixedBaseTableSize = 1 << fixedBaseWindow
+)
+
+// fixedBaseTable holds precomputed multiples of a curve's base point for the
+// fixed-base window method: rows[i][j] = [j·16^i]G for j = 0..15, in Jacobian
+// coordinates. With it, [k]G costs one addition per 4-bit digit of k and no
+// doublings. The rows are built on first use.
+// Reference: org.bouncycastle.math.ec.FixedPointCombMultiplier
+type fixedBaseTable struct {
+	g    *Point
+	once sync.Once
+	rows [][fixedBaseTableSize]*jacobianPoint
+}
+
+// newFixedBaseTable creates an empty table for the base point g.
+func newFixedBaseTable(g *Point) *fixedBaseTable {
+	return &fixedBaseTable{g: g}
+}
+
+// build fills in the table, covering every digit of a scalar below the order.
+// Only public multiples of G are involved, so variable-time addition is fine.
+func (t *fixedBaseTable) build(c *Curve) {
+	windows := (c.order.BitLen() + fixedBaseWindow - 1) / fixedBaseWindow
+	t.rows = make([][fixedBaseTableSize]*jacobianPoint, windows)
+
+	base := c.toJacobian(t.g)
+	for i := 0; i < windows; i++ {
+		row := &t.rows[i]
+		row[0] = c.newJacobianInfinity()
+		row[1] = base
+		for j := 2; j < fixedBaseTableSize; j++ {
+			row[j] = c.jacobianAddVartime(row[j-1], base)
+		}
+		// 16·base = 2·(8·base)
+		base = c.jacobianDouble(row[fixedBaseTableSize/2])
+	}
+}
+
+// isBasePoint reports whether p is the curve's base point G.
+func (c *Curve) isBasePoint(p *Point) bool {
+	return c.g != nil && !p.isInfinity && (p == c.g || p.Equals(c.g))
+}
+
+// getBaseTable returns the base point table, building it on first use.
+func (c *Curve) getBaseTable() *fixedBaseTable {
+	t := c.baseTable
+	t.once.Do(func() { t.build(c) })
+	return t
+}
+
+// digits splits k mod n into one 4-bit digit per table row, least
+// significant first.
+func (t *fixedBaseTable) digits(k, n *big.Int) []uint64 {
+	scalar := reduceScalar(k, n)
+
+	digits := make([]uint64, len(t.rows))
+	for i := range digits {
+		digits[i] = scalar[i/16] >> (4 * (i % 16)) & 0x0F
+	}
+	return digits
+}
+
+// reduceScalar returns k mod n as little-endian 64-bit limbs, as many as n
+// needs. A non-negative k of at most that many limbs, such as a private key
+// or nonce, is reduced by masked subtractions of n·2^j for j from the spare
+// bits of the top limb down to 0: their number depends only on n, and no
+// branch depends on k. Negative and longer scalars are reduced with big.Int
+// first.
+func reduceScalar(k, n *big.Int) []uint64 {
+	words := (n.BitLen() + 63) / 64
+	if k.Sign() < 0 || k.BitLen() > 64*words {
+		k = new(big.Int).Mod(k, n)
+	}
+
+	// Before each step r < 2·n·2^j, as n >= 2^(bitlen-1); after it r < n·2^j
+	r := bigToLimbs(k, words)
+	d := make([]uint64, words)
+	for j := 64*words - n.BitLen(); j >= 0; j-- {
+		m := bigToLimbs(new(big.Int).Lsh(n, uint(j)), words)
+		var b uint64
+		for i := range r {
+			d[i], b = bits.Sub64(r[i], m[i], b)
+		}
+		// Keep r if the subtraction borrowed
+		mask := -b
+		for i := range r {
+			r[i] = d[i] ^ ((d[i] ^ r[i]) & mask)
+		}
+	}
+	return r
+}
+
+// multiplyBase computes [k]G from the precomputed table. k is reduced modulo
+// the order, and every digit costs one table scan and one complete addition,
+// so the running time does not depend on k.
+func (c *Curve) multiplyBase(k *big.Int) *Point {
+	t := c.getBaseTable()
+
+	result := c.newJacobianInfinity()
+	selected := c.newJacobianInfinity()
+	for i, digit := range t.digits(k, c.order) {
+		for j := 0; j < fixedBaseTableSize; j++ {
+			jacobianCmov(selected, t.rows[i][j], ctEq(uint64(j), digit))
+		}
+		result = c.jacobianAdd(result, selected)
+	}
+
+	return c.toAffine(result)
+}
+
+// addBaseVartime returns acc + [k]G, indexing the table directly and
+// skipping zero digits. It must only be used with public scalars.
+func (c *Curve) addBaseVartime(acc *jacobianPoint, k *big.Int) *jacobianPoint {
+	t := c.getBaseTable()
+	for i, digit := range t.digits(k, c.order) {
+		if digit != 0 {
+			acc = c.jacobianAddVartime(acc, t.rows[i][digit])
+		}
+	}
+	return acc
+}
@@ -0,0 +1,123 @@
+package ec
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestMultiplyBaseMatchesGeneric(t *testing.T) {
+	for name, curve := range map[string]*Curve{
+		"sm2p256v1": newTestSM2Curve(),
+		"fp256test": newTestFp256Curve(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := curve.GetG()
+			n := curve.GetOrder()
+
+			scalars := []*big.Int{
+				big.NewInt(1),
+				big.NewInt(2),
+				big.NewInt(15),
+				big.NewInt(16),
+				new(big.Int).Sub(n, big.NewInt(1)),
+			}
+			for i := 0; i < 10; i++ {
+				k, err := rand.Int(rand.Reader, n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				scalars = append(scalars, k)
+			}
+
+			for _, k := range scalars {
+				expected := curve.multiplyVartime(g, k)
+				if !curve.multiplyBase(k).Equals(expected) {
+					t.Fatalf("multiplyBase mismatch for k=%x", k)
+				}
+				if !g.MultiplySecret(k).Equals(expected) {
+					t.Fatalf("MultiplySecret on G mismatch for k=%x", k)
+				}
+				if !g.Multiply(k).Equals(expected) {
+					t.Fatalf("Multiply on G mismatch for k=%x", k)
+				}
+				if !curve.ScalarBaseMult(k.Bytes()).Equals(expected) {
+					t.Fatalf("ScalarBaseMult mismatch for k=%x", k)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiplyBaseEdgeCases(t *testing.T) {
+	curve := newTestSM2Curve()
+	g := curve.GetG()
+	n := curve.GetOrder()
+
+	if !curve.multiplyBase(big.NewInt(0)).IsInfinity() {
+		t.Error("[0]G should be infinity")
+	}
+	if !curve.multiplyBase(n).IsInfinity() {
+		t.Error("[n]G should be infinity")
+	}
+
+	// Scalars outside [0, n-1] are reduced modulo n
+	k := big.NewInt(12345)
+	if !curve.multiplyBase(new(big.Int).Add(n, k)).Equals(g.Multiply(k)) {
+		t.Error("[n+k]G != [k]G")
+	}
+	if !curve.multiplyBase(new(big.Int).Neg(k)).Equals(g.Multiply(k).Negate()) {
+		t.Error("[-k]G != -[k]G")
+	}
+
+	// A copy of G with the same coordinates is recognised as the base point
+	gCopy := curve.CreatePoint(g.GetXCoord().ToBigInt(), g.GetYCoord().ToBigInt())
+	if !curve.isBasePoint(gCopy) {
+		t.Error("Copy of G not recognised as the base point")
+	}
+	if curve.isBasePoint(g.Twice()) {
+		t.Error("[2]G recognised as the base point")
+	}
+}
+
+func TestSetGResetsTable(t *testing.T) {
+	curve := newTestSM2Curve()
+	k := big.NewInt(987654321)
+	_ = curve.GetG().MultiplySecret(k)
+
+	g2 := curve.GetG().Multiply(big.NewInt(7))
+	curve.SetG(g2)
+
+	expected := curve.multiplySecret(g2, k)
+	if !curve.ScalarBaseMult(k.Bytes()).Equals(expected) {
+		t.Error("Table not rebuilt after SetG")
+	}
+}
+
+func TestReduceScalar(t *testing.T) {
+	// Orders filling their top limb, and one leaving 62 spare bits
+	odd, _ := new(big.Int).SetString("3FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", 16)
+	for _, n := range []*big.Int{sm2P256V1N, newTestFp256Curve().GetOrder(), odd} {
+		words := (n.BitLen() + 63) / 64
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(64*words))
+		scalars := []*big.Int{
+			big.NewInt(0),
+			new(big.Int).Sub(n, big.NewInt(1)),
+			new(big.Int).Set(n),
+			new(big.Int).Sub(limit, big.NewInt(1)),
+			new(big.Int).Neg(n),
+			new(big.Int).Lsh(n, 300),
+		}
+		for i := 0; i < 20; i++ {
+			k, _ := rand.Int(rand.Reader, limit)
+			scalars = append(scalars, k)
+		}
+
+		for _, k := range scalars {
+			expected := new(big.Int).Mod(k, n)
+			if got := limbsToBig(reduceScalar(k, n)); got.Cmp(expected) != 0 {
+				t.Errorf("n=%x: %x mod n = %x, want %x", n, k, got, expected)
+			}
+		}
+	}
+}
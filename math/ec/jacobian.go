@@ -0,0 +1,283 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+)
+
+// jacobianPoint is a point in Jacobian projective coordinates over the
+// curve's Montgomery field: (X, Y, Z) represents the affine point
+// (X/Z², Y/Z³). The point at infinity has Z = 0.
+type jacobianPoint struct {
+	x, y, z []uint64
+}
+
+// newJacobianInfinity returns the point at infinity (1, 1, 0).
+func (c *Curve) newJacobianInfinity() *jacobianPoint {
+	f := c.field
+	q := &jacobianPoint{x: f.newElement(), y: f.newElement(), z: f.newElement()}
+	f.setOne(q.x)
+	f.setOne(q.y)
+	return q
+}
+
+// toJacobian converts an affine point to Jacobian coordinates.
+func (c *Curve) toJacobian(p *Point) *jacobianPoint {
+	if p.isInfinity {
+		return c.newJacobianInfinity()
+	}
+	f := c.field
+	q := &jacobianPoint{
+		x: f.fromBig(p.x.ToBigInt()),
+		y: f.fromBig(p.y.ToBigInt()),
+		z: f.newElement(),
+	}
+	f.setOne(q.z)
+	return q
+}
+
+// toAffine converts a Jacobian point back to an affine Point.
+func (c *Curve) toAffine(q *jacobianPoint) *Point {
+	f := c.field
+	if f.isZero(q.z) == 1 {
+		return c.GetInfinity()
+	}
+
+	zInv := f.newElement()
+	f.inv(zInv, q.z)
+	zInv2 := f.newElement()
+	f.sqr(zInv2, zInv)
+	zInv3 := f.newElement()
+	f.mul(zInv3, zInv2, zInv)
+
+	x := f.newElement()
+	f.mul(x, q.x, zInv2)
+	y := f.newElement()
+	f.mul(y, q.y, zInv3)
+
+	return NewPoint(c, c.FromBigInteger(f.toBig(x)), c.FromBigInteger(f.toBig(y)))
+}
+
+// jacobianCmov sets r = q if cond == 1, in constant time.
+func jacobianCmov(r, q *jacobianPoint, cond uint64) {
+	cmov(r.x, q.x, cond)
+	cmov(r.y, q.y, cond)
+	cmov(r.z, q.z, cond)
+}
+
+// jacobianDouble returns 2q using dbl-2007-bl for a general coefficient a.
+// Doubling the point at infinity, or a point with Y = 0, yields Z = 0.
+func (c *Curve) jacobianDouble(q *jacobianPoint) *jacobianPoint {
+	f := c.field
+	xx, yy, yyyy, zz := f.newElement(), f.newElement(), f.newElement(), f.newElement()
+	s, m, t := f.newElement(), f.newElement(), f.newElement()
+
+	f.sqr(xx, q.x)
+	f.sqr(yy, q.y)
+	f.sqr(yyyy, yy)
+	f.sqr(zz, q.z)
+
+	// S = 2·((X + YY)² - XX - YYYY)
+	f.add(s, q.x, yy)
+	f.sqr(s, s)
+	f.sub(s, s, xx)
+	f.sub(s, s, yyyy)
+	f.add(s, s, s)
+
+	// M = 3·XX + a·ZZ²
+	f.sqr(m, zz)
+	f.mul(m, m, c.aMont)
+	f.add(m, m, xx)
+	f.add(m, m, xx)
+	f.add(m, m, xx)
+
+	// X3 = T = M² - 2·S
+	f.sqr(t, m)
+	f.sub(t, t, s)
+	f.sub(t, t, s)
+
+	r := &jacobianPoint{x: t, y: f.newElement(), z: f.newElement()}
+
+	// Y3 = M·(S - T) - 8·YYYY
+	f.sub(r.y, s, t)
+	f.mul(r.y, r.y, m)
+	f.add(yyyy, yyyy, yyyy)
+	f.add(yyyy, yyyy, yyyy)
+	f.add(yyyy, yyyy, yyyy)
+	f.sub(r.y, r.y, yyyy)
+
+	// Z3 = (Y + Z)² - YY - ZZ
+	f.add(r.z, q.y, q.z)
+	f.sqr(r.z, r.z)
+	f.sub(r.z, r.z, yy)
+	f.sub(r.z, r.z, zz)
+
+	return r
+}
+
+// jacobianAddFormula computes p + q with add-2007-bl. It also reports, as
+// 0/1 masks, whether H and r are zero; the result is only meaningful when
+// both inputs are finite and p != q.
+func (c *Curve) jacobianAddFormula(p, q *jacobianPoint) (res *jacobianPoint, hZero, rZero uint64) {
+	f := c.field
+	z1z1, z2z2 := f.newElement(), f.newElement()
+	u1, u2, s1, s2 := f.newElement(), f.newElement(), f.newElement(), f.newElement()
+	h, i, j, rr, v := f.newElement(), f.newElement(), f.newElement(), f.newElement(), f.newElement()
+
+	f.sqr(z1z1, p.z)
+	f.sqr(z2z2, q.z)
+	f.mul(u1, p.x, z2z2)
+	f.mul(u2, q.x, z1z1)
+	f.mul(s1, p.y, q.z)
+	f.mul(s1, s1, z2z2)
+	f.mul(s2, q.y, p.z)
+	f.mul(s2, s2, z1z1)
+
+	// H = U2 - U1, I = (2·H)², J = H·I, r = 2·(S2 - S1), V = U1·I
+	f.sub(h, u2, u1)
+	f.add(i, h, h)
+	f.sqr(i, i)
+	f.mul(j, h, i)
+	f.sub(rr, s2, s1)
+	f.add(rr, rr, rr)
+	f.mul(v, u1, i)
+
+	res = &jacobianPoint{x: f.newElement(), y: f.newElement(), z: f.newElement()}
+
+	// X3 = r² - J - 2·V
+	f.sqr(res.x, rr)
+	f.sub(res.x, res.x, j)
+	f.sub(res.x, res.x, v)
+	f.sub(res.x, res.x, v)
+
+	// Y3 = r·(V - X3) - 2·S1·J
+	f.sub(res.y, v, res.x)
+	f.mul(res.y, res.y, rr)
+	f.mul(s1, s1, j)
+	f.add(s1, s1, s1)
+	f.sub(res.y, res.y, s1)
+
+	// Z3 = ((Z1 + Z2)² - Z1Z1 - Z2Z2)·H
+	f.add(res.z, p.z, q.z)
+	f.sqr(res.z, res.z)
+	f.sub(res.z, res.z, z1z1)
+	f.sub(res.z, res.z, z2z2)
+	f.mul(res.z, res.z, h)
+
+	return res, f.isZero(h), f.isZero(rr)
+}
+
+// jacobianAdd returns p + q. Every input, including the point at infinity
+// and p == q, is handled by constant-time selection rather than by
+// branching, so the sequence of field operations is always the same.
+func (c *Curve) jacobianAdd(p, q *jacobianPoint) *jacobianPoint {
+	f := c.field
+	res, hZero, rZero := c.jacobianAddFormula(p, q)
+
+	// H = 0 and r = 0 means p == q: the formula degenerates, use doubling.
+	// H = 0 and r != 0 means p == -q: Z3 = 0 already encodes infinity.
+	dbl := c.jacobianDouble(p)
+	jacobianCmov(res, dbl, hZero&rZero)
+	jacobianCmov(res, q, f.isZero(p.z))
+	jacobianCmov(res, p, f.isZero(q.z))
+
+	return res
+}
+
+// jacobianAddVartime returns p + q, branching on the special cases.
+// It is faster than jacobianAdd and must only be used on public data.
+func (c *Curve) jacobianAddVartime(p, q *jacobianPoint) *jacobianPoint {
+	f := c.field
+	if f.isZero(p.z) == 1 {
+		return q
+	}
+	if f.isZero(q.z) == 1 {
+		return p
+	}
+	res, hZero, rZero := c.jacobianAddFormula(p, q)
+	if hZero == 1 && rZero == 1 {
+		return c.jacobianDouble(p)
+	}
+	return res
+}
+
+// multiplyVartime computes [k]p for k >= 0 with left-to-right double-and-add
+// over Jacobian coordinates. Its running time depends on k.
+func (c *Curve) multiplyVartime(p *Point, k *big.Int) *Point {
+	q := c.toJacobian(p)
+	result := c.newJacobianInfinity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = c.jacobianDouble(result)
+		if k.Bit(i) == 1 {
+			result = c.jacobianAddVartime(result, q)
+		}
+	}
+	return c.toAffine(result)
+}
+
+// jacobianNegate returns -q.
+func (c *Curve) jacobianNegate(q *jacobianPoint) *jacobianPoint {
+	f := c.field
+	r := &jacobianPoint{x: q.x, y: f.newElement(), z: q.z}
+	f.sub(r.y, f.newElement(), q.y)
+	return r
+}
+
+// multiplySecret computes [k]p with a fixed 4-bit window over Jacobian
+// coordinates. k is reduced modulo the group order, so the number of
+// doublings and additions depends only on the bit length of the order, and
+// table entries are selected by scanning the whole table, so neither timing
+// nor memory access reveals k.
+func (c *Curve) multiplySecret(p *Point, k *big.Int) *Point {
+	if p.isInfinity {
+		return c.GetInfinity()
+	}
+	return c.toAffine(c.sumOfMultipliesSecret([]*Point{p}, []*big.Int{k}))
+}
+
+// sumOfMultipliesSecret computes [k0]p0 + [k1]p1 + ... with interleaved
+// fixed 4-bit windows sharing one chain of doublings (Straus). Like
+// multiplySecret, it reduces each scalar modulo the group order, and the
+// operation sequence depends only on the number of points and the bit
+// length of the order.
+func (c *Curve) sumOfMultipliesSecret(ps []*Point, ks []*big.Int) *jacobianPoint {
+	const windowSize = 4
+	const tableSize = 1 << windowSize
+
+	// Precompute T[i] = [i]P for i = 0..15 for each point
+	tables := make([][tableSize]*jacobianPoint, len(ps))
+	scalars := make([][]uint64, len(ps))
+	for n, p := range ps {
+		table := &tables[n]
+		table[0] = c.newJacobianInfinity()
+		table[1] = c.toJacobian(p)
+		for i := 2; i < tableSize; i++ {
+			if i%2 == 0 {
+				table[i] = c.jacobianDouble(table[i/2])
+			} else {
+				table[i] = c.jacobianAdd(table[i-1], table[1])
+			}
+		}
+		scalars[n] = reduceScalar(ks[n], c.order)
+	}
+
+	// The window count is fixed by the group order so it does not leak k
+	windows := (c.order.BitLen() + windowSize - 1) / windowSize
+
+	result := c.newJacobianInfinity()
+	selected := c.newJacobianInfinity()
+	for w := windows - 1; w >= 0; w-- {
+		for i := 0; i < windowSize; i++ {
+			result = c.jacobianDouble(result)
+		}
+		for n := range tables {
+			digit := scalars[n][w/16] >> (windowSize * (w % 16)) & 0x0F
+			for i := 0; i < tableSize; i++ {
+				jacobianCmov(selected, tables[n][i], ctEq(uint64(i), digit))
+			}
+			result = c.jacobianAdd(result, selected)
+		}
+	}
+
+	return result
+}
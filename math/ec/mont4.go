@@ -0,0 +1,217 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// mont4 implements Montgomery arithmetic for a fixed 256-bit odd modulus
+// using four 64-bit limbs held in arrays, so no operation allocates.
+// It backs the dedicated sm2p256v1 prime field and scalar field.
+//
+// Like montField, every operation is constant-time in its inputs.
+type mont4 struct {
+	m    [4]uint64 // Modulus
+	mInv uint64    // -m^-1 mod 2^64
+	rr   [4]uint64 // R^2 mod m, R = 2^256
+	one  [4]uint64 // R mod m
+	exp  [4]uint64 // m-2, exponent for Fermat inversion (public)
+	mBig *big.Int
+}
+
+// newMont4 creates 4-limb Montgomery arithmetic for the odd modulus m < 2^256.
+func newMont4(m *big.Int) *mont4 {
+	f := &mont4{mBig: new(big.Int).Set(m)}
+	copy(f.m[:], bigToLimbs(m, 4))
+
+	inv := uint64(1)
+	for i := 0; i < 6; i++ {
+		inv *= 2 - f.m[0]*inv
+	}
+	f.mInv = -inv
+
+	r := new(big.Int).Lsh(big.NewInt(1), 256)
+	copy(f.one[:], bigToLimbs(new(big.Int).Mod(r, m), 4))
+	rr := new(big.Int).Mul(r, r)
+	copy(f.rr[:], bigToLimbs(rr.Mod(rr, m), 4))
+	copy(f.exp[:], bigToLimbs(new(big.Int).Sub(m, big.NewInt(2)), 4))
+
+	return f
+}
+
+// mul4 sets z = x·y·R^-1 mod m. z may alias x or y.
+func (f *mont4) mul4(z, x, y *[4]uint64) {
+	var t [6]uint64
+	var c, cc, hi, lo uint64
+
+	for i := 0; i < 4; i++ {
+		// t += x·y[i]
+		c = 0
+		for j := 0; j < 4; j++ {
+			hi, lo = bits.Mul64(x[j], y[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j] = lo
+			c = hi
+		}
+		t[4], cc = bits.Add64(t[4], c, 0)
+		t[5] = cc
+
+		// t = (t + q·m) / 2^64
+		q := t[0] * f.mInv
+		hi, lo = bits.Mul64(q, f.m[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(q, f.m[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1] = lo
+			c = hi
+		}
+		t[3], cc = bits.Add64(t[4], c, 0)
+		t[4] = t[5] + cc
+	}
+
+	f.reduceOnce4(z, (*[4]uint64)(t[:4]), t[4])
+}
+
+// add4 sets z = x + y mod m.
+func (f *mont4) add4(z, x, y *[4]uint64) {
+	var t [4]uint64
+	var c uint64
+	t[0], c = bits.Add64(x[0], y[0], 0)
+	t[1], c = bits.Add64(x[1], y[1], c)
+	t[2], c = bits.Add64(x[2], y[2], c)
+	t[3], c = bits.Add64(x[3], y[3], c)
+	f.reduceOnce4(z, &t, c)
+}
+
+// sub4 sets z = x - y mod m.
+func (f *mont4) sub4(z, x, y *[4]uint64) {
+	var t [4]uint64
+	var b, c uint64
+	t[0], b = bits.Sub64(x[0], y[0], 0)
+	t[1], b = bits.Sub64(x[1], y[1], b)
+	t[2], b = bits.Sub64(x[2], y[2], b)
+	t[3], b = bits.Sub64(x[3], y[3], b)
+
+	// Add m back if the subtraction borrowed
+	mask := -b
+	z[0], c = bits.Add64(t[0], f.m[0]&mask, 0)
+	z[1], c = bits.Add64(t[1], f.m[1]&mask, c)
+	z[2], c = bits.Add64(t[2], f.m[2]&mask, c)
+	z[3], _ = bits.Add64(t[3], f.m[3]&mask, c)
+}
+
+// reduceOnce4 sets z = t - m if (carry:t) >= m, otherwise z = t.
+func (f *mont4) reduceOnce4(z, t *[4]uint64, carry uint64) {
+	var d [4]uint64
+	var b uint64
+	d[0], b = bits.Sub64(t[0], f.m[0], 0)
+	d[1], b = bits.Sub64(t[1], f.m[1], b)
+	d[2], b = bits.Sub64(t[2], f.m[2], b)
+	d[3], b = bits.Sub64(t[3], f.m[3], b)
+
+	mask := -(b &^ carry)
+	z[0] = d[0] ^ ((d[0] ^ t[0]) & mask)
+	z[1] = d[1] ^ ((d[1] ^ t[1]) & mask)
+	z[2] = d[2] ^ ((d[2] ^ t[2]) & mask)
+	z[3] = d[3] ^ ((d[3] ^ t[3]) & mask)
+}
+
+// exp4 sets z = x^e (Montgomery form) with a fixed 4-bit window.
+// The exponent must be public: the multiplications skipped for zero
+// digits depend on it, but never on x.
+func (f *mont4) exp4(z, x, e *[4]uint64) {
+	var table [16][4]uint64
+	table[0] = f.one
+	table[1] = *x
+	for i := 2; i < 16; i++ {
+		f.mul4(&table[i], &table[i-1], x)
+	}
+
+	r := f.one
+	for i := 63; i >= 0; i-- {
+		for j := 0; j < 4; j++ {
+			f.mul4(&r, &r, &r)
+		}
+		digit := (e[i/16] >> (4 * (uint(i) % 16))) & 0x0F
+		if digit != 0 {
+			f.mul4(&r, &r, &table[digit])
+		}
+	}
+	*z = r
+}
+
+// inv4 sets z = x^-1 mod m by Fermat's little theorem.
+func (f *mont4) inv4(z, x *[4]uint64) {
+	f.exp4(z, x, &f.exp)
+}
+
+// toMont4 converts x into Montgomery form, reducing it first if needed.
+func (f *mont4) toMont4(z *[4]uint64, x *big.Int) {
+	v := x
+	if x.Sign() < 0 || x.Cmp(f.mBig) >= 0 {
+		v = new(big.Int).Mod(x, f.mBig)
+	}
+	copy(z[:], bigToLimbs(v, 4))
+	f.mul4(z, z, &f.rr)
+}
+
+// fromMont4 converts a Montgomery-form element back to an integer.
+func (f *mont4) fromMont4(x *[4]uint64) *big.Int {
+	var z [4]uint64
+	f.mul4(&z, x, &[4]uint64{1})
+	return limbsToBig(z[:])
+}
+
+// The methods below adapt mont4 to the slice-based fieldArith interface.
+
+func (f *mont4) newElement() []uint64 {
+	return make([]uint64, 4)
+}
+
+func (f *mont4) fromBig(x *big.Int) []uint64 {
+	z := f.newElement()
+	f.toMont4((*[4]uint64)(z), x)
+	return z
+}
+
+func (f *mont4) toBig(a []uint64) *big.Int {
+	return f.fromMont4((*[4]uint64)(a))
+}
+
+func (f *mont4) setOne(z []uint64) {
+	copy(z, f.one[:])
+}
+
+func (f *mont4) add(z, x, y []uint64) {
+	f.add4((*[4]uint64)(z), (*[4]uint64)(x), (*[4]uint64)(y))
+}
+
+func (f *mont4) sub(z, x, y []uint64) {
+	f.sub4((*[4]uint64)(z), (*[4]uint64)(x), (*[4]uint64)(y))
+}
+
+func (f *mont4) mul(z, x, y []uint64) {
+	f.mul4((*[4]uint64)(z), (*[4]uint64)(x), (*[4]uint64)(y))
+}
+
+func (f *mont4) sqr(z, x []uint64) {
+	f.mul4((*[4]uint64)(z), (*[4]uint64)(x), (*[4]uint64)(x))
+}
+
+func (f *mont4) inv(z, x []uint64) {
+	f.inv4((*[4]uint64)(z), (*[4]uint64)(x))
+}
+
+func (f *mont4) isZero(x []uint64) uint64 {
+	v := x[0] | x[1] | x[2] | x[3]
+	return ((v | -v) >> 63) ^ 1
+}
@@ -0,0 +1,247 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// fieldArith is fixed-width, branch-free arithmetic modulo an odd prime on
+// little-endian 64-bit limbs kept in an implementation-defined (Montgomery)
+// representation. It is the internal counterpart of FieldElement used by the
+// Jacobian point formulas and the scalar field.
+type fieldArith interface {
+	newElement() []uint64
+	fromBig(x *big.Int) []uint64
+	toBig(a []uint64) *big.Int
+	setOne(z []uint64)
+	add(z, x, y []uint64)
+	sub(z, x, y []uint64)
+	mul(z, x, y []uint64)
+	sqr(z, x []uint64)
+	inv(z, x []uint64)
+	isZero(x []uint64) uint64
+}
+
+// montField implements fixed-width arithmetic modulo an odd prime using
+// Montgomery multiplication (CIOS). Elements are little-endian 64-bit limbs
+// in the Montgomery domain (a·R mod p, R = 2^(64·n)).
+//
+// Every operation runs in time that depends only on the number of limbs,
+// never on the values, so it is suitable for computations on secret data.
+type montField struct {
+	n    int      // Number of 64-bit limbs
+	p    []uint64 // Modulus
+	pBig *big.Int // Modulus as an integer
+	pInv uint64   // -p^-1 mod 2^64
+	rr   []uint64 // R^2 mod p
+	one  []uint64 // R mod p (1 in Montgomery form)
+	exp  []uint64 // p-2, exponent for Fermat inversion (public)
+}
+
+// newMontField creates Montgomery arithmetic for the odd modulus p.
+func newMontField(p *big.Int) *montField {
+	n := (p.BitLen() + 63) / 64
+	f := &montField{
+		n:    n,
+		p:    bigToLimbs(p, n),
+		pBig: new(big.Int).Set(p),
+	}
+
+	// Newton iteration for p^-1 mod 2^64, then negate
+	inv := uint64(1)
+	for i := 0; i < 6; i++ {
+		inv *= 2 - f.p[0]*inv
+	}
+	f.pInv = -inv
+
+	r := new(big.Int).Lsh(big.NewInt(1), uint(64*n))
+	f.one = bigToLimbs(new(big.Int).Mod(r, p), n)
+	rr := new(big.Int).Mul(r, r)
+	f.rr = bigToLimbs(rr.Mod(rr, p), n)
+	f.exp = bigToLimbs(new(big.Int).Sub(p, big.NewInt(2)), n)
+
+	return f
+}
+
+// newElement allocates a zero element.
+func (f *montField) newElement() []uint64 {
+	return make([]uint64, f.n)
+}
+
+// setOne sets z to 1 in Montgomery form.
+func (f *montField) setOne(z []uint64) {
+	copy(z, f.one)
+}
+
+// fromBig converts x (reduced mod p) into Montgomery form.
+func (f *montField) fromBig(x *big.Int) []uint64 {
+	v := x
+	if x.Sign() < 0 || x.Cmp(f.pBig) >= 0 {
+		v = new(big.Int).Mod(x, f.pBig)
+	}
+	z := bigToLimbs(v, f.n)
+	f.mul(z, z, f.rr)
+	return z
+}
+
+// toBig converts a Montgomery-form element back to an integer.
+func (f *montField) toBig(a []uint64) *big.Int {
+	one := f.newElement()
+	one[0] = 1
+	z := f.newElement()
+	f.mul(z, a, one)
+	return limbsToBig(z)
+}
+
+// mul sets z = x·y·R^-1 mod p. z may alias x or y.
+func (f *montField) mul(z, x, y []uint64) {
+	n := f.n
+	t := make([]uint64, n+2)
+
+	for i := 0; i < n; i++ {
+		// t += x·y[i]
+		var c, cc uint64
+		for j := 0; j < n; j++ {
+			hi, lo := bits.Mul64(x[j], y[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j] = lo
+			c = hi
+		}
+		t[n], cc = bits.Add64(t[n], c, 0)
+		t[n+1] = cc
+
+		// t = (t + m·p) / 2^64
+		m := t[0] * f.pInv
+		hi, lo := bits.Mul64(m, f.p[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < n; j++ {
+			hi, lo = bits.Mul64(m, f.p[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1] = lo
+			c = hi
+		}
+		t[n-1], cc = bits.Add64(t[n], c, 0)
+		t[n] = t[n+1] + cc
+	}
+
+	f.reduceOnce(z, t[:n], t[n])
+}
+
+// sqr sets z = x²·R^-1 mod p.
+func (f *montField) sqr(z, x []uint64) {
+	f.mul(z, x, x)
+}
+
+// add sets z = x + y mod p.
+func (f *montField) add(z, x, y []uint64) {
+	t := make([]uint64, f.n)
+	var c uint64
+	for i := 0; i < f.n; i++ {
+		t[i], c = bits.Add64(x[i], y[i], c)
+	}
+	f.reduceOnce(z, t, c)
+}
+
+// sub sets z = x - y mod p.
+func (f *montField) sub(z, x, y []uint64) {
+	t := make([]uint64, f.n)
+	var b uint64
+	for i := 0; i < f.n; i++ {
+		t[i], b = bits.Sub64(x[i], y[i], b)
+	}
+	// Add p back if the subtraction borrowed
+	mask := -b
+	var c uint64
+	for i := 0; i < f.n; i++ {
+		t[i], c = bits.Add64(t[i], f.p[i]&mask, c)
+	}
+	copy(z, t)
+}
+
+// inv sets z = x^-1 mod p using Fermat's little theorem (z = x^(p-2)).
+// The exponent is public, so the square-and-multiply chain is fixed.
+func (f *montField) inv(z, x []uint64) {
+	r := f.newElement()
+	f.setOne(r)
+	base := f.newElement()
+	copy(base, x)
+
+	for i := len(f.exp)*64 - 1; i >= 0; i-- {
+		f.sqr(r, r)
+		if (f.exp[i/64]>>(uint(i)%64))&1 == 1 {
+			f.mul(r, r, base)
+		}
+	}
+	copy(z, r)
+}
+
+// isZero returns 1 if x is zero and 0 otherwise, in constant time.
+func (f *montField) isZero(x []uint64) uint64 {
+	var v uint64
+	for i := 0; i < f.n; i++ {
+		v |= x[i]
+	}
+	return ((v | -v) >> 63) ^ 1
+}
+
+// reduceOnce sets z = t - p if (carry:t) >= p, otherwise z = t.
+// Requires (carry:t) < 2p.
+func (f *montField) reduceOnce(z, t []uint64, carry uint64) {
+	d := make([]uint64, f.n)
+	var b uint64
+	for i := 0; i < f.n; i++ {
+		d[i], b = bits.Sub64(t[i], f.p[i], b)
+	}
+	// Use d unless the subtraction borrowed out of a value without carry
+	useT := b &^ carry
+	cmov(d, t, useT)
+	copy(z, d)
+}
+
+// cmov sets z = x if c == 1, leaving z unchanged if c == 0.
+func cmov(z, x []uint64, c uint64) {
+	mask := -c
+	for i := range z {
+		z[i] ^= (z[i] ^ x[i]) & mask
+	}
+}
+
+// ctEq returns 1 if a == b and 0 otherwise, in constant time.
+func ctEq(a, b uint64) uint64 {
+	v := a ^ b
+	return ((v | -v) >> 63) ^ 1
+}
+
+// bigToLimbs converts a non-negative integer to n little-endian 64-bit limbs.
+func bigToLimbs(x *big.Int, n int) []uint64 {
+	buf := x.FillBytes(make([]byte, 8*n))
+	z := make([]uint64, n)
+	for i := 0; i < n; i++ {
+		off := len(buf) - 8*(i+1)
+		for j := 0; j < 8; j++ {
+			z[i] = z[i]<<8 | uint64(buf[off+j])
+		}
+	}
+	return z
+}
+
+// limbsToBig converts little-endian 64-bit limbs to an integer.
+func limbsToBig(a []uint64) *big.Int {
+	buf := make([]byte, 8*len(a))
+	for i, w := range a {
+		off := len(buf) - 8*(i+1)
+		for j := 7; j >= 0; j-- {
+			buf[off+j] = byte(w)
+			w >>= 8
+		}
+	}
+	return new(big.Int).SetBytes(buf)
+}
@@ -0,0 +1,61 @@
+package ec
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestMontFieldArithmetic(t *testing.T) {
+	moduli := []*big.Int{
+		fromHexString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF"),
+		fromHexString("8542D69E4C044F18E8B92435BF6FF7DE457283915C45517D722EDB8B08F1DFC3"),
+		big.NewInt(65537),
+	}
+
+	for _, p := range moduli {
+		f := newMontField(p)
+		for i := 0; i < 50; i++ {
+			x, _ := rand.Int(rand.Reader, p)
+			y, _ := rand.Int(rand.Reader, p)
+			if x.Sign() == 0 {
+				x.SetInt64(1)
+			}
+			xm, ym := f.fromBig(x), f.fromBig(y)
+			z := f.newElement()
+
+			if f.toBig(xm).Cmp(x) != 0 {
+				t.Fatalf("Round trip failed for %x", x)
+			}
+
+			f.add(z, xm, ym)
+			expected := new(big.Int).Add(x, y)
+			if f.toBig(z).Cmp(expected.Mod(expected, p)) != 0 {
+				t.Fatalf("add mismatch: %x + %x", x, y)
+			}
+
+			f.sub(z, xm, ym)
+			expected = new(big.Int).Sub(x, y)
+			if f.toBig(z).Cmp(expected.Mod(expected, p)) != 0 {
+				t.Fatalf("sub mismatch: %x - %x", x, y)
+			}
+
+			f.mul(z, xm, ym)
+			expected = new(big.Int).Mul(x, y)
+			if f.toBig(z).Cmp(expected.Mod(expected, p)) != 0 {
+				t.Fatalf("mul mismatch: %x * %x", x, y)
+			}
+
+			f.inv(z, xm)
+			expected = new(big.Int).ModInverse(x, p)
+			if f.toBig(z).Cmp(expected) != 0 {
+				t.Fatalf("inv mismatch: %x", x)
+			}
+		}
+
+		zero := f.fromBig(big.NewInt(0))
+		if f.isZero(zero) != 1 || f.isZero(f.one) != 0 {
+			t.Error("isZero returned wrong result")
+		}
+	}
+}
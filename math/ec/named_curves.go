@@ -0,0 +1,190 @@
+package ec
+
+import (
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in curves.
+const (
+	// SM2P256V1 is the SM2 recommended curve of GM/T 0003.5-2012,
+	// OID 1.2.156.10197.1.301.
+	SM2P256V1 = "sm2p256v1"
+	// SM2TestFp256 is the 256-bit prime field curve used by the worked
+	// examples in the appendices of GM/T 0003-2012 Parts 2 to 4. It is for
+	// testing only and has no OID.
+	SM2TestFp256 = "sm2testfp256"
+)
+
+// namedCurve is an entry of the curve registry. The curve is built on first
+// use.
+type namedCurve struct {
+	name  string
+	oid   asn1.ObjectIdentifier
+	build func() *Curve
+	once  sync.Once
+	curve *Curve
+}
+
+func (e *namedCurve) get() *Curve {
+	e.once.Do(func() {
+		e.curve = e.build()
+	})
+	return e.curve
+}
+
+var (
+	namedCurvesMu sync.RWMutex
+	namedCurves   = []*namedCurve{
+		{name: SM2P256V1, oid: asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}, build: newSM2P256V1Curve},
+		{name: SM2TestFp256, build: newSM2TestFp256Curve},
+	}
+)
+
+// RegisterNamedCurve adds a curve to the registry under name and, if oid is
+// not nil, under oid. build is called once, on first use, and must return a
+// curve with its base point set. Names are case-insensitive; a name or OID
+// that is already registered is rejected.
+// Based on: org.bouncycastle.asn1.x9.ECNamedCurveTable
+func RegisterNamedCurve(name string, oid asn1.ObjectIdentifier, build func() *Curve) error {
+	if name == "" || build == nil {
+		return errors.New("curve name and constructor required")
+	}
+
+	namedCurvesMu.Lock()
+	defer namedCurvesMu.Unlock()
+	for _, e := range namedCurves {
+		if strings.EqualFold(e.name, name) {
+			return errors.New("curve name already registered: " + name)
+		}
+		if oid != nil && e.oid.Equal(oid) {
+			return errors.New("curve OID already registered: " + oid.String())
+		}
+	}
+	namedCurves = append(namedCurves, &namedCurve{name: name, oid: oid, build: build})
+	return nil
+}
+
+// GetNamedCurve returns the registered curve with the given name, or nil.
+func GetNamedCurve(name string) *Curve {
+	if e := findNamedCurve(func(e *namedCurve) bool { return strings.EqualFold(e.name, name) }); e != nil {
+		return e.get()
+	}
+	return nil
+}
+
+// GetNamedCurveByOID returns the registered curve with the given OID, or
+// nil.
+func GetNamedCurveByOID(oid asn1.ObjectIdentifier) *Curve {
+	if e := findNamedCurve(func(e *namedCurve) bool { return e.oid != nil && e.oid.Equal(oid) }); e != nil {
+		return e.get()
+	}
+	return nil
+}
+
+// GetNamedCurveOID returns the OID of the named curve, or nil if the curve
+// is unknown or has no OID.
+func GetNamedCurveOID(name string) asn1.ObjectIdentifier {
+	if e := findNamedCurve(func(e *namedCurve) bool { return strings.EqualFold(e.name, name) }); e != nil {
+		return e.oid
+	}
+	return nil
+}
+
+// GetNamedCurveNames returns the names of all registered curves.
+func GetNamedCurveNames() []string {
+	namedCurvesMu.RLock()
+	defer namedCurvesMu.RUnlock()
+	names := make([]string, len(namedCurves))
+	for i, e := range namedCurves {
+		names[i] = e.name
+	}
+	return names
+}
+
+// GetCurveName returns the registry name of c, or "" if c is not a
+// registered curve. Curves are matched by their parameters and base point,
+// so a curve built separately from the same parameters is found as well.
+func GetCurveName(c *Curve) string {
+	if e := findNamedCurve(func(e *namedCurve) bool { return sameCurve(e.get(), c) }); e != nil {
+		return e.name
+	}
+	return ""
+}
+
+// GetCurveOID returns the OID of c, or nil if c is not a registered curve or
+// has no OID.
+func GetCurveOID(c *Curve) asn1.ObjectIdentifier {
+	if e := findNamedCurve(func(e *namedCurve) bool { return e.oid != nil && sameCurve(e.get(), c) }); e != nil {
+		return e.oid
+	}
+	return nil
+}
+
+// findNamedCurve returns the first registry entry matching match.
+func findNamedCurve(match func(*namedCurve) bool) *namedCurve {
+	namedCurvesMu.RLock()
+	entries := append([]*namedCurve{}, namedCurves...)
+	namedCurvesMu.RUnlock()
+
+	for _, e := range entries {
+		if match(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// sameCurve reports whether a and b have the same parameters and base
+// point.
+func sameCurve(a, b *Curve) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || !a.Equals(b) || a.N.Cmp(b.N) != 0 || a.H != b.H {
+		return false
+	}
+	if a.G == nil || b.G == nil {
+		return a.G == b.G
+	}
+	return a.G.Equals(b.G)
+}
+
+// newSM2P256V1Curve builds sm2p256v1 from GM/T 0003.5-2012.
+func newSM2P256V1Curve() *Curve {
+	return newNamedCurve(
+		"FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF",
+		"FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFC",
+		"28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93",
+		"FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123",
+		"32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7",
+		"BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0",
+	)
+}
+
+// newSM2TestFp256Curve builds the 256-bit Fp example curve of GM/T
+// 0003-2012.
+func newSM2TestFp256Curve() *Curve {
+	return newNamedCurve(
+		"8542D69E4C044F18E8B92435BF6FF7DE457283915C45517D722EDB8B08F1DFC3",
+		"787968B4FA32C3FD2417842E73BBFEFF2F3C848B6831D7E0EC65228B3937E498",
+		"63E4C6D3B23B0C849CF84241484BFE48F61D59A5B16BA06E6E12D1DA27C5249A",
+		"8542D69E4C044F18E8B92435BF6FF7DD297720630485628D5AE74EE7C32E79B7",
+		"421DEBD61B62EAB6746434EBC3CC315E32220B3BADD50BDC4C4E6C147FEDD43D",
+		"0680512BCBB42C07D47349D2153B70C4E5D7FDFCBFA36EA1A85841B9E46E09A2",
+	)
+}
+
+// newNamedCurve builds a curve with cofactor 1 from hexadecimal parameters.
+func newNamedCurve(p, a, b, n, gx, gy string) *Curve {
+	hex := func(s string) *big.Int {
+		v, _ := new(big.Int).SetString(s, 16)
+		return v
+	}
+	curve := NewCurve(hex(p), hex(a), hex(b), hex(n), 1)
+	curve.SetG(curve.CreatePoint(hex(gx), hex(gy)))
+	return curve
+}
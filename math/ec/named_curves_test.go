@@ -0,0 +1,118 @@
+package ec
+
+import (
+	"encoding/asn1"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNamedCurves(t *testing.T) {
+	sm2OID := asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+
+	for _, name := range []string{SM2P256V1, SM2TestFp256} {
+		curve := GetNamedCurve(name)
+		if curve == nil {
+			t.Fatalf("%s: not registered", name)
+		}
+		if curve != GetNamedCurve(name) {
+			t.Errorf("%s: curve is rebuilt on each lookup", name)
+		}
+		g := curve.GetG()
+		if g == nil || !g.IsValid() {
+			t.Errorf("%s: invalid base point", name)
+			continue
+		}
+		if !g.Multiply(curve.GetOrder()).IsInfinity() {
+			t.Errorf("%s: [n]G is not infinity", name)
+		}
+		if got := GetCurveName(curve); got != name {
+			t.Errorf("GetCurveName = %q, want %q", got, name)
+		}
+	}
+
+	if GetNamedCurve("SM2P256V1") != GetNamedCurve(SM2P256V1) {
+		t.Error("Names should be case-insensitive")
+	}
+	if GetNamedCurve("unknown") != nil {
+		t.Error("Expected nil for an unknown curve")
+	}
+	if !GetNamedCurveOID(SM2P256V1).Equal(sm2OID) {
+		t.Errorf("Unexpected sm2p256v1 OID %v", GetNamedCurveOID(SM2P256V1))
+	}
+	if GetNamedCurveByOID(sm2OID) != GetNamedCurve(SM2P256V1) {
+		t.Error("Lookup by OID does not return sm2p256v1")
+	}
+	if GetNamedCurveOID(SM2TestFp256) != nil || GetCurveOID(GetNamedCurve(SM2TestFp256)) != nil {
+		t.Error("The test curve should have no OID")
+	}
+
+	// A curve built separately from the same parameters is recognized
+	if got := GetCurveName(newTestSM2Curve()); got != SM2P256V1 {
+		t.Errorf("GetCurveName of a rebuilt curve = %q", got)
+	}
+	if !GetCurveOID(newTestSM2Curve()).Equal(sm2OID) {
+		t.Error("GetCurveOID of a rebuilt curve does not return the sm2p256v1 OID")
+	}
+}
+
+func TestRegisterNamedCurve(t *testing.T) {
+	if err := RegisterNamedCurve("Sm2P256v1", nil, newSM2P256V1Curve); err == nil {
+		t.Error("Expected error for a duplicate name")
+	}
+	if err := RegisterNamedCurve("sm2-duplicate-oid", GetNamedCurveOID(SM2P256V1), newSM2P256V1Curve); err == nil {
+		t.Error("Expected error for a duplicate OID")
+	}
+	if err := RegisterNamedCurve("", nil, newSM2P256V1Curve); err == nil {
+		t.Error("Expected error for an empty name")
+	}
+
+	// The test curve registered again under an OID
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	if err := RegisterNamedCurve("sm2testfp256-oid", oid, newSM2TestFp256Curve); err != nil {
+		t.Fatalf("RegisterNamedCurve failed: %v", err)
+	}
+	curve := GetNamedCurveByOID(oid)
+	if curve == nil || !sameCurve(curve, GetNamedCurve(SM2TestFp256)) {
+		t.Fatal("Registered curve not found by OID")
+	}
+	if !GetCurveOID(GetNamedCurve(SM2TestFp256)).Equal(oid) {
+		t.Error("GetCurveOID should find the registration with an OID")
+	}
+}
+
+// TestNamedCurveConcurrentFirstUse looks up a curve that has not been
+// built yet from many goroutines; run with -race.
+func TestNamedCurveConcurrentFirstUse(t *testing.T) {
+	var builds int32
+	err := RegisterNamedCurve("sm2testfp256-concurrent", nil, func() *Curve {
+		atomic.AddInt32(&builds, 1)
+		return newSM2TestFp256Curve()
+	})
+	if err != nil {
+		t.Fatalf("RegisterNamedCurve failed: %v", err)
+	}
+
+	curves := make([]*Curve, 32)
+	var wg sync.WaitGroup
+	for i := range curves {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			curve := GetNamedCurve("sm2testfp256-concurrent")
+			// The base point table is also built on first use
+			curve.ScalarBaseMult([]byte{byte(i + 1)})
+			curves[i] = curve
+		}(i)
+	}
+	wg.Wait()
+
+	if builds != 1 {
+		t.Errorf("curve built %d times", builds)
+	}
+	for i, curve := range curves {
+		if curve != curves[0] {
+			t.Fatalf("goroutine %d got a different curve", i)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ec
 
 import (
 	"bytes"
+	"fmt"
 	"math/big"
 )
 
@@ -135,10 +136,10 @@ func (p *Point) Twice() *Point {
 	
 	// λ = (3x^2 + a) / (2y)
 	x2 := p.x.Square()
-	three := NewFp(p.curve.a.(*Fp).q, big.NewInt(3))
+	three := p.curve.FromBigInteger(big.NewInt(3))
 	numerator := three.Multiply(x2).Add(p.curve.a)
 	
-	two := NewFp(p.curve.a.(*Fp).q, big.NewInt(2))
+	two := p.curve.FromBigInteger(big.NewInt(2))
 	denominator := two.Multiply(p.y)
 	
 	lambda := numerator.Divide(denominator)
@@ -166,6 +167,9 @@ func (p *Point) Subtract(q *Point) *Point {
 }
 
 // Multiply multiplies the point by a scalar (double-and-add).
+// Its running time depends on the bits of k, so it must only be used with
+// public scalars; use MultiplySecret for private keys and nonces.
+// Multiples of the curve's base point use its precomputed table.
 func (p *Point) Multiply(k *big.Int) *Point {
 	if p.isInfinity {
 		return p
@@ -180,21 +184,21 @@ func (p *Point) Multiply(k *big.Int) *Point {
 		return p.Negate().Multiply(neg)
 	}
 	
-	// Double-and-add algorithm
-	result := p.GetInfinity()
-	addend := p
-	
-	kBits := k.Bytes()
-	for i := len(kBits) - 1; i >= 0; i-- {
-		for j := 0; j < 8; j++ {
-			if (kBits[i] & (1 << j)) != 0 {
-				result = result.Add(addend)
-			}
-			addend = addend.Twice()
-		}
+	if p.curve.isBasePoint(p) {
+		return p.curve.multiplyBase(k)
 	}
-	
-	return result
+	return p.curve.multiplyVartime(p, k)
+}
+
+// MultiplySecret multiplies the point by a secret scalar in constant time.
+// It uses a fixed-window method over Jacobian coordinates whose sequence of
+// field operations and table accesses does not depend on k. Multiples of
+// the curve's base point use its precomputed table.
+func (p *Point) MultiplySecret(k *big.Int) *Point {
+	if p.curve.isBasePoint(p) {
+		return p.curve.multiplyBase(k)
+	}
+	return p.curve.multiplySecret(p, k)
 }
 
 // Equals checks if two points are equal.
@@ -251,30 +255,38 @@ func (p *Point) GetEncoded(compressed bool) []byte {
 	return result
 }
 
-// DecodePoint decodes a point from bytes.
-func DecodePoint(curve *Curve, encoded []byte) *Point {
+// DecodePoint decodes a point in SEC 1 form: compressed (0x02/0x03 || x),
+// uncompressed (0x04 || x || y) or hybrid (0x06/0x07 || x || y).
+// The result is always a finite point of order n on the curve; otherwise an
+// error wrapping ErrInvalidPointEncoding, ErrPointNotOnCurve,
+// ErrPointAtInfinity or ErrPointNotInSubgroup is returned.
+// Based on: org.bouncycastle.math.ec.ECCurve.decodePoint
+func DecodePoint(curve *Curve, encoded []byte) (*Point, error) {
 	if len(encoded) == 0 {
-		return nil
+		return nil, fmt.Errorf("%w: empty input", ErrInvalidPointEncoding)
 	}
 	
 	typ := encoded[0]
-	
-	if typ == 0x00 {
-		return &Point{curve: curve, isInfinity: true}
-	}
-	
 	byteLen := (curve.GetFieldSize() + 7) / 8
 	
-	if typ == 0x02 || typ == 0x03 {
+	var point *Point
+	switch typ {
+	case 0x00:
+		if len(encoded) != 1 {
+			return nil, fmt.Errorf("%w: infinity encoding of %d bytes", ErrInvalidPointEncoding, len(encoded))
+		}
+		return nil, ErrPointAtInfinity
+		
+	case 0x02, 0x03:
 		// Compressed point
 		if len(encoded) != 1+byteLen {
-			return nil
+			return nil, fmt.Errorf("%w: compressed point of %d bytes", ErrInvalidPointEncoding, len(encoded))
 		}
-		
-		yTilde := typ & 1
-		xBytes := encoded[1:]
-		x := new(big.Int).SetBytes(xBytes)
-		xField := NewFp(curve.p, x)
+		x, err := decodeCoordinate(curve, encoded[1:])
+		if err != nil {
+			return nil, err
+		}
+		xField := curve.FromBigInteger(x)
 		
 		// Compute y^2 = x^3 + ax + b
 		x2 := xField.Square()
@@ -285,41 +297,54 @@ func DecodePoint(curve *Curve, encoded []byte) *Point {
 		// Find square root
 		beta := alpha.Sqrt()
 		if beta == nil {
-			return nil
+			return nil, fmt.Errorf("%w: no y for compressed x", ErrPointNotOnCurve)
 		}
 		
-		// Select correct root based on yTilde
-		bit0 := byte(0)
-		if beta.TestBitZero() {
-			bit0 = 1
-		}
-		
-		if bit0 != yTilde {
+		// Select correct root based on yTilde; y = 0 has only one
+		if beta.TestBitZero() != (typ == 0x03) {
 			beta = beta.Negate()
+			if beta.TestBitZero() != (typ == 0x03) {
+				return nil, fmt.Errorf("%w: invalid point compression", ErrInvalidPointEncoding)
+			}
 		}
+		point = NewPoint(curve, xField, beta)
 		
-		return NewPoint(curve, xField, beta)
-	}
-	
-	if typ == 0x04 {
-		// Uncompressed point
+	case 0x04, 0x06, 0x07:
+		// Uncompressed or hybrid point
 		if len(encoded) != 1+2*byteLen {
-			return nil
+			return nil, fmt.Errorf("%w: uncompressed point of %d bytes", ErrInvalidPointEncoding, len(encoded))
 		}
+		x, err := decodeCoordinate(curve, encoded[1:1+byteLen])
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeCoordinate(curve, encoded[1+byteLen:])
+		if err != nil {
+			return nil, err
+		}
+		if typ != 0x04 && (y.Bit(0) == 1) != (typ == 0x07) {
+			return nil, fmt.Errorf("%w: hybrid prefix does not match y", ErrInvalidPointEncoding)
+		}
+		point = curve.CreatePoint(x, y)
 		
-		xBytes := encoded[1 : 1+byteLen]
-		yBytes := encoded[1+byteLen:]
-		
-		x := new(big.Int).SetBytes(xBytes)
-		y := new(big.Int).SetBytes(yBytes)
-		
-		xField := NewFp(curve.p, x)
-		yField := NewFp(curve.p, y)
-		
-		return NewPoint(curve, xField, yField)
+	default:
+		return nil, fmt.Errorf("%w: unknown prefix 0x%02x", ErrInvalidPointEncoding, typ)
 	}
 	
-	return nil
+	if err := curve.CheckPoint(point); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// decodeCoordinate decodes a big-endian field element, which must be less
+// than p.
+func decodeCoordinate(curve *Curve, b []byte) (*big.Int, error) {
+	v := new(big.Int).SetBytes(b)
+	if v.Cmp(curve.p) >= 0 {
+		return nil, fmt.Errorf("%w: coordinate not less than p", ErrInvalidPointEncoding)
+	}
+	return v, nil
 }
 
 // String returns a string representation of the point.
@@ -0,0 +1,269 @@
+package ec
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// newTestSM2Curve builds the sm2p256v1 curve from its published parameters.
+func newTestSM2Curve() *Curve {
+	p := fromHexString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF")
+	a := fromHexString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFC")
+	b := fromHexString("28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93")
+	n := fromHexString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123")
+	gx := fromHexString("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7")
+	gy := fromHexString("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0")
+
+	curve := NewCurve(p, a, b, n, 1)
+	curve.SetG(curve.CreatePoint(gx, gy))
+	return curve
+}
+
+// newTestFp256Curve builds the 256-bit Fp test curve from GM/T 0003 Annex A,
+// whose coefficient a is not p-3.
+func newTestFp256Curve() *Curve {
+	p := fromHexString("8542D69E4C044F18E8B92435BF6FF7DE457283915C45517D722EDB8B08F1DFC3")
+	a := fromHexString("787968B4FA32C3FD2417842E73BBFEFF2F3C848B6831D7E0EC65228B3937E498")
+	b := fromHexString("63E4C6D3B23B0C849CF84241484BFE48F61D59A5B16BA06E6E12D1DA27C5249A")
+	n := fromHexString("8542D69E4C044F18E8B92435BF6FF7DD297720630485628D5AE74EE7C32E79B7")
+	gx := fromHexString("421DEBD61B62EAB6746434EBC3CC315E32220B3BADD50BDC4C4E6C147FEDD43D")
+	gy := fromHexString("0680512BCBB42C07D47349D2153B70C4E5D7FDFCBFA36EA1A85841B9E46E09A2")
+
+	curve := NewCurve(p, a, b, n, 1)
+	curve.SetG(curve.CreatePoint(gx, gy))
+	return curve
+}
+
+func fromHexString(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 16)
+	return n
+}
+
+func TestMultiplySecretMatchesMultiply(t *testing.T) {
+	for name, curve := range map[string]*Curve{
+		"sm2p256v1": newTestSM2Curve(),
+		"fp256test": newTestFp256Curve(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := curve.GetG()
+			if !g.IsValid() {
+				t.Fatal("Base point is not on the curve")
+			}
+
+			for i := 0; i < 10; i++ {
+				k, err := rand.Int(rand.Reader, curve.GetOrder())
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := g.Multiply(k)
+				actual := g.MultiplySecret(k)
+				if !expected.Equals(actual) {
+					t.Fatalf("MultiplySecret mismatch for k=%x", k)
+				}
+				if !actual.IsValid() {
+					t.Fatalf("Result is not on the curve for k=%x", k)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiplySecretEdgeCases(t *testing.T) {
+	curve := newTestSM2Curve()
+	g := curve.GetG()
+	n := curve.GetOrder()
+
+	// 0*G and n*G are infinity
+	if !g.MultiplySecret(big.NewInt(0)).IsInfinity() {
+		t.Error("0*G should be infinity")
+	}
+	if !g.MultiplySecret(n).IsInfinity() {
+		t.Error("n*G should be infinity")
+	}
+
+	// 1*G = G, 2*G = G.Twice()
+	if !g.MultiplySecret(big.NewInt(1)).Equals(g) {
+		t.Error("1*G should equal G")
+	}
+	if !g.MultiplySecret(big.NewInt(2)).Equals(g.Twice()) {
+		t.Error("2*G should equal G.Twice()")
+	}
+
+	// (n-1)*G = -G
+	nMinus1 := new(big.Int).Sub(n, big.NewInt(1))
+	if !g.MultiplySecret(nMinus1).Equals(g.Negate()) {
+		t.Error("(n-1)*G should equal -G")
+	}
+
+	// (-k)*G = -(k*G)
+	k := big.NewInt(123456789)
+	negK := new(big.Int).Neg(k)
+	if !g.MultiplySecret(negK).Equals(g.MultiplySecret(k).Negate()) {
+		t.Error("(-k)*G should equal -(k*G)")
+	}
+
+	// Scalars larger than n still multiply correctly
+	kPlusN := new(big.Int).Add(k, n)
+	if !g.MultiplySecret(kPlusN).Equals(g.MultiplySecret(k)) {
+		t.Error("(k+n)*G should equal k*G")
+	}
+
+	// Infinity stays infinity
+	if !curve.GetInfinity().MultiplySecret(k).IsInfinity() {
+		t.Error("k*O should be infinity")
+	}
+}
+
+func TestMultiplySecretReducesScalar(t *testing.T) {
+	curve := newTestSM2Curve()
+	n := curve.GetOrder()
+	// Not the base point, so the windowed path is used
+	p := curve.GetG().Multiply(big.NewInt(7))
+	k := big.NewInt(123456789)
+	expected := p.Multiply(k)
+
+	if !p.MultiplySecret(big.NewInt(0)).IsInfinity() {
+		t.Error("0*P should be infinity")
+	}
+	if !p.MultiplySecret(n).IsInfinity() {
+		t.Error("n*P should be infinity")
+	}
+	if !p.MultiplySecret(new(big.Int).Sub(k, n)).Equals(expected) {
+		t.Error("(k-n)*P should equal k*P")
+	}
+
+	// Scalars well beyond the order give the same result as k mod n
+	long := new(big.Int).Add(k, new(big.Int).Lsh(n, 200))
+	if !p.MultiplySecret(long).Equals(expected) {
+		t.Error("(k+n*2^200)*P should equal k*P")
+	}
+	if !SumOfTwoMultipliesSecret(p, long, p, new(big.Int).Neg(k)).IsInfinity() {
+		t.Error("(k+n*2^200)*P - k*P should be infinity")
+	}
+}
+
+func TestScalarMultUsesSecretPath(t *testing.T) {
+	curve := newTestSM2Curve()
+	k := fromHexString("128B2FA8BD433C6C068C8D803DFF79792A519A55171B1B650C23661D15897263")
+
+	expected := curve.GetG().Multiply(k)
+	if !curve.ScalarBaseMult(k.Bytes()).Equals(expected) {
+		t.Error("ScalarBaseMult mismatch")
+	}
+	if !curve.ScalarMult(curve.GetG(), k.Bytes()).Equals(expected) {
+		t.Error("ScalarMult mismatch")
+	}
+}
+
+func TestDecodePoint(t *testing.T) {
+	for _, curve := range []*Curve{newTestSM2Curve(), newTestFp256Curve()} {
+		p := curve.GetG().Multiply(big.NewInt(1234567))
+		uncompressed := p.GetEncoded(false)
+		hybrid := append([]byte{}, uncompressed...)
+		hybrid[0] = 0x06
+		if p.GetYCoord().TestBitZero() {
+			hybrid[0] = 0x07
+		}
+
+		for _, encoded := range [][]byte{uncompressed, p.GetEncoded(true), hybrid} {
+			q, err := curve.DecodePoint(encoded)
+			if err != nil {
+				t.Errorf("DecodePoint(%x) failed: %v", encoded[:1], err)
+			} else if !q.Equals(p) {
+				t.Errorf("DecodePoint(%x) returned another point", encoded[:1])
+			}
+		}
+	}
+
+	curve := newTestSM2Curve()
+	g := curve.GetG().GetEncoded(false)
+	modify := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte{}, g...))
+	}
+	wrongHybrid := modify(func(b []byte) []byte {
+		b[0] = 0x06
+		if !curve.GetG().GetYCoord().TestBitZero() {
+			b[0] = 0x07
+		}
+		return b
+	})
+	unreduced := modify(func(b []byte) []byte {
+		x := new(big.Int).Add(curve.GetG().X, curve.GetP())
+		if x.BitLen() > 256 {
+			// x + p overflows 32 bytes; use p itself
+			x = curve.GetP()
+		}
+		x.FillBytes(b[1:33])
+		return b
+	})
+	offCurve := modify(func(b []byte) []byte {
+		b[64] ^= 0x01
+		return b
+	})
+	// An x for which x^3 + ax + b is not a square
+	noY := make([]byte, 33)
+	noY[0] = 0x02
+	for x := int64(1); ; x++ {
+		rhs := new(big.Int).Exp(big.NewInt(x), big.NewInt(3), nil)
+		rhs.Add(rhs, new(big.Int).Mul(curve.GetA().ToBigInt(), big.NewInt(x)))
+		rhs.Add(rhs, curve.GetB().ToBigInt()).Mod(rhs, curve.GetP())
+		if big.Jacobi(rhs, curve.GetP()) == -1 {
+			big.NewInt(x).FillBytes(noY[1:])
+			break
+		}
+	}
+
+	tests := []struct {
+		name    string
+		encoded []byte
+		want    error
+	}{
+		{"Empty", nil, ErrInvalidPointEncoding},
+		{"Infinity", []byte{0x00}, ErrPointAtInfinity},
+		{"LongInfinity", []byte{0x00, 0x00}, ErrInvalidPointEncoding},
+		{"UnknownPrefix", modify(func(b []byte) []byte { b[0] = 0x05; return b }), ErrInvalidPointEncoding},
+		{"Short", g[:64], ErrInvalidPointEncoding},
+		{"ShortCompressed", curve.GetG().GetEncoded(true)[:32], ErrInvalidPointEncoding},
+		{"WrongHybridParity", wrongHybrid, ErrInvalidPointEncoding},
+		{"UnreducedX", unreduced, ErrInvalidPointEncoding},
+		{"OffCurve", offCurve, ErrPointNotOnCurve},
+		{"CompressedNoY", noY, ErrPointNotOnCurve},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := curve.DecodePoint(tt.encoded)
+			if p != nil || !errors.Is(err, tt.want) {
+				t.Errorf("DecodePoint = %v, %v; want error %v", p, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPointSubgroup(t *testing.T) {
+	// y^2 = x^3 + x + 1 over F23 has 28 points; (3, 10) generates the whole
+	// group, and [4](3, 10) the subgroup of order 7
+	curve := NewCurve(big.NewInt(23), big.NewInt(1), big.NewInt(1), big.NewInt(7), 4)
+	generator := curve.CreatePoint(big.NewInt(3), big.NewInt(10))
+	curve.SetG(generator.Multiply(big.NewInt(4)))
+
+	if err := curve.CheckPoint(curve.GetG()); err != nil {
+		t.Errorf("CheckPoint(G) failed: %v", err)
+	}
+	if _, err := curve.DecodePoint(curve.GetG().GetEncoded(true)); err != nil {
+		t.Errorf("DecodePoint(G) failed: %v", err)
+	}
+	if err := curve.CheckPoint(generator); !errors.Is(err, ErrPointNotInSubgroup) {
+		t.Errorf("CheckPoint of a point of order 28 = %v", err)
+	}
+	if _, err := curve.DecodePoint(generator.GetEncoded(false)); !errors.Is(err, ErrPointNotInSubgroup) {
+		t.Errorf("DecodePoint of a point of order 28 = %v", err)
+	}
+	if err := curve.CheckPoint(curve.GetInfinity()); !errors.Is(err, ErrPointAtInfinity) {
+		t.Errorf("CheckPoint(O) = %v", err)
+	}
+	if err := curve.CheckPoint(newTestSM2Curve().GetG()); !errors.Is(err, ErrPointNotOnCurve) {
+		t.Errorf("CheckPoint of a point on another curve = %v", err)
+	}
+}
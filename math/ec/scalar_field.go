@@ -0,0 +1,61 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+)
+
+// ScalarField performs arithmetic modulo the order n of a curve's base point
+// on fixed-width limbs, so that computations on private keys and nonces
+// (such as SM2's (1 + d)^-1) do not leak through timing. Inputs may be any
+// integer; results are always in [0, n-1].
+//
+// Converting to and from big.Int is not constant-time, but the modular
+// reduction, multiplication and inversion themselves are.
+type ScalarField struct {
+	n *big.Int
+	f fieldArith
+}
+
+// newScalarField creates the scalar field for the odd order n, using the
+// dedicated 4-limb implementation for the sm2p256v1 order.
+func newScalarField(n *big.Int) *ScalarField {
+	if n.Cmp(sm2P256V1N) == 0 {
+		return &ScalarField{n: sm2P256V1N, f: sm2P256V1Scalar}
+	}
+	return &ScalarField{n: new(big.Int).Set(n), f: newMontField(n)}
+}
+
+// GetOrder returns the modulus n.
+func (s *ScalarField) GetOrder() *big.Int {
+	return new(big.Int).Set(s.n)
+}
+
+// Add returns (a + b) mod n.
+func (s *ScalarField) Add(a, b *big.Int) *big.Int {
+	z := s.f.newElement()
+	s.f.add(z, s.f.fromBig(a), s.f.fromBig(b))
+	return s.f.toBig(z)
+}
+
+// Sub returns (a - b) mod n.
+func (s *ScalarField) Sub(a, b *big.Int) *big.Int {
+	z := s.f.newElement()
+	s.f.sub(z, s.f.fromBig(a), s.f.fromBig(b))
+	return s.f.toBig(z)
+}
+
+// Mul returns (a · b) mod n.
+func (s *ScalarField) Mul(a, b *big.Int) *big.Int {
+	z := s.f.newElement()
+	s.f.mul(z, s.f.fromBig(a), s.f.fromBig(b))
+	return s.f.toBig(z)
+}
+
+// Inverse returns a^-1 mod n, computed as a^(n-2) since n is prime.
+// The inverse of zero is returned as zero.
+func (s *ScalarField) Inverse(a *big.Int) *big.Int {
+	z := s.f.newElement()
+	s.f.inv(z, s.f.fromBig(a))
+	return s.f.toBig(z)
+}
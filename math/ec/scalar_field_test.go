@@ -0,0 +1,55 @@
+package ec
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestScalarFieldArithmetic(t *testing.T) {
+	for name, curve := range map[string]*Curve{
+		"sm2p256v1": newTestSM2Curve(),
+		"fp256test": newTestFp256Curve(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			sf := curve.GetScalarField()
+			if sf == nil {
+				t.Fatal("Scalar field not available")
+			}
+			n := curve.GetOrder()
+			if sf.GetOrder().Cmp(n) != 0 {
+				t.Fatal("Scalar field order mismatch")
+			}
+
+			for i := 0; i < 50; i++ {
+				a, _ := rand.Int(rand.Reader, n)
+				b, _ := rand.Int(rand.Reader, n)
+
+				expected := new(big.Int).Add(a, b)
+				if sf.Add(a, b).Cmp(expected.Mod(expected, n)) != 0 {
+					t.Fatalf("Add mismatch for a=%x b=%x", a, b)
+				}
+				expected = new(big.Int).Sub(a, b)
+				if sf.Sub(a, b).Cmp(expected.Mod(expected, n)) != 0 {
+					t.Fatalf("Sub mismatch for a=%x b=%x", a, b)
+				}
+				expected = new(big.Int).Mul(a, b)
+				if sf.Mul(a, b).Cmp(expected.Mod(expected, n)) != 0 {
+					t.Fatalf("Mul mismatch for a=%x b=%x", a, b)
+				}
+				if a.Sign() != 0 && sf.Inverse(a).Cmp(new(big.Int).ModInverse(a, n)) != 0 {
+					t.Fatalf("Inverse mismatch for a=%x", a)
+				}
+			}
+
+			// Inputs outside [0, n-1] are reduced
+			x := new(big.Int).Add(n, big.NewInt(3))
+			if sf.Mul(x, big.NewInt(-1)).Cmp(new(big.Int).Sub(n, big.NewInt(3))) != 0 {
+				t.Error("Unreduced inputs not handled")
+			}
+			if sf.Inverse(big.NewInt(0)).Sign() != 0 {
+				t.Error("Inverse of zero should be zero")
+			}
+		})
+	}
+}
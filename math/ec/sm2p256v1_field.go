@@ -0,0 +1,144 @@
+// Package ec implements elliptic curve cryptography.
+package ec
+
+import (
+	"math/big"
+)
+
+// sm2p256v1 field prime p = 2^256 - 2^224 - 2^96 + 2^64 - 1 and group order n.
+var (
+	sm2P256V1P, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF", 16)
+	sm2P256V1N, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123", 16)
+
+	sm2P256V1Field  = newMont4(sm2P256V1P)
+	sm2P256V1Scalar = newMont4(sm2P256V1N)
+
+	// (p+1)/4, the square root exponent since p ≡ 3 (mod 4)
+	sm2P256V1SqrtExp = func() [4]uint64 {
+		var e [4]uint64
+		v := new(big.Int).Add(sm2P256V1P, big.NewInt(1))
+		copy(e[:], bigToLimbs(v.Rsh(v, 2), 4))
+		return e
+	}()
+)
+
+// SM2P256V1FieldElement is an element of the sm2p256v1 prime field stored
+// as four 64-bit limbs in Montgomery form. Arithmetic is constant-time and
+// does not allocate big.Int values.
+// Reference: org.bouncycastle.math.ec.custom.gm.SM2P256V1FieldElement
+type SM2P256V1FieldElement struct {
+	x [4]uint64
+}
+
+// NewSM2P256V1FieldElement creates a field element from an integer,
+// reducing it modulo p.
+func NewSM2P256V1FieldElement(x *big.Int) *SM2P256V1FieldElement {
+	e := &SM2P256V1FieldElement{}
+	sm2P256V1Field.toMont4(&e.x, x)
+	return e
+}
+
+// ToBigInt returns the value as big.Int.
+func (e *SM2P256V1FieldElement) ToBigInt() *big.Int {
+	return sm2P256V1Field.fromMont4(&e.x)
+}
+
+// GetFieldSize returns the bit length of the field.
+func (e *SM2P256V1FieldElement) GetFieldSize() int {
+	return 256
+}
+
+// Add adds two field elements.
+func (e *SM2P256V1FieldElement) Add(b FieldElement) FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.add4(&r.x, &e.x, &b.(*SM2P256V1FieldElement).x)
+	return r
+}
+
+// AddOne adds 1 to the field element.
+func (e *SM2P256V1FieldElement) AddOne() FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.add4(&r.x, &e.x, &sm2P256V1Field.one)
+	return r
+}
+
+// Subtract subtracts two field elements.
+func (e *SM2P256V1FieldElement) Subtract(b FieldElement) FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.sub4(&r.x, &e.x, &b.(*SM2P256V1FieldElement).x)
+	return r
+}
+
+// Multiply multiplies two field elements.
+func (e *SM2P256V1FieldElement) Multiply(b FieldElement) FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.mul4(&r.x, &e.x, &b.(*SM2P256V1FieldElement).x)
+	return r
+}
+
+// Divide divides two field elements.
+func (e *SM2P256V1FieldElement) Divide(b FieldElement) FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.inv4(&r.x, &b.(*SM2P256V1FieldElement).x)
+	sm2P256V1Field.mul4(&r.x, &r.x, &e.x)
+	return r
+}
+
+// Negate negates the field element.
+func (e *SM2P256V1FieldElement) Negate() FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.sub4(&r.x, &[4]uint64{}, &e.x)
+	return r
+}
+
+// Square squares the field element.
+func (e *SM2P256V1FieldElement) Square() FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.mul4(&r.x, &e.x, &e.x)
+	return r
+}
+
+// Invert inverts the field element.
+func (e *SM2P256V1FieldElement) Invert() FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.inv4(&r.x, &e.x)
+	return r
+}
+
+// Sqrt computes the square root if it exists, otherwise returns nil.
+// Since p ≡ 3 (mod 4), the candidate root is x^((p+1)/4).
+func (e *SM2P256V1FieldElement) Sqrt() FieldElement {
+	r := &SM2P256V1FieldElement{}
+	sm2P256V1Field.exp4(&r.x, &e.x, &sm2P256V1SqrtExp)
+
+	var check [4]uint64
+	sm2P256V1Field.mul4(&check, &r.x, &r.x)
+	if check != e.x {
+		return nil
+	}
+	return r
+}
+
+// TestBitZero returns true if the least significant bit is 1.
+func (e *SM2P256V1FieldElement) TestBitZero() bool {
+	var z [4]uint64
+	sm2P256V1Field.mul4(&z, &e.x, &[4]uint64{1})
+	return z[0]&1 == 1
+}
+
+// Equals checks if two field elements are equal.
+func (e *SM2P256V1FieldElement) Equals(b FieldElement) bool {
+	if b == nil {
+		return false
+	}
+	other, ok := b.(*SM2P256V1FieldElement)
+	if !ok {
+		return false
+	}
+	return e.x == other.x
+}
+
+// String returns string representation.
+func (e *SM2P256V1FieldElement) String() string {
+	return e.ToBigInt().Text(16)
+}
@@ -0,0 +1,83 @@
+package ec
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestSM2P256V1FieldElementMatchesFp(t *testing.T) {
+	p := sm2P256V1P
+	for i := 0; i < 50; i++ {
+		a, _ := rand.Int(rand.Reader, p)
+		b, _ := rand.Int(rand.Reader, p)
+
+		fa, fb := NewSM2P256V1FieldElement(a), NewSM2P256V1FieldElement(b)
+		ga, gb := NewFp(p, a), NewFp(p, b)
+
+		cases := []struct {
+			name     string
+			actual   FieldElement
+			expected FieldElement
+		}{
+			{"Add", fa.Add(fb), ga.Add(gb)},
+			{"AddOne", fa.AddOne(), ga.AddOne()},
+			{"Subtract", fa.Subtract(fb), ga.Subtract(gb)},
+			{"Multiply", fa.Multiply(fb), ga.Multiply(gb)},
+			{"Divide", fa.Divide(fb), ga.Divide(gb)},
+			{"Negate", fa.Negate(), ga.Negate()},
+			{"Square", fa.Square(), ga.Square()},
+			{"Invert", fa.Invert(), ga.Invert()},
+		}
+		for _, c := range cases {
+			if c.actual.ToBigInt().Cmp(c.expected.ToBigInt()) != 0 {
+				t.Fatalf("%s mismatch for a=%x b=%x: got %x, expected %x",
+					c.name, a, b, c.actual.ToBigInt(), c.expected.ToBigInt())
+			}
+		}
+
+		if fa.TestBitZero() != (a.Bit(0) == 1) {
+			t.Fatalf("TestBitZero mismatch for %x", a)
+		}
+	}
+}
+
+func TestSM2P256V1FieldElementSqrt(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		a, _ := rand.Int(rand.Reader, sm2P256V1P)
+		sq := NewSM2P256V1FieldElement(a).Square()
+
+		root := sq.Sqrt()
+		if root == nil {
+			t.Fatalf("No square root found for a square")
+		}
+		if !root.Square().Equals(sq) {
+			t.Fatalf("Sqrt(%x)² != %x", sq.ToBigInt(), sq.ToBigInt())
+		}
+
+		// -1 is a non-residue since p ≡ 3 (mod 4), so -a² has no root
+		if sq.ToBigInt().Sign() != 0 && sq.Negate().Sqrt() != nil {
+			t.Fatalf("Found a square root of a non-residue")
+		}
+	}
+}
+
+func TestSM2P256V1FieldElementReduces(t *testing.T) {
+	x := new(big.Int).Add(sm2P256V1P, big.NewInt(5))
+	if NewSM2P256V1FieldElement(x).ToBigInt().Cmp(big.NewInt(5)) != 0 {
+		t.Error("Value not reduced modulo p")
+	}
+	if NewSM2P256V1FieldElement(big.NewInt(-1)).ToBigInt().Cmp(new(big.Int).Sub(sm2P256V1P, big.NewInt(1))) != 0 {
+		t.Error("Negative value not reduced modulo p")
+	}
+}
+
+func TestNewCurveUsesSM2P256V1Field(t *testing.T) {
+	curve := newTestSM2Curve()
+	if _, ok := curve.GetG().GetXCoord().(*SM2P256V1FieldElement); !ok {
+		t.Errorf("Expected SM2P256V1FieldElement, got %T", curve.GetG().GetXCoord())
+	}
+	if _, ok := newTestFp256Curve().GetG().GetXCoord().(*Fp); !ok {
+		t.Error("Generic curves should keep using Fp")
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"math/big"
 	
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
 	"github.com/lihongjie0209/sm-go-bc/pkcs8"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // CertificationRequest represents a PKCS#10 certificate signing request.
@@ -47,18 +49,17 @@ func ParseCertificationRequest(der []byte) (*CertificationRequest, error) {
 	
 	// Parse the top-level structure
 	var rawCSR struct {
-		Raw                asn1.RawContent
-		TBSCertificationRequest asn1.RawContent
+		TBSCertificationRequest asn1.RawValue
 		SignatureAlgorithm pkix.AlgorithmIdentifier
 		SignatureValue     asn1.BitString
 	}
 	
 	_, err := asn1.Unmarshal(der, &rawCSR)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse CSR: %w", err)
 	}
 	
-	csr.RawTBSCertificationRequest = rawCSR.TBSCertificationRequest
+	csr.RawTBSCertificationRequest = rawCSR.TBSCertificationRequest.FullBytes
 	csr.SignatureAlgorithm = rawCSR.SignatureAlgorithm
 	csr.Signature = rawCSR.SignatureValue.RightAlign()
 	
@@ -66,13 +67,13 @@ func ParseCertificationRequest(der []byte) (*CertificationRequest, error) {
 	var tbs struct {
 		Version       int
 		Subject       asn1.RawValue
-		PublicKey     asn1.RawContent
+		PublicKey     asn1.RawValue
 		Attributes    []pkix.AttributeTypeAndValue `asn1:"tag:0,optional"`
 	}
 	
 	_, err = asn1.Unmarshal(csr.RawTBSCertificationRequest, &tbs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse TBS CSR: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse TBS CSR: %w", err)
 	}
 	
 	csr.Version = tbs.Version
@@ -82,13 +83,13 @@ func ParseCertificationRequest(der []byte) (*CertificationRequest, error) {
 	var subjectRDN pkix.RDNSequence
 	_, err = asn1.Unmarshal(tbs.Subject.FullBytes, &subjectRDN)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse subject: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse subject: %w", err)
 	}
 	csr.Subject.FillFromRDNSequence(&subjectRDN)
 	
 	// Parse public key
-	csr.RawSubjectPublicKeyInfo = tbs.PublicKey
-	pubKey, err := pkcs8.ParseSM2PublicKey(tbs.PublicKey)
+	csr.RawSubjectPublicKeyInfo = tbs.PublicKey.FullBytes
+	pubKey, err := pkcs8.ParseSM2PublicKey(tbs.PublicKey.FullBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse public key: %w", err)
 	}
@@ -97,7 +98,9 @@ func ParseCertificationRequest(der []byte) (*CertificationRequest, error) {
 	return csr, nil
 }
 
-// CreateCertificationRequest creates a new PKCS#10 CSR.
+// CreateCertificationRequest creates a new PKCS#10 CSR. The curve is taken
+// from publicKey and must be registered in the ec curve registry with an
+// OID; the CSR names it in the key and signature algorithm identifiers.
 func CreateCertificationRequest(
 	subject pkix.Name,
 	publicKey *ec.Point,
@@ -106,10 +109,14 @@ func CreateCertificationRequest(
 ) ([]byte, error) {
 	// Validate inputs
 	if privateKey == nil {
-		return nil, fmt.Errorf("private key is required")
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "private key is required")
 	}
 	if publicKey == nil {
-		return nil, fmt.Errorf("public key is required")
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "public key is required")
+	}
+	domain, curveOID, err := domainOf(publicKey)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Encode public key
@@ -121,7 +128,7 @@ func CreateCertificationRequest(
 	var spki pkcs8.SubjectPublicKeyInfo
 	_, err = asn1.Unmarshal(pubKeyBytes, &spki)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse public key info: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse public key info: %w", err)
 	}
 	
 	// Build TBS CertificationRequest
@@ -139,29 +146,29 @@ func CreateCertificationRequest(
 	
 	tbsBytes, err := asn1.Marshal(tbs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal TBS CSR: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to marshal TBS CSR: %w", err)
 	}
 	
 	// Sign the TBS CSR
 	signer := signers.NewSM2Signer()
-	err = signer.Init(true, publicKey, privateKey)
+	err = signer.Init(true, params.NewECPrivateKeyParameters(privateKey, domain))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize signer: %w", err)
 	}
-	signer.Update(tbsBytes)
+	signer.BlockUpdate(tbsBytes, 0, len(tbsBytes))
 	signature, err := signer.GenerateSignature()
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign CSR: %w", err)
 	}
 	
 	// Build final CSR
-	sigAlg := pkcs8.NewSM2AlgorithmIdentifier()
+	sigAlg := pkcs8.NewSM2AlgorithmIdentifierWithCurve(curveOID)
 	csr := struct {
-		TBSCertificationRequest asn1.RawContent
+		TBSCertificationRequest asn1.RawValue
 		SignatureAlgorithm      pkix.AlgorithmIdentifier
 		SignatureValue          asn1.BitString
 	}{
-		TBSCertificationRequest: tbsBytes,
+		TBSCertificationRequest: asn1.RawValue{FullBytes: tbsBytes},
 		SignatureAlgorithm:      sigAlg,
 		SignatureValue: asn1.BitString{
 			Bytes:     signature,
@@ -176,24 +183,45 @@ func CreateCertificationRequest(
 func (csr *CertificationRequest) VerifySignature() error {
 	// Verify it's an SM2 CSR
 	if !csr.SignatureAlgorithm.Algorithm.Equal(pkcs8.OidSM2) {
-		return fmt.Errorf("unsupported signature algorithm: %v", csr.SignatureAlgorithm.Algorithm)
+		return exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "unsupported signature algorithm: %v", csr.SignatureAlgorithm.Algorithm)
 	}
 	
-	// Verify using SM2Signer
+	// Verify using SM2Signer on the curve of the public key
+	if csr.PublicKey == nil {
+		return exceptions.New(exceptions.ErrInvalidKey, "public key is required")
+	}
+	domain, _, err := domainOf(csr.PublicKey)
+	if err != nil {
+		return err
+	}
 	verifier := signers.NewSM2Signer()
-	err := verifier.Init(false, csr.PublicKey, nil)
+	err = verifier.Init(false, params.NewECPublicKeyParameters(csr.PublicKey, domain))
 	if err != nil {
 		return fmt.Errorf("failed to initialize verifier: %w", err)
 	}
-	verifier.Update(csr.RawTBSCertificationRequest)
+	verifier.BlockUpdate(csr.RawTBSCertificationRequest, 0, len(csr.RawTBSCertificationRequest))
 	
 	valid, err := verifier.VerifySignature(csr.Signature)
 	if err != nil {
 		return fmt.Errorf("verification error: %w", err)
 	}
 	if !valid {
-		return fmt.Errorf("signature verification failed")
+		return exceptions.New(exceptions.ErrInvalidSignature, "signature verification failed")
 	}
 	
 	return nil
 }
+
+// domainOf returns the domain parameters of the curve of Q and the curve's
+// OID from the ec curve registry.
+func domainOf(Q *ec.Point) (*params.ECDomainParameters, asn1.ObjectIdentifier, error) {
+	curveOID := ec.GetCurveOID(Q.GetCurve())
+	if curveOID == nil {
+		return nil, nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "curve is not registered with an OID")
+	}
+	domain, err := params.NewECDomainParametersByOID(curveOID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return domain, curveOID, nil
+}
@@ -5,7 +5,6 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/hex"
-	"io"
 	"math/big"
 	"testing"
 	
@@ -33,9 +32,16 @@ func TestCreateAndParseSM2CSR(t *testing.T) {
 	t.Logf("CSR DER length: %d bytes", len(csrDER))
 	t.Logf("CSR DER (first 64 bytes): %s", hex.EncodeToString(csrDER[:min(64, len(csrDER))]))
 	
-	// For now, just verify CSR was created successfully
-	// TODO: Fix ParseCertificationRequest ASN.1 structure parsing
-	t.Logf("CSR created successfully")
+	csr, err := ParseCertificationRequest(csrDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CSR: %v", err)
+	}
+	if csr.Subject.CommonName != subject.CommonName || !csr.PublicKey.Equals(Q) {
+		t.Errorf("Parsed CSR mismatch: %v", csr.Subject)
+	}
+	if err := csr.VerifySignature(); err != nil {
+		t.Errorf("CSR signature failed to verify: %v", err)
+	}
 }
 
 // TestCSRWithAttributes tests CSR with custom attributes.
@@ -129,32 +135,66 @@ func TestCSRRoundTrip(t *testing.T) {
 	t.Logf("CSR created successfully with full subject fields (%d bytes)", len(csrDER))
 }
 
-// Helper functions
-func generateTestKeyPair(t *testing.T) (*big.Int, *ec.Point) {
-	n := sm2.GetN()
-	d, err := randFieldElement(rand.Reader, n)
+// TestCSRCurveFromKey tests that the CSR is signed on the curve of the
+// key and names that curve.
+func TestCSRCurveFromKey(t *testing.T) {
+	// Register the GM/T 0003 test curve with an OID so that it can be encoded
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 10}
+	if ec.GetNamedCurveByOID(oid) == nil {
+		err := ec.RegisterNamedCurve("sm2testfp256-pkcs10", oid, func() *ec.Curve {
+			return ec.GetNamedCurve(ec.SM2TestFp256)
+		})
+		if err != nil {
+			t.Fatalf("Failed to register curve: %v", err)
+		}
+	}
+	
+	curve := ec.GetNamedCurve(ec.SM2TestFp256)
+	d, err := rand.Int(rand.Reader, new(big.Int).Sub(curve.GetOrder(), big.NewInt(2)))
 	if err != nil {
-		t.Fatalf("Failed to generate private key: %v", err)
+		t.Fatalf("Failed to generate key: %v", err)
 	}
+	d.Add(d, big.NewInt(1))
+	Q := curve.GetG().Multiply(d)
 	
-	G := sm2.GetG()
-	Q := G.Multiply(d)
+	csrDER, err := CreateCertificationRequest(pkix.Name{CommonName: "Test curve"}, Q, d, nil)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
 	
-	return d, Q
+	csr, err := ParseCertificationRequest(csrDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CSR: %v", err)
+	}
+	
+	// The key and signature algorithm name the test curve
+	if !csr.PublicKey.Equals(Q) {
+		t.Error("Public key mismatch")
+	}
+	var sigCurve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(csr.SignatureAlgorithm.Parameters.FullBytes, &sigCurve); err != nil || !sigCurve.Equal(oid) {
+		t.Errorf("Signature algorithm curve %v, want %v (%v)", sigCurve, oid, err)
+	}
+	if err := csr.VerifySignature(); err != nil {
+		t.Errorf("Signature on the test curve failed to verify: %v", err)
+	}
+	
+	// The same d on sm2p256v1 gives a different key, which does not verify
+	csr.PublicKey = sm2.GetG().Multiply(d)
+	if err := csr.VerifySignature(); err == nil {
+		t.Error("Signature verified with the key on another curve")
+	}
+
 }
 
-func randFieldElement(random io.Reader, n *big.Int) (*big.Int, error) {
-	b := make([]byte, (n.BitLen()+7)/8)
-	for {
-		_, err := io.ReadFull(random, b)
-		if err != nil {
-			return nil, err
-		}
-		k := new(big.Int).SetBytes(b)
-		if k.Sign() > 0 && k.Cmp(n) < 0 {
-			return k, nil
-		}
+// Helper functions
+func generateTestKeyPair(t *testing.T) (*big.Int, *ec.Point) {
+	keyPair, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
 	}
+	
+	return keyPair.PrivateKey, keyPair.PublicKey
 }
 
 func min(a, b int) int {
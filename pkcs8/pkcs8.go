@@ -3,9 +3,9 @@ package pkcs8
 import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
-	"fmt"
 	
 	asn "github.com/lihongjie0209/sm-go-bc/asn1"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // PrivateKeyInfo represents a PKCS#8 private key structure.
@@ -41,7 +41,7 @@ func ParsePrivateKeyInfo(der []byte) (*PrivateKeyInfo, error) {
 	var pki PrivateKeyInfo
 	_, err := asn1.Unmarshal(der, &pki)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse PKCS#8 private key: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse PKCS#8 private key: %w", err)
 	}
 	return &pki, nil
 }
@@ -56,7 +56,7 @@ func ParseSubjectPublicKeyInfo(der []byte) (*SubjectPublicKeyInfo, error) {
 	var spki SubjectPublicKeyInfo
 	_, err := asn1.Unmarshal(der, &spki)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse SubjectPublicKeyInfo: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse SubjectPublicKeyInfo: %w", err)
 	}
 	return &spki, nil
 }
@@ -81,39 +81,49 @@ var (
 	OidSM2Curve = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
 )
 
-// NewSM2AlgorithmIdentifier creates an AlgorithmIdentifier for SM2.
+// NewSM2AlgorithmIdentifier creates an AlgorithmIdentifier for SM2 on
+// sm2p256v1.
 func NewSM2AlgorithmIdentifier() pkix.AlgorithmIdentifier {
-	// SM2 uses the curve OID as the algorithm parameter
-	curveBytes, _ := asn1.Marshal(OidSM2Curve)
-	
-	// Extract just the OID value bytes, skipping the tag (1 byte) and length (1 byte)
-	// DER encoding: TAG | LENGTH | VALUE
-	// For standard OIDs, tag=0x06 and length is usually 1 byte
-	const derHeaderSize = 2 // TAG (1 byte) + LENGTH (1 byte)
-	
+	return NewSM2AlgorithmIdentifierWithCurve(OidSM2Curve)
+}
+
+// NewSM2AlgorithmIdentifierWithCurve creates an AlgorithmIdentifier for SM2
+// on the curve with the given OID.
+func NewSM2AlgorithmIdentifierWithCurve(curveOID asn1.ObjectIdentifier) pkix.AlgorithmIdentifier {
 	return pkix.AlgorithmIdentifier{
 		Algorithm:  OidSM2,
-		Parameters: asn1.RawValue{
-			Tag:   asn.TagObjectIdentifier,
-			Bytes: curveBytes[derHeaderSize:],
-		},
+		Parameters: curveParameters(curveOID),
 	}
 }
 
-// NewSM2PublicKeyAlgorithmIdentifier creates an AlgorithmIdentifier for SM2 public key.
+// NewSM2PublicKeyAlgorithmIdentifier creates an AlgorithmIdentifier for SM2
+// public keys on sm2p256v1.
 func NewSM2PublicKeyAlgorithmIdentifier() pkix.AlgorithmIdentifier {
+	return NewSM2PublicKeyAlgorithmIdentifierWithCurve(OidSM2Curve)
+}
+
+// NewSM2PublicKeyAlgorithmIdentifierWithCurve creates an AlgorithmIdentifier
+// for SM2 public keys on the curve with the given OID.
+func NewSM2PublicKeyAlgorithmIdentifierWithCurve(curveOID asn1.ObjectIdentifier) pkix.AlgorithmIdentifier {
 	// For public keys, we use the encryption OID
-	curveBytes, _ := asn1.Marshal(OidSM2Curve)
+	return pkix.AlgorithmIdentifier{
+		Algorithm:  OidSM2Encryption,
+		Parameters: curveParameters(curveOID),
+	}
+}
+
+// curveParameters encodes a curve OID as AlgorithmIdentifier parameters.
+func curveParameters(curveOID asn1.ObjectIdentifier) asn1.RawValue {
+	// SM2 uses the curve OID as the algorithm parameter
+	curveBytes, _ := asn1.Marshal(curveOID)
 	
 	// Extract just the OID value bytes, skipping the tag (1 byte) and length (1 byte)
 	// DER encoding: TAG | LENGTH | VALUE
-	const derHeaderSize = 2
+	// For standard OIDs, tag=0x06 and length is usually 1 byte
+	const derHeaderSize = 2 // TAG (1 byte) + LENGTH (1 byte)
 	
-	return pkix.AlgorithmIdentifier{
-		Algorithm:  OidSM2Encryption,
-		Parameters: asn1.RawValue{
-			Tag:   asn.TagObjectIdentifier,
-			Bytes: curveBytes[derHeaderSize:],
-		},
+	return asn1.RawValue{
+		Tag:   asn.TagObjectIdentifier,
+		Bytes: curveBytes[derHeaderSize:],
 	}
 }
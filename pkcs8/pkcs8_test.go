@@ -3,14 +3,17 @@ package pkcs8
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/asn1"
 	"encoding/hex"
-	"io"
+	"errors"
 	"math/big"
 	"testing"
 	
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // TestSM2PrivateKeyEncodingDecoding tests PKCS#8 encoding/decoding of SM2 private keys.
@@ -76,6 +79,50 @@ func TestSM2PublicKeyEncodingDecoding(t *testing.T) {
 	}
 }
 
+// TestSM2PublicKeyCompressed tests compressed SubjectPublicKeyInfo encoding
+// and rejection of invalid points.
+func TestSM2PublicKeyCompressed(t *testing.T) {
+	_, Q := generateTestKeyPair(t)
+	
+	der, err := MarshalSM2PublicKeyCompressed(Q)
+	if err != nil {
+		t.Fatalf("Failed to marshal compressed SM2 public key: %v", err)
+	}
+	uncompressed, _ := MarshalSM2PublicKey(Q)
+	if len(der) != len(uncompressed)-32 {
+		t.Errorf("Compressed encoding is %d bytes, uncompressed %d", len(der), len(uncompressed))
+	}
+	
+	parsedQ, err := ParseSM2PublicKey(der)
+	if err != nil {
+		t.Fatalf("Failed to parse compressed SM2 public key: %v", err)
+	}
+	if !parsedQ.Equals(Q) {
+		t.Error("Public key mismatch")
+	}
+	
+	// A point off the curve is rejected with the decoding error
+	encoded := Q.GetEncoded(false)
+	encoded[64] ^= 0x01
+	der, _ = MarshalSubjectPublicKeyInfo(&SubjectPublicKeyInfo{
+		Algorithm: NewSM2PublicKeyAlgorithmIdentifier(),
+		SubjectPublicKey: asn1.BitString{
+			Bytes:     encoded,
+			BitLength: len(encoded) * 8,
+		},
+	})
+	if _, err := ParseSM2PublicKey(der); !errors.Is(err, ec.ErrPointNotOnCurve) {
+		t.Errorf("Off-curve key: got %v, want ec.ErrPointNotOnCurve", err)
+	} else if !errors.Is(err, exceptions.ErrInvalidKey) {
+		t.Errorf("Off-curve key: got %v, want exceptions.ErrInvalidKey", err)
+	}
+	
+	// Malformed DER is an encoding error
+	if _, err := ParseSM2PublicKey(der[:len(der)-1]); !errors.Is(err, exceptions.ErrInvalidEncoding) {
+		t.Errorf("Truncated DER: got %v, want exceptions.ErrInvalidEncoding", err)
+	}
+}
+
 // TestSM2KeyRoundTrip tests that we can sign/verify after encoding/decoding.
 func TestSM2KeyRoundTrip(t *testing.T) {
 	// Generate original key pair
@@ -92,6 +139,9 @@ func TestSM2KeyRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to parse private key: %v", err)
 	}
+	if !decodedQ.Equals(Q) {
+		t.Error("Decoded public key does not match")
+	}
 	
 	// Encode public key
 	pubDER, err := MarshalSM2PublicKey(Q)
@@ -110,11 +160,11 @@ func TestSM2KeyRoundTrip(t *testing.T) {
 	
 	// Sign with decoded private key using SM2Signer
 	signer := signers.NewSM2Signer()
-	err = signer.Init(true, decodedQ, decodedD)
+	err = signer.Init(true, params.NewECPrivateKeyParameters(decodedD, sm2.GetECDomainParameters()))
 	if err != nil {
 		t.Fatalf("Failed to initialize signer: %v", err)
 	}
-	signer.Update(message)
+	signer.BlockUpdate(message, 0, len(message))
 	signature, err := signer.GenerateSignature()
 	if err != nil {
 		t.Fatalf("Failed to sign: %v", err)
@@ -122,11 +172,11 @@ func TestSM2KeyRoundTrip(t *testing.T) {
 	
 	// Verify with decoded public key
 	verifier := signers.NewSM2Signer()
-	err = verifier.Init(false, decodedPubQ, nil)
+	err = verifier.Init(false, params.NewECPublicKeyParameters(decodedPubQ, sm2.GetECDomainParameters()))
 	if err != nil {
 		t.Fatalf("Failed to initialize verifier: %v", err)
 	}
-	verifier.Update(message)
+	verifier.BlockUpdate(message, 0, len(message))
 	valid, err := verifier.VerifySignature(signature)
 	if err != nil {
 		t.Fatalf("Verification error: %v", err)
@@ -137,11 +187,11 @@ func TestSM2KeyRoundTrip(t *testing.T) {
 	
 	// Also verify with original public key
 	verifier2 := signers.NewSM2Signer()
-	err = verifier2.Init(false, Q, nil)
+	err = verifier2.Init(false, params.NewECPublicKeyParameters(Q, sm2.GetECDomainParameters()))
 	if err != nil {
 		t.Fatalf("Failed to initialize verifier2: %v", err)
 	}
-	verifier2.Update(message)
+	verifier2.BlockUpdate(message, 0, len(message))
 	valid2, err := verifier2.VerifySignature(signature)
 	if err != nil {
 		t.Fatalf("Verification2 error: %v", err)
@@ -229,6 +279,65 @@ func TestDeterministicEncoding(t *testing.T) {
 	}
 }
 
+// TestSM2KeyCurves tests that the curve is taken from the key and the
+// encoding.
+func TestSM2KeyCurves(t *testing.T) {
+	d, Q := generateTestKeyPair(t)
+	
+	// The GM/T 0003 test curve has no OID and cannot be encoded
+	testCurve := ec.GetNamedCurve(ec.SM2TestFp256)
+	testQ := testCurve.GetG().Multiply(d)
+	if _, err := MarshalSM2PrivateKey(d, testQ); err == nil {
+		t.Error("Expected error for a private key on a curve without OID")
+	}
+	if _, err := MarshalSM2PublicKey(testQ); err == nil {
+		t.Error("Expected error for a public key on a curve without OID")
+	}
+	
+	// Without the SEC 1 parameters the curve comes from the algorithm identifier
+	ecPrivKeyDER, err := asn1.Marshal(ECPrivateKey{
+		Version:    1,
+		PrivateKey: d.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal EC private key: %v", err)
+	}
+	der, err := MarshalPrivateKeyInfo(&PrivateKeyInfo{
+		Algorithm:  NewSM2AlgorithmIdentifierWithCurve(ec.GetNamedCurveOID(ec.SM2P256V1)),
+		PrivateKey: ecPrivKeyDER,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal PKCS#8: %v", err)
+	}
+	parsedD, parsedQ, err := ParseSM2PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Failed to parse key without SEC 1 parameters: %v", err)
+	}
+	if parsedD.Cmp(d) != 0 || !parsedQ.Equals(Q) {
+		t.Error("Key pair mismatch")
+	}
+	
+	// Unknown curves are rejected
+	unknown := asn1.ObjectIdentifier{1, 2, 3, 4}
+	der, _ = MarshalPrivateKeyInfo(&PrivateKeyInfo{
+		Algorithm:  NewSM2AlgorithmIdentifierWithCurve(unknown),
+		PrivateKey: ecPrivKeyDER,
+	})
+	if _, _, err := ParseSM2PrivateKey(der); !errors.Is(err, exceptions.ErrUnsupportedAlgorithm) {
+		t.Errorf("Unknown curve: got %v, want exceptions.ErrUnsupportedAlgorithm", err)
+	}
+	der, _ = MarshalSubjectPublicKeyInfo(&SubjectPublicKeyInfo{
+		Algorithm: NewSM2PublicKeyAlgorithmIdentifierWithCurve(unknown),
+		SubjectPublicKey: asn1.BitString{
+			Bytes:     Q.GetEncoded(false),
+			BitLength: 65 * 8,
+		},
+	})
+	if _, err := ParseSM2PublicKey(der); !errors.Is(err, exceptions.ErrUnsupportedAlgorithm) {
+		t.Errorf("Unknown curve: got %v, want exceptions.ErrUnsupportedAlgorithm", err)
+	}
+}
+
 // Helper functions
 func min(a, b int) int {
 	if a < b {
@@ -238,32 +347,10 @@ func min(a, b int) int {
 }
 
 func generateTestKeyPair(t *testing.T) (*big.Int, *ec.Point) {
-	// Generate random private key
-	n := sm2.GetN()
-	
-	d, err := randFieldElement(rand.Reader, n)
+	keyPair, err := sm2.GenerateKey(rand.Reader)
 	if err != nil {
-		t.Fatalf("Failed to generate private key: %v", err)
+		t.Fatalf("Failed to generate key pair: %v", err)
 	}
 	
-	// Compute public key Q = [d]G
-	G := sm2.GetG()
-	Q := G.Multiply(d)
-	
-	return d, Q
-}
-
-// randFieldElement returns a random element of the order of the given curve
-func randFieldElement(random io.Reader, n *big.Int) (k *big.Int, err error) {
-	b := make([]byte, (n.BitLen()+7)/8)
-	for {
-		_, err = io.ReadFull(random, b)
-		if err != nil {
-			return
-		}
-		k = new(big.Int).SetBytes(b)
-		if k.Sign() > 0 && k.Cmp(n) < 0 {
-			return
-		}
-	}
+	return keyPair.PrivateKey, keyPair.PublicKey
 }
@@ -1,12 +1,12 @@
 package pkcs8
 
 import (
+	"crypto/x509/pkix"
 	"encoding/asn1"
-	"fmt"
 	"math/big"
 	
-	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // ECPrivateKey represents an EC private key in SEC 1 format.
@@ -25,50 +25,33 @@ type ECPrivateKey struct {
 }
 
 // MarshalSM2PrivateKey converts an SM2 private key to PKCS#8 DER format.
+// The curve is taken from Q and must be registered in the ec curve registry
+// with an OID.
 func MarshalSM2PrivateKey(d *big.Int, Q *ec.Point) ([]byte, error) {
+	curve, curveOID, err := namedCurveOf(Q)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Validate inputs
-	if !sm2.ValidatePrivateKey(d) {
-		return nil, fmt.Errorf("invalid SM2 private key")
+	if d == nil || d.Sign() <= 0 || d.Cmp(curve.GetOrder()) >= 0 {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid SM2 private key")
 	}
-	if !sm2.ValidatePublicKey(Q) {
-		return nil, fmt.Errorf("invalid SM2 public key")
+	if !validatePublicKey(curve, Q) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid SM2 public key")
 	}
 	
-	// Get the private key D value as bytes
-	dBytes := d.Bytes()
-	// Pad to 32 bytes if needed
-	if len(dBytes) < 32 {
-		padded := make([]byte, 32)
-		copy(padded[32-len(dBytes):], dBytes)
-		dBytes = padded
-	}
+	// Get the private key D value as bytes, padded to the order length
+	dBytes := d.FillBytes(make([]byte, (curve.GetOrder().BitLen()+7)/8))
 	
 	// Encode public key point as uncompressed: 0x04 || X || Y
-	xBytes := Q.GetX().ToBigInt().Bytes()
-	yBytes := Q.GetY().ToBigInt().Bytes()
-	
-	// Pad X and Y to 32 bytes
-	if len(xBytes) < 32 {
-		padded := make([]byte, 32)
-		copy(padded[32-len(xBytes):], xBytes)
-		xBytes = padded
-	}
-	if len(yBytes) < 32 {
-		padded := make([]byte, 32)
-		copy(padded[32-len(yBytes):], yBytes)
-		yBytes = padded
-	}
-	
-	pubKeyBytes := make([]byte, 65)
-	pubKeyBytes[0] = 0x04 // Uncompressed
-	copy(pubKeyBytes[1:33], xBytes)
-	copy(pubKeyBytes[33:65], yBytes)
+	pubKeyBytes := Q.GetEncoded(false)
 	
 	// Create ECPrivateKey structure
 	ecPrivKey := ECPrivateKey{
 		Version:       1,
 		PrivateKey:    dBytes,
-		NamedCurveOID: OidSM2Curve,
+		NamedCurveOID: curveOID,
 		PublicKey: asn1.BitString{
 			Bytes:     pubKeyBytes,
 			BitLength: len(pubKeyBytes) * 8,
@@ -78,13 +61,13 @@ func MarshalSM2PrivateKey(d *big.Int, Q *ec.Point) ([]byte, error) {
 	// Marshal the EC private key
 	ecPrivKeyDER, err := asn1.Marshal(ecPrivKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal EC private key: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to marshal EC private key: %w", err)
 	}
 	
 	// Create PKCS#8 PrivateKeyInfo
 	pki := PrivateKeyInfo{
 		Version:    0,
-		Algorithm:  NewSM2AlgorithmIdentifier(),
+		Algorithm:  NewSM2AlgorithmIdentifierWithCurve(curveOID),
 		PrivateKey: ecPrivKeyDER,
 	}
 	
@@ -102,81 +85,78 @@ func ParseSM2PrivateKey(der []byte) (*big.Int, *ec.Point, error) {
 	
 	// Verify it's an SM2 key
 	if !pki.Algorithm.Algorithm.Equal(OidSM2) && !pki.Algorithm.Algorithm.Equal(OidSM2Encryption) {
-		return nil, nil, fmt.Errorf("not an SM2 private key (OID: %v)", pki.Algorithm.Algorithm)
+		return nil, nil, exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "not an SM2 private key (OID: %v)", pki.Algorithm.Algorithm)
 	}
 	
 	// Parse the EC private key
 	var ecPrivKey ECPrivateKey
 	_, err = asn1.Unmarshal(pki.PrivateKey, &ecPrivKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to unmarshal EC private key: %w", err)
+		return nil, nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to unmarshal EC private key: %w", err)
 	}
 	
 	// Extract private key d
 	d := new(big.Int).SetBytes(ecPrivKey.PrivateKey)
 	
-	// Get SM2 curve
-	curve := sm2.GetCurve()
+	// Get the curve from the key, falling back to the algorithm parameters
+	curve, err := parseCurve(ecPrivKey.NamedCurveOID, pki.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
 	var Q *ec.Point
 	
 	// Extract public key if present
 	if ecPrivKey.PublicKey.BitLength > 0 {
-		pubBytes := ecPrivKey.PublicKey.Bytes
-		if len(pubBytes) != 65 || pubBytes[0] != 0x04 {
-			return nil, nil, fmt.Errorf("invalid public key format")
+		Q, err = decodePublicKey(curve, ecPrivKey.PublicKey.Bytes)
+		if err != nil {
+			return nil, nil, err
 		}
-		
-		x := new(big.Int).SetBytes(pubBytes[1:33])
-		y := new(big.Int).SetBytes(pubBytes[33:65])
-		Q = curve.CreatePoint(x, y)
 	} else {
 		// Compute public key from private key
-		G := sm2.GetG()
-		Q = G.Multiply(d)
+		Q = curve.GetG().MultiplySecret(d)
 	}
 	
 	// Validate the key pair
-	if !sm2.ValidatePrivateKey(d) {
-		return nil, nil, fmt.Errorf("invalid private key")
+	if d.Sign() <= 0 || d.Cmp(curve.GetOrder()) >= 0 {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidKey, "invalid private key")
 	}
-	if !sm2.ValidatePublicKey(Q) {
-		return nil, nil, fmt.Errorf("invalid public key")
+	if !validatePublicKey(curve, Q) {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidKey, "invalid public key")
 	}
 	
 	return d, Q, nil
 }
 
 // MarshalSM2PublicKey converts an SM2 public key to SubjectPublicKeyInfo DER format.
+// The curve is taken from Q and must be registered in the ec curve registry
+// with an OID. The point is written uncompressed.
 func MarshalSM2PublicKey(Q *ec.Point) ([]byte, error) {
-	// Validate public key
-	if !sm2.ValidatePublicKey(Q) {
-		return nil, fmt.Errorf("invalid SM2 public key")
+	return marshalSM2PublicKey(Q, false)
+}
+
+// MarshalSM2PublicKeyCompressed is like MarshalSM2PublicKey but writes the
+// point in compressed form (0x02/0x03 || X).
+func MarshalSM2PublicKeyCompressed(Q *ec.Point) ([]byte, error) {
+	return marshalSM2PublicKey(Q, true)
+}
+
+func marshalSM2PublicKey(Q *ec.Point, compressed bool) ([]byte, error) {
+	curve, curveOID, err := namedCurveOf(Q)
+	if err != nil {
+		return nil, err
 	}
 	
-	// Encode public key point as uncompressed: 0x04 || X || Y
-	xBytes := Q.GetX().ToBigInt().Bytes()
-	yBytes := Q.GetY().ToBigInt().Bytes()
-	
-	// Pad X and Y to 32 bytes
-	if len(xBytes) < 32 {
-		padded := make([]byte, 32)
-		copy(padded[32-len(xBytes):], xBytes)
-		xBytes = padded
-	}
-	if len(yBytes) < 32 {
-		padded := make([]byte, 32)
-		copy(padded[32-len(yBytes):], yBytes)
-		yBytes = padded
+	// Validate public key
+	if !validatePublicKey(curve, Q) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid SM2 public key")
 	}
 	
-	pubKeyBytes := make([]byte, 65)
-	pubKeyBytes[0] = 0x04 // Uncompressed
-	copy(pubKeyBytes[1:33], xBytes)
-	copy(pubKeyBytes[33:65], yBytes)
+	// Encode public key point: 0x04 || X || Y or 0x02/0x03 || X
+	pubKeyBytes := Q.GetEncoded(compressed)
 	
 	// Create SubjectPublicKeyInfo
 	spki := SubjectPublicKeyInfo{
-		Algorithm: NewSM2PublicKeyAlgorithmIdentifier(),
+		Algorithm: NewSM2PublicKeyAlgorithmIdentifierWithCurve(curveOID),
 		SubjectPublicKey: asn1.BitString{
 			Bytes:     pubKeyBytes,
 			BitLength: len(pubKeyBytes) * 8,
@@ -198,25 +178,74 @@ func ParseSM2PublicKey(der []byte) (*ec.Point, error) {
 	// Verify it's an SM2 key
 	if !spki.Algorithm.Algorithm.Equal(OidSM2) && 
 	   !spki.Algorithm.Algorithm.Equal(OidSM2Encryption) {
-		return nil, fmt.Errorf("not an SM2 public key (OID: %v)", spki.Algorithm.Algorithm)
+		return nil, exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "not an SM2 public key (OID: %v)", spki.Algorithm.Algorithm)
 	}
 	
-	// Unmarshal the public key point
-	pubBytes := spki.SubjectPublicKey.Bytes
-	if len(pubBytes) != 65 || pubBytes[0] != 0x04 {
-		return nil, fmt.Errorf("invalid public key format")
+	// Get the curve from the algorithm parameters
+	curve, err := parseCurve(nil, spki.Algorithm)
+	if err != nil {
+		return nil, err
 	}
 	
-	x := new(big.Int).SetBytes(pubBytes[1:33])
-	y := new(big.Int).SetBytes(pubBytes[33:65])
-	
-	curve := sm2.GetCurve()
-	Q := curve.CreatePoint(x, y)
+	// Unmarshal the public key point
+	Q, err := decodePublicKey(curve, spki.SubjectPublicKey.Bytes)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Validate public key
-	if !sm2.ValidatePublicKey(Q) {
-		return nil, fmt.Errorf("invalid public key point")
+	if !validatePublicKey(curve, Q) {
+		return nil, exceptions.New(exceptions.ErrInvalidKey, "invalid public key point")
 	}
 	
 	return Q, nil
 }
+
+// namedCurveOf returns the curve of Q and its OID from the ec curve registry.
+func namedCurveOf(Q *ec.Point) (*ec.Curve, asn1.ObjectIdentifier, error) {
+	if Q == nil || Q.IsInfinity() {
+		return nil, nil, exceptions.New(exceptions.ErrInvalidKey, "invalid SM2 public key")
+	}
+	curve := Q.GetCurve()
+	curveOID := ec.GetCurveOID(curve)
+	if curveOID == nil {
+		if name := ec.GetCurveName(curve); name != "" {
+			return nil, nil, exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "curve %s has no OID", name)
+		}
+		return nil, nil, exceptions.New(exceptions.ErrUnsupportedAlgorithm, "curve is not registered")
+	}
+	return curve, curveOID, nil
+}
+
+// parseCurve looks up the curve named by curveOID or, if it is empty, by the
+// algorithm parameters. Keys without either are on sm2p256v1.
+func parseCurve(curveOID asn1.ObjectIdentifier, algorithm pkix.AlgorithmIdentifier) (*ec.Curve, error) {
+	if len(curveOID) == 0 && algorithm.Parameters.Tag == asn1.TagOID && len(algorithm.Parameters.FullBytes) > 0 {
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &curveOID); err != nil {
+			return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "invalid curve parameters: %w", err)
+		}
+	}
+	if len(curveOID) == 0 {
+		return ec.GetNamedCurve(ec.SM2P256V1), nil
+	}
+	curve := ec.GetNamedCurveByOID(curveOID)
+	if curve == nil {
+		return nil, exceptions.Newf(exceptions.ErrUnsupportedAlgorithm, "unsupported curve (OID: %v)", curveOID)
+	}
+	return curve, nil
+}
+
+// decodePublicKey decodes a compressed, uncompressed or hybrid point on
+// curve, checking that it is a point of order n.
+func decodePublicKey(curve *ec.Curve, pubBytes []byte) (*ec.Point, error) {
+	Q, err := curve.DecodePoint(pubBytes)
+	if err != nil {
+		return nil, exceptions.Newf(exceptions.ErrInvalidKey, "invalid public key: %w", err)
+	}
+	return Q, nil
+}
+
+// validatePublicKey checks that Q is a point of order n on curve.
+func validatePublicKey(curve *ec.Curve, Q *ec.Point) bool {
+	return curve.CheckPoint(Q) == nil
+}
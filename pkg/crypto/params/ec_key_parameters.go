@@ -2,6 +2,8 @@ package params
 
 import (
 	"math/big"
+
+	"github.com/lihongjie0209/sm-go-bc/util"
 )
 
 // AsymmetricKeyParameter is the base interface for asymmetric keys
@@ -68,3 +70,8 @@ func NewECPrivateKeyParameters(d *big.Int, parameters *ECDomainParameters) *ECPr
 func (k *ECPrivateKeyParameters) GetD() *big.Int {
 	return k.d
 }
+
+// Destroy overwrites the private key value with zeros
+func (k *ECPrivateKeyParameters) Destroy() {
+	util.ClearBigInt(k.d)
+}
@@ -1,14 +1,48 @@
 package exceptions
 
-// CryptoException is the base exception class for cryptographic errors
+import (
+	"errors"
+	"fmt"
+)
+
+// CryptoException is the base exception class for cryptographic errors.
+// It has the message of the error and matches its kind and, if any, the
+// error it wraps with errors.Is.
 type CryptoException struct {
-	message string
+	kind error
+	err  error
 }
 
+// NewCryptoException creates an error with message and no kind.
 func NewCryptoException(message string) *CryptoException {
-	return &CryptoException{message: message}
+	return &CryptoException{err: errors.New(message)}
+}
+
+// New creates an error of kind with message.
+func New(kind error, message string) *CryptoException {
+	return &CryptoException{kind: kind, err: errors.New(message)}
+}
+
+// Newf creates an error of kind with a message formatted as by fmt.Errorf.
+// An error operand of a %w verb is wrapped, so that errors.Is and errors.As
+// also match it.
+func Newf(kind error, format string, args ...interface{}) *CryptoException {
+	return &CryptoException{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// Kind returns the kind of the error, or nil if it has none.
+func (e *CryptoException) Kind() error {
+	return e.kind
 }
 
 func (e *CryptoException) Error() string {
-	return e.message
+	return e.err.Error()
+}
+
+// Unwrap returns the kind of the error and the error it wraps.
+func (e *CryptoException) Unwrap() []error {
+	if e.kind == nil {
+		return []error{e.err}
+	}
+	return []error{e.kind, e.err}
 }
@@ -0,0 +1,72 @@
+package exceptions
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestKindHierarchy(t *testing.T) {
+	tests := []struct {
+		kind, parent error
+	}{
+		{ErrOutputLength, ErrDataLength},
+		{ErrInvalidPadding, ErrInvalidCipherText},
+		{ErrAuthenticationFailed, ErrInvalidCipherText},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.kind, tt.parent) {
+			t.Errorf("%v is not %v", tt.kind, tt.parent)
+		}
+		if errors.Is(tt.parent, tt.kind) {
+			t.Errorf("%v is %v", tt.parent, tt.kind)
+		}
+	}
+	if errors.Is(ErrInvalidPadding, ErrAuthenticationFailed) {
+		t.Error("ErrInvalidPadding is ErrAuthenticationFailed")
+	}
+}
+
+func TestCryptoException(t *testing.T) {
+	err := New(ErrOutputLength, "output buffer too short")
+	if err.Error() != "output buffer too short" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if err.Kind() != ErrOutputLength {
+		t.Errorf("Kind() = %v", err.Kind())
+	}
+	if !errors.Is(err, ErrOutputLength) || !errors.Is(err, ErrDataLength) {
+		t.Error("error does not match its kind and parent kind")
+	}
+	if errors.Is(err, ErrInvalidKey) {
+		t.Error("error matches an unrelated kind")
+	}
+
+	var ce *CryptoException
+	if !errors.As(error(err), &ce) || ce != err {
+		t.Error("errors.As did not find the CryptoException")
+	}
+}
+
+func TestCryptoExceptionWrap(t *testing.T) {
+	err := Newf(ErrInvalidEncoding, "failed to parse key: %w", io.ErrUnexpectedEOF)
+	if err.Error() != "failed to parse key: unexpected EOF" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if !errors.Is(err, ErrInvalidEncoding) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("error does not match its kind and the wrapped error")
+	}
+
+	// A wrapped CryptoException keeps its kind
+	outer := Newf(ErrInvalidKey, "invalid public key: %w", New(ErrUnsupportedAlgorithm, "unsupported curve"))
+	if !errors.Is(outer, ErrInvalidKey) || !errors.Is(outer, ErrUnsupportedAlgorithm) {
+		t.Error("error does not match both kinds")
+	}
+}
+
+func TestNewCryptoException(t *testing.T) {
+	err := NewCryptoException("failure")
+	if err.Error() != "failure" || err.Kind() != nil {
+		t.Errorf("NewCryptoException = %q, kind %v", err.Error(), err.Kind())
+	}
+}
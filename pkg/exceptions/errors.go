@@ -0,0 +1,80 @@
+// Package exceptions defines the errors reported by this module.
+//
+// Errors are returned as *CryptoException values that carry one of the
+// sentinel kinds below, so callers can branch with errors.Is on the kind
+// and errors.As on *CryptoException instead of matching strings. Kinds
+// follow the Bouncy Castle exception hierarchy: ErrOutputLength is an
+// ErrDataLength, and ErrInvalidPadding and ErrAuthenticationFailed are
+// ErrInvalidCipherText.
+package exceptions
+
+// Error kinds. Use errors.Is to test an error for a kind.
+var (
+	// ErrInvalidKey reports a key that is missing, of the wrong type or
+	// length, or otherwise unusable.
+	// Reference: java.security.InvalidKeyException
+	ErrInvalidKey error = &kind{text: "invalid key"}
+
+	// ErrInvalidParameter reports invalid parameters other than the key,
+	// such as an IV, nonce, MAC size, user ID or parameter type.
+	// Reference: java.lang.IllegalArgumentException
+	ErrInvalidParameter error = &kind{text: "invalid parameter"}
+
+	// ErrInvalidState reports an operation on an object that is not
+	// initialized for it, or a protocol step called out of order.
+	// Reference: java.lang.IllegalStateException
+	ErrInvalidState error = &kind{text: "invalid state"}
+
+	// ErrDataLength reports input that is too short or too long.
+	// Reference: org.bouncycastle.crypto.DataLengthException
+	ErrDataLength error = &kind{text: "invalid data length"}
+
+	// ErrOutputLength reports an output buffer that is too short.
+	// Reference: org.bouncycastle.crypto.OutputLengthException
+	ErrOutputLength error = &kind{text: "output buffer too short", parent: ErrDataLength}
+
+	// ErrInvalidCipherText reports a ciphertext that cannot be decrypted.
+	// Reference: org.bouncycastle.crypto.InvalidCipherTextException
+	ErrInvalidCipherText error = &kind{text: "invalid ciphertext"}
+
+	// ErrInvalidPadding reports a decrypted block with invalid padding.
+	// The error does not tell which padding check failed.
+	ErrInvalidPadding error = &kind{text: "invalid padding", parent: ErrInvalidCipherText}
+
+	// ErrAuthenticationFailed reports a MAC, tag or confirmation value that
+	// does not match. The error does not tell which check failed.
+	ErrAuthenticationFailed error = &kind{text: "authentication failed", parent: ErrInvalidCipherText}
+
+	// ErrInvalidSignature reports a malformed signature encoding, or a
+	// signature that does not verify where that is reported as an error,
+	// as when checking the signature of a certificate request.
+	ErrInvalidSignature error = &kind{text: "invalid signature"}
+
+	// ErrInvalidEncoding reports malformed encoded data, such as DER, PEM
+	// or a point encoding.
+	ErrInvalidEncoding error = &kind{text: "invalid encoding"}
+
+	// ErrInvalidMessage reports a protocol message, such as one of a key
+	// exchange or of multi-party signing, that is malformed or fails
+	// verification.
+	ErrInvalidMessage error = &kind{text: "invalid protocol message"}
+
+	// ErrUnsupportedAlgorithm reports an algorithm, curve or operation that
+	// is not supported.
+	ErrUnsupportedAlgorithm error = &kind{text: "unsupported algorithm"}
+)
+
+// kind is an error kind, optionally a special case of a parent kind.
+type kind struct {
+	text   string
+	parent error
+}
+
+func (k *kind) Error() string {
+	return k.text
+}
+
+// Unwrap returns the parent kind, so that errors.Is matches it too.
+func (k *kind) Unwrap() error {
+	return k.parent
+}
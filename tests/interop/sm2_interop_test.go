@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"testing"
 	
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
 )
@@ -62,12 +63,12 @@ console.log(JSON.stringify({ signature: signatureHex }));
 		publicKey := curve.CreatePoint(publicKeyX, publicKeyY)
 		
 		signer := signers.NewSM2Signer()
-		err = signer.Init(false, publicKey, nil)
+		err = signer.Init(false, params.NewECPublicKeyParameters(publicKey, sm2.GetECDomainParameters()))
 		if err != nil {
 			t.Fatalf("Failed to initialize signer: %v", err)
 		}
 		
-		signer.Update(messageBytes)
+		signer.BlockUpdate(messageBytes, 0, len(messageBytes))
 		verified, err := signer.VerifySignature(signatureBytes)
 		if err != nil {
 			t.Fatalf("Failed to verify signature: %v", err)
@@ -82,12 +83,12 @@ console.log(JSON.stringify({ signature: signatureHex }));
 	t.Run("GoSign_JSVerify", func(t *testing.T) {
 		// Sign in Go
 		signer := signers.NewSM2Signer()
-		err := signer.Init(true, nil, privateKey)
+		err := signer.Init(true, params.NewECPrivateKeyParameters(privateKey, sm2.GetECDomainParameters()))
 		if err != nil {
 			t.Fatalf("Failed to initialize signer: %v", err)
 		}
 		
-		signer.Update(messageBytes)
+		signer.BlockUpdate(messageBytes, 0, len(messageBytes))
 		goSignature, err := signer.GenerateSignature()
 		if err != nil {
 			t.Fatalf("Failed to generate signature: %v", err)
@@ -130,3 +130,8 @@ func FillUint32(a []uint32, val uint32) {
 		a[i] = val
 	}
 }
+
+// ClearUint32 clears the uint32 slice (fills with zeros)
+func ClearUint32(data []uint32) {
+	FillUint32(data, 0)
+}
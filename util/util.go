@@ -2,6 +2,7 @@ package util
 
 import (
 	"crypto/subtle"
+	"math/big"
 )
 
 // PadLeft pads a byte slice with zeros on the left to reach the target length
@@ -27,3 +28,16 @@ func Concat(slices ...[]byte) []byte {
 func ConstantTimeCompare(a, b []byte) bool {
 	return subtle.ConstantTimeCompare(a, b) == 1
 }
+
+// ClearBigInt overwrites the words of x and sets it to zero. Copies made
+// by earlier arithmetic on x are not reached.
+func ClearBigInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetInt64(0)
+}
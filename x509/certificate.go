@@ -9,6 +9,7 @@ import (
 	
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
 	"github.com/lihongjie0209/sm-go-bc/pkcs8"
+	"github.com/lihongjie0209/sm-go-bc/pkg/exceptions"
 )
 
 // Certificate represents an X.509 certificate.
@@ -89,10 +90,10 @@ func ParseCertificate(der []byte) (*Certificate, error) {
 	
 	rest, err := asn1.Unmarshal(der, &rawCert)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse certificate: %w", err)
 	}
 	if len(rest) > 0 {
-		return nil, fmt.Errorf("trailing data after certificate")
+		return nil, exceptions.New(exceptions.ErrInvalidEncoding, "trailing data after certificate")
 	}
 	
 	cert.RawTBSCertificate = rawCert.TBSCertificate.FullBytes
@@ -102,7 +103,7 @@ func ParseCertificate(der []byte) (*Certificate, error) {
 	// Parse TBS Certificate
 	err = parseTBSCertificate(cert)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse TBS certificate: %w", err)
+		return nil, exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse TBS certificate: %w", err)
 	}
 	
 	return cert, nil
@@ -130,7 +131,7 @@ func parseTBSCertificate(cert *Certificate) error {
 		return err
 	}
 	if len(rest) > 0 {
-		return fmt.Errorf("trailing data in TBS certificate")
+		return exceptions.New(exceptions.ErrInvalidEncoding, "trailing data in TBS certificate")
 	}
 	
 	cert.Version = tbs.Version
@@ -144,14 +145,14 @@ func parseTBSCertificate(cert *Certificate) error {
 	var issuerRDN pkix.RDNSequence
 	_, err = asn1.Unmarshal(tbs.Issuer.FullBytes, &issuerRDN)
 	if err != nil {
-		return fmt.Errorf("failed to parse issuer: %w", err)
+		return exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse issuer: %w", err)
 	}
 	cert.Issuer.FillFromRDNSequence(&issuerRDN)
 	
 	var subjectRDN pkix.RDNSequence
 	_, err = asn1.Unmarshal(tbs.Subject.FullBytes, &subjectRDN)
 	if err != nil {
-		return fmt.Errorf("failed to parse subject: %w", err)
+		return exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse subject: %w", err)
 	}
 	cert.Subject.FillFromRDNSequence(&subjectRDN)
 	
@@ -180,7 +181,7 @@ func parseExtensions(cert *Certificate, extensions []pkix.Extension) error {
 			var usageBits asn1.BitString
 			_, err := asn1.Unmarshal(ext.Value, &usageBits)
 			if err != nil {
-				return fmt.Errorf("failed to parse key usage: %w", err)
+				return exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse key usage: %w", err)
 			}
 			if len(usageBits.Bytes) > 0 {
 				cert.KeyUsage = KeyUsage(usageBits.Bytes[0])
@@ -196,7 +197,7 @@ func parseExtensions(cert *Certificate, extensions []pkix.Extension) error {
 			}
 			_, err := asn1.Unmarshal(ext.Value, &constraints)
 			if err != nil {
-				return fmt.Errorf("failed to parse basic constraints: %w", err)
+				return exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse basic constraints: %w", err)
 			}
 			cert.IsCA = constraints.IsCA
 			cert.MaxPathLen = constraints.MaxPathLen
@@ -207,7 +208,7 @@ func parseExtensions(cert *Certificate, extensions []pkix.Extension) error {
 		case ext.Id.Equal(oidExtensionSubjectKeyId):
 			_, err := asn1.Unmarshal(ext.Value, &cert.SubjectKeyId)
 			if err != nil {
-				return fmt.Errorf("failed to parse subject key ID: %w", err)
+				return exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse subject key ID: %w", err)
 			}
 			
 		case ext.Id.Equal(oidExtensionAuthorityKeyId):
@@ -216,7 +217,7 @@ func parseExtensions(cert *Certificate, extensions []pkix.Extension) error {
 			}
 			_, err := asn1.Unmarshal(ext.Value, &authKeyId)
 			if err != nil {
-				return fmt.Errorf("failed to parse authority key ID: %w", err)
+				return exceptions.Newf(exceptions.ErrInvalidEncoding, "failed to parse authority key ID: %w", err)
 			}
 			cert.AuthorityKeyId = authKeyId.KeyIdentifier
 		}
@@ -232,7 +233,7 @@ func (c *Certificate) VerifySignature(publicKey *ec.Point) error {
 	// Note: Full implementation requires importing crypto/signers which would create
 	// a circular dependency (signers -> sm2 -> this package might import signers).
 	// Users should use the verifyCertificateSignature pattern shown in tests.
-	return fmt.Errorf("certificate signature verification not yet implemented - see test file for pattern")
+	return exceptions.New(exceptions.ErrUnsupportedAlgorithm, "certificate signature verification not yet implemented - see test file for pattern")
 }
 
 // OIDs for certificate extensions
@@ -5,12 +5,12 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"fmt"
-	"io"
 	"math/big"
 	"testing"
 	"time"
 	
 	"github.com/lihongjie0209/sm-go-bc/crypto/sm2"
+	"github.com/lihongjie0209/sm-go-bc/crypto/params"
 	"github.com/lihongjie0209/sm-go-bc/crypto/signers"
 	"github.com/lihongjie0209/sm-go-bc/math/ec"
 	"github.com/lihongjie0209/sm-go-bc/pkcs8"
@@ -433,11 +433,11 @@ func CreateCertificate(
 	
 	// Sign the TBS certificate
 	signer := signers.NewSM2Signer()
-	err = signer.Init(true, signerPub, privKey)
+	err = signer.Init(true, params.NewECPrivateKeyParameters(privKey, sm2.GetECDomainParameters()))
 	if err != nil {
 		return nil, err
 	}
-	signer.Update(tbsBytes)
+	signer.BlockUpdate(tbsBytes, 0, len(tbsBytes))
 	signature, err := signer.GenerateSignature()
 	if err != nil {
 		return nil, err
@@ -474,30 +474,12 @@ func CreateCertificate(
 
 // Helper functions
 func generateTestKeyPair(t *testing.T) (*big.Int, *ec.Point) {
-	n := sm2.GetN()
-	d, err := randFieldElement(rand.Reader, n)
+	keyPair, err := sm2.GenerateKey(rand.Reader)
 	if err != nil {
-		t.Fatalf("Failed to generate private key: %v", err)
+		t.Fatalf("Failed to generate key pair: %v", err)
 	}
 	
-	G := sm2.GetG()
-	Q := G.Multiply(d)
-	
-	return d, Q
-}
-
-func randFieldElement(random io.Reader, n *big.Int) (*big.Int, error) {
-	b := make([]byte, (n.BitLen()+7)/8)
-	for {
-		_, err := rand.Read(b)
-		if err != nil {
-			return nil, err
-		}
-		k := new(big.Int).SetBytes(b)
-		if k.Sign() > 0 && k.Cmp(n) < 0 {
-			return k, nil
-		}
-	}
+	return keyPair.PrivateKey, keyPair.PublicKey
 }
 
 func verifyCertificateSignature(cert *Certificate, publicKey *ec.Point) error {
@@ -512,11 +494,11 @@ func verifyCertificateSignature(cert *Certificate, publicKey *ec.Point) error {
 	
 	// Verify using SM2Signer
 	verifier := signers.NewSM2Signer()
-	err = verifier.Init(false, publicKey, nil)
+	err = verifier.Init(false, params.NewECPublicKeyParameters(publicKey, sm2.GetECDomainParameters()))
 	if err != nil {
 		return err
 	}
-	verifier.Update(cert.RawTBSCertificate)
+	verifier.BlockUpdate(cert.RawTBSCertificate, 0, len(cert.RawTBSCertificate))
 	
 	// Reconstruct signature bytes
 	sigBytes, err := asn1.Marshal(sig)